@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/Miklakapi/go-file-share/internal/bootstrap"
+)
+
+func main() {
+	os.Exit(bootstrap.Run(os.Args[1:], ""))
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"net/http"
+
+	apierrors "github.com/Miklakapi/go-file-share/internal/api/api-errors"
+	"github.com/Miklakapi/go-file-share/internal/api/dto"
+	"github.com/Miklakapi/go-file-share/internal/api/middleware"
+	fileShare "github.com/Miklakapi/go-file-share/internal/file-share/application"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type AdminController struct {
+	fileShareService *fileShare.Service
+	eventPublisher   ports.EventPublisher
+}
+
+func NewAdminController(fileShareService *fileShare.Service, eventPublisher ports.EventPublisher) *AdminController {
+	return &AdminController{
+		fileShareService: fileShareService,
+		eventPublisher:   eventPublisher,
+	}
+}
+
+func (aC *AdminController) Stats(ctx *gin.Context) {
+	stats, err := aC.fileShareService.AdminStats(ctx.Request.Context())
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": dto.NewStorageStats(stats),
+	})
+}
+
+func (aC *AdminController) Rooms(ctx *gin.Context) {
+	rooms, err := aC.fileShareService.AdminRooms(ctx.Request.Context())
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	result := make([]dto.AdminRoom, 0, len(rooms))
+	for _, r := range rooms {
+		result = append(result, dto.NewAdminRoom(r, false))
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": result,
+	})
+}
+
+func (aC *AdminController) Room(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+
+	room, err := aC.fileShareService.AdminRoom(ctx.Request.Context(), roomId)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": dto.NewAdminRoom(room, true),
+	})
+}
+
+func (aC *AdminController) Files(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+
+	room, err := aC.fileShareService.AdminRoom(ctx.Request.Context(), roomId)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	result := make([]dto.RoomFile, 0, len(room.Room.Files))
+	for _, f := range room.Room.ListFiles() {
+		result = append(result, dto.NewFileRoomFile(f))
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": result,
+	})
+}
+
+func (aC *AdminController) DeleteRoom(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+
+	if err := aC.fileShareService.AdminDeleteRoom(ctx.Request.Context(), roomId); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	if err := aC.eventPublisher.Publish(ports.Event{Name: ports.EventRoomDelete, Data: roomId}); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (aC *AdminController) DeleteFile(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+	fileId := middleware.MustFileIDParam(ctx)
+
+	if err := aC.fileShareService.AdminDeleteFile(ctx.Request.Context(), roomId, fileId); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (aC *AdminController) RevokeTokens(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+
+	revoked, err := aC.fileShareService.AdminRevokeTokens(ctx.Request.Context(), roomId)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": gin.H{"revoked": revoked},
+	})
+}
+
+func (aC *AdminController) Audit(ctx *gin.Context) {
+	requestData := dto.AuditQueryRequest{}
+	if err := ctx.ShouldBindQuery(&requestData); err != nil {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+	if requestData.Limit < 0 || requestData.Limit > maxAuditLimit {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+	if requestData.Limit == 0 {
+		requestData.Limit = defaultAuditLimit
+	}
+
+	query := ports.AuditQuery{
+		Since:  requestData.Since,
+		Until:  requestData.Until,
+		Action: requestData.Action,
+		Limit:  requestData.Limit,
+	}
+	if requestData.RoomID != "" {
+		roomId, err := uuid.Parse(requestData.RoomID)
+		if err != nil {
+			_ = ctx.Error(apierrors.ErrInvalidRequest)
+			return
+		}
+		query.RoomID = roomId
+	}
+
+	records, err := aC.fileShareService.AdminAudit(ctx.Request.Context(), query)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	result := make([]dto.AuditRecord, 0, len(records))
+	for _, r := range records {
+		result = append(result, dto.NewAuditRecord(r))
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": result,
+	})
+}
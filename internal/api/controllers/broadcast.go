@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	apierrors "github.com/Miklakapi/go-file-share/internal/api/api-errors"
+	"github.com/Miklakapi/go-file-share/internal/api/dto"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/gin-gonic/gin"
+)
+
+const maxBroadcastMinReceivers = 64
+
+type BroadcastController struct {
+	broadcast     ports.DirectBroadcast
+	defaultPolicy ports.BroadcastPolicy
+}
+
+func NewBroadcastController(broadcast ports.DirectBroadcast, defaultPolicy ports.BroadcastPolicy) *BroadcastController {
+	return &BroadcastController{
+		broadcast:     broadcast,
+		defaultPolicy: defaultPolicy,
+	}
+}
+
+func (bC *BroadcastController) Receive(ctx *gin.Context) {
+	code := strings.TrimSpace(ctx.Param("code"))
+	if code == "" {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	receiverID, transfer, err := bC.broadcast.Join(ctx.Request.Context(), code)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	defer bC.broadcast.Leave(code, receiverID)
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Content-Type", "application/octet-stream")
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, transfer.Filename))
+	ctx.Header("Trailer", headerReprDigest)
+
+	ctx.Writer.WriteHeaderNow()
+	ctx.Writer.Flush()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(flushWriter{ctx.Writer}, io.TeeReader(transfer.Reader, hasher)); err != nil {
+		abortStream(ctx)
+		return
+	}
+
+	setDigestHeaders(ctx, hex.EncodeToString(hasher.Sum(nil)))
+	ctx.Writer.Flush()
+
+	_ = bC.broadcast.Complete(code, receiverID)
+}
+
+func (bC *BroadcastController) Send(ctx *gin.Context) {
+	code := strings.TrimSpace(ctx.Param("code"))
+	if code == "" {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	requestData := dto.BroadcastRequest{}
+	if err := ctx.ShouldBindQuery(&requestData); err != nil {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+	if requestData.Receivers < 0 || requestData.Receivers > maxBroadcastMinReceivers {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	policy := bC.defaultPolicy
+	if requestData.Policy != "" {
+		policy = ports.BroadcastPolicy(requestData.Policy)
+	}
+
+	if ctx.Request.Body == nil || ctx.Request.Body == http.NoBody {
+		_ = ctx.Error(apierrors.ErrInvalidFile)
+		return
+	}
+	defer func() { _ = ctx.Request.Body.Close() }()
+
+	clearReadDeadline(ctx)
+
+	filename := ctx.GetHeader("X-Filename")
+	if filename == "" {
+		filename = ctx.Query("filename")
+	}
+	if decoded, err := url.QueryUnescape(filename); err == nil {
+		filename = decoded
+	}
+
+	opts := ports.BroadcastOptions{Policy: policy, MinReceivers: requestData.Receivers}
+	result, err := bC.broadcast.Broadcast(ctx.Request.Context(), code, safeDirectFilename(filename), opts, ctx.Request.Body)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	setDigestHeaders(ctx, result.Digest)
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": dto.NewBroadcastResult(result),
+	})
+}
+
+func (bC *BroadcastController) Start(ctx *gin.Context) {
+	code := strings.TrimSpace(ctx.Param("code"))
+	if code == "" {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	if err := bC.broadcast.Start(code); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (bC *BroadcastController) Receivers(ctx *gin.Context) {
+	code := strings.TrimSpace(ctx.Param("code"))
+	if code == "" {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	count, err := bC.broadcast.Receivers(code)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": count,
+	})
+}
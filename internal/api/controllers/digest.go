@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	headerReprDigest = "Repr-Digest"
+	headerDigest     = "Digest"
+)
+
+var expectedDigestHeaders = []string{headerReprDigest, "Content-Digest"}
+
+// expectedDigest returns the hex SHA-256 the uploader announced through the
+// RFC 9530 Repr-Digest or Content-Digest header, or "" when none was sent.
+func expectedDigest(ctx *gin.Context) (string, error) {
+	for _, name := range expectedDigestHeaders {
+		header := ctx.GetHeader(name)
+		if header == "" {
+			continue
+		}
+		return parseSHA256Digest(header)
+	}
+	return "", nil
+}
+
+func parseSHA256Digest(header string) (string, error) {
+	for _, item := range strings.Split(header, ",") {
+		algo, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(algo), "sha-256") {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		if !strings.HasPrefix(value, ":") || !strings.HasSuffix(value, ":") || len(value) < 2 {
+			return "", ports.ErrInvalidDigest
+		}
+
+		raw, err := base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+		if err != nil || len(raw) != sha256.Size {
+			return "", ports.ErrInvalidDigest
+		}
+		return hex.EncodeToString(raw), nil
+	}
+	return "", nil
+}
+
+func setDigestHeaders(ctx *gin.Context, hexDigest string) {
+	raw, err := hex.DecodeString(hexDigest)
+	if err != nil || len(raw) != sha256.Size {
+		return
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(raw)
+	ctx.Header(headerReprDigest, "sha-256=:"+encoded+":")
+	ctx.Header(headerDigest, "sha-256="+encoded)
+}
@@ -1,16 +1,24 @@
 package controllers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"path/filepath"
 	"strings"
+	"time"
 
 	apierrors "github.com/Miklakapi/go-file-share/internal/api/api-errors"
 	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
 	"github.com/gin-gonic/gin"
 )
 
+const defaultDirectFilename = "file"
+
 type DirectController struct {
 	directTransfer ports.DirectTransfer
 }
@@ -38,10 +46,25 @@ func (dC *DirectController) DownloadStream(ctx *gin.Context) {
 	ctx.Header("Cache-Control", "no-store")
 	ctx.Header("Content-Type", "application/octet-stream")
 	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, transfer.Filename))
+	ctx.Header("Trailer", headerReprDigest+", "+headerDigest)
+
+	ctx.Writer.WriteHeaderNow()
+	ctx.Writer.Flush()
 
-	_, _ = io.Copy(ctx.Writer, transfer.Reader)
+	hasher := sha256.New()
+	if _, err := io.Copy(flushWriter{ctx.Writer}, io.TeeReader(transfer.Reader, hasher)); err != nil {
+		abortStream(ctx)
+		return
+	}
+
+	setDigestHeaders(ctx, hex.EncodeToString(hasher.Sum(nil)))
+	ctx.Writer.Flush()
+
+	_ = dC.directTransfer.Complete(code)
 }
 
+// UploadStream reads a multipart body part by part, so the file part is piped
+// to the receiver as it arrives instead of being buffered by FormFile.
 func (dC *DirectController) UploadStream(ctx *gin.Context) {
 	code := strings.TrimSpace(ctx.Param("code"))
 	if code == "" {
@@ -49,23 +72,134 @@ func (dC *DirectController) UploadStream(ctx *gin.Context) {
 		return
 	}
 
-	fh, err := ctx.FormFile("file")
+	digest, err := expectedDigest(ctx)
 	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	clearReadDeadline(ctx)
+
+	mr, err := ctx.Request.MultipartReader()
+	if err != nil {
+		_ = ctx.Error(apierrors.ErrInvalidFile)
+		return
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			_ = ctx.Error(apierrors.ErrInvalidFile)
+			return
+		}
+		if err != nil {
+			_ = ctx.Error(apierrors.ErrInvalidFile)
+			return
+		}
+
+		if part.FormName() != "file" || part.FileName() == "" {
+			_ = part.Close()
+			continue
+		}
+
+		sent, err := dC.directTransfer.Send(ctx.Request.Context(), code, safeDirectFilename(part.FileName()), digest, part)
+		_ = part.Close()
+		if err != nil {
+			_ = ctx.Error(err)
+			return
+		}
+
+		setDigestHeaders(ctx, sent)
+		ctx.Status(http.StatusNoContent)
+		return
+	}
+}
+
+// UploadRaw streams the raw request body straight into the transfer pipe.
+// The filename is taken from the X-Filename header or the filename query param.
+func (dC *DirectController) UploadRaw(ctx *gin.Context) {
+	code := strings.TrimSpace(ctx.Param("code"))
+	if code == "" {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	if ctx.Request.Body == nil || ctx.Request.Body == http.NoBody {
 		_ = ctx.Error(apierrors.ErrInvalidFile)
 		return
 	}
+	defer func() { _ = ctx.Request.Body.Close() }()
 
-	src, err := fh.Open()
+	digest, err := expectedDigest(ctx)
 	if err != nil {
 		_ = ctx.Error(err)
 		return
 	}
-	defer func() { _ = src.Close() }()
 
-	if err := dC.directTransfer.Send(ctx.Request.Context(), code, fh.Filename, src); err != nil {
+	clearReadDeadline(ctx)
+
+	filename := ctx.GetHeader("X-Filename")
+	if filename == "" {
+		filename = ctx.Query("filename")
+	}
+	if decoded, err := url.QueryUnescape(filename); err == nil {
+		filename = decoded
+	}
+
+	sent, err := dC.directTransfer.Send(ctx.Request.Context(), code, safeDirectFilename(filename), digest, ctx.Request.Body)
+	if err != nil {
 		_ = ctx.Error(err)
 		return
 	}
 
+	setDigestHeaders(ctx, sent)
 	ctx.Status(http.StatusNoContent)
 }
+
+func safeDirectFilename(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return defaultDirectFilename
+	}
+
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r == '"' || r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+
+	if name == "" || name == "." || name == "/" {
+		return defaultDirectFilename
+	}
+	return name
+}
+
+// clearReadDeadline lifts the server-wide ReadTimeout for this request, since
+// a direct transfer body is read for as long as the receiver keeps up.
+func clearReadDeadline(ctx *gin.Context) {
+	_ = http.NewResponseController(ctx.Writer).SetReadDeadline(time.Time{})
+}
+
+// abortStream drops the connection so a receiver whose sender failed mid-way
+// sees a broken transfer instead of a cleanly terminated, truncated body.
+func abortStream(ctx *gin.Context) {
+	conn, _, err := http.NewResponseController(ctx.Writer).Hijack()
+	if err != nil {
+		return
+	}
+	_ = conn.Close()
+}
+
+type flushWriter struct {
+	w gin.ResponseWriter
+}
+
+func (fw flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if n > 0 {
+		fw.w.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/api/middleware"
+	directtransfer "github.com/Miklakapi/go-file-share/internal/file-share/adapters/direct-transfer"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/gin-gonic/gin"
+)
+
+// transferSize is above gin's default multipart memory, so a buffering
+// handler would spill the upload to a temp file.
+const transferSize = 48 << 20
+
+func TestDirectTransferStreams(t *testing.T) {
+	tests := []struct {
+		name    string
+		request func(url string, body io.Reader) *http.Request
+	}{
+		{"raw", rawUpload},
+		{"multipart", multipartUpload},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmp := t.TempDir()
+			t.Setenv("TMPDIR", tmp)
+			srv := newDirectServer(t)
+			code := fmt.Sprintf("stream%010d", i)
+
+			firstBytes := make(chan struct{})
+			src := newSlowReader(transferSize, firstBytes, tmp)
+
+			type result struct {
+				sum         string
+				size        int64
+				sentAtFirst int64
+				trailer     http.Header
+				err         error
+			}
+			got := make(chan result, 1)
+			go func() {
+				res, err := http.Get(srv.URL + "/api/v1/direct/" + code + "/download")
+				if err != nil {
+					got <- result{err: err}
+					return
+				}
+				defer res.Body.Close()
+
+				h := sha256.New()
+				first := make([]byte, 1)
+				if _, err := io.ReadFull(res.Body, first); err != nil {
+					got <- result{err: err}
+					return
+				}
+				sentAtFirst := src.sent.Load()
+				h.Write(first)
+				close(firstBytes)
+
+				n, err := io.Copy(h, res.Body)
+				got <- result{sum: hex.EncodeToString(h.Sum(nil)), size: n + 1, sentAtFirst: sentAtFirst, trailer: res.Trailer, err: err}
+			}()
+
+			res, err := http.DefaultClient.Do(tt.request(srv.URL+"/api/v1/direct/"+code, src))
+			if err != nil {
+				t.Fatalf("upload: %v", err)
+			}
+			_ = res.Body.Close()
+			if res.StatusCode != http.StatusNoContent {
+				t.Fatalf("upload status = %d, want %d", res.StatusCode, http.StatusNoContent)
+			}
+
+			r := <-got
+			if r.err != nil {
+				t.Fatalf("download: %v", r.err)
+			}
+			want := src.sum()
+			if r.size != transferSize || r.sum != want {
+				t.Fatalf("received %d bytes with digest %s, want %d bytes with %s", r.size, r.sum, int64(transferSize), want)
+			}
+			if r.sentAtFirst >= transferSize {
+				t.Errorf("receiver saw its first byte only after all %d bytes were sent", r.sentAtFirst)
+			}
+			wantRaw, _ := hex.DecodeString(want)
+			encoded := base64.StdEncoding.EncodeToString(wantRaw)
+			if got := r.trailer.Get(headerReprDigest); got != "sha-256=:"+encoded+":" {
+				t.Errorf("%s trailer = %q", headerReprDigest, got)
+			}
+			if got := r.trailer.Get(headerDigest); got != "sha-256="+encoded {
+				t.Errorf("%s trailer = %q", headerDigest, got)
+			}
+			if res.Header.Get(headerReprDigest) == "" {
+				t.Errorf("upload response has no %s", headerReprDigest)
+			}
+			if src.spilled.Load() {
+				t.Errorf("temp files appeared while the transfer was running")
+			}
+			assertEmptyDir(t, tmp)
+		})
+	}
+}
+
+func newDirectServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	dC := NewDirectController(waitingTransfer{directtransfer.New()})
+	engine := gin.New()
+	direct := engine.Group("/api/v1/direct/:code", middleware.ErrorMiddleware())
+	direct.PUT("", dC.UploadRaw)
+	direct.GET("/download", dC.DownloadStream)
+	direct.POST("/upload", dC.UploadStream)
+
+	srv := httptest.NewServer(engine)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func rawUpload(url string, body io.Reader) *http.Request {
+	req, _ := http.NewRequest(http.MethodPut, url, body)
+	req.Header.Set("X-Filename", "large.bin")
+	return req
+}
+
+func multipartUpload(url string, body io.Reader) *http.Request {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		part, err := mw.CreateFormFile("file", "large.bin")
+		if err == nil {
+			_, err = io.Copy(part, body)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	req, _ := http.NewRequest(http.MethodPost, url+"/upload", pr)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+// waitingTransfer lets the sender retry until the receiver has registered
+// its code, like the cluster relay does.
+type waitingTransfer struct {
+	ports.DirectTransfer
+}
+
+func (w waitingTransfer) Send(ctx context.Context, code, filename, expectedDigest string, src io.Reader) (string, error) {
+	for {
+		digest, err := w.DirectTransfer.Send(ctx, code, filename, expectedDigest, src)
+		if !errors.Is(err, ports.ErrTransferCodeNotFound) {
+			return digest, err
+		}
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// slowReader produces size bytes in small, delayed chunks. After the first
+// chunk it waits until the receiver has seen data, so the transfer can only
+// finish if bytes reach the receiver while the upload is still running.
+type slowReader struct {
+	size       int64
+	firstBytes <-chan struct{}
+	tmp        string
+
+	h       hash.Hash
+	checked bool
+	sent    atomic.Int64
+	spilled atomic.Bool
+}
+
+func newSlowReader(size int64, firstBytes <-chan struct{}, tmp string) *slowReader {
+	return &slowReader{size: size, firstBytes: firstBytes, tmp: tmp, h: sha256.New()}
+}
+
+func (s *slowReader) Read(p []byte) (int, error) {
+	sent := s.sent.Load()
+	if sent >= s.size {
+		return 0, io.EOF
+	}
+	if sent > 0 {
+		select {
+		case <-s.firstBytes:
+		case <-time.After(5 * time.Second):
+			return 0, errors.New("receiver got nothing while the upload was running")
+		}
+	}
+	if sent >= s.size/2 && !s.checked {
+		s.checked = true
+		if entries, err := os.ReadDir(s.tmp); err != nil || len(entries) > 0 {
+			s.spilled.Store(true)
+		}
+	}
+	time.Sleep(100 * time.Microsecond)
+
+	n := min(len(p), 64<<10, int(s.size-sent))
+	for i := range n {
+		p[i] = byte((sent + int64(i)) % 251)
+	}
+	s.h.Write(p[:n])
+	s.sent.Add(int64(n))
+	return n, nil
+}
+
+func (s *slowReader) sum() string {
+	return hex.EncodeToString(s.h.Sum(nil))
+}
+
+func assertEmptyDir(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		t.Errorf("unexpected temp file %s", e.Name())
+	}
+}
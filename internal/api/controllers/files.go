@@ -2,40 +2,70 @@ package controllers
 
 import (
 	"io"
+	"log/slog"
+	"mime"
 	"net/http"
+	"path"
 	"strconv"
+	"strings"
+	"time"
 
+	apierrors "github.com/Miklakapi/go-file-share/internal/api/api-errors"
 	"github.com/Miklakapi/go-file-share/internal/api/dto"
 	"github.com/Miklakapi/go-file-share/internal/api/middleware"
 	fileShare "github.com/Miklakapi/go-file-share/internal/file-share/application"
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type FilesController struct {
 	fileShareService *fileShare.Service
+	eventPublisher   ports.EventPublisher
 }
 
-func NewFilesController(fileShareService *fileShare.Service) *FilesController {
-	return &FilesController{fileShareService: fileShareService}
+func NewFilesController(fileShareService *fileShare.Service, eventPublisher ports.EventPublisher) *FilesController {
+	return &FilesController{
+		fileShareService: fileShareService,
+		eventPublisher:   eventPublisher,
+	}
 }
 
 func (fC *FilesController) Get(ctx *gin.Context) {
 	roomId := middleware.MustRoomIDParam(ctx)
 	token := middleware.MustToken(ctx)
 
-	files, err := fC.fileShareService.Files(ctx.Request.Context(), roomId, token)
+	var requestData dto.FileListRequest
+	if err := ctx.ShouldBindQuery(&requestData); err != nil {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	page, err := fC.fileShareService.Files(ctx.Request.Context(), roomId, token, ports.FileQuery{
+		Folder:      requestData.Prefix,
+		Name:        requestData.Name,
+		ContentType: requestData.Type,
+		MinSize:     requestData.MinSize,
+		MaxSize:     requestData.MaxSize,
+		Sort:        requestData.Sort,
+		Desc:        requestData.Order == "desc",
+		Cursor:      requestData.Cursor,
+		Limit:       requestData.Limit,
+	})
 	if err != nil {
 		_ = ctx.Error(err)
 		return
 	}
 
-	result := make([]dto.RoomFile, 0, len(files))
-	for _, f := range files {
+	result := make([]dto.RoomFile, 0, len(page.Files))
+	for _, f := range page.Files {
 		result = append(result, dto.NewFileRoomFile(f))
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"data": result,
+		"next": page.Next,
 	})
 }
 
@@ -67,8 +97,17 @@ func (fC *FilesController) Download(ctx *gin.Context) {
 	}
 	defer func() { _ = rc.Close() }()
 
-	ctx.Header("Content-Disposition", `attachment; filename="`+meta.Name+`"`)
-	ctx.Header("Content-Type", "application/octet-stream")
+	// The last download already removed the file from its room, so a failed
+	// announcement must not fail the download itself.
+	if meta.Exhausted() {
+		deleted := domain.ExpiredFile{RoomID: roomId, FileID: meta.ID, Paths: meta.Paths()}
+		if err := fC.eventPublisher.Publish(ports.Event{Name: ports.EventFileDelete, Data: deleted}); err != nil {
+			slog.WarnContext(ctx.Request.Context(), "cannot publish file delete event", slog.Any("error", err))
+		}
+	}
+
+	setContentHeaders(ctx, meta.Name, meta.ContentType)
+	setDigestHeaders(ctx, meta.SHA256)
 	if meta.Size > 0 {
 		ctx.Header("Content-Length", strconv.FormatInt(meta.Size, 10))
 	}
@@ -79,10 +118,180 @@ func (fC *FilesController) Download(ctx *gin.Context) {
 	}
 }
 
+func (fC *FilesController) Versions(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+	fileId := middleware.MustFileIDParam(ctx)
+	token := middleware.MustToken(ctx)
+
+	_, versions, err := fC.fileShareService.FileVersions(ctx.Request.Context(), roomId, fileId, token)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	result := make([]dto.FileVersion, 0, len(versions))
+	for _, v := range versions {
+		result = append(result, dto.NewFileVersion(v))
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": result,
+	})
+}
+
+func (fC *FilesController) DownloadVersion(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+	fileId := middleware.MustFileIDParam(ctx)
+	token := middleware.MustToken(ctx)
+
+	version, err := strconv.Atoi(ctx.Param("version"))
+	if err != nil || version < 1 {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	meta, v, rc, err := fC.fileShareService.DownloadFileVersion(ctx.Request.Context(), roomId, fileId, token, version)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	defer func() { _ = rc.Close() }()
+
+	setContentHeaders(ctx, meta.Name, v.ContentType)
+	setDigestHeaders(ctx, v.SHA256)
+	if v.Size > 0 {
+		ctx.Header("Content-Length", strconv.FormatInt(v.Size, 10))
+	}
+
+	_, copyErr := io.Copy(ctx.Writer, rc)
+	if copyErr != nil {
+		return
+	}
+}
+
+func (fC *FilesController) Preview(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+	fileId := middleware.MustFileIDParam(ctx)
+	token := middleware.MustToken(ctx)
+
+	file, preview, err := fC.fileShareService.Preview(ctx.Request.Context(), roomId, fileId, token)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": dto.NewFilePreview(file, preview),
+	})
+}
+
+func (fC *FilesController) Thumbnail(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+	fileId := middleware.MustFileIDParam(ctx)
+	token := middleware.MustToken(ctx)
+
+	requestData := dto.ThumbnailRequest{Size: fileShare.DefaultThumbnailSize}
+	if err := ctx.ShouldBindQuery(&requestData); err != nil || requestData.Size <= 0 {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	rc, err := fC.fileShareService.Thumbnail(ctx.Request.Context(), roomId, fileId, token, requestData.Size)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	defer func() { _ = rc.Close() }()
+
+	ctx.Header("Content-Type", "image/jpeg")
+	ctx.Header("Cache-Control", "private, max-age=3600")
+	ctx.Header("X-Content-Type-Options", "nosniff")
+
+	_, copyErr := io.Copy(ctx.Writer, rc)
+	if copyErr != nil {
+		return
+	}
+}
+
+func (fC *FilesController) Entries(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+	fileId := middleware.MustFileIDParam(ctx)
+	token := middleware.MustToken(ctx)
+
+	_, entries, err := fC.fileShareService.ArchiveEntries(ctx.Request.Context(), roomId, fileId, token)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	result := make([]dto.ArchiveEntry, 0, len(entries))
+	for _, e := range entries {
+		result = append(result, dto.NewArchiveEntry(e))
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": result,
+	})
+}
+
+func (fC *FilesController) Entry(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+	fileId := middleware.MustFileIDParam(ctx)
+	token := middleware.MustToken(ctx)
+
+	name := strings.TrimPrefix(ctx.Param("path"), "/")
+	if name == "" {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	entry, rc, err := fC.fileShareService.OpenArchiveEntry(ctx.Request.Context(), roomId, fileId, token, name)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	defer func() { _ = rc.Close() }()
+
+	setContentHeaders(ctx, path.Base(entry.Name), mime.TypeByExtension(path.Ext(entry.Name)))
+	ctx.Header("Content-Length", strconv.FormatInt(entry.Size, 10))
+
+	_, copyErr := io.Copy(ctx.Writer, rc)
+	if copyErr != nil {
+		return
+	}
+}
+
+func (fC *FilesController) Extract(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+	fileId := middleware.MustFileIDParam(ctx)
+	token := middleware.MustToken(ctx)
+
+	files, err := fC.fileShareService.ExtractArchive(ctx.Request.Context(), roomId, fileId, token)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	result := make([]dto.RoomFile, 0, len(files))
+	for _, f := range files {
+		result = append(result, dto.NewFileRoomFile(f))
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"data": result,
+	})
+}
+
 func (fC *FilesController) Upload(ctx *gin.Context) {
 	roomId := middleware.MustRoomIDParam(ctx)
 	token := middleware.MustToken(ctx)
 
+	digest, err := expectedDigest(ctx)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
 	fh, err := ctx.FormFile("file")
 	if err != nil {
 		_ = ctx.Error(err)
@@ -95,7 +304,95 @@ func (fC *FilesController) Upload(ctx *gin.Context) {
 	}
 	defer func() { _ = src.Close() }()
 
-	file, err := fC.fileShareService.UploadFile(ctx.Request.Context(), roomId, token, fh.Filename, src)
+	// ?sanitize=1 strips image metadata even when the room does not require it.
+	sanitize, _ := strconv.ParseBool(ctx.Query("sanitize"))
+	// The folder comes from a form field next to the file, so directory
+	// uploads can send one per file, or from ?folder=.
+	folder := ctx.PostForm("folder")
+	if folder == "" {
+		folder = ctx.Query("folder")
+	}
+	limits, err := uploadLimits(ctx)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	opts := fileShare.UploadOptions{
+		ExpectedDigest: digest,
+		Sanitize:       sanitize,
+		Folder:         folder,
+		Lifespan:       limits.Lifespan,
+		MaxDownloads:   limits.MaxDownloads,
+		VersionOf:      limits.VersionOf,
+	}
+
+	file, err := fC.fileShareService.UploadFile(ctx.Request.Context(), roomId, token, fh.Filename, opts, src)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"data": dto.NewFileRoomFile(file),
+	})
+}
+
+func (fC *FilesController) Update(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+	fileId := middleware.MustFileIDParam(ctx)
+	token := middleware.MustToken(ctx)
+
+	var requestData dto.UpdateFileRequest
+	if err := ctx.ShouldBind(&requestData); err != nil {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	file, err := fC.fileShareService.SetFileDescription(ctx.Request.Context(), roomId, fileId, token, *requestData.Description)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": dto.NewFileRoomFile(file),
+	})
+}
+
+// headerTargetToken carries the token of the room a file is copied or moved
+// to, next to the source room's token.
+const headerTargetToken = "X-Target-Token"
+
+func (fC *FilesController) Copy(ctx *gin.Context) {
+	fC.copy(ctx, false)
+}
+
+func (fC *FilesController) Move(ctx *gin.Context) {
+	fC.copy(ctx, true)
+}
+
+func (fC *FilesController) copy(ctx *gin.Context, move bool) {
+	roomId := middleware.MustRoomIDParam(ctx)
+	fileId := middleware.MustFileIDParam(ctx)
+	token := middleware.MustToken(ctx)
+
+	var requestData dto.CopyFileRequest
+	if err := ctx.ShouldBind(&requestData); err != nil {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+	targetId, err := uuid.Parse(requestData.RoomID)
+	if err != nil {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+	targetToken := strings.TrimSpace(ctx.GetHeader(headerTargetToken))
+	if t, ok := strings.CutPrefix(targetToken, "Bearer "); ok {
+		targetToken = t
+	}
+
+	opts := fileShare.CopyOptions{Folder: requestData.Folder, Move: move}
+	file, err := fC.fileShareService.CopyFile(ctx.Request.Context(), roomId, fileId, token, targetId, targetToken, opts)
 	if err != nil {
 		_ = ctx.Error(err)
 		return
@@ -118,3 +415,64 @@ func (fC *FilesController) Delete(ctx *gin.Context) {
 
 	ctx.Status(http.StatusNoContent)
 }
+
+type fileLimits struct {
+	Lifespan     time.Duration
+	MaxDownloads int
+	VersionOf    uuid.UUID
+}
+
+// uploadLimits reads the optional lifespan, in seconds, maxDownloads and the
+// fileId the upload is a new version of from the form or the query, like the
+// folder.
+func uploadLimits(ctx *gin.Context) (fileLimits, error) {
+	var limits fileLimits
+	field := func(name string) string {
+		if v := ctx.PostForm(name); v != "" {
+			return v
+		}
+		return ctx.Query(name)
+	}
+	if v := field("lifespan"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return limits, apierrors.ErrInvalidRequest
+		}
+		limits.Lifespan = time.Duration(secs) * time.Second
+	}
+	if v := field("maxDownloads"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return limits, apierrors.ErrInvalidRequest
+		}
+		limits.MaxDownloads = n
+	}
+	if v := field("fileId"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return limits, apierrors.ErrInvalidRequest
+		}
+		limits.VersionOf = id
+	}
+	return limits, nil
+}
+
+// setContentHeaders describes a file being sent for download. ?inline=1 lets
+// the browser display passive types such as images, but anything it could
+// execute is always downloaded.
+func setContentHeaders(ctx *gin.Context, filename, contentType string) {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	disposition := "attachment"
+	if inline, _ := strconv.ParseBool(ctx.Query("inline")); inline && !domain.IsActiveContentType(contentType) {
+		disposition = "inline"
+	}
+	if domain.IsActiveContentType(contentType) {
+		ctx.Header("Content-Security-Policy", "sandbox")
+	}
+
+	ctx.Header("Content-Disposition", disposition+`; filename="`+filename+`"`)
+	ctx.Header("Content-Type", contentType)
+	ctx.Header("X-Content-Type-Options", "nosniff")
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+
+	apierrors "github.com/Miklakapi/go-file-share/internal/api/api-errors"
+	"github.com/Miklakapi/go-file-share/internal/api/dto"
+	"github.com/Miklakapi/go-file-share/internal/api/middleware"
+	fileShare "github.com/Miklakapi/go-file-share/internal/file-share/application"
+	"github.com/gin-gonic/gin"
+)
+
+type FoldersController struct {
+	fileShareService *fileShare.Service
+}
+
+func NewFoldersController(fileShareService *fileShare.Service) *FoldersController {
+	return &FoldersController{fileShareService: fileShareService}
+}
+
+func (fC *FoldersController) Get(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+	token := middleware.MustToken(ctx)
+
+	var requestData dto.PrefixRequest
+	if err := ctx.ShouldBindQuery(&requestData); err != nil {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	folders, err := fC.fileShareService.Folders(ctx.Request.Context(), roomId, token, requestData.Prefix)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	result := make([]dto.Folder, 0, len(folders))
+	for _, f := range folders {
+		result = append(result, dto.NewFolder(f))
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": result,
+	})
+}
+
+func (fC *FoldersController) Create(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+	token := middleware.MustToken(ctx)
+
+	var requestData dto.FolderRequest
+	if err := ctx.ShouldBind(&requestData); err != nil {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	folder, err := fC.fileShareService.CreateFolder(ctx.Request.Context(), roomId, token, requestData.Path)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"data": dto.NewFolder(folder),
+	})
+}
+
+func (fC *FoldersController) Rename(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+	token := middleware.MustToken(ctx)
+
+	var requestData dto.RenameFolderRequest
+	if err := ctx.ShouldBind(&requestData); err != nil {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	folder, err := fC.fileShareService.RenameFolder(ctx.Request.Context(), roomId, token, requestData.Path, requestData.Name)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": dto.NewFolder(folder),
+	})
+}
+
+func (fC *FoldersController) Move(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+	token := middleware.MustToken(ctx)
+
+	var requestData dto.MoveFolderRequest
+	if err := ctx.ShouldBind(&requestData); err != nil {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	folder, err := fC.fileShareService.MoveFolder(ctx.Request.Context(), roomId, token, requestData.Path, requestData.Parent)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": dto.NewFolder(folder),
+	})
+}
+
+func (fC *FoldersController) Delete(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+	token := middleware.MustToken(ctx)
+
+	var requestData dto.FolderRequest
+	if err := ctx.ShouldBindQuery(&requestData); err != nil {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	if _, err := fC.fileShareService.DeleteFolder(ctx.Request.Context(), roomId, token, requestData.Path); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// Download sends ?path= as a ZIP archive, or the whole room without it.
+func (fC *FoldersController) Download(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+	token := middleware.MustToken(ctx)
+
+	name, rc, err := fC.fileShareService.DownloadFolder(ctx.Request.Context(), roomId, token, ctx.Query("path"))
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	defer func() { _ = rc.Close() }()
+
+	setContentHeaders(ctx, name, "application/zip")
+
+	_, copyErr := io.Copy(ctx.Writer, rc)
+	if copyErr != nil {
+		return
+	}
+}
@@ -1,16 +1,44 @@
 package controllers
 
 import (
+	"context"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
 	"github.com/gin-gonic/gin"
 )
 
+const healthCheckTimeout = 2 * time.Second
+
+const (
+	healthUp   = "up"
+	healthDown = "down"
+)
+
+type componentHealth struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
 type HealthController struct {
+	checkers []ports.HealthChecker
+	draining atomic.Bool
+}
+
+func NewHealthController(checkers ...ports.HealthChecker) *HealthController {
+	return &HealthController{
+		checkers: checkers,
+	}
 }
 
-func NewHealthController() *HealthController {
-	return &HealthController{}
+// Drain makes readiness fail from now on, so load balancers stop routing new
+// requests while the server shuts down.
+func (hC *HealthController) Drain() {
+	hC.draining.Store(true)
 }
 
 func (hC *HealthController) Ping(ctx *gin.Context) {
@@ -19,8 +47,61 @@ func (hC *HealthController) Ping(ctx *gin.Context) {
 	})
 }
 
-func (hC *HealthController) Health(ctx *gin.Context) {
+// Live reports that the process is up and serving requests.
+func (hC *HealthController) Live(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{
-		"status": "ok",
+		"status": healthUp,
 	})
 }
+
+// Ready probes every dependency concurrently and fails when any of them is
+// down or the server is shutting down.
+func (hC *HealthController) Ready(ctx *gin.Context) {
+	checkCtx, cancel := context.WithTimeout(ctx.Request.Context(), healthCheckTimeout)
+	defer cancel()
+
+	results := make([]componentHealth, len(hC.checkers))
+	var wg sync.WaitGroup
+	for i, checker := range hC.checkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = check(checkCtx, checker)
+		}()
+	}
+	wg.Wait()
+
+	status, code := healthUp, http.StatusOK
+	components := make(map[string]componentHealth, len(results))
+	for i, result := range results {
+		components[hC.checkers[i].Name()] = result
+		if result.Status != healthUp {
+			status, code = healthDown, http.StatusServiceUnavailable
+		}
+	}
+
+	body := gin.H{
+		"status":     status,
+		"components": components,
+	}
+	if hC.draining.Load() {
+		body["status"] = healthDown
+		body["draining"] = true
+		code = http.StatusServiceUnavailable
+	}
+	ctx.JSON(code, body)
+}
+
+func check(ctx context.Context, checker ports.HealthChecker) componentHealth {
+	start := time.Now()
+	err := checker.Check(ctx)
+	result := componentHealth{
+		Status:    healthUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = healthDown
+		result.Error = err.Error()
+	}
+	return result
+}
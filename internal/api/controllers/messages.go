@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+
+	apierrors "github.com/Miklakapi/go-file-share/internal/api/api-errors"
+	"github.com/Miklakapi/go-file-share/internal/api/dto"
+	"github.com/Miklakapi/go-file-share/internal/api/middleware"
+	fileShare "github.com/Miklakapi/go-file-share/internal/file-share/application"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/gin-gonic/gin"
+)
+
+type MessagesController struct {
+	fileShareService *fileShare.Service
+	eventPublisher   ports.EventPublisher
+}
+
+func NewMessagesController(fileShareService *fileShare.Service, eventPublisher ports.EventPublisher) *MessagesController {
+	return &MessagesController{
+		fileShareService: fileShareService,
+		eventPublisher:   eventPublisher,
+	}
+}
+
+func (mC *MessagesController) Get(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+	token := middleware.MustToken(ctx)
+
+	messages, err := mC.fileShareService.Messages(ctx.Request.Context(), roomId, token)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	result := make([]dto.RoomMessage, 0, len(messages))
+	for _, m := range messages {
+		result = append(result, dto.NewRoomMessage(m))
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": result,
+	})
+}
+
+func (mC *MessagesController) Create(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+	token := middleware.MustToken(ctx)
+
+	var requestData dto.MessageRequest
+	if err := ctx.ShouldBind(&requestData); err != nil {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	msg, err := mC.fileShareService.PostMessage(ctx.Request.Context(), roomId, token, requestData.Author, requestData.Text)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	// The message is stored already, so a failed announcement is only logged.
+	if err := mC.eventPublisher.Publish(ports.Event{Name: ports.EventRoomMessage, Data: roomId}); err != nil {
+		slog.WarnContext(ctx.Request.Context(), "cannot publish room message event", slog.Any("error", err))
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"data": dto.NewRoomMessage(*msg),
+	})
+}
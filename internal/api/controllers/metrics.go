@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/Miklakapi/go-file-share/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+type MetricsController struct {
+	registry *metrics.Registry
+	token    []byte
+}
+
+// NewMetricsController serves the registry; a non-empty token must be sent
+// as a bearer token by the scraper.
+func NewMetricsController(registry *metrics.Registry, token string) *MetricsController {
+	return &MetricsController{
+		registry: registry,
+		token:    []byte(token),
+	}
+}
+
+func (mC *MetricsController) Metrics(ctx *gin.Context) {
+	if len(mC.token) > 0 {
+		got, _ := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), mC.token) != 1 {
+			ctx.Header("WWW-Authenticate", `Bearer realm="metrics"`)
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	ctx.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	ctx.Status(http.StatusOK)
+	_ = mC.registry.WriteTo(ctx.Request.Context(), ctx.Writer)
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"io"
 	"net/http"
 	"strings"
 	"time"
@@ -26,19 +27,39 @@ func NewRoomsController(fileShareService *fileShare.Service, eventPublisher port
 }
 
 func (rC *RoomsController) Get(ctx *gin.Context) {
-	rooms, err := rC.fileShareService.Rooms(ctx.Request.Context())
+	var requestData dto.RoomListRequest
+	if err := ctx.ShouldBindQuery(&requestData); err != nil {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	query := ports.RoomQuery{
+		ExpiresAfter:  requestData.ExpiresAfter,
+		ExpiresBefore: requestData.ExpiresBefore,
+		Sort:          requestData.Sort,
+		Desc:          requestData.Order == "desc",
+		Cursor:        requestData.Cursor,
+		Limit:         requestData.Limit,
+	}
+	// Newest first by default; an explicit order always wins.
+	if requestData.Sort == "" && requestData.Order == "" {
+		query.Desc = true
+	}
+
+	page, err := rC.fileShareService.Rooms(ctx.Request.Context(), query)
 	if err != nil {
 		_ = ctx.Error(err)
 		return
 	}
 
-	result := make([]dto.Room, 0, len(rooms))
-	for _, r := range rooms {
+	result := make([]dto.Room, 0, len(page.Rooms))
+	for _, r := range page.Rooms {
 		result = append(result, dto.NewRoom(r))
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"data": result,
+		"next": page.Next,
 	})
 }
 
@@ -85,9 +106,16 @@ func (rC *RoomsController) Create(ctx *gin.Context) {
 		return
 	}
 
+	rules, err := requestData.ContentRules()
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
 	duration := time.Second * time.Duration(requestData.Lifespan)
 
-	room, token, err := rC.fileShareService.CreateRoom(ctx.Request.Context(), requestData.Password, duration)
+	opts := fileShare.RoomOptions{Content: rules, SanitizeImages: requestData.SanitizeImages, Versioning: requestData.Versioning}
+	room, token, err := rC.fileShareService.CreateRoom(ctx.Request.Context(), requestData.Password, duration, opts)
 	if err != nil {
 		_ = ctx.Error(err)
 		return
@@ -98,11 +126,51 @@ func (rC *RoomsController) Create(ctx *gin.Context) {
 		return
 	}
 
-	basePath := strings.TrimSuffix(ctx.Request.URL.Path, "/")
-	cookiePath := basePath + "/" + room.ID.String()
+	setRoomCookie(ctx, strings.TrimSuffix(ctx.Request.URL.Path, "/"), room.ID.String(), token, duration)
 
-	ctx.SetSameSite(http.SameSiteStrictMode)
-	ctx.SetCookie("auth_token", token, int(duration.Seconds()), cookiePath, "", false, true)
+	ctx.JSON(http.StatusOK, gin.H{
+		"data":  dto.NewRoom(room),
+		"token": token,
+	})
+}
+
+// Import recreates a room from an exported bundle sent as the "bundle" form
+// file, with the password and lifespan from the form.
+func (rC *RoomsController) Import(ctx *gin.Context) {
+	requestData := dto.ImportRoomRequest{}
+
+	if err := ctx.ShouldBind(&requestData); err != nil {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	fh, err := ctx.FormFile("bundle")
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	src, err := fh.Open()
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	defer func() { _ = src.Close() }()
+
+	duration := time.Second * time.Duration(requestData.Lifespan)
+
+	room, token, err := rC.fileShareService.ImportRoom(ctx.Request.Context(), src, requestData.Password, duration)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	if err := rC.eventPublisher.Publish(ports.Event{Name: ports.EventRoomCreate, Data: room.ID}); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	basePath := strings.TrimSuffix(strings.TrimSuffix(ctx.Request.URL.Path, "/"), "/import")
+	setRoomCookie(ctx, basePath, room.ID.String(), token, duration)
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"data":  dto.NewRoom(room),
@@ -110,6 +178,26 @@ func (rC *RoomsController) Create(ctx *gin.Context) {
 	})
 }
 
+// Export streams the room as a signed tarball for Import.
+func (rC *RoomsController) Export(ctx *gin.Context) {
+	roomId := middleware.MustRoomIDParam(ctx)
+	token := middleware.MustToken(ctx)
+
+	name, rc, err := rC.fileShareService.ExportRoom(ctx.Request.Context(), roomId, token)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	defer func() { _ = rc.Close() }()
+
+	setContentHeaders(ctx, name, "application/x-tar")
+
+	_, copyErr := io.Copy(ctx.Writer, rc)
+	if copyErr != nil {
+		return
+	}
+}
+
 func (rC *RoomsController) Delete(ctx *gin.Context) {
 	roomId := middleware.MustRoomIDParam(ctx)
 	token := middleware.MustToken(ctx)
@@ -129,3 +217,8 @@ func (rC *RoomsController) Delete(ctx *gin.Context) {
 
 	ctx.Status(http.StatusNoContent)
 }
+
+func setRoomCookie(ctx *gin.Context, roomsPath, roomId, token string, duration time.Duration) {
+	ctx.SetSameSite(http.SameSiteStrictMode)
+	ctx.SetCookie("auth_token", token, int(duration.Seconds()), roomsPath+"/"+roomId, "", false, true)
+}
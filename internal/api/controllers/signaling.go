@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	apierrors "github.com/Miklakapi/go-file-share/internal/api/api-errors"
+	"github.com/Miklakapi/go-file-share/internal/api/dto"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxSignalPayload = 16 * 1024
+	// maxSignalBody leaves room for the envelope and for JSON escaping of
+	// the payload; the decoded payload is held to maxSignalPayload.
+	maxSignalBody = 4 * maxSignalPayload
+)
+
+var clientSignalKinds = map[ports.SignalKind]bool{
+	ports.SignalOffer:     true,
+	ports.SignalAnswer:    true,
+	ports.SignalCandidate: true,
+	ports.SignalFallback:  true,
+	ports.SignalBye:       true,
+}
+
+type SignalingController struct {
+	appCtx    context.Context
+	signaling ports.Signaling
+}
+
+func NewSignalingController(appCtx context.Context, signaling ports.Signaling) *SignalingController {
+	return &SignalingController{appCtx: appCtx, signaling: signaling}
+}
+
+func (sC *SignalingController) Stream(ctx *gin.Context) {
+	code := strings.TrimSpace(ctx.Param("code"))
+	role := ports.SignalRole(ctx.Query("role"))
+	if code == "" {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	flusher, ok := ctx.Writer.(http.Flusher)
+	if !ok {
+		ctx.String(http.StatusInternalServerError, "Streaming unsupported")
+		return
+	}
+
+	signals, unsubscribe, err := sC.signaling.Join(ctx.Request.Context(), code, role)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	defer unsubscribe()
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+
+	ctx.Writer.WriteHeaderNow()
+	flusher.Flush()
+
+	pingTicker := time.NewTicker(30 * time.Second)
+	defer pingTicker.Stop()
+
+	reqCtx := ctx.Request.Context()
+
+	for {
+		select {
+		case signal, ok := <-signals:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(dto.NewSignal(signal))
+			if err != nil {
+				continue
+			}
+			if !sC.sendEvent(ctx, flusher, string(signal.Kind), string(data)) {
+				return
+			}
+
+		case <-pingTicker.C:
+			if !sC.sendEvent(ctx, flusher, "Ping", time.Now().Format(time.RFC3339)) {
+				return
+			}
+
+		case <-reqCtx.Done():
+			return
+
+		case <-sC.appCtx.Done():
+			return
+		}
+	}
+}
+
+func (sC *SignalingController) Send(ctx *gin.Context) {
+	code := strings.TrimSpace(ctx.Param("code"))
+	role := ports.SignalRole(ctx.Query("role"))
+	if code == "" {
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxSignalBody)
+	requestData := dto.SignalRequest{}
+	if err := ctx.ShouldBindJSON(&requestData); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			_ = ctx.Error(ports.ErrInvalidSignal)
+			return
+		}
+		_ = ctx.Error(apierrors.ErrInvalidRequest)
+		return
+	}
+
+	kind := ports.SignalKind(requestData.Type)
+	if !clientSignalKinds[kind] || len(requestData.Payload) > maxSignalPayload {
+		_ = ctx.Error(ports.ErrInvalidSignal)
+		return
+	}
+
+	signal := ports.Signal{Kind: kind, From: role, Payload: requestData.Payload}
+	if err := sC.signaling.Send(ctx.Request.Context(), code, signal); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (sC *SignalingController) sendEvent(ctx *gin.Context, flusher http.Flusher, name, data string) bool {
+	if _, err := fmt.Fprintf(ctx.Writer, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return false
+	}
+	flusher.Flush()
+	return true
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Miklakapi/go-file-share/internal/api/middleware"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/gin-gonic/gin"
+)
+
+type recordingSignaling struct {
+	ports.Signaling
+	sent []ports.Signal
+}
+
+func (r *recordingSignaling) Send(_ context.Context, _ string, signal ports.Signal) error {
+	r.sent = append(r.sent, signal)
+	return nil
+}
+
+func TestSignalingSendLimitsBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	signaling := &recordingSignaling{}
+	engine := gin.New()
+	engine.POST("/direct/:code/signal", middleware.ErrorMiddleware(), NewSignalingController(context.Background(), signaling).Send)
+
+	post := func(body io.Reader) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/direct/signal0000000001/signal?role=sender", body)
+		req.Header.Set("Content-Type", "application/json")
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post(strings.NewReader(`{"type":"offer","payload":"v=0"}`)); w.Code != http.StatusNoContent {
+		t.Fatalf("small signal: status %d, body %s", w.Code, w.Body)
+	}
+
+	// A body far past the limit is cut off while it is read, not decoded.
+	huge := &countingReader{r: io.MultiReader(
+		strings.NewReader(`{"type":"offer","payload":"`),
+		strings.NewReader(strings.Repeat("a", 64*maxSignalBody)),
+		strings.NewReader(`"}`),
+	)}
+	w := post(huge)
+	if huge.n > 2*maxSignalBody {
+		t.Errorf("read %d bytes of an oversized body, limit is %d", huge.n, maxSignalBody)
+	}
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("oversized body: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != "SIGNAL_INVALID" {
+		t.Fatalf("oversized body: response %s", w.Body)
+	}
+
+	if len(signaling.sent) != 1 {
+		t.Fatalf("%d signals reached signaling, want 1", len(signaling.sent))
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
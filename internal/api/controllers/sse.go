@@ -6,8 +6,10 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
 	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type SSEController struct {
@@ -50,6 +52,18 @@ func (sC *SSEController) SSE(ctx *gin.Context) {
 	}
 	defer unsubscribe2()
 
+	fileDeleteCh, unsubscribe3, err := sC.eventSubscriber.Subscribe(ports.EventFileDelete)
+	if err != nil {
+		return
+	}
+	defer unsubscribe3()
+
+	messageCh, unsubscribe4, err := sC.eventSubscriber.Subscribe(ports.EventRoomMessage)
+	if err != nil {
+		return
+	}
+	defer unsubscribe4()
+
 	for {
 		select {
 		case <-createCh:
@@ -62,6 +76,20 @@ func (sC *SSEController) SSE(ctx *gin.Context) {
 				return
 			}
 
+		case e := <-fileDeleteCh:
+			// Only the room is named; file details stay behind the token.
+			deleted, _ := e.Data.(domain.ExpiredFile)
+			if !sC.sendEvent(ctx, flusher, "FilesChange", deleted.RoomID.String()) {
+				return
+			}
+
+		case e := <-messageCh:
+			// Like FilesChange, the text itself is fetched with a token.
+			roomID, _ := e.Data.(uuid.UUID)
+			if !sC.sendEvent(ctx, flusher, "MessagesChange", roomID.String()) {
+				return
+			}
+
 		case <-pingTicker.C:
 			if !sC.sendEvent(ctx, flusher, "Ping", time.Now().Format(time.RFC3339)) {
 				return
@@ -3,49 +3,435 @@ package dto
 import (
 	"time"
 
+	fileShare "github.com/Miklakapi/go-file-share/internal/file-share/application"
 	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
 	"github.com/google/uuid"
 )
 
 type CreateRoomRequest struct {
 	Password string `json:"password" form:"password" binding:"required"`
 	Lifespan int    `json:"lifespan" form:"lifespan"`
+
+	AllowTypes      []string `json:"allowTypes" form:"allowTypes"`
+	DenyTypes       []string `json:"denyTypes" form:"denyTypes"`
+	AllowExtensions []string `json:"allowExtensions" form:"allowExtensions"`
+	DenyExtensions  []string `json:"denyExtensions" form:"denyExtensions"`
+
+	SanitizeImages bool `json:"sanitizeImages" form:"sanitizeImages"`
+	Versioning     bool `json:"versioning" form:"versioning"`
+}
+
+func (r CreateRoomRequest) ContentRules() (domain.ContentRules, error) {
+	return domain.NewContentRules(r.AllowTypes, r.DenyTypes, r.AllowExtensions, r.DenyExtensions)
+}
+
+// ImportRoomRequest comes as multipart form fields next to the bundle file.
+type ImportRoomRequest struct {
+	Password string `form:"password" binding:"required"`
+	Lifespan int    `form:"lifespan"`
 }
 
 type AuthRoomRequest struct {
-	CreateRoomRequest
+	Password string `json:"password" form:"password" binding:"required"`
+	Lifespan int    `json:"lifespan" form:"lifespan"`
 }
 
 type Room struct {
-	ID        uuid.UUID `json:"id"`
-	ExpiresAt time.Time `json:"expiresAt"`
-	Files     int       `json:"files"`
-	Tokens    int       `json:"tokens"`
+	ID             uuid.UUID     `json:"id"`
+	ExpiresAt      time.Time     `json:"expiresAt"`
+	Files          int           `json:"files"`
+	Tokens         int           `json:"tokens"`
+	Messages       int           `json:"messages"`
+	ContentRules   *ContentRules `json:"contentRules,omitempty"`
+	SanitizeImages bool          `json:"sanitizeImages"`
+	Versioning     bool          `json:"versioning"`
+}
+
+type ContentRules struct {
+	AllowTypes      []string `json:"allowTypes,omitempty"`
+	DenyTypes       []string `json:"denyTypes,omitempty"`
+	AllowExtensions []string `json:"allowExtensions,omitempty"`
+	DenyExtensions  []string `json:"denyExtensions,omitempty"`
 }
 
 func NewRoom(s *domain.Room) Room {
-	return Room{
+	out := Room{
 		ID:        s.ID,
 		ExpiresAt: s.ExpiresAt,
 		Files:     len(s.Files),
 		Tokens:    s.TokensCount(),
+		Messages:  len(s.Messages),
+
+		SanitizeImages: s.SanitizeImages,
+		Versioning:     s.Versioning,
+	}
+	if !s.Content.IsZero() {
+		out.ContentRules = &ContentRules{
+			AllowTypes:      s.Content.AllowTypes,
+			DenyTypes:       s.Content.DenyTypes,
+			AllowExtensions: s.Content.AllowExtensions,
+			DenyExtensions:  s.Content.DenyExtensions,
+		}
 	}
+	return out
 }
 
 type RoomFile struct {
+	ID          uuid.UUID `json:"id"`
+	Path        string    `json:"path"`
+	Folder      string    `json:"folder"`
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	ContentType string    `json:"contentType"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Scan        FileScan  `json:"scan"`
+	// Sanitization is set when metadata was stripped on upload.
+	Sanitization *FileSanitization `json:"sanitization,omitempty"`
+	ExpiresAt    *time.Time        `json:"expiresAt,omitempty"`
+	MaxDownloads int               `json:"maxDownloads,omitempty"`
+	Downloads    int               `json:"downloads"`
+	Version      int               `json:"version"`
+	// Versions counts all stored versions, the current one included.
+	Versions    int    `json:"versions"`
+	Description string `json:"description,omitempty"`
+}
+
+type UpdateFileRequest struct {
+	Description *string `json:"description" form:"description" binding:"required"`
+}
+
+type FileVersion struct {
+	Version     int       `json:"version"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	ContentType string    `json:"contentType"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func NewFileVersion(s domain.FileVersion) FileVersion {
+	return FileVersion{
+		Version:     s.Version,
+		Size:        s.Size,
+		SHA256:      s.SHA256,
+		ContentType: s.ContentType,
+		CreatedAt:   s.CreatedAt,
+	}
+}
+
+type FileSanitization struct {
+	OriginalSize   int64  `json:"originalSize"`
+	OriginalSHA256 string `json:"originalSha256"`
+}
+
+type FileScan struct {
+	Status    string     `json:"status"`
+	Scanner   string     `json:"scanner,omitempty"`
+	ScannedAt *time.Time `json:"scannedAt,omitempty"`
+}
+
+func NewFileRoomFile(s *domain.RoomFile) RoomFile {
+	out := RoomFile{
+		ID:          s.ID,
+		Path:        s.Path,
+		Folder:      s.Folder,
+		Name:        s.Name,
+		Size:        s.Size,
+		SHA256:      s.SHA256,
+		ContentType: s.ContentType,
+		CreatedAt:   s.CreatedAt,
+		Scan:        NewFileScan(s.Scan),
+		Downloads:   s.Downloads,
+		Version:     max(s.Version, 1),
+		Versions:    len(s.Versions) + 1,
+		Description: s.Description,
+	}
+	if !s.ExpiresAt.IsZero() {
+		out.ExpiresAt = &s.ExpiresAt
+	}
+	if s.Limited() {
+		out.MaxDownloads = s.MaxDownloads
+	}
+	if s.Sanitization.Applied {
+		out.Sanitization = &FileSanitization{
+			OriginalSize:   s.Sanitization.OriginalSize,
+			OriginalSHA256: s.Sanitization.OriginalSHA256,
+		}
+	}
+	return out
+}
+
+func NewFileScan(s domain.ScanVerdict) FileScan {
+	out := FileScan{
+		Status:  s.Status,
+		Scanner: s.Scanner,
+	}
+	if out.Status == "" {
+		out.Status = domain.ScanStatusUnscanned
+	}
+	if !s.ScannedAt.IsZero() {
+		out.ScannedAt = &s.ScannedAt
+	}
+	return out
+}
+
+type PrefixRequest struct {
+	Prefix string `form:"prefix"`
+}
+
+type FileListRequest struct {
+	Prefix  string `form:"prefix"`
+	Name    string `form:"name"`
+	Type    string `form:"type"`
+	MinSize int64  `form:"minSize"`
+	MaxSize int64  `form:"maxSize"`
+	Sort    string `form:"sort"`
+	Order   string `form:"order" binding:"omitempty,oneof=asc desc"`
+	Cursor  string `form:"cursor"`
+	Limit   int    `form:"limit"`
+}
+
+type RoomListRequest struct {
+	ExpiresAfter  time.Time `form:"expiresAfter" time_format:"2006-01-02T15:04:05Z07:00"`
+	ExpiresBefore time.Time `form:"expiresBefore" time_format:"2006-01-02T15:04:05Z07:00"`
+	Sort          string    `form:"sort"`
+	Order         string    `form:"order" binding:"omitempty,oneof=asc desc"`
+	Cursor        string    `form:"cursor"`
+	Limit         int       `form:"limit"`
+}
+
+type FolderRequest struct {
+	Path string `json:"path" form:"path" binding:"required"`
+}
+
+type RenameFolderRequest struct {
+	Path string `json:"path" form:"path" binding:"required"`
+	Name string `json:"name" form:"name" binding:"required"`
+}
+
+type MoveFolderRequest struct {
+	Path string `json:"path" form:"path" binding:"required"`
+	// Parent is the destination folder, empty for the room root.
+	Parent string `json:"parent" form:"parent"`
+}
+
+type CopyFileRequest struct {
+	RoomID string `json:"roomId" form:"roomId" binding:"required"`
+	Folder string `json:"folder" form:"folder"`
+}
+
+type MessageRequest struct {
+	Author string `json:"author" form:"author"`
+	Text   string `json:"text" form:"text" binding:"required"`
+}
+
+type RoomMessage struct {
 	ID        uuid.UUID `json:"id"`
-	Path      string    `json:"path"`
-	Name      string    `json:"name"`
-	Size      int64     `json:"size"`
+	Author    string    `json:"author,omitempty"`
+	Text      string    `json:"text"`
 	CreatedAt time.Time `json:"createdAt"`
 }
 
-func NewFileRoomFile(s *domain.RoomFile) RoomFile {
-	return RoomFile{
+func NewRoomMessage(s domain.RoomMessage) RoomMessage {
+	return RoomMessage{
 		ID:        s.ID,
-		Path:      s.Path,
-		Name:      s.Name,
-		Size:      s.Size,
+		Author:    s.Author,
+		Text:      s.Text,
 		CreatedAt: s.CreatedAt,
 	}
 }
+
+type Folder struct {
+	Path   string `json:"path"`
+	Name   string `json:"name"`
+	Parent string `json:"parent"`
+}
+
+func NewFolder(path string) Folder {
+	return Folder{
+		Path:   path,
+		Name:   domain.FolderName(path),
+		Parent: domain.ParentFolder(path),
+	}
+}
+
+type ThumbnailRequest struct {
+	Size int `form:"size"`
+}
+
+type FilePreview struct {
+	Kind        string `json:"kind"`
+	ContentType string `json:"contentType"`
+	Text        string `json:"text,omitempty"`
+	Truncated   bool   `json:"truncated,omitempty"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+	Icon        string `json:"icon,omitempty"`
+}
+
+func NewFilePreview(f *domain.RoomFile, p fileShare.Preview) FilePreview {
+	return FilePreview{
+		Kind:        p.Kind,
+		ContentType: f.ContentType,
+		Text:        p.Text,
+		Truncated:   p.Truncated,
+		Width:       p.Width,
+		Height:      p.Height,
+		Icon:        p.Icon,
+	}
+}
+
+type ArchiveEntry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Dir     bool      `json:"dir"`
+}
+
+func NewArchiveEntry(e ports.ArchiveEntry) ArchiveEntry {
+	return ArchiveEntry{
+		Name:    e.Name,
+		Size:    e.Size,
+		ModTime: e.ModTime,
+		Dir:     e.Dir,
+	}
+}
+
+type BroadcastRequest struct {
+	Receivers int    `form:"receivers"`
+	Policy    string `form:"policy"`
+}
+
+type BroadcastResult struct {
+	Receivers int    `json:"receivers"`
+	Completed int    `json:"completed"`
+	Dropped   int    `json:"dropped"`
+	SHA256    string `json:"sha256"`
+}
+
+func NewBroadcastResult(s ports.BroadcastResult) BroadcastResult {
+	return BroadcastResult{
+		Receivers: s.Receivers,
+		Completed: s.Completed,
+		Dropped:   s.Dropped,
+		SHA256:    s.Digest,
+	}
+}
+
+type SignalRequest struct {
+	Type    string `json:"type" binding:"required"`
+	Payload string `json:"payload"`
+}
+
+type Signal struct {
+	From    ports.SignalRole `json:"from"`
+	Payload string           `json:"payload,omitempty"`
+}
+
+func NewSignal(s ports.Signal) Signal {
+	return Signal{
+		From:    s.From,
+		Payload: s.Payload,
+	}
+}
+
+type AdminToken struct {
+	Fingerprint string    `json:"fingerprint"`
+	IssuedAt    time.Time `json:"issuedAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	Valid       bool      `json:"valid"`
+}
+
+type AdminRoom struct {
+	Room
+	Bytes       int64        `json:"bytes"`
+	TokenDetail []AdminToken `json:"tokenDetails"`
+	FileList    []RoomFile   `json:"fileList,omitempty"`
+}
+
+func NewAdminRoom(s fileShare.AdminRoom, withFiles bool) AdminRoom {
+	out := AdminRoom{
+		Room:        NewRoom(s.Room),
+		Bytes:       s.Bytes,
+		TokenDetail: make([]AdminToken, 0, len(s.Tokens)),
+	}
+	for _, t := range s.Tokens {
+		out.TokenDetail = append(out.TokenDetail, AdminToken{
+			Fingerprint: t.Fingerprint,
+			IssuedAt:    t.IssuedAt,
+			ExpiresAt:   t.ExpiresAt,
+			Valid:       t.Valid,
+		})
+	}
+	if withFiles {
+		out.FileList = make([]RoomFile, 0, len(s.Room.Files))
+		for _, f := range s.Room.ListFiles() {
+			out.FileList = append(out.FileList, NewFileRoomFile(f))
+		}
+	}
+	return out
+}
+
+type StorageStats struct {
+	Rooms     int   `json:"rooms"`
+	Tokens    int   `json:"tokens"`
+	Files     int   `json:"files"`
+	Versions  int   `json:"versions"`
+	Bytes     int64 `json:"bytes"`
+	DiskFiles int   `json:"diskFiles"`
+	DiskBytes int64 `json:"diskBytes"`
+}
+
+func NewStorageStats(s fileShare.StorageStats) StorageStats {
+	return StorageStats{
+		Rooms:     s.Rooms,
+		Tokens:    s.Tokens,
+		Files:     s.Files,
+		Versions:  s.Versions,
+		Bytes:     s.Bytes,
+		DiskFiles: s.DiskFiles,
+		DiskBytes: s.DiskBytes,
+	}
+}
+
+type AuditQueryRequest struct {
+	Since  time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until  time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	Action string    `form:"action"`
+	RoomID string    `form:"roomId"`
+	Limit  int       `form:"limit"`
+}
+
+type AuditRecord struct {
+	Time             time.Time  `json:"time"`
+	Action           string     `json:"action"`
+	Result           string     `json:"result"`
+	Actor            string     `json:"actor"`
+	ActorIP          string     `json:"actorIp,omitempty"`
+	TokenFingerprint string     `json:"tokenFingerprint,omitempty"`
+	RoomID           *uuid.UUID `json:"roomId,omitempty"`
+	FileID           *uuid.UUID `json:"fileId,omitempty"`
+	RequestID        string     `json:"requestId,omitempty"`
+	Detail           string     `json:"detail,omitempty"`
+}
+
+func NewAuditRecord(r ports.AuditRecord) AuditRecord {
+	out := AuditRecord{
+		Time:             r.Time,
+		Action:           r.Action,
+		Result:           r.Result,
+		Actor:            r.Actor,
+		ActorIP:          r.ActorIP,
+		TokenFingerprint: r.TokenFingerprint,
+		RequestID:        r.RequestID,
+		Detail:           r.Detail,
+	}
+	if r.RoomID != uuid.Nil {
+		out.RoomID = &r.RoomID
+	}
+	if r.FileID != uuid.Nil {
+		out.FileID = &r.FileID
+	}
+	return out
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/gin-gonic/gin"
+)
+
+// Actor stores the caller's address in the request context so the audit log
+// can attribute actions.
+func Actor() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		actor := ports.Actor{Name: ports.AuditActorAnonymous, IP: ctx.ClientIP()}
+		ctx.Request = ctx.Request.WithContext(ports.ContextWithActor(ctx.Request.Context(), actor))
+		ctx.Next()
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuthMiddleware only lets requests through that send the static admin
+// key as a bearer token.
+func AdminAuthMiddleware(key string) gin.HandlerFunc {
+	expected := []byte(key)
+
+	return func(ctx *gin.Context) {
+		got, ok := strings.CutPrefix(strings.TrimSpace(ctx.GetHeader("Authorization")), "Bearer ")
+		if !ok || len(expected) == 0 || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), expected) != 1 {
+			ctx.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code":    "ADMIN_UNAUTHORIZED",
+				"message": "Unauthorized",
+			})
+			return
+		}
+
+		actor := ports.ActorFromContext(ctx.Request.Context())
+		actor.Name = ports.AuditActorAdmin
+		if actor.IP == "" {
+			actor.IP = ctx.ClientIP()
+		}
+		ctx.Request = ctx.Request.WithContext(ports.ContextWithActor(ctx.Request.Context(), actor))
+		ctx.Next()
+	}
+}
@@ -90,9 +90,93 @@ func MapErrors(err error) HTTPError {
 	case errors.Is(err, domain.ErrInvalidFile):
 		return HTTPError{Status: http.StatusBadRequest, Code: "INVALID_FILE", Message: "Invalid file"}
 
+	case errors.Is(err, domain.ErrInvalidFileLimits):
+		return HTTPError{Status: http.StatusBadRequest, Code: "INVALID_FILE_LIMITS", Message: "File lifespan and download limit must not be negative"}
+
+	case errors.Is(err, domain.ErrFileDownloadLimited):
+		return HTTPError{Status: http.StatusConflict, Code: "FILE_DOWNLOAD_LIMITED", Message: "File with a download limit can only be downloaded"}
+
+	case errors.Is(err, domain.ErrVersionNotFound):
+		return HTTPError{Status: http.StatusNotFound, Code: "VERSION_NOT_FOUND", Message: "File version not found"}
+
+	case errors.Is(err, domain.ErrTargetAccessDenied):
+		return HTTPError{Status: http.StatusForbidden, Code: "TARGET_ACCESS_DENIED", Message: "No access to the target room"}
+
+	case errors.Is(err, domain.ErrSameRoom):
+		return HTTPError{Status: http.StatusConflict, Code: "SAME_ROOM", Message: "File is already in that room"}
+
+	case errors.Is(err, domain.ErrInvalidDescription):
+		return HTTPError{Status: http.StatusBadRequest, Code: "INVALID_DESCRIPTION", Message: "File description is too long or contains control characters"}
+
+	case errors.Is(err, domain.ErrInvalidMessage):
+		return HTTPError{Status: http.StatusBadRequest, Code: "INVALID_MESSAGE", Message: "Message text is required; text and author are limited in length"}
+
 	case errors.Is(err, ports.ErrEmptyFilename):
 		return HTTPError{Status: http.StatusBadRequest, Code: "FILENAME_EMPTY", Message: "Filename is required"}
 
+	case errors.Is(err, ports.ErrInvalidDigest):
+		return HTTPError{Status: http.StatusBadRequest, Code: "INVALID_DIGEST", Message: "Invalid digest header"}
+
+	case errors.Is(err, ports.ErrDigestMismatch):
+		return HTTPError{Status: http.StatusUnprocessableEntity, Code: "DIGEST_MISMATCH", Message: "File digest does not match"}
+
+	case errors.Is(err, domain.ErrRoomQuotaExceeded):
+		return HTTPError{Status: http.StatusRequestEntityTooLarge, Code: "ROOM_QUOTA_EXCEEDED", Message: "Room file or size limit reached"}
+
+	case errors.Is(err, domain.ErrFileTypeNotAllowed):
+		return HTTPError{Status: http.StatusUnsupportedMediaType, Code: "FILE_TYPE_NOT_ALLOWED", Message: "File type is not allowed"}
+
+	case errors.Is(err, domain.ErrInvalidContentRules):
+		return HTTPError{Status: http.StatusBadRequest, Code: "INVALID_CONTENT_RULES", Message: "Invalid content rules"}
+
+	case errors.Is(err, ports.ErrFileInfected):
+		return HTTPError{Status: http.StatusUnprocessableEntity, Code: "FILE_INFECTED", Message: "File rejected by content scanner"}
+
+	case errors.Is(err, ports.ErrScanFailed):
+		return HTTPError{Status: http.StatusServiceUnavailable, Code: "SCAN_FAILED", Message: "File could not be scanned, try again later"}
+
+	case errors.Is(err, ports.ErrThumbnailUnsupported):
+		return HTTPError{Status: http.StatusUnsupportedMediaType, Code: "THUMBNAIL_UNSUPPORTED", Message: "No thumbnail available for this file"}
+
+	case errors.Is(err, ports.ErrImageTooLarge):
+		return HTTPError{Status: http.StatusUnprocessableEntity, Code: "IMAGE_TOO_LARGE", Message: "Image is too large to thumbnail"}
+
+	case errors.Is(err, ports.ErrSanitizeFailed):
+		return HTTPError{Status: http.StatusUnprocessableEntity, Code: "SANITIZE_FAILED", Message: "Image metadata could not be removed"}
+
+	case errors.Is(err, ports.ErrNotArchive):
+		return HTTPError{Status: http.StatusUnsupportedMediaType, Code: "NOT_AN_ARCHIVE", Message: "File is not a supported archive"}
+
+	case errors.Is(err, ports.ErrArchiveEntryNotFound):
+		return HTTPError{Status: http.StatusNotFound, Code: "ARCHIVE_ENTRY_NOT_FOUND", Message: "Archive entry not found"}
+
+	case errors.Is(err, ports.ErrArchiveUnsafe):
+		return HTTPError{Status: http.StatusUnprocessableEntity, Code: "ARCHIVE_UNSAFE", Message: "Archive contains unsafe paths"}
+
+	case errors.Is(err, ports.ErrArchiveTooLarge):
+		return HTTPError{Status: http.StatusUnprocessableEntity, Code: "ARCHIVE_TOO_LARGE", Message: "Archive expands beyond the allowed limits"}
+
+	case errors.Is(err, ports.ErrArchiveUnreadable):
+		return HTTPError{Status: http.StatusUnprocessableEntity, Code: "ARCHIVE_UNREADABLE", Message: "Archive is corrupt or unreadable"}
+
+	case errors.Is(err, ports.ErrBundleSignature):
+		return HTTPError{Status: http.StatusUnprocessableEntity, Code: "BUNDLE_SIGNATURE_INVALID", Message: "Room bundle was not signed with this server's key"}
+
+	case errors.Is(err, ports.ErrBundleInvalid):
+		return HTTPError{Status: http.StatusUnprocessableEntity, Code: "BUNDLE_INVALID", Message: "Room bundle is corrupt or unreadable"}
+
+	// ======================
+	// FOLDER
+	// ======================
+	case errors.Is(err, domain.ErrInvalidFolder):
+		return HTTPError{Status: http.StatusBadRequest, Code: "INVALID_FOLDER", Message: "Invalid folder path"}
+
+	case errors.Is(err, domain.ErrFolderNotFound):
+		return HTTPError{Status: http.StatusNotFound, Code: "FOLDER_NOT_FOUND", Message: "Folder not found"}
+
+	case errors.Is(err, domain.ErrFolderExists):
+		return HTTPError{Status: http.StatusConflict, Code: "FOLDER_EXISTS", Message: "Folder already exists"}
+
 	case errors.Is(err, ports.ErrNilReader):
 		return HTTPError{Status: http.StatusInternalServerError, Code: "FILE_STREAM_MISSING", Message: "Internal server error"}
 
@@ -118,9 +202,48 @@ func MapErrors(err error) HTTPError {
 	case errors.Is(err, ports.ErrTransferCodeExists):
 		return HTTPError{Status: http.StatusConflict, Code: "TRANSFER_CODE_EXISTS", Message: "Transfer code already in use"}
 
+	case errors.Is(err, ports.ErrTransferIncomplete):
+		return HTTPError{Status: http.StatusConflict, Code: "TRANSFER_INCOMPLETE", Message: "Receiver did not complete the transfer"}
+
+	case errors.Is(err, ports.ErrBroadcastStarted):
+		return HTTPError{Status: http.StatusConflict, Code: "BROADCAST_STARTED", Message: "Broadcast already started"}
+
+	case errors.Is(err, ports.ErrBroadcastFull):
+		return HTTPError{Status: http.StatusConflict, Code: "BROADCAST_FULL", Message: "Broadcast receiver limit reached"}
+
+	case errors.Is(err, ports.ErrBroadcastNoReceivers):
+		return HTTPError{Status: http.StatusConflict, Code: "BROADCAST_NO_RECEIVERS", Message: "No receivers joined the broadcast"}
+
+	case errors.Is(err, ports.ErrBroadcastInvalidPolicy):
+		return HTTPError{Status: http.StatusBadRequest, Code: "BROADCAST_POLICY_INVALID", Message: "Invalid broadcast policy"}
+
+	case errors.Is(err, ports.ErrSlowReceiver):
+		return HTTPError{Status: http.StatusConflict, Code: "RECEIVER_TOO_SLOW", Message: "Receiver was too slow and got dropped"}
+
+	case errors.Is(err, ports.ErrInvalidSignal):
+		return HTTPError{Status: http.StatusBadRequest, Code: "SIGNAL_INVALID", Message: "Invalid signal"}
+
+	case errors.Is(err, ports.ErrSignalPeerJoined):
+		return HTTPError{Status: http.StatusConflict, Code: "SIGNAL_PEER_JOINED", Message: "Peer already joined signaling"}
+
+	case errors.Is(err, ports.ErrSignalBufferFull):
+		return HTTPError{Status: http.StatusTooManyRequests, Code: "SIGNAL_BUFFER_FULL", Message: "Too many pending signals"}
+
+	case errors.Is(err, ports.ErrTransferRelayFailed):
+		return HTTPError{Status: http.StatusBadGateway, Code: "TRANSFER_RELAY_FAILED", Message: "Transfer could not be relayed"}
+
+	case errors.Is(err, ports.ErrInvalidRelaySignature):
+		return HTTPError{Status: http.StatusUnauthorized, Code: "RELAY_UNAUTHORIZED", Message: "Unauthorized"}
+
 	// ======================
 	// API
 	// ======================
+	case errors.Is(err, ports.ErrInvalidQuery):
+		return HTTPError{Status: http.StatusBadRequest, Code: "INVALID_QUERY", Message: "Invalid sort, filter or page size"}
+
+	case errors.Is(err, ports.ErrInvalidCursor):
+		return HTTPError{Status: http.StatusBadRequest, Code: "INVALID_CURSOR", Message: "Invalid page cursor"}
+
 	case errors.Is(err, apierrors.ErrInvalidRequest):
 		return HTTPError{Status: http.StatusBadRequest, Code: "INVALID_REQUEST", Message: "Invalid request payload"}
 
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+func Metrics(reg *metrics.Registry) gin.HandlerFunc {
+	duration := reg.Histogram("file_share_http_request_duration_seconds", "HTTP request latency by route template.", metrics.DefBuckets, "method", "route", "status")
+	inFlight := reg.Gauge("file_share_http_requests_in_flight", "HTTP requests currently being served.")
+
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		inFlight.Add(1)
+		defer inFlight.Add(-1)
+
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		duration.Observe(time.Since(start).Seconds(), ctx.Request.Method, route, strconv.Itoa(ctx.Writer.Status()))
+	}
+}
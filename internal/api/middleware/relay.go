@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RelayAuthMiddleware(verify func(r *http.Request) error) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if err := verify(ctx.Request); err != nil {
+			httpErr := MapErrors(err)
+			ctx.AbortWithStatusJSON(httpErr.Status, gin.H{
+				"code":    httpErr.Code,
+				"message": httpErr.Message,
+			})
+			return
+		}
+
+		ctx.Next()
+	}
+}
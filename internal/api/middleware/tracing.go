@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Miklakapi/go-file-share/internal/logging"
+	"github.com/Miklakapi/go-file-share/internal/tracing"
+	"github.com/gin-gonic/gin"
+)
+
+// Tracing starts the server span for a request, continuing an incoming W3C
+// trace context when present.
+func Tracing() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		route := ctx.FullPath()
+		name := ctx.Request.Method + " " + route
+		if route == "" {
+			name = ctx.Request.Method
+		}
+
+		reqCtx := tracing.Extract(ctx.Request.Context(), ctx.Request.Header)
+		reqCtx, span := tracing.StartKind(reqCtx, name, tracing.KindServer,
+			tracing.String("http.request.method", ctx.Request.Method),
+			tracing.String("http.route", route),
+			tracing.String("url.path", ctx.Request.URL.Path),
+			tracing.String("client.address", ctx.ClientIP()),
+			tracing.String("request.id", logging.RequestID(reqCtx)),
+		)
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttributes(
+			tracing.Int("http.response.status_code", status),
+			tracing.Int("http.response.body.size", ctx.Writer.Size()),
+		)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(tracing.StatusError, http.StatusText(status))
+		}
+		span.End()
+	}
+}
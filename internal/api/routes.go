@@ -7,15 +7,26 @@ import (
 )
 
 type ControllerBag struct {
-	HealthController *controllers.HealthController
-	HtmlController   *controllers.HtmlController
-	AuthController   *controllers.AuthController
-	RoomsController  *controllers.RoomsController
-	FilesController  *controllers.FilesController
-	SSEController    *controllers.SSEController
-	DirectController *controllers.DirectController
-	AuthMiddleware   gin.HandlerFunc
-	ErrorMiddleware  gin.HandlerFunc
+	HealthController    *controllers.HealthController
+	HtmlController      *controllers.HtmlController
+	AuthController      *controllers.AuthController
+	RoomsController     *controllers.RoomsController
+	FilesController     *controllers.FilesController
+	FoldersController   *controllers.FoldersController
+	MessagesController  *controllers.MessagesController
+	SSEController       *controllers.SSEController
+	DirectController    *controllers.DirectController
+	BroadcastController *controllers.BroadcastController
+	SignalingController *controllers.SignalingController
+	RelayController     *controllers.DirectController
+	MetricsController   *controllers.MetricsController
+	AdminController     *controllers.AdminController
+	AuthMiddleware      gin.HandlerFunc
+	ErrorMiddleware     gin.HandlerFunc
+	RelayMiddleware     gin.HandlerFunc
+	AdminMiddleware     gin.HandlerFunc
+	// RoomBundles serves room export and import.
+	RoomBundles bool
 }
 
 func RegisterRoutes(router *gin.Engine, cB *ControllerBag) {
@@ -26,18 +37,56 @@ func RegisterRoutes(router *gin.Engine, cB *ControllerBag) {
 	securedRouter.StaticFile("/favicon.ico", cB.HtmlController.Favicon())
 	router.NoRoute(middleware.SecureHeaders, cB.HtmlController.SPAFallback)
 
+	if cB.MetricsController != nil {
+		router.GET("/metrics", cB.MetricsController.Metrics)
+	}
+
 	api := securedRouter.Group("/api/v1", cB.ErrorMiddleware)
 	api.GET("/ping", cB.HealthController.Ping)
-	api.GET("/health", cB.HealthController.Health)
+	api.GET("/health", cB.HealthController.Ready)
+	api.GET("/health/live", cB.HealthController.Live)
+	api.GET("/health/ready", cB.HealthController.Ready)
 	api.GET("/sse", cB.SSEController.SSE)
 
 	direct := api.Group("/direct/:code")
+	direct.PUT("", cB.DirectController.UploadRaw)
 	direct.GET("/download", cB.DirectController.DownloadStream)
 	direct.POST("/upload", cB.DirectController.UploadStream)
 
+	direct.GET("/signal", cB.SignalingController.Stream)
+	direct.POST("/signal", cB.SignalingController.Send)
+
+	broadcast := direct.Group("/broadcast")
+	broadcast.GET("", cB.BroadcastController.Receive)
+	broadcast.PUT("", cB.BroadcastController.Send)
+	broadcast.POST("/start", cB.BroadcastController.Start)
+	broadcast.GET("/receivers", cB.BroadcastController.Receivers)
+
+	if cB.RelayController != nil {
+		internal := router.Group("/internal/v1", cB.RelayMiddleware, cB.ErrorMiddleware)
+		internal.PUT("/direct/:code", cB.RelayController.UploadRaw)
+	}
+
+	if cB.AdminController != nil {
+		admin := api.Group("/admin", cB.AdminMiddleware)
+		admin.GET("/stats", cB.AdminController.Stats)
+		admin.GET("/rooms", cB.AdminController.Rooms)
+		admin.GET("/audit", cB.AdminController.Audit)
+
+		adminRoom := admin.Group("/rooms/:roomID", middleware.SetRoomIDParam())
+		adminRoom.GET("", cB.AdminController.Room)
+		adminRoom.DELETE("", cB.AdminController.DeleteRoom)
+		adminRoom.DELETE("/tokens", cB.AdminController.RevokeTokens)
+		adminRoom.GET("/files", cB.AdminController.Files)
+		adminRoom.DELETE("/files/:fileID", middleware.SetFileIDParam(), cB.AdminController.DeleteFile)
+	}
+
 	rooms := api.Group("/rooms")
 	rooms.GET("", cB.RoomsController.Get)
 	rooms.POST("", cB.RoomsController.Create)
+	if cB.RoomBundles {
+		rooms.POST("/import", cB.RoomsController.Import)
+	}
 
 	room := rooms.Group("/:roomID", middleware.SetRoomIDParam())
 	room.GET("", cB.RoomsController.GetByUUID)
@@ -46,6 +95,9 @@ func RegisterRoutes(router *gin.Engine, cB *ControllerBag) {
 	securedRooms := api.Group("/rooms/:roomID", middleware.SetRoomIDParam(), cB.AuthMiddleware)
 	securedRooms.DELETE("", cB.RoomsController.Delete)
 	securedRooms.GET("/access", cB.RoomsController.CheckAccess)
+	if cB.RoomBundles {
+		securedRooms.GET("/export", cB.RoomsController.Export)
+	}
 	securedRooms.POST("/logout", cB.AuthController.Logout)
 
 	files := securedRooms.Group("/files")
@@ -55,5 +107,27 @@ func RegisterRoutes(router *gin.Engine, cB *ControllerBag) {
 	file := files.Group("/:fileID", middleware.SetFileIDParam())
 	file.GET("", cB.FilesController.GetByUUID)
 	file.GET("/download", cB.FilesController.Download)
+	file.GET("/versions", cB.FilesController.Versions)
+	file.GET("/versions/:version/download", cB.FilesController.DownloadVersion)
+	file.GET("/preview", cB.FilesController.Preview)
+	file.GET("/thumbnail", cB.FilesController.Thumbnail)
+	file.GET("/entries", cB.FilesController.Entries)
+	file.GET("/entries/*path", cB.FilesController.Entry)
+	file.POST("/extract", cB.FilesController.Extract)
+	file.POST("/copy", cB.FilesController.Copy)
+	file.POST("/move", cB.FilesController.Move)
+	file.PATCH("", cB.FilesController.Update)
 	file.DELETE("", cB.FilesController.Delete)
+
+	folders := securedRooms.Group("/folders")
+	folders.GET("", cB.FoldersController.Get)
+	folders.POST("", cB.FoldersController.Create)
+	folders.DELETE("", cB.FoldersController.Delete)
+	folders.POST("/rename", cB.FoldersController.Rename)
+	folders.POST("/move", cB.FoldersController.Move)
+	folders.GET("/download", cB.FoldersController.Download)
+
+	messages := securedRooms.Group("/messages")
+	messages.GET("", cB.MessagesController.Get)
+	messages.POST("", cB.MessagesController.Create)
 }
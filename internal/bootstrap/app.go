@@ -0,0 +1,198 @@
+package bootstrap
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Miklakapi/go-file-share/internal/api"
+	"github.com/Miklakapi/go-file-share/internal/api/controllers"
+	"github.com/Miklakapi/go-file-share/internal/api/middleware"
+	"github.com/Miklakapi/go-file-share/internal/config"
+	"github.com/Miklakapi/go-file-share/internal/file-share/adapters/archive"
+	"github.com/Miklakapi/go-file-share/internal/file-share/adapters/audited"
+	directtransfer "github.com/Miklakapi/go-file-share/internal/file-share/adapters/direct-transfer"
+	clustertransfer "github.com/Miklakapi/go-file-share/internal/file-share/adapters/direct-transfer/cluster-transfer"
+	eventbus "github.com/Miklakapi/go-file-share/internal/file-share/adapters/event-bus"
+	filestore "github.com/Miklakapi/go-file-share/internal/file-share/adapters/file-store"
+	imagesanitizer "github.com/Miklakapi/go-file-share/internal/file-share/adapters/image-sanitizer"
+	"github.com/Miklakapi/go-file-share/internal/file-share/adapters/instrumented"
+	roombundle "github.com/Miklakapi/go-file-share/internal/file-share/adapters/room-bundle"
+	"github.com/Miklakapi/go-file-share/internal/file-share/adapters/security"
+	"github.com/Miklakapi/go-file-share/internal/file-share/adapters/thumbnail"
+	"github.com/Miklakapi/go-file-share/internal/file-share/adapters/traced"
+	fileShare "github.com/Miklakapi/go-file-share/internal/file-share/application"
+	fileShareDomain "github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/jobs"
+	"github.com/Miklakapi/go-file-share/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// App is the wired application: decorated adapters, the service and the
+// background job. It is shared by every subcommand; only serve builds the
+// HTTP handler.
+type App struct {
+	Config   config.Config
+	Backend  *Backend
+	Registry *metrics.Registry
+
+	// Loader reloads the configuration on SIGHUP; nil disables reloading.
+	Loader *config.Loader
+	// Args are the positional arguments of the subcommand.
+	Args []string
+
+	Rooms      ports.RoomRepository
+	FileStore  ports.FileStore
+	EventBus   ports.EventPublisherSubscriber
+	Tokens     ports.TokenService
+	Audit      ports.AuditSink
+	Service    *fileShare.Service
+	CleanupJob *jobs.RoomCleanupJob
+	// AuditJob prunes the audit log past AUDIT_RETENTION.
+	AuditJob *jobs.AuditRetentionJob
+
+	health *controllers.HealthController
+	// scannerHealth probes the content scanner when it supports it.
+	scannerHealth ports.HealthChecker
+}
+
+func NewApp(cfg config.Config, backend *Backend, audit ports.AuditSink, scanner ports.ContentScanner) *App {
+	registry := metrics.NewRegistry()
+	metrics.RegisterRuntime(registry)
+
+	roomRepo := instrumented.NewRoomRepository(traced.NewRoomRepository(backend.Rooms), registry)
+	eventBus := instrumented.NewEventBus(eventbus.New(), registry)
+	fileStore := instrumented.NewFileStore(traced.NewFileStore(filestore.DiskStore{}), registry)
+	hasher := instrumented.NewPasswordHasher(traced.NewPasswordHasher(security.BcryptHasher{Cost: 12}), registry)
+	tokenService := security.NewJwtService(cfg.JWTSecret)
+	fileShareService := fileShare.NewService(roomRepo, fileStore, hasher, tokenService, audit, policy(cfg))
+	var scannerHealth ports.HealthChecker
+	if scanner != nil {
+		scannerHealth, _ = scanner.(ports.HealthChecker)
+		fileShareService.UseScanner(instrumented.NewContentScanner(traced.NewContentScanner(scanner), registry), cfg.ScannerFailOpen)
+	}
+	fileShareService.UseSanitizer(imagesanitizer.New())
+	fileShareService.UseArchives(archive.New(int64(cfg.ArchiveMaxExpandedMegabytes) << 20))
+	if cfg.RoomBundles {
+		bundleSecret := cfg.BundleSecret
+		if len(bundleSecret) == 0 {
+			bundleSecret = cfg.JWTSecret
+		}
+		fileShareService.UseBundles(roombundle.New(bundleSecret))
+	}
+	if cfg.ThumbnailWorkers > 0 {
+		fileShareService.UseThumbnailer(thumbnail.New(cfg.ThumbnailWorkers, cfg.ThumbnailMaxMegapixels*1_000_000))
+	}
+	roomCleanupJob := jobs.New(fileShareService, eventBus, cfg.CleanupInterval)
+	roomCleanupJob.Observe(instrumented.NewCleanupObserver(registry))
+
+	return &App{
+		Config:     cfg,
+		Backend:    backend,
+		Registry:   registry,
+		Rooms:      roomRepo,
+		FileStore:  fileStore,
+		EventBus:   eventBus,
+		Tokens:     tokenService,
+		Audit:      audit,
+		Service:    fileShareService,
+		CleanupJob: roomCleanupJob,
+		AuditJob:   jobs.NewAuditRetention(audit, cfg.AuditRetention),
+
+		scannerHealth: scannerHealth,
+	}
+}
+
+// Handler builds the gin engine with every route. appCtx bounds long-lived
+// streams such as SSE.
+func (a *App) Handler(appCtx context.Context) http.Handler {
+	cfg := a.Config
+
+	var directTransfer ports.DirectTransfer = directtransfer.New()
+	var relayController *controllers.DirectController
+	var relayMiddleware gin.HandlerFunc
+	if a.Backend.Redis != nil && cfg.NodeURL != "" {
+		clusterTransfer := clustertransfer.New(directTransfer, a.Backend.Redis, cfg.NodeURL, cfg.ClusterSecret)
+		directTransfer = clusterTransfer
+		relayController = controllers.NewDirectController(clusterTransfer.Relay())
+		relayMiddleware = middleware.RelayAuthMiddleware(clusterTransfer.VerifyRelay)
+	}
+	directTransfer = instrumented.NewDirectTransfer(audited.NewDirectTransfer(directTransfer, a.Audit), a.Registry)
+	directBroadcast := instrumented.NewDirectBroadcast(audited.NewDirectBroadcast(directtransfer.NewBroadcaster(), a.Audit), a.Registry)
+	signaling := directtransfer.NewSignaling()
+
+	checkers := []ports.HealthChecker{
+		filestore.NewDiskHealth(cfg.UploadDir, uint64(cfg.HealthMinFreeMegabytes)*1024*1024),
+		a.CleanupJob,
+	}
+	if a.Backend.Health != nil {
+		checkers = append(checkers, a.Backend.Health)
+	}
+	if a.scannerHealth != nil {
+		checkers = append(checkers, a.scannerHealth)
+	}
+	a.health = controllers.NewHealthController(checkers...)
+
+	var adminController *controllers.AdminController
+	if cfg.AdminKey != "" {
+		adminController = controllers.NewAdminController(a.Service, a.EventBus)
+	}
+
+	gin.SetMode(cfg.Mode)
+	engine := gin.New()
+	// Client IPs end up in the audit log, so forwarding headers are only
+	// believed from configured proxies. The list was validated with the
+	// config.
+	_ = engine.SetTrustedProxies(config.List(cfg.TrustedProxies))
+	engine.Use(middleware.RequestID(), middleware.Actor(), middleware.Tracing(), middleware.Logger(), middleware.Recovery(), middleware.Metrics(a.Registry))
+
+	api.RegisterRoutes(engine, &api.ControllerBag{
+		HealthController:    a.health,
+		HtmlController:      controllers.NewHtmlController(cfg.PublicDir),
+		AuthController:      controllers.NewAuthController(a.Service),
+		RoomsController:     controllers.NewRoomsController(a.Service, a.EventBus),
+		FilesController:     controllers.NewFilesController(a.Service, a.EventBus),
+		FoldersController:   controllers.NewFoldersController(a.Service),
+		MessagesController:  controllers.NewMessagesController(a.Service, a.EventBus),
+		SSEController:       controllers.NewSSEController(appCtx, a.EventBus),
+		DirectController:    controllers.NewDirectController(directTransfer),
+		BroadcastController: controllers.NewBroadcastController(directBroadcast, ports.BroadcastPolicy(cfg.BroadcastPolicy)),
+		SignalingController: controllers.NewSignalingController(appCtx, signaling),
+		RelayController:     relayController,
+		MetricsController:   controllers.NewMetricsController(a.Registry, cfg.MetricsToken),
+		AdminController:     adminController,
+		AuthMiddleware:      middleware.AuthMiddleware(a.Tokens),
+		ErrorMiddleware:     middleware.ErrorMiddleware(),
+		RelayMiddleware:     relayMiddleware,
+		AdminMiddleware:     middleware.AdminAuthMiddleware(cfg.AdminKey),
+		RoomBundles:         cfg.RoomBundles,
+	})
+
+	return engine
+}
+
+func policy(cfg config.Config) fileShareDomain.Policy {
+	return fileShareDomain.NewPolicy(
+		cfg.DefaultRoomTTL,
+		cfg.TokenTTL,
+		cfg.MaxFiles,
+		cfg.MaxMessages,
+		cfg.MaxRoomBytes,
+		cfg.MaxRoomLifespan,
+		cfg.MaxTokenLifespan,
+		cfg.UploadDir,
+		contentRules(cfg),
+	)
+}
+
+// contentRules builds the server-wide upload rules. Config.Validate has
+// already rejected malformed types.
+func contentRules(cfg config.Config) fileShareDomain.ContentRules {
+	rules, _ := fileShareDomain.NewContentRules(
+		config.List(cfg.UploadAllowTypes),
+		config.List(cfg.UploadDenyTypes),
+		config.List(cfg.UploadAllowExtensions),
+		config.List(cfg.UploadDenyExtensions),
+	)
+	return rules
+}
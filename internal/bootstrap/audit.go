@@ -0,0 +1,43 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Miklakapi/go-file-share/internal/config"
+	auditsink "github.com/Miklakapi/go-file-share/internal/file-share/adapters/audit-sink"
+	"github.com/Miklakapi/go-file-share/internal/file-share/adapters/db"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+)
+
+const (
+	AuditSinkNone   = "none"
+	AuditSinkFile   = "file"
+	AuditSinkSqlite = "sqlite"
+)
+
+// OpenAuditSink opens the audit log selected by AUDIT_SINK. The SQLite sink
+// uses its own database so it survives wiping the room backend.
+func OpenAuditSink(ctx context.Context, cfg config.Config) (ports.AuditSink, error) {
+	switch cfg.AuditSink {
+	case AuditSinkNone:
+		return auditsink.Nop{}, nil
+
+	case AuditSinkFile:
+		return auditsink.NewFileSink(cfg.AuditDir)
+
+	case AuditSinkSqlite:
+		sqliteDb, err := db.NewSqlite(cfg.AuditSqlitePath)
+		if err != nil {
+			return nil, err
+		}
+		sink, err := auditsink.NewSqliteSink(ctx, sqliteDb.Conn)
+		if err != nil {
+			_ = sqliteDb.Conn.Close()
+			return nil, err
+		}
+		return sink, nil
+	}
+
+	return nil, fmt.Errorf("unknown audit sink %q", cfg.AuditSink)
+}
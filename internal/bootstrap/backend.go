@@ -0,0 +1,111 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Miklakapi/go-file-share/internal/config"
+	"github.com/Miklakapi/go-file-share/internal/file-share/adapters/db"
+	memoryrepository "github.com/Miklakapi/go-file-share/internal/file-share/adapters/room-repository/memory-repository"
+	redisrepository "github.com/Miklakapi/go-file-share/internal/file-share/adapters/room-repository/redis-repository"
+	sqliterepository "github.com/Miklakapi/go-file-share/internal/file-share/adapters/room-repository/sqlite-repository"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	BackendMemory = "memory"
+	BackendSqlite = "sqlite"
+	BackendRedis  = "redis"
+)
+
+// Backend is the storage selected by BACKEND together with the maintenance
+// hooks the subcommands need.
+type Backend struct {
+	Name  string
+	Rooms ports.RoomRepository
+	// Redis is set for the redis backend, which also coordinates cluster transfers.
+	Redis *redis.Client
+	// Health probes the storage; nil for the in-memory backend.
+	Health ports.HealthChecker
+	// WipeOnStart tells serve to drop all rooms and stored files before
+	// listening. Redis is shared between nodes, so a starting node must not
+	// wipe it or the files it points to.
+	WipeOnStart bool
+
+	migrate func(ctx context.Context) error
+	wipe    func(ctx context.Context) error
+	close   func() error
+}
+
+func OpenBackend(ctx context.Context, cfg config.Config) (*Backend, error) {
+	switch cfg.Backend {
+	case BackendMemory:
+		return &Backend{
+			Name:        BackendMemory,
+			Rooms:       memoryrepository.New(),
+			WipeOnStart: true,
+		}, nil
+
+	case BackendSqlite:
+		sqliteDb, err := db.NewSqlite(cfg.SqlitePath)
+		if err != nil {
+			return nil, err
+		}
+		repo := sqliterepository.New(ctx, sqliteDb.Conn)
+		return &Backend{
+			Name:        BackendSqlite,
+			Rooms:       repo,
+			Health:      sqliteDb,
+			WipeOnStart: true,
+			migrate: func(ctx context.Context) error {
+				return sqliterepository.MakeMigrations(ctx, sqliteDb.Conn, cfg.SqliteMigrationsDir)
+			},
+			wipe:  repo.WipeAll,
+			close: sqliteDb.Conn.Close,
+		}, nil
+
+	case BackendRedis:
+		redisDb, err := db.NewRedis(cfg.RedisPath)
+		if err != nil {
+			return nil, err
+		}
+		repo := redisrepository.New(redisDb.Conn)
+		return &Backend{
+			Name:   BackendRedis,
+			Rooms:  repo,
+			Redis:  redisDb.Conn,
+			Health: redisDb,
+			// Redis has no schema, but rooms written before the listing
+			// indexes existed need indexing.
+			migrate: repo.Reindex,
+			wipe:    repo.WipeAll,
+			close:   redisDb.Conn.Close,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown backend %q", cfg.Backend)
+}
+
+// Migrate brings the schema up to date; backends without a schema do nothing.
+func (b *Backend) Migrate(ctx context.Context) error {
+	if b.migrate == nil {
+		return nil
+	}
+	return b.migrate(ctx)
+}
+
+// Wipe removes every room.
+func (b *Backend) Wipe(ctx context.Context) error {
+	if b.wipe == nil {
+		return nil
+	}
+	return b.wipe(ctx)
+}
+
+func (b *Backend) Close() error {
+	if b.close == nil {
+		return nil
+	}
+	return b.close()
+}
@@ -0,0 +1,175 @@
+package bootstrap
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/Miklakapi/go-file-share/internal/config"
+	"github.com/Miklakapi/go-file-share/internal/logging"
+	"github.com/google/uuid"
+)
+
+type command struct {
+	name string
+	// args names the positional arguments, which follow the flags.
+	args string
+	// bundles turns on room_bundles, so the command fails without a
+	// configured signing key.
+	bundles bool
+	help    string
+	run     func(ctx context.Context, app *App) error
+}
+
+var commands = []command{
+	{"serve", "", false, "start the HTTP server (default)", Serve},
+	{"migrate", "", false, "apply pending database migrations", Migrate},
+	{"wipe", "", false, "delete every room and stored file", Wipe},
+	{"gc", "", false, "remove expired rooms, unreferenced files and old audit records once", GC},
+	{"export", "<room-id> <file>", true, "write a room as a signed bundle to a new file", Export},
+	{"import", "<file>", true, "recreate a room from a bundle, reading its password from standard input", Import},
+}
+
+// Run executes a subcommand and returns the process exit code. Flags follow
+// the subcommand, ahead of its arguments. A non-empty backend pins BACKEND
+// above every other source, which is how the legacy per-backend binaries
+// keep working.
+func Run(args []string, backend string) int {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == name {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		usage(name)
+		if name == "help" {
+			return 0
+		}
+		return 2
+	}
+
+	loader, err := config.NewLoader("file-share "+name, args, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if want := strings.Fields(cmd.args); len(loader.Args) != len(want) {
+		fmt.Fprintf(os.Stderr, "usage: file-share %s [flags] %s\n", cmd.name, cmd.args)
+		return 2
+	}
+	if backend != "" {
+		loader.Set("backend", backend)
+	}
+	if cmd.bundles {
+		loader.Set("room_bundles", "true")
+	}
+
+	cfg, err := loader.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if loader.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+	if err := prepare(&cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdown, err := SetupObservability(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer shutdown()
+
+	store, err := OpenBackend(ctx, cfg)
+	if err != nil {
+		slog.Error("startup error", slog.Any("error", err))
+		return 1
+	}
+	defer store.Close()
+
+	audit, err := OpenAuditSink(ctx, cfg)
+	if err != nil {
+		slog.Error("startup error", slog.Any("error", err))
+		return 1
+	}
+	defer audit.Close()
+
+	scanner, err := OpenScanner(cfg)
+	if err != nil {
+		slog.Error("startup error", slog.Any("error", err))
+		return 1
+	}
+
+	if cmd.name != "serve" {
+		ctx = logging.WithRequestID(ctx, cmd.name+"-"+uuid.NewString())
+	}
+	app := NewApp(cfg, store, audit, scanner)
+	app.Loader = loader
+	app.Args = loader.Args
+	if err := cmd.run(ctx, app); err != nil {
+		slog.ErrorContext(ctx, cmd.name+" failed", slog.Any("error", err))
+		return 1
+	}
+	return 0
+}
+
+// prepare creates the directories the configuration points at and generates
+// a JWT secret when none is configured.
+func prepare(cfg *config.Config) error {
+	if err := os.MkdirAll(cfg.UploadDir, 0755); err != nil {
+		return fmt.Errorf("cannot create upload dir: %w", err)
+	}
+	if err := os.MkdirAll(cfg.PublicDir, 0755); err != nil {
+		return fmt.Errorf("cannot create public dir: %w", err)
+	}
+
+	if len(cfg.JWTSecret) == 0 {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return fmt.Errorf("cannot generate jwt secret: %w", err)
+		}
+		cfg.JWTSecret = secret
+	}
+	return nil
+}
+
+func usage(name string) {
+	if name != "help" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	}
+	fmt.Fprintln(os.Stderr, "Usage: file-share [command] [flags] [arguments]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-24s %s\n", strings.TrimSpace(c.name+" "+c.args), c.help)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun file-share <command> -h to list the flags. Settings are read from")
+	fmt.Fprintln(os.Stderr, "defaults, a YAML or TOML file (--config or CONFIG_FILE), the environment")
+	fmt.Fprintln(os.Stderr, "and flags, later sources winning.")
+}
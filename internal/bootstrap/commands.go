@@ -0,0 +1,196 @@
+package bootstrap
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/google/uuid"
+)
+
+const orphanGrace = 10 * time.Minute
+
+// Serve prepares storage, starts the cleanup job and serves HTTP until ctx is
+// cancelled. SIGHUP reloads the runtime-safe settings.
+func Serve(ctx context.Context, app *App) error {
+	cfg := app.Config
+
+	if err := app.Backend.Migrate(ctx); err != nil {
+		return fmt.Errorf("migration error: %w", err)
+	}
+	if app.Backend.WipeOnStart {
+		if err := app.Backend.Wipe(ctx); err != nil {
+			return fmt.Errorf("wipe error: %w", err)
+		}
+		if err := app.FileStore.ClearAll(ctx, cfg.UploadDir); err != nil {
+			return fmt.Errorf("file error: %w", err)
+		}
+	}
+
+	closeJob, err := app.CleanupJob.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("file error: %w", err)
+	}
+	defer closeJob()
+	defer app.AuditJob.Run(ctx)()
+
+	watchReload(ctx, app)
+
+	srv := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           app.Handler(ctx),
+		ReadTimeout:       10 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	listenErr := make(chan error, 1)
+	go func() {
+		slog.Info("HTTP server started", slog.String("addr", srv.Addr), slog.String("backend", app.Backend.Name))
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			listenErr <- err
+		}
+	}()
+
+	select {
+	case err := <-listenErr:
+		return fmt.Errorf("listen error: %w", err)
+	case <-ctx.Done():
+	}
+	slog.Info("shutdown signal received")
+
+	if app.health != nil {
+		app.health.Drain()
+	}
+	if cfg.ShutdownDelay > 0 {
+		slog.Info("draining before shutdown", slog.Duration("delay", cfg.ShutdownDelay))
+		time.Sleep(cfg.ShutdownDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("graceful shutdown failed, forcing close", slog.Any("error", err))
+
+		if err := srv.Close(); err != nil {
+			slog.Error("forced server close failed", slog.Any("error", err))
+		}
+	}
+
+	slog.Info("server stopped gracefully")
+	return nil
+}
+
+func Migrate(ctx context.Context, app *App) error {
+	if err := app.Backend.Migrate(ctx); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "migrations up to date", slog.String("backend", app.Backend.Name))
+	return nil
+}
+
+// Wipe drops every room and every stored file.
+func Wipe(ctx context.Context, app *App) error {
+	if err := app.Backend.Migrate(ctx); err != nil {
+		return err
+	}
+	if err := app.Backend.Wipe(ctx); err != nil {
+		return err
+	}
+	if err := app.FileStore.ClearAll(ctx, app.Config.UploadDir); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "storage wiped", slog.String("backend", app.Backend.Name))
+	return nil
+}
+
+// GC runs one expired room cleanup pass, removes files no room references and
+// prunes the audit log.
+func GC(ctx context.Context, app *App) error {
+	if err := app.Backend.Migrate(ctx); err != nil {
+		return err
+	}
+
+	rooms, err := app.Service.CleanupExpired(ctx)
+	if err != nil {
+		return err
+	}
+	orphans, err := app.Service.RemoveOrphanFiles(ctx, orphanGrace)
+	if err != nil {
+		return err
+	}
+	audits, err := app.AuditJob.Prune(ctx)
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "garbage collected", slog.Int("rooms", len(rooms)), slog.Int("orphan_files", orphans), slog.Int("audit_records", audits))
+	return nil
+}
+
+// Export writes a room as a signed bundle to a new file. Unlike the HTTP
+// export it needs no token and keeps files with a download limit.
+func Export(ctx context.Context, app *App) error {
+	id, err := uuid.Parse(app.Args[0])
+	if err != nil {
+		return fmt.Errorf("invalid room id %q", app.Args[0])
+	}
+	if err := app.Backend.Migrate(ctx); err != nil {
+		return err
+	}
+
+	path := app.Args[1]
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	ctx = ports.ContextWithActor(ctx, ports.Actor{Name: ports.AuditActorAdmin})
+	_, rc, err := app.Service.AdminExportRoom(ctx, id)
+	if err == nil {
+		_, err = io.Copy(f, rc)
+		_ = rc.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return err
+	}
+	slog.InfoContext(ctx, "room exported", slog.String("room_id", id.String()), slog.String("file", path))
+	return nil
+}
+
+// Import recreates the room in a bundle; its new ID is logged. The password
+// is the first line of standard input, so it stays out of the process list;
+// the room lives for ROOM_TTL.
+func Import(ctx context.Context, app *App) error {
+	f, err := os.Open(app.Args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("cannot read password: %w", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+
+	if err := app.Backend.Migrate(ctx); err != nil {
+		return err
+	}
+
+	ctx = ports.ContextWithActor(ctx, ports.Actor{Name: ports.AuditActorAdmin})
+	_, _, err = app.Service.ImportRoom(ctx, f, password, 0)
+	return err
+}
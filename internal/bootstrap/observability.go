@@ -0,0 +1,57 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/config"
+	"github.com/Miklakapi/go-file-share/internal/logging"
+	"github.com/Miklakapi/go-file-share/internal/tracing"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupObservability installs the process logger and tracer. The returned
+// function flushes pending spans and closes the log file.
+func SetupObservability(cfg config.Config) (func(), error) {
+	logger, logCloser, err := logging.New(logging.Options{
+		Format:     cfg.LogFormat,
+		Level:      cfg.LogLevel,
+		File:       cfg.LogFile,
+		MaxSize:    cfg.LogMaxBytes,
+		MaxAge:     cfg.LogMaxAge,
+		MaxBackups: cfg.LogMaxBackups,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot set up logging: %w", err)
+	}
+	slog.SetDefault(logger)
+
+	gin.DefaultWriter = logging.Writer(logger, slog.LevelDebug)
+	gin.DefaultErrorWriter = logging.Writer(logger, slog.LevelError)
+
+	tracer, err := tracing.New(tracing.Options{
+		Exporter: cfg.TraceExporter,
+		Service:  cfg.TraceServiceName,
+		Endpoint: cfg.TraceOTLPEndpoint,
+		Headers:  cfg.TraceOTLPHeaders,
+		File:     cfg.TraceFile,
+	})
+	if err != nil {
+		_ = logCloser.Close()
+		return nil, fmt.Errorf("cannot set up tracing: %w", err)
+	}
+	if tracer != nil {
+		tracing.SetDefault(tracer)
+	}
+
+	return func() {
+		if tracer != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = tracer.Shutdown(ctx)
+		}
+		_ = logCloser.Close()
+	}, nil
+}
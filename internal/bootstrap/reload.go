@@ -0,0 +1,68 @@
+package bootstrap
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Miklakapi/go-file-share/internal/config"
+	"github.com/Miklakapi/go-file-share/internal/logging"
+	"github.com/google/uuid"
+)
+
+// watchReload re-reads the configuration on every SIGHUP until ctx is done
+// and applies the settings that are safe to change at runtime: the log level
+// and the room limits and TTLs. Anything else is reported as needing a restart.
+func watchReload(ctx context.Context, app *App) {
+	if app.Loader == nil {
+		return
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hup)
+
+		cur := app.Config
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				cur = reload(logging.WithRequestID(ctx, "reload-"+uuid.NewString()), app, cur)
+			}
+		}
+	}()
+}
+
+func reload(ctx context.Context, app *App, cur config.Config) config.Config {
+	next, err := app.Loader.Load()
+	if err != nil {
+		slog.ErrorContext(ctx, "config reload failed, keeping current settings", slog.Any("error", err))
+		return cur
+	}
+	if len(next.JWTSecret) == 0 {
+		// The secret was generated at startup; keep it so tokens stay valid.
+		next.JWTSecret = cur.JWTSecret
+	}
+
+	cur, changed, restart := cur.Reload(next)
+	if len(restart) > 0 {
+		slog.WarnContext(ctx, "config changes need a restart", slog.Any("keys", restart))
+	}
+	if len(changed) == 0 {
+		slog.InfoContext(ctx, "config reloaded, nothing to apply")
+		return cur
+	}
+
+	if err := logging.SetLevel(cur.LogLevel); err != nil {
+		slog.ErrorContext(ctx, "cannot apply log level", slog.Any("error", err))
+	}
+	app.Service.SetPolicy(policy(cur))
+
+	slog.InfoContext(ctx, "config reloaded", slog.Any("changed", changed))
+	return cur
+}
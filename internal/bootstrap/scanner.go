@@ -0,0 +1,30 @@
+package bootstrap
+
+import (
+	"fmt"
+
+	"github.com/Miklakapi/go-file-share/internal/config"
+	contentscanner "github.com/Miklakapi/go-file-share/internal/file-share/adapters/content-scanner"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+)
+
+const (
+	ScannerNone  = "none"
+	ScannerClamd = "clamd"
+	ScannerExec  = "exec"
+)
+
+// OpenScanner builds the upload scanner selected by SCANNER; nil when
+// scanning is disabled.
+func OpenScanner(cfg config.Config) (ports.ContentScanner, error) {
+	switch cfg.Scanner {
+	case ScannerNone:
+		return nil, nil
+	case ScannerClamd:
+		return contentscanner.NewClamd(cfg.ScannerAddress, cfg.ScannerTimeout)
+	case ScannerExec:
+		return contentscanner.NewExec(cfg.ScannerCommand, cfg.ScannerTimeout)
+	}
+
+	return nil, fmt.Errorf("unknown scanner %q", cfg.Scanner)
+}
@@ -1,135 +1,276 @@
 package config
 
 import (
-	"crypto/rand"
 	"fmt"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// Config is the typed configuration schema. Every tagged field can be set, in
+// increasing priority, by its default, a YAML or TOML config file (key), an
+// environment variable (env) and a command line flag (the key with dashes).
+// Fields marked reload are re-applied on SIGHUP without a restart.
 type Config struct {
-	Mode string
-	Port string
+	Mode string `key:"mode" env:"MODE" default:"release" usage:"gin mode: debug, release or test"`
+	Port string `key:"port" env:"PORT" default:"8080" usage:"HTTP listen port"`
 
-	UploadDir string
-	PublicDir string
+	TrustedProxies string `key:"trusted_proxies" env:"TRUSTED_PROXIES" usage:"comma separated proxy IPs or CIDRs whose X-Forwarded-For header names the client; empty trusts none"`
 
-	DefaultRoomTTL time.Duration
-	TokenTTL       time.Duration
+	LogFormat       string        `key:"log_format" env:"LOG_FORMAT" default:"text" usage:"log format: text or json"`
+	LogLevel        string        `key:"log_level" env:"LOG_LEVEL" default:"info" reload:"true" usage:"log level: debug, info, warn or error"`
+	LogFile         string        `key:"log_file" env:"LOG_FILE" default:"logs.log" usage:"log file, empty logs to stdout only"`
+	LogMaxMegabytes int           `key:"log_max_megabytes" env:"LOG_MAX_MEGABYTES" default:"10" usage:"rotate the log file after this many megabytes, 0 disables"`
+	LogMaxAge       time.Duration `key:"log_max_age" env:"LOG_MAX_AGE" default:"24h" usage:"rotate the log file after this age, 0 disables"`
+	LogMaxBackups   int           `key:"log_max_backups" env:"LOG_MAX_BACKUPS" default:"7" usage:"rotated log files to keep"`
 
-	MaxFiles         int
-	MaxRoomBytes     int64
-	MaxRoomLifespan  time.Duration
-	MaxTokenLifespan time.Duration
-	CleanupInterval  time.Duration
+	UploadDir string `key:"upload_dir" env:"UPLOAD_DIR" default:"./uploads" usage:"directory for uploaded files"`
+	PublicDir string `key:"public_dir" env:"PUBLIC_DIR" default:"./public" usage:"directory served as the web UI"`
 
-	SqlitePath string
-	RedisPath  string
+	DefaultRoomTTL time.Duration `key:"room_ttl" env:"ROOM_TTL" default:"10m" reload:"true" usage:"room lifespan when none is requested"`
+	TokenTTL       time.Duration `key:"token_ttl" env:"TOKEN_TTL" default:"10m" reload:"true" usage:"token lifespan when none is requested"`
 
-	JWTSecret []byte
+	MaxFiles         int           `key:"max_files" env:"MAX_FILES" default:"30" reload:"true" usage:"files allowed per room"`
+	MaxMessages      int           `key:"max_messages" env:"MAX_MESSAGES" default:"200" reload:"true" usage:"messages allowed per room"`
+	MaxRoomMegabytes int           `key:"max_room_megabytes" env:"MAX_ROOM_MEGABYTES" default:"50" reload:"true" usage:"megabytes allowed per room"`
+	MaxRoomLifespan  time.Duration `key:"max_room_lifespan" env:"MAX_ROOM_LIFESPAN" default:"60m" reload:"true" usage:"longest lifespan a room may request"`
+	MaxTokenLifespan time.Duration `key:"max_token_lifespan" env:"MAX_TOKEN_LIFESPAN" default:"60m" reload:"true" usage:"longest lifespan a token may request"`
+	CleanupInterval  time.Duration `key:"cleanup_interval" env:"CLEANUP_INTERVAL" default:"30s" usage:"how often expired rooms are removed"`
+
+	UploadAllowTypes      string `key:"upload_allow_types" env:"UPLOAD_ALLOW_TYPES" reload:"true" usage:"comma separated MIME types uploads must match, e.g. image/*,application/pdf; empty allows all"`
+	UploadDenyTypes       string `key:"upload_deny_types" env:"UPLOAD_DENY_TYPES" reload:"true" usage:"comma separated MIME types uploads must not match"`
+	UploadAllowExtensions string `key:"upload_allow_extensions" env:"UPLOAD_ALLOW_EXTENSIONS" reload:"true" usage:"comma separated file extensions uploads must have; empty allows all"`
+	UploadDenyExtensions  string `key:"upload_deny_extensions" env:"UPLOAD_DENY_EXTENSIONS" reload:"true" usage:"comma separated file extensions uploads must not have"`
+
+	HealthMinFreeMegabytes int           `key:"health_min_free_megabytes" env:"HEALTH_MIN_FREE_MEGABYTES" default:"100" usage:"readiness fails below this much free upload disk space"`
+	ShutdownDelay          time.Duration `key:"shutdown_delay" env:"SHUTDOWN_DELAY" default:"0s" usage:"keep serving with failing readiness this long before shutting down"`
+
+	Backend string `key:"backend" env:"BACKEND" default:"memory" usage:"room storage: memory, sqlite or redis"`
+
+	SqlitePath          string `key:"sqlite_path" env:"SQLITE_PATH" default:"./sqlite.db" usage:"sqlite database file"`
+	SqliteMigrationsDir string `key:"sqlite_migrations_dir" env:"SQLITE_MIGRATIONS_DIR" default:"./sqlite-migrations" usage:"sqlite migrations directory"`
+	RedisPath           string `key:"redis_path" env:"REDIS_PATH" default:"127.0.0.1:6379" usage:"redis address"`
+
+	NodeURL       string `key:"node_url" env:"NODE_URL" usage:"public URL of this node, enables clustering"`
+	ClusterSecret []byte `key:"cluster_secret" env:"CLUSTER_SECRET" secret:"true" usage:"shared secret between cluster nodes"`
+
+	BroadcastPolicy string `key:"broadcast_policy" env:"BROADCAST_POLICY" default:"stall" usage:"slow broadcast receivers: stall or drop"`
+
+	MetricsToken string `key:"metrics_token" env:"METRICS_TOKEN" secret:"true" usage:"bearer token required by /metrics"`
+	AdminKey     string `key:"admin_key" env:"ADMIN_KEY" secret:"true" usage:"bearer key for /api/v1/admin, empty disables the admin API"`
+
+	AuditSink       string        `key:"audit_sink" env:"AUDIT_SINK" default:"file" usage:"audit log storage: none, file or sqlite"`
+	AuditDir        string        `key:"audit_dir" env:"AUDIT_DIR" default:"./audit" usage:"directory for the daily audit files"`
+	AuditSqlitePath string        `key:"audit_sqlite_path" env:"AUDIT_SQLITE_PATH" default:"./audit.db" usage:"sqlite database for the audit log"`
+	AuditRetention  time.Duration `key:"audit_retention" env:"AUDIT_RETENTION" default:"720h" usage:"how long audit records are kept, 0 keeps them forever"`
+
+	Scanner         string        `key:"scanner" env:"SCANNER" default:"none" usage:"upload content scanner: none, clamd or exec"`
+	ScannerAddress  string        `key:"scanner_address" env:"SCANNER_ADDRESS" default:"unix:///var/run/clamav/clamd.ctl" usage:"clamd address: unix:///path, tcp://host:port or host:port"`
+	ScannerCommand  string        `key:"scanner_command" env:"SCANNER_COMMAND" usage:"exec scanner command reading the file on stdin; exit 0 clean, 1 infected"`
+	ScannerTimeout  time.Duration `key:"scanner_timeout" env:"SCANNER_TIMEOUT" default:"60s" usage:"time limit for scanning one upload"`
+	ScannerFailOpen bool          `key:"scanner_fail_open" env:"SCANNER_FAIL_OPEN" default:"false" usage:"accept uploads as unverified when the scanner fails instead of rejecting them"`
+
+	ThumbnailWorkers       int `key:"thumbnail_workers" env:"THUMBNAIL_WORKERS" default:"2" usage:"thumbnails rendered at once, 0 disables thumbnails"`
+	ThumbnailMaxMegapixels int `key:"thumbnail_max_megapixels" env:"THUMBNAIL_MAX_MEGAPIXELS" default:"50" usage:"larger images get no thumbnail"`
+
+	ArchiveMaxExpandedMegabytes int `key:"archive_max_expanded_megabytes" env:"ARCHIVE_MAX_EXPANDED_MEGABYTES" default:"1024" usage:"stop reading a ZIP file or compressed tarball after this many decompressed megabytes"`
+
+	TraceExporter     string            `key:"trace_exporter" env:"TRACE_EXPORTER" default:"none" usage:"span exporter: none, otlp, stdout or file"`
+	TraceServiceName  string            `key:"trace_service_name" env:"TRACE_SERVICE_NAME" default:"go-file-share" usage:"service name reported with spans"`
+	TraceOTLPEndpoint string            `key:"trace_otlp_endpoint" env:"TRACE_OTLP_ENDPOINT" default:"http://localhost:4318" usage:"OTLP/HTTP collector URL"`
+	TraceOTLPHeaders  map[string]string `key:"trace_otlp_headers" env:"TRACE_OTLP_HEADERS" secret:"true" usage:"OTLP headers as key=value,key2=value2"`
+	TraceFile         string            `key:"trace_file" env:"TRACE_FILE" default:"traces.jsonl" usage:"span file for the file exporter"`
+
+	JWTSecret    []byte `key:"jwt_secret" env:"JWT_SECRET" secret:"true" usage:"token signing key, random per process when empty"`
+	BundleSecret []byte `key:"bundle_secret" env:"BUNDLE_SECRET" secret:"true" usage:"room export signing key, jwt_secret when empty"`
+	RoomBundles  bool   `key:"room_bundles" env:"ROOM_BUNDLES" default:"false" usage:"serve room export and import; needs bundle_secret or jwt_secret"`
+
+	// Derived from the megabyte settings.
+	LogMaxBytes  int64
+	MaxRoomBytes int64
 }
 
-func Load() (Config, error) {
-	cfg := Config{}
+type field struct {
+	index  int
+	key    string
+	env    string
+	def    string
+	usage  string
+	secret bool
+	reload bool
+}
 
-	cfg.Mode = getEnv("MODE", "dev")
-	cfg.Port = getEnv("PORT", "8080")
+func (f field) String() string {
+	return fmt.Sprintf("%s (%s)", f.key, f.env)
+}
 
-	cfg.UploadDir = getEnv("UPLOAD_DIR", "./uploads")
-	if err := os.MkdirAll(cfg.UploadDir, 0755); err != nil {
-		return cfg, fmt.Errorf("cannot create upload dir: %w", err)
-	}
-	cfg.PublicDir = getEnv("PUBLIC_DIR", "./public")
-	if err := os.MkdirAll(cfg.PublicDir, 0755); err != nil {
-		return cfg, fmt.Errorf("cannot create public dir: %w", err)
+// schema lists the configurable fields of Config in declaration order.
+var schema = func() []field {
+	t := reflect.TypeOf(Config{})
+	fields := make([]field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key := sf.Tag.Get("key")
+		if key == "" {
+			continue
+		}
+		fields = append(fields, field{
+			index:  i,
+			key:    key,
+			env:    sf.Tag.Get("env"),
+			def:    sf.Tag.Get("default"),
+			usage:  sf.Tag.Get("usage"),
+			secret: sf.Tag.Get("secret") == "true",
+			reload: sf.Tag.Get("reload") == "true",
+		})
 	}
+	return fields
+}()
 
-	var err error
-	cfg.DefaultRoomTTL, err = parseDurationEnv("ROOM_TTL", "10m")
-	if err != nil {
-		return cfg, err
-	}
-	cfg.TokenTTL, err = parseDurationEnv("TOKEN_TTL", "10m")
-	if err != nil {
-		return cfg, err
-	}
-	cfg.MaxRoomLifespan, err = parseDurationEnv("MAX_ROOM_LIFESPAN", "60m")
-	if err != nil {
-		return cfg, err
-	}
-	cfg.MaxTokenLifespan, err = parseDurationEnv("MAX_TOKEN_LIFESPAN", "60m")
-	if err != nil {
-		return cfg, err
-	}
-	cfg.CleanupInterval, err = parseDurationEnv("CLEANUP_INTERVAL", "30s")
-	if err != nil {
-		return cfg, err
+func lookup(key string) (field, bool) {
+	for _, f := range schema {
+		if f.key == key {
+			return f, true
+		}
 	}
+	return field{}, false
+}
 
-	cfg.MaxFiles, err = parseIntEnv("MAX_FILES", 30)
-	if err != nil {
-		return cfg, err
-	}
-	if cfg.MaxFiles <= 0 {
-		return cfg, fmt.Errorf("MAX_FILES must be positive")
-	}
-	maxRoomMB, err := parseIntEnv("MAX_ROOM_MEGABYTES", 50)
-	if err != nil {
-		return cfg, err
-	}
-	if maxRoomMB <= 0 {
-		return cfg, fmt.Errorf("MAX_ROOM_MEGABYTES must be positive")
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func (f field) set(cfg *Config, raw string) error {
+	v := reflect.ValueOf(cfg).Elem().Field(f.index)
+	raw = strings.TrimSpace(raw)
+
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		v.SetBytes([]byte(raw))
+	case v.Kind() == reflect.Map:
+		m, err := parseMap(raw)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
 	}
-	cfg.MaxRoomBytes = int64(maxRoomMB) * 1024 * 1024
+	return nil
+}
+
+func (f field) value(cfg Config) reflect.Value {
+	return reflect.ValueOf(cfg).Field(f.index)
+}
+
+func (f field) isMap() bool {
+	return reflect.TypeOf(Config{}).Field(f.index).Type.Kind() == reflect.Map
+}
 
-	if cfg.DefaultRoomTTL > cfg.MaxRoomLifespan {
-		return cfg, fmt.Errorf("ROOM_TTL (%s) cannot exceed MAX_ROOM_LIFESPAN (%s)", cfg.DefaultRoomTTL, cfg.MaxRoomLifespan)
+func (f field) isBool() bool {
+	return reflect.TypeOf(Config{}).Field(f.index).Type.Kind() == reflect.Bool
+}
+
+// List splits a comma separated setting, dropping empty items.
+func List(raw string) []string {
+	var out []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
 	}
-	if cfg.TokenTTL > cfg.MaxTokenLifespan {
-		return cfg, fmt.Errorf("TOKEN_TTL (%s) cannot exceed MAX_TOKEN_LIFESPAN (%s)", cfg.TokenTTL, cfg.MaxTokenLifespan)
+	return out
+}
+
+// parseMap reads "key=value,key2=value2".
+func parseMap(raw string) (map[string]string, error) {
+	out := map[string]string{}
+	if raw == "" {
+		return out, nil
 	}
-	if cfg.CleanupInterval <= 0 {
-		return cfg, fmt.Errorf("CLEANUP_INTERVAL must be positive")
+	for _, pair := range strings.Split(raw, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("expected key=value pairs")
+		}
+		out[k] = strings.TrimSpace(v)
 	}
+	return out, nil
+}
 
-	cfg.SqlitePath = getEnv("SQLITE_PATH", "./sqlite.db")
-	cfg.RedisPath = getEnv("REDIS_PATH", "127.0.0.1:6379")
+// derive fills the fields computed from other settings.
+func (c *Config) derive() {
+	c.LogMaxBytes = int64(c.LogMaxMegabytes) * 1024 * 1024
+	c.MaxRoomBytes = int64(c.MaxRoomMegabytes) * 1024 * 1024
+	c.NodeURL = strings.TrimSuffix(c.NodeURL, "/")
+}
 
-	secret := make([]byte, 32)
-	if _, err := rand.Read(secret); err != nil {
-		return cfg, fmt.Errorf("cannot generate jwt secret: %w", err)
+// Load resolves every layer and validates the result. It has no side effects,
+// so it is safe to call again to reload.
+func (l *Loader) Load() (Config, error) {
+	values := make(map[string]string, len(schema))
+	for _, f := range schema {
+		values[f.key] = f.def
 	}
-	cfg.JWTSecret = secret
 
-	return cfg, nil
-}
+	path := l.file
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		fileValues, err := readFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+		for k, v := range fileValues {
+			values[k] = v
+		}
+	}
 
-func getEnv(key, def string) string {
-	if val := os.Getenv(key); val != "" {
-		return val
+	for _, f := range schema {
+		if v := os.Getenv(f.env); v != "" {
+			values[f.key] = v
+		}
+	}
+	for k, v := range l.flags {
+		values[k] = v
 	}
-	return def
-}
 
-func parseIntEnv(key string, def int) (int, error) {
-	val := strings.TrimSpace(os.Getenv(key))
-	if val == "" {
-		return def, nil
+	var cfg Config
+	var errs fieldErrors
+	for _, f := range schema {
+		if err := f.set(&cfg, values[f.key]); err != nil {
+			errs = append(errs, &FieldError{Field: f.String(), Err: err.Error()})
+		}
 	}
-	n, err := strconv.Atoi(val)
-	if err != nil {
-		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	if len(errs) > 0 {
+		return Config{}, errs
 	}
-	return n, nil
-}
+	cfg.derive()
 
-func parseDurationEnv(key, def string) (time.Duration, error) {
-	val := getEnv(key, def)
-	d, err := time.ParseDuration(val)
-	if err != nil {
-		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
 	}
-	return d, nil
+	return cfg, nil
 }
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Loader remembers the config file and flags given on the command line so the
+// configuration can be loaded again on reload with the same overrides.
+type Loader struct {
+	file  string
+	flags map[string]string
+
+	// PrintConfig is set by --print-config.
+	PrintConfig bool
+	// Args are the arguments left after the flags.
+	Args []string
+}
+
+// NewLoader parses command line flags. Every schema key is accepted as a flag
+// with dashes, e.g. --max-files=10, next to --config and --print-config.
+func NewLoader(name string, args []string, output io.Writer) (*Loader, error) {
+	l := &Loader{flags: map[string]string{}}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&l.file, "config", "", "YAML or TOML config file (overrides CONFIG_FILE)")
+	fs.BoolVar(&l.PrintConfig, "print-config", false, "print the effective configuration with secrets redacted and exit")
+
+	for _, f := range schema {
+		usage := fmt.Sprintf("%s (%s)", f.usage, f.env)
+		if f.isBool() {
+			fs.Var(&boolFlag{value: f.def}, flagName(f.key), usage)
+			continue
+		}
+		fs.String(flagName(f.key), f.def, usage)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	l.Args = fs.Args()
+
+	fs.Visit(func(fl *flag.Flag) {
+		if key := strings.ReplaceAll(fl.Name, "-", "_"); key != "config" && key != "print_config" {
+			l.flags[key] = fl.Value.String()
+		}
+	})
+	return l, nil
+}
+
+// Set pins a setting above every other layer.
+func (l *Loader) Set(key, value string) {
+	l.flags[key] = value
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+// readFile flattens a YAML or TOML file into schema keys. Nested tables are
+// joined with underscores, so [log] format = "json" sets log_format.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config file: %w", err)
+	}
+
+	doc := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	out := map[string]string{}
+	if err := flatten("", doc, out); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return out, nil
+}
+
+func flatten(prefix string, doc map[string]any, out map[string]string) error {
+	for k, v := range doc {
+		key := prefix + strings.ReplaceAll(strings.ToLower(k), "-", "_")
+		f, known := lookup(key)
+
+		if nested, ok := v.(map[string]any); ok {
+			if known && f.isMap() {
+				pairs := make([]string, 0, len(nested))
+				for nk, nv := range nested {
+					s, err := scalar(nv)
+					if err != nil {
+						return fmt.Errorf("%s.%s: %w", key, nk, err)
+					}
+					pairs = append(pairs, nk+"="+s)
+				}
+				sort.Strings(pairs)
+				out[key] = strings.Join(pairs, ",")
+				continue
+			}
+			if err := flatten(key+"_", nested, out); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !known {
+			return fmt.Errorf("unknown key %q", key)
+		}
+		s, err := scalar(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		out[key] = s
+	}
+	return nil
+}
+
+func scalar(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("expected a scalar value, got %T", v)
+}
+
+// boolFlag lets boolean settings be passed as a bare --flag.
+type boolFlag struct {
+	value string
+}
+
+func (b *boolFlag) String() string {
+	return b.value
+}
+
+func (b *boolFlag) Set(s string) error {
+	b.value = s
+	return nil
+}
+
+func (b *boolFlag) IsBoolFlag() bool {
+	return true
+}
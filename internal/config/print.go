@@ -0,0 +1,69 @@
+package config
+
+import (
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+const redacted = "[redacted]"
+
+// Print writes the configuration as a YAML config file, with secrets redacted.
+func (c Config) Print(w io.Writer) error {
+	doc := make(yaml.MapSlice, 0, len(schema))
+	for _, f := range schema {
+		doc = append(doc, yaml.MapItem{Key: f.key, Value: printable(f, f.value(c))})
+	}
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+func printable(f field, v reflect.Value) any {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Map:
+		m := make(map[string]string, v.Len())
+		for _, k := range v.MapKeys() {
+			m[k.String()] = v.MapIndex(k).String()
+			if f.secret {
+				m[k.String()] = redacted
+			}
+		}
+		return m
+	case v.Kind() == reflect.Slice:
+		if f.secret && v.Len() > 0 {
+			return redacted
+		}
+		return string(v.Bytes())
+	case v.Kind() == reflect.String && f.secret && v.Len() > 0:
+		return redacted
+	}
+	return v.Interface()
+}
+
+// Reload copies the reloadable settings of next onto c. It returns the keys
+// that changed and the keys that differ but only take effect after a restart.
+func (c Config) Reload(next Config) (_ Config, changed, restart []string) {
+	cur := reflect.ValueOf(&c).Elem()
+	for _, f := range schema {
+		if reflect.DeepEqual(f.value(c).Interface(), f.value(next).Interface()) {
+			continue
+		}
+		if !f.reload {
+			restart = append(restart, f.key)
+			continue
+		}
+		cur.Field(f.index).Set(f.value(next))
+		changed = append(changed, f.key)
+	}
+	c.derive()
+	return c, changed, restart
+}
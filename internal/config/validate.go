@@ -0,0 +1,181 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// FieldError reports an invalid setting by its key and environment variable.
+type FieldError struct {
+	Field string
+	Err   string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err
+}
+
+type fieldErrors []*FieldError
+
+func (errs fieldErrors) Error() string {
+	lines := make([]string, len(errs))
+	for i, err := range errs {
+		lines[i] = err.Error()
+	}
+	return "invalid configuration:\n  " + strings.Join(lines, "\n  ")
+}
+
+// Validate checks every field and reports all problems at once.
+func (c Config) Validate() error {
+	var errs fieldErrors
+	fail := func(key string, format string, args ...any) {
+		f, _ := lookup(key)
+		errs = append(errs, &FieldError{Field: f.String(), Err: fmt.Sprintf(format, args...)})
+	}
+	oneOf := func(key, value string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		fail(key, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+	}
+
+	oneOf("mode", c.Mode, "debug", "release", "test")
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		fail("port", "must be a port number, got %q", c.Port)
+	}
+	for _, proxy := range List(c.TrustedProxies) {
+		if _, err := netip.ParsePrefix(proxy); err == nil {
+			continue
+		}
+		if _, err := netip.ParseAddr(proxy); err != nil {
+			fail("trusted_proxies", "%q is not an IP address or CIDR", proxy)
+		}
+	}
+
+	oneOf("log_format", c.LogFormat, "text", "json")
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		fail("log_level", "must be debug, info, warn or error, got %q", c.LogLevel)
+	}
+	if c.LogMaxMegabytes < 0 {
+		fail("log_max_megabytes", "cannot be negative")
+	}
+	if c.LogMaxAge < 0 {
+		fail("log_max_age", "cannot be negative")
+	}
+	if c.LogMaxBackups < 0 {
+		fail("log_max_backups", "cannot be negative")
+	}
+
+	if c.UploadDir == "" {
+		fail("upload_dir", "is required")
+	}
+	if c.PublicDir == "" {
+		fail("public_dir", "is required")
+	}
+
+	if c.DefaultRoomTTL <= 0 {
+		fail("room_ttl", "must be positive")
+	} else if c.DefaultRoomTTL > c.MaxRoomLifespan {
+		fail("room_ttl", "%s cannot exceed max_room_lifespan (%s)", c.DefaultRoomTTL, c.MaxRoomLifespan)
+	}
+	if c.TokenTTL <= 0 {
+		fail("token_ttl", "must be positive")
+	} else if c.TokenTTL > c.MaxTokenLifespan {
+		fail("token_ttl", "%s cannot exceed max_token_lifespan (%s)", c.TokenTTL, c.MaxTokenLifespan)
+	}
+	if c.MaxFiles <= 0 {
+		fail("max_files", "must be positive")
+	}
+	if c.MaxMessages <= 0 {
+		fail("max_messages", "must be positive")
+	}
+	if c.MaxRoomMegabytes <= 0 {
+		fail("max_room_megabytes", "must be positive")
+	}
+	mimeList := func(key, raw string) {
+		for _, t := range List(raw) {
+			if major, minor, ok := strings.Cut(t, "/"); !ok || major == "" || major == "*" || minor == "" {
+				fail(key, "%q is not a MIME type such as image/png or image/*", t)
+			}
+		}
+	}
+	mimeList("upload_allow_types", c.UploadAllowTypes)
+	mimeList("upload_deny_types", c.UploadDenyTypes)
+	if c.CleanupInterval <= 0 {
+		fail("cleanup_interval", "must be positive")
+	}
+	if c.HealthMinFreeMegabytes < 0 {
+		fail("health_min_free_megabytes", "cannot be negative")
+	}
+	if c.ShutdownDelay < 0 {
+		fail("shutdown_delay", "cannot be negative")
+	}
+
+	oneOf("backend", c.Backend, "memory", "sqlite", "redis")
+	oneOf("broadcast_policy", c.BroadcastPolicy, "stall", "drop")
+	oneOf("audit_sink", c.AuditSink, "none", "file", "sqlite")
+	oneOf("scanner", c.Scanner, "none", "clamd", "exec")
+	oneOf("trace_exporter", c.TraceExporter, "none", "otlp", "stdout", "file")
+
+	if c.NodeURL != "" {
+		if u, err := url.Parse(c.NodeURL); err != nil || u.Scheme == "" || u.Host == "" {
+			fail("node_url", "must be an absolute URL, got %q", c.NodeURL)
+		}
+		if len(c.ClusterSecret) < 16 {
+			fail("cluster_secret", "must be at least 16 bytes when node_url is set")
+		}
+	}
+	if c.AuditSink == "file" && c.AuditDir == "" {
+		fail("audit_dir", "is required when audit_sink is file")
+	}
+	if c.AuditSink == "sqlite" && c.AuditSqlitePath == "" {
+		fail("audit_sqlite_path", "is required when audit_sink is sqlite")
+	}
+	if c.AuditRetention < 0 {
+		fail("audit_retention", "cannot be negative")
+	}
+	if c.Scanner == "clamd" && c.ScannerAddress == "" {
+		fail("scanner_address", "is required when scanner is clamd")
+	}
+	if c.Scanner == "exec" && strings.TrimSpace(c.ScannerCommand) == "" {
+		fail("scanner_command", "is required when scanner is exec")
+	}
+	if c.ScannerTimeout <= 0 {
+		fail("scanner_timeout", "must be positive")
+	}
+	if c.ThumbnailWorkers < 0 {
+		fail("thumbnail_workers", "cannot be negative")
+	}
+	if c.ThumbnailMaxMegapixels <= 0 {
+		fail("thumbnail_max_megapixels", "must be positive")
+	}
+	if c.ArchiveMaxExpandedMegabytes <= 0 {
+		fail("archive_max_expanded_megabytes", "must be positive")
+	}
+	if c.AdminKey != "" && len(c.AdminKey) < 16 {
+		fail("admin_key", "must be at least 16 bytes")
+	}
+	if len(c.JWTSecret) > 0 && len(c.JWTSecret) < 32 {
+		fail("jwt_secret", "must be at least 32 bytes")
+	}
+	if len(c.BundleSecret) > 0 && len(c.BundleSecret) < 32 {
+		fail("bundle_secret", "must be at least 32 bytes")
+	}
+	// A key generated at startup would make every bundle unreadable after a
+	// restart or on any other node.
+	if c.RoomBundles && len(c.BundleSecret) == 0 && len(c.JWTSecret) == 0 {
+		fail("bundle_secret", "is required when room_bundles is set, unless jwt_secret is")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
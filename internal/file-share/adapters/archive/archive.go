@@ -0,0 +1,198 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+)
+
+const (
+	FormatZip      = "zip"
+	FormatTar      = "tar"
+	FormatTarGzip  = "tar.gz"
+	FormatTarBzip2 = "tar.bz2"
+)
+
+// Reader reads ZIP archives through their central directory and tarballs,
+// plain or compressed, as a stream. Compressed tarballs are cut off after
+// maxExpanded decompressed bytes, since skipping an entry still means
+// inflating it; ZIP entries share the same allowance across the archive.
+type Reader struct {
+	maxExpanded int64
+}
+
+func New(maxExpanded int64) Reader {
+	return Reader{maxExpanded: maxExpanded}
+}
+
+func (Reader) Format(name, contentType string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case contentType == "application/zip" || strings.HasSuffix(lower, ".zip"):
+		return FormatZip
+	case strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz"):
+		return FormatTarGzip
+	case strings.HasSuffix(lower, ".tar.bz2") || strings.HasSuffix(lower, ".tbz2") || strings.HasSuffix(lower, ".tbz"):
+		return FormatTarBzip2
+	case contentType == "application/x-tar" || strings.HasSuffix(lower, ".tar"):
+		return FormatTar
+	}
+	return ""
+}
+
+func (a Reader) Walk(ctx context.Context, format string, r io.Reader, size int64, fn func(ports.ArchiveEntry, func() (io.Reader, error)) error) error {
+	switch format {
+	case FormatZip:
+		ra, ok := r.(io.ReaderAt)
+		if !ok {
+			return fmt.Errorf("%w: zip needs random access", ports.ErrArchiveUnreadable)
+		}
+		return walkZip(ctx, ra, size, a.maxExpanded, fn)
+	case FormatTar:
+		return walkTar(ctx, r, fn)
+	case FormatTarGzip:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return unreadable(err)
+		}
+		defer gz.Close()
+		return walkTar(ctx, a.limit(gz), fn)
+	case FormatTarBzip2:
+		return walkTar(ctx, a.limit(bzip2.NewReader(r)), fn)
+	}
+	return ports.ErrNotArchive
+}
+
+func walkZip(ctx context.Context, ra io.ReaderAt, size, maxExpanded int64, fn func(ports.ArchiveEntry, func() (io.Reader, error)) error) error {
+	zr, err := zip.NewReader(ra, size)
+	// Unsafe names are reported to the caller, which decides what to do.
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return unreadable(err)
+	}
+
+	left := maxExpanded
+	for _, f := range zr.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		entry := ports.ArchiveEntry{
+			Name:       f.Name,
+			Size:       int64(f.UncompressedSize64),
+			PackedSize: int64(f.CompressedSize64),
+			ModTime:    f.Modified,
+			Dir:        f.FileInfo().IsDir(),
+			Regular:    f.Mode().IsRegular(),
+		}
+		var rc io.ReadCloser
+		open := func() (io.Reader, error) {
+			if rc != nil {
+				return nil, errors.New("archive entry already opened")
+			}
+			var err error
+			if rc, err = f.Open(); err != nil {
+				return nil, unreadable(err)
+			}
+			if maxExpanded <= 0 {
+				return unreadableReader{rc}, nil
+			}
+			return unreadableReader{&expansionLimit{r: rc, left: &left}}, nil
+		}
+
+		err := fn(entry, open)
+		if rc != nil {
+			_ = rc.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkTar(ctx context.Context, r io.Reader, fn func(ports.ArchiveEntry, func() (io.Reader, error)) error) error {
+	tr := tar.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if errors.Is(err, ports.ErrArchiveTooLarge) {
+				return err
+			}
+			return unreadable(err)
+		}
+
+		entry := ports.ArchiveEntry{
+			Name:    h.Name,
+			Size:    h.Size,
+			ModTime: h.ModTime,
+			Dir:     h.Typeflag == tar.TypeDir,
+			Regular: h.FileInfo().Mode().IsRegular(),
+		}
+		open := func() (io.Reader, error) {
+			return unreadableReader{tr}, nil
+		}
+		if err := fn(entry, open); err != nil {
+			return err
+		}
+	}
+}
+
+func (a Reader) limit(r io.Reader) io.Reader {
+	if a.maxExpanded <= 0 {
+		return r
+	}
+	left := a.maxExpanded
+	return &expansionLimit{r: r, left: &left}
+}
+
+// expansionLimit fails reads once left runs out. Readers sharing left share
+// the allowance.
+type expansionLimit struct {
+	r    io.Reader
+	left *int64
+}
+
+func (l *expansionLimit) Read(p []byte) (int, error) {
+	if *l.left <= 0 {
+		return 0, ports.ErrArchiveTooLarge
+	}
+	if int64(len(p)) > *l.left {
+		p = p[:*l.left]
+	}
+	n, err := l.r.Read(p)
+	*l.left -= int64(n)
+	return n, err
+}
+
+// unreadableReader tags decoding errors so callers can tell a broken archive
+// from a failing disk.
+type unreadableReader struct {
+	r io.Reader
+}
+
+func (u unreadableReader) Read(p []byte) (int, error) {
+	n, err := u.r.Read(p)
+	if err != nil && err != io.EOF && !errors.Is(err, ports.ErrArchiveTooLarge) {
+		err = unreadable(err)
+	}
+	return n, err
+}
+
+func unreadable(err error) error {
+	return fmt.Errorf("%w: %v", ports.ErrArchiveUnreadable, err)
+}
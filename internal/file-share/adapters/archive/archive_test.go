@@ -0,0 +1,72 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+)
+
+// zipOf builds a zip of entries zero-filled files of size bytes each.
+func zipOf(t *testing.T, entries int, size int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i := range entries {
+		w, err := zw.Create(fmt.Sprintf("part-%02d.bin", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(make([]byte, size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func readAll(ctx context.Context, a Reader, data []byte) (int64, error) {
+	var total int64
+	err := a.Walk(ctx, FormatZip, bytes.NewReader(data), int64(len(data)), func(_ ports.ArchiveEntry, open func() (io.Reader, error)) error {
+		r, err := open()
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(io.Discard, r)
+		total += n
+		return err
+	})
+	return total, err
+}
+
+func TestZipExpansionIsShared(t *testing.T) {
+	data := zipOf(t, 16, 1<<20)
+
+	// Each entry fits on its own; together they are four times the limit.
+	total, err := readAll(context.Background(), New(4<<20), data)
+	if !errors.Is(err, ports.ErrArchiveTooLarge) {
+		t.Fatalf("walk = %v, want %v", err, ports.ErrArchiveTooLarge)
+	}
+	if total > 4<<20 {
+		t.Fatalf("inflated %d bytes past the %d byte limit", total, 4<<20)
+	}
+
+	total, err = readAll(context.Background(), New(32<<20), data)
+	if err != nil {
+		t.Fatalf("walk within the limit: %v", err)
+	}
+	if total != 16<<20 {
+		t.Fatalf("inflated %d bytes, want %d", total, 16<<20)
+	}
+
+	if _, err := readAll(context.Background(), New(0), data); err != nil {
+		t.Fatalf("walk without a limit: %v", err)
+	}
+}
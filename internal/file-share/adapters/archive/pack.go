@@ -0,0 +1,48 @@
+package archive
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"strings"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+)
+
+// Pack writes entries to w as a ZIP archive, in the order given. Entries are
+// opened one at a time while the archive is written.
+func (Reader) Pack(ctx context.Context, w io.Writer, entries []ports.PackEntry) error {
+	zw := zip.NewWriter(w)
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := packEntry(zw, e); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func packEntry(zw *zip.Writer, e ports.PackEntry) error {
+	hdr := &zip.FileHeader{Name: e.Name, Method: zip.Deflate, Modified: e.ModTime}
+	if e.Dir {
+		hdr.Name = strings.TrimSuffix(e.Name, "/") + "/"
+		hdr.Method = zip.Store
+		_, err := zw.CreateHeader(hdr)
+		return err
+	}
+
+	dst, err := zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	src, err := e.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
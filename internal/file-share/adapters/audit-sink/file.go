@@ -0,0 +1,268 @@
+package auditsink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/google/uuid"
+)
+
+const (
+	filePrefix = "audit-"
+	fileSuffix = ".jsonl"
+	dayLayout  = "2006-01-02"
+)
+
+type fileRecord struct {
+	Time             time.Time `json:"time"`
+	Action           string    `json:"action"`
+	Result           string    `json:"result"`
+	Actor            string    `json:"actor"`
+	ActorIP          string    `json:"actorIp,omitempty"`
+	TokenFingerprint string    `json:"tokenFingerprint,omitempty"`
+	RoomID           string    `json:"roomId,omitempty"`
+	FileID           string    `json:"fileId,omitempty"`
+	RequestID        string    `json:"requestId,omitempty"`
+	Detail           string    `json:"detail,omitempty"`
+}
+
+// FileSink appends JSON lines to one file per UTC day, audit-2006-01-02.jsonl,
+// so retention is a matter of deleting whole files.
+type FileSink struct {
+	dir string
+
+	mu   sync.Mutex
+	day  string
+	file *os.File
+}
+
+func NewFileSink(dir string) (*FileSink, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &FileSink{dir: dir}, nil
+}
+
+func (s *FileSink) Record(ctx context.Context, record ports.AuditRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(toFileRecord(record))
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	day := record.Time.UTC().Format(dayLayout)
+	if s.file == nil || s.day != day {
+		if s.file != nil {
+			_ = s.file.Close()
+		}
+		f, err := os.OpenFile(s.path(day), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+		if err != nil {
+			s.file = nil
+			return err
+		}
+		s.file, s.day = f, day
+	}
+
+	_, err = s.file.Write(line)
+	return err
+}
+
+func (s *FileSink) Query(ctx context.Context, query ports.AuditQuery) ([]ports.AuditRecord, error) {
+	days, err := s.days()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []ports.AuditRecord
+	for _, day := range days {
+		if !query.Since.IsZero() && day < query.Since.UTC().Format(dayLayout) {
+			continue
+		}
+		if !query.Until.IsZero() && day > query.Until.UTC().Format(dayLayout) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := s.scan(day, query, &out); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.After(out[j].Time) })
+	if query.Limit > 0 && len(out) > query.Limit {
+		out = out[:query.Limit]
+	}
+	return out, nil
+}
+
+func (s *FileSink) Prune(ctx context.Context, before time.Time) (int, error) {
+	days, err := s.days()
+	if err != nil {
+		return 0, err
+	}
+
+	// A file is only dropped once its whole day is older than the cutoff.
+	cutoff := before.UTC().Format(dayLayout)
+	removed := 0
+	var joined error
+	for _, day := range days {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+		if day >= cutoff {
+			continue
+		}
+
+		s.mu.Lock()
+		if s.day == day && s.file != nil {
+			_ = s.file.Close()
+			s.file, s.day = nil, ""
+		}
+		err := os.Remove(s.path(day))
+		s.mu.Unlock()
+
+		if err != nil {
+			joined = errors.Join(joined, err)
+			continue
+		}
+		removed++
+	}
+	return removed, joined
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file, s.day = nil, ""
+	return err
+}
+
+func (s *FileSink) path(day string) string {
+	return filepath.Join(s.dir, filePrefix+day+fileSuffix)
+}
+
+// days lists the days that have a file, oldest first.
+func (s *FileSink) days() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var days []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		day := strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix)
+		if _, err := time.Parse(dayLayout, day); err != nil {
+			continue
+		}
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	return days, nil
+}
+
+func (s *FileSink) scan(day string, query ports.AuditQuery, out *[]ports.AuditRecord) error {
+	f, err := os.Open(s.path(day))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var fr fileRecord
+		if err := json.Unmarshal(scanner.Bytes(), &fr); err != nil {
+			// A torn line from a crash should not hide the rest of the day.
+			continue
+		}
+		record := fromFileRecord(fr)
+		if matches(record, query) {
+			*out = append(*out, record)
+		}
+	}
+	return scanner.Err()
+}
+
+func matches(record ports.AuditRecord, query ports.AuditQuery) bool {
+	if !query.Since.IsZero() && record.Time.Before(query.Since) {
+		return false
+	}
+	if !query.Until.IsZero() && record.Time.After(query.Until) {
+		return false
+	}
+	if query.Action != "" && record.Action != query.Action {
+		return false
+	}
+	if query.RoomID != uuid.Nil && record.RoomID != query.RoomID {
+		return false
+	}
+	return true
+}
+
+func toFileRecord(r ports.AuditRecord) fileRecord {
+	return fileRecord{
+		Time:             r.Time.UTC(),
+		Action:           r.Action,
+		Result:           r.Result,
+		Actor:            r.Actor,
+		ActorIP:          r.ActorIP,
+		TokenFingerprint: r.TokenFingerprint,
+		RoomID:           idString(r.RoomID),
+		FileID:           idString(r.FileID),
+		RequestID:        r.RequestID,
+		Detail:           r.Detail,
+	}
+}
+
+func fromFileRecord(r fileRecord) ports.AuditRecord {
+	roomID, _ := uuid.Parse(r.RoomID)
+	fileID, _ := uuid.Parse(r.FileID)
+	return ports.AuditRecord{
+		Time:             r.Time,
+		Action:           r.Action,
+		Result:           r.Result,
+		Actor:            r.Actor,
+		ActorIP:          r.ActorIP,
+		TokenFingerprint: r.TokenFingerprint,
+		RoomID:           roomID,
+		FileID:           fileID,
+		RequestID:        r.RequestID,
+		Detail:           r.Detail,
+	}
+}
+
+func idString(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return ""
+	}
+	return id.String()
+}
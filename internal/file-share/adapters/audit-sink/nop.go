@@ -0,0 +1,19 @@
+package auditsink
+
+import (
+	"context"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+)
+
+// Nop discards every record, for AUDIT_SINK=none.
+type Nop struct{}
+
+func (Nop) Record(context.Context, ports.AuditRecord) error { return nil }
+
+func (Nop) Query(context.Context, ports.AuditQuery) ([]ports.AuditRecord, error) { return nil, nil }
+
+func (Nop) Prune(context.Context, time.Time) (int, error) { return 0, nil }
+
+func (Nop) Close() error { return nil }
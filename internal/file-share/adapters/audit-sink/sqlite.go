@@ -0,0 +1,117 @@
+package auditsink
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/google/uuid"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS audit_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	at INTEGER NOT NULL,
+	action TEXT NOT NULL,
+	result TEXT NOT NULL,
+	actor TEXT NOT NULL,
+	actor_ip TEXT NOT NULL DEFAULT '',
+	token_fingerprint TEXT NOT NULL DEFAULT '',
+	room_id TEXT NOT NULL DEFAULT '',
+	file_id TEXT NOT NULL DEFAULT '',
+	request_id TEXT NOT NULL DEFAULT '',
+	detail TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_audit_log_at ON audit_log (at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_room_id ON audit_log (room_id);
+`
+
+// SqliteSink keeps the audit log in its own SQLite database, independent of
+// the room backend. Timestamps are stored as Unix nanoseconds.
+type SqliteSink struct {
+	db *sql.DB
+}
+
+func NewSqliteSink(ctx context.Context, db *sql.DB) (*SqliteSink, error) {
+	if _, err := db.ExecContext(ctx, sqliteSchema); err != nil {
+		return nil, err
+	}
+	return &SqliteSink{db: db}, nil
+}
+
+func (s *SqliteSink) Record(ctx context.Context, r ports.AuditRecord) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO audit_log (at, action, result, actor, actor_ip, token_fingerprint, room_id, file_id, request_id, detail)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, r.Time.UnixNano(), r.Action, r.Result, r.Actor, r.ActorIP, r.TokenFingerprint, idString(r.RoomID), idString(r.FileID), r.RequestID, r.Detail)
+	return err
+}
+
+func (s *SqliteSink) Query(ctx context.Context, query ports.AuditQuery) ([]ports.AuditRecord, error) {
+	var where []string
+	var args []any
+	if !query.Since.IsZero() {
+		where = append(where, "at >= ?")
+		args = append(args, query.Since.UnixNano())
+	}
+	if !query.Until.IsZero() {
+		where = append(where, "at <= ?")
+		args = append(args, query.Until.UnixNano())
+	}
+	if query.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, query.Action)
+	}
+	if query.RoomID != uuid.Nil {
+		where = append(where, "room_id = ?")
+		args = append(args, query.RoomID.String())
+	}
+
+	q := `SELECT at, action, result, actor, actor_ip, token_fingerprint, room_id, file_id, request_id, detail FROM audit_log`
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	q += " ORDER BY at DESC, id DESC"
+	if query.Limit > 0 {
+		q += " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ports.AuditRecord
+	for rows.Next() {
+		var (
+			at             int64
+			roomID, fileID string
+			r              ports.AuditRecord
+		)
+		if err := rows.Scan(&at, &r.Action, &r.Result, &r.Actor, &r.ActorIP, &r.TokenFingerprint, &roomID, &fileID, &r.RequestID, &r.Detail); err != nil {
+			return nil, err
+		}
+		r.Time = time.Unix(0, at).UTC()
+		r.RoomID, _ = uuid.Parse(roomID)
+		r.FileID, _ = uuid.Parse(fileID)
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (s *SqliteSink) Prune(ctx context.Context, before time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM audit_log WHERE at < ?`, before.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *SqliteSink) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,112 @@
+package audited
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/logging"
+)
+
+// DirectTransfer records every pairing and finished send. Transfers are not
+// tied to a room, so the record carries the code and filename as detail.
+type DirectTransfer struct {
+	inner ports.DirectTransfer
+	sink  ports.AuditSink
+}
+
+func NewDirectTransfer(inner ports.DirectTransfer, sink ports.AuditSink) *DirectTransfer {
+	return &DirectTransfer{inner: inner, sink: sink}
+}
+
+func (dt *DirectTransfer) Receive(ctx context.Context, code string) (*ports.Transfer, error) {
+	transfer, err := dt.inner.Receive(ctx, code)
+	detail := "code " + code
+	if transfer != nil {
+		detail += ", " + transfer.Filename
+	}
+	record(ctx, dt.sink, ports.AuditDirectReceive, detail, err)
+	return transfer, err
+}
+
+func (dt *DirectTransfer) Send(ctx context.Context, code string, filename string, expectedDigest string, src io.Reader) (string, error) {
+	digest, err := dt.inner.Send(ctx, code, filename, expectedDigest, src)
+	record(ctx, dt.sink, ports.AuditDirectSend, "code "+code+", "+filename, err)
+	return digest, err
+}
+
+func (dt *DirectTransfer) Complete(code string) error {
+	return dt.inner.Complete(code)
+}
+
+func (dt *DirectTransfer) Cancel(code string) error {
+	return dt.inner.Cancel(code)
+}
+
+type DirectBroadcast struct {
+	inner ports.DirectBroadcast
+	sink  ports.AuditSink
+}
+
+func NewDirectBroadcast(inner ports.DirectBroadcast, sink ports.AuditSink) *DirectBroadcast {
+	return &DirectBroadcast{inner: inner, sink: sink}
+}
+
+func (db *DirectBroadcast) Join(ctx context.Context, code string) (string, *ports.Transfer, error) {
+	receiverID, transfer, err := db.inner.Join(ctx, code)
+	detail := "code " + code
+	if transfer != nil {
+		detail += ", " + transfer.Filename
+	}
+	record(ctx, db.sink, ports.AuditBroadcastJoin, detail, err)
+	return receiverID, transfer, err
+}
+
+func (db *DirectBroadcast) Broadcast(ctx context.Context, code string, filename string, opts ports.BroadcastOptions, src io.Reader) (ports.BroadcastResult, error) {
+	res, err := db.inner.Broadcast(ctx, code, filename, opts, src)
+	record(ctx, db.sink, ports.AuditBroadcastSend, "code "+code+", "+filename, err)
+	return res, err
+}
+
+func (db *DirectBroadcast) Start(code string) error {
+	return db.inner.Start(code)
+}
+
+func (db *DirectBroadcast) Receivers(code string) (int, error) {
+	return db.inner.Receivers(code)
+}
+
+func (db *DirectBroadcast) Complete(code string, receiverID string) error {
+	return db.inner.Complete(code, receiverID)
+}
+
+func (db *DirectBroadcast) Leave(code string, receiverID string) error {
+	return db.inner.Leave(code, receiverID)
+}
+
+func record(ctx context.Context, sink ports.AuditSink, action string, detail string, err error) {
+	actor := ports.ActorFromContext(ctx)
+	rec := ports.AuditRecord{
+		Time:      time.Now(),
+		Action:    action,
+		Result:    ports.AuditResultOK,
+		Actor:     actor.Name,
+		ActorIP:   actor.IP,
+		RequestID: logging.RequestID(ctx),
+		Detail:    detail,
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, ports.ErrTransferCodeNotFound), errors.Is(err, ports.ErrTransferCodeInvalidLength), errors.Is(err, ports.ErrTransferCodeExists):
+		rec.Result = ports.AuditResultDenied
+	default:
+		rec.Result = ports.AuditResultError
+	}
+
+	if sinkErr := sink.Record(context.WithoutCancel(ctx), rec); sinkErr != nil {
+		slog.ErrorContext(ctx, "cannot write audit record", slog.String("action", action), slog.Any("error", sinkErr))
+	}
+}
@@ -0,0 +1,196 @@
+package contentscanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+)
+
+const clamdChunkSize = 64 * 1024
+
+// Clamd streams content to a clamd daemon with the INSTREAM command. The
+// address is unix:///path/to/clamd.sock, tcp://host:port or a bare host:port.
+type Clamd struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+func NewClamd(address string, timeout time.Duration) (*Clamd, error) {
+	network, addr := "tcp", address
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		network, addr = "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "tcp://"):
+		addr = strings.TrimPrefix(address, "tcp://")
+	}
+	if addr == "" {
+		return nil, fmt.Errorf("clamd address %q is empty", address)
+	}
+
+	return &Clamd{
+		network: network,
+		address: addr,
+		timeout: timeout,
+	}, nil
+}
+
+func (c *Clamd) Name() string {
+	return "clamd"
+}
+
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (ports.ScanResult, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return ports.ScanResult{}, err
+	}
+	defer conn.Close()
+
+	if err := c.stream(conn, r); err != nil {
+		// clamd closes the stream early when it refuses it, e.g. above
+		// StreamMaxLength; its reply explains why.
+		if reply, replyErr := readReply(conn); replyErr == nil && reply != "" {
+			return ports.ScanResult{}, fmt.Errorf("clamd: %s", reply)
+		}
+		return ports.ScanResult{}, ctxErr(ctx, err)
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return ports.ScanResult{}, ctxErr(ctx, err)
+	}
+	return parseReply(reply)
+}
+
+// Check pings the daemon so readiness fails while clamd is down.
+func (c *Clamd) Check(ctx context.Context) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("zPING\x00")); err != nil {
+		return err
+	}
+	reply, err := readReply(conn)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("clamd: unexpected ping reply %q", reply)
+	}
+	return nil
+}
+
+func (c *Clamd) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+// dial connects and ties the connection's deadline to ctx.
+func (c *Clamd) dial(ctx context.Context) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	// Unblock reads and writes when the caller gives up.
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	return &stoppingConn{Conn: conn, stop: stop}, nil
+}
+
+func (c *Clamd) stream(conn net.Conn, r io.Reader) error {
+	w := bufio.NewWriterSize(conn, clamdChunkSize+4)
+	if _, err := w.WriteString("zINSTREAM\x00"); err != nil {
+		return err
+	}
+
+	buf := make([]byte, clamdChunkSize)
+	var size [4]byte
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			if _, werr := w.Write(size[:]); werr != nil {
+				return werr
+			}
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	binary.BigEndian.PutUint32(size[:], 0)
+	if _, err := w.Write(size[:]); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func readReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(io.LimitReader(conn, 4096)).ReadBytes(0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return string(bytes.TrimSpace(bytes.TrimRight(reply, "\x00"))), nil
+}
+
+// parseReply understands "stream: OK", "stream: <name> FOUND" and
+// "<message> ERROR".
+func parseReply(reply string) (ports.ScanResult, error) {
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return ports.ScanResult{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return ports.ScanResult{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	case reply == "":
+		return ports.ScanResult{}, errors.New("clamd: empty reply")
+	}
+	return ports.ScanResult{}, fmt.Errorf("clamd: %s", reply)
+}
+
+func ctxErr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+type stoppingConn struct {
+	net.Conn
+	stop func() bool
+}
+
+func (c *stoppingConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
@@ -0,0 +1,179 @@
+package contentscanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd answers zPING and zINSTREAM on a unix socket the way clamd does.
+// Streams holding the EICAR string are reported infected, and streams past
+// maxStream are refused mid-way like clamd's StreamMaxLength.
+type fakeClamd struct {
+	maxStream int
+	// stall makes the daemon read the stream but never reply.
+	stall bool
+}
+
+func (f *fakeClamd) start(t *testing.T) string {
+	t.Helper()
+	sock := filepath.Join(t.TempDir(), "clamd.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.handle(conn)
+		}
+	}()
+	return "unix://" + sock
+}
+
+func (f *fakeClamd) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	cmd, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+
+	switch cmd {
+	case "zPING\x00":
+		_, _ = conn.Write([]byte("PONG\x00"))
+	case "zINSTREAM\x00":
+		var data bytes.Buffer
+		for {
+			var size uint32
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				return
+			}
+			if size == 0 {
+				break
+			}
+			if f.maxStream > 0 && data.Len()+int(size) > f.maxStream {
+				_, _ = conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+				return
+			}
+			if _, err := io.CopyN(&data, r, int64(size)); err != nil {
+				return
+			}
+		}
+		if f.stall {
+			_, _ = io.Copy(io.Discard, r)
+			return
+		}
+		if bytes.Contains(data.Bytes(), []byte(eicar)) {
+			_, _ = conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+			return
+		}
+		_, _ = conn.Write([]byte("stream: OK\x00"))
+	default:
+		_, _ = conn.Write([]byte("UNKNOWN COMMAND\x00"))
+	}
+}
+
+func newTestClamd(t *testing.T, f *fakeClamd, timeout time.Duration) *Clamd {
+	t.Helper()
+	c, err := NewClamd(f.start(t), timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestClamdScan(t *testing.T) {
+	c := newTestClamd(t, &fakeClamd{}, 5*time.Second)
+
+	// Larger than one INSTREAM chunk, so the stream spans several.
+	clean := strings.Repeat("harmless ", 3*clamdChunkSize/8)
+	res, err := c.Scan(context.Background(), strings.NewReader(clean))
+	if err != nil {
+		t.Fatalf("clean scan: %v", err)
+	}
+	if res.Infected {
+		t.Fatalf("clean content reported infected: %+v", res)
+	}
+
+	infected := clean + eicar
+	res, err = c.Scan(context.Background(), strings.NewReader(infected))
+	if err != nil {
+		t.Fatalf("infected scan: %v", err)
+	}
+	if !res.Infected || res.Signature != "Eicar-Test-Signature" {
+		t.Fatalf("infected scan = %+v", res)
+	}
+}
+
+func TestClamdRefusesLargeStream(t *testing.T) {
+	c := newTestClamd(t, &fakeClamd{maxStream: clamdChunkSize}, 5*time.Second)
+
+	_, err := c.Scan(context.Background(), bytes.NewReader(make([]byte, 8*clamdChunkSize)))
+	if err == nil || !strings.Contains(err.Error(), "size limit exceeded") {
+		t.Fatalf("scan past StreamMaxLength = %v, want clamd's refusal", err)
+	}
+}
+
+func TestClamdTimeout(t *testing.T) {
+	c := newTestClamd(t, &fakeClamd{stall: true}, 100*time.Millisecond)
+
+	start := time.Now()
+	if _, err := c.Scan(context.Background(), strings.NewReader("waiting")); err == nil {
+		t.Fatal("stalled scan succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("stalled scan returned after %s", elapsed)
+	}
+}
+
+func TestClamdCheck(t *testing.T) {
+	c := newTestClamd(t, &fakeClamd{}, time.Second)
+	if err := c.Check(context.Background()); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	down, err := NewClamd("unix://"+filepath.Join(t.TempDir(), "missing.sock"), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := down.Check(context.Background()); err == nil {
+		t.Fatal("Check succeeded without a daemon")
+	}
+}
+
+func TestNewClamdAddress(t *testing.T) {
+	tests := []struct {
+		address, network, addr string
+	}{
+		{"unix:///run/clamd.sock", "unix", "/run/clamd.sock"},
+		{"tcp://127.0.0.1:3310", "tcp", "127.0.0.1:3310"},
+		{"clamav:3310", "tcp", "clamav:3310"},
+	}
+	for _, tt := range tests {
+		c, err := NewClamd(tt.address, 0)
+		if err != nil {
+			t.Fatalf("NewClamd(%q): %v", tt.address, err)
+		}
+		if c.network != tt.network || c.address != tt.addr {
+			t.Errorf("NewClamd(%q) = %s %s, want %s %s", tt.address, c.network, c.address, tt.network, tt.addr)
+		}
+	}
+	if _, err := NewClamd("unix://", 0); err == nil {
+		t.Error("NewClamd accepted an empty socket path")
+	}
+}
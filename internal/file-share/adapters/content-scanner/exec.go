@@ -0,0 +1,109 @@
+package contentscanner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+)
+
+const (
+	maxSignatureLength = 256
+	// maxCommandOutput is how much of the command's stdout and stderr is
+	// kept; only their first line is ever used.
+	maxCommandOutput = 4096
+	// execWaitDelay bounds the wait for output after the command was killed,
+	// as children it started may still hold its stdout open.
+	execWaitDelay = time.Second
+)
+
+// Exec pipes content to an external command on stdin, following the
+// clamscan convention: exit status 0 is clean, 1 is infected with the
+// signature on the first line of stdout, anything else is a scan failure.
+type Exec struct {
+	command []string
+	timeout time.Duration
+}
+
+func NewExec(command string, timeout time.Duration) (*Exec, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, errors.New("scanner command is empty")
+	}
+
+	return &Exec{
+		command: fields,
+		timeout: timeout,
+	}, nil
+}
+
+func (e *Exec) Name() string {
+	return "exec"
+}
+
+func (e *Exec) Scan(ctx context.Context, r io.Reader) (ports.ScanResult, error) {
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+
+	stdout := &limitedBuffer{limit: maxCommandOutput}
+	stderr := &limitedBuffer{limit: maxCommandOutput}
+	cmd := exec.CommandContext(ctx, e.command[0], e.command[1:]...)
+	cmd.Stdin = r
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = execWaitDelay
+
+	err := cmd.Run()
+	if err == nil {
+		return ports.ScanResult{}, nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ports.ScanResult{}, ctxErr
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return ports.ScanResult{Infected: true, Signature: firstLine(stdout.String())}, nil
+	}
+	if msg := firstLine(stderr.String()); msg != "" {
+		return ports.ScanResult{}, fmt.Errorf("%s: %w: %s", e.command[0], err, msg)
+	}
+	return ports.ScanResult{}, fmt.Errorf("%s: %w", e.command[0], err)
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	line = strings.TrimSpace(line)
+	if len(line) > maxSignatureLength {
+		line = line[:maxSignatureLength]
+	}
+	return line
+}
+
+// limitedBuffer keeps the first limit bytes written to it and drops the
+// rest without failing the write, so a chatty command neither grows memory
+// nor dies of a broken pipe.
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
@@ -0,0 +1,88 @@
+package contentscanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func script(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scan.sh")
+	if err := os.WriteFile(path, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return "sh " + path
+}
+
+func TestExecScan(t *testing.T) {
+	tests := []struct {
+		name      string
+		script    string
+		infected  bool
+		signature string
+		wantErr   string
+	}{
+		{name: "clean", script: "cat >/dev/null\nexit 0\n"},
+		{name: "infected", script: "cat >/dev/null\necho 'Eicar-Test-Signature'\necho more\nexit 1\n", infected: true, signature: "Eicar-Test-Signature"},
+		{name: "failure", script: "cat >/dev/null\necho 'database missing' >&2\nexit 2\n", wantErr: "database missing"},
+		{
+			// Far more output than is kept; the command must still finish
+			// normally and the signature is cut to its maximum length.
+			name:      "chatty",
+			script:    "cat >/dev/null\nhead -c 4000000 /dev/zero | tr '\\0' 'v'\nhead -c 4000000 /dev/zero | tr '\\0' 'e' >&2\nexit 1\n",
+			infected:  true,
+			signature: strings.Repeat("v", maxSignatureLength),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewExec(script(t, tt.script), 10*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := e.Scan(context.Background(), strings.NewReader("content"))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Scan error = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if res.Infected != tt.infected || res.Signature != tt.signature {
+				t.Fatalf("Scan = %+v, want infected %v with %.40q", res, tt.infected, tt.signature)
+			}
+		})
+	}
+}
+
+func TestExecTimeout(t *testing.T) {
+	e, err := NewExec(script(t, "sleep 5\n"), 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := e.Scan(context.Background(), strings.NewReader("content")); err == nil {
+		t.Fatal("Scan succeeded past its timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("Scan returned after %s", elapsed)
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	b := &limitedBuffer{limit: 8}
+	for _, s := range []string{"0123", "4567", "89ab"} {
+		if n, err := b.Write([]byte(s)); n != len(s) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", s, n, err)
+		}
+	}
+	if got := b.String(); got != "01234567" {
+		t.Fatalf("kept %q, want %q", got, "01234567")
+	}
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+func (s *SqliteDB) Name() string {
+	return "sqlite"
+}
+
+// Check pings the database and makes sure it still accepts writes by
+// creating and filling a probe table in a transaction that is rolled back.
+func (s *SqliteDB) Check(ctx context.Context) error {
+	if err := s.Conn.PingContext(ctx); err != nil {
+		return err
+	}
+
+	tx, err := s.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS health_probe (checked_at INTEGER)`); err != nil {
+		return fmt.Errorf("write probe: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO health_probe (checked_at) VALUES (unixepoch())`); err != nil {
+		return fmt.Errorf("write probe: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisDB) Name() string {
+	return "redis"
+}
+
+func (r *RedisDB) Check(ctx context.Context) error {
+	return r.Conn.Ping(ctx).Err()
+}
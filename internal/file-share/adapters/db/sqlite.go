@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 
 	_ "modernc.org/sqlite"
 )
@@ -12,7 +13,14 @@ type SqliteDB struct {
 }
 
 func NewSqlite(path string) (*SqliteDB, error) {
-	dsn := path
+	// Writers wait for each other instead of failing at once with
+	// SQLITE_BUSY, e.g. when several downloads of one file are counted.
+	// Foreign keys are per connection, so every pooled one enables them.
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	dsn := path + sep + "_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)"
 
 	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
@@ -0,0 +1,457 @@
+package directtransfer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+)
+
+const (
+	broadcastChunkSize    = 32 * 1024
+	broadcastBufferChunks = 32
+	maxBroadcastReceivers = 64
+)
+
+type broadcastReceiver struct {
+	id     string
+	chunks chan []byte
+	gone   chan struct{}
+	once   sync.Once
+
+	mu        sync.Mutex
+	err       error
+	completed bool
+}
+
+func newBroadcastReceiver(id string) *broadcastReceiver {
+	return &broadcastReceiver{
+		id:     id,
+		chunks: make(chan []byte, broadcastBufferChunks),
+		gone:   make(chan struct{}),
+	}
+}
+
+func (r *broadcastReceiver) leave(err error, completed bool) {
+	r.once.Do(func() {
+		r.mu.Lock()
+		r.err = err
+		r.completed = completed
+		r.mu.Unlock()
+		close(r.gone)
+	})
+}
+
+func (r *broadcastReceiver) outcome() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.completed, r.err
+}
+
+// streamError is what the receiver's reader returns once its chunks run out.
+func (r *broadcastReceiver) streamError() error {
+	_, err := r.outcome()
+	if err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+type broadcastReader struct {
+	rcv     *broadcastReceiver
+	pending []byte
+}
+
+func (br *broadcastReader) Read(p []byte) (int, error) {
+	if len(br.pending) == 0 {
+		select {
+		case chunk, ok := <-br.rcv.chunks:
+			if !ok {
+				return 0, br.rcv.streamError()
+			}
+			br.pending = chunk
+		case <-br.rcv.gone:
+			_, err := br.rcv.outcome()
+			if err == nil {
+				err = io.ErrClosedPipe
+			}
+			return 0, err
+		}
+	}
+
+	n := copy(p, br.pending)
+	br.pending = br.pending[n:]
+	return n, nil
+}
+
+type broadcastSession struct {
+	mu        sync.Mutex
+	receivers map[string]*broadcastReceiver
+	nextID    uint64
+	hasSender bool
+	started   bool
+	filename  string
+	changed   chan struct{}
+	start     chan struct{}
+}
+
+func newBroadcastSession() *broadcastSession {
+	return &broadcastSession{
+		receivers: make(map[string]*broadcastReceiver),
+		changed:   make(chan struct{}, 1),
+		start:     make(chan struct{}),
+	}
+}
+
+func (s *broadcastSession) notify() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Broadcaster tees one sender's stream to every receiver that joined the same
+// code. Sessions live in this process only.
+type Broadcaster struct {
+	mu       sync.Mutex
+	sessions map[string]*broadcastSession
+}
+
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{
+		sessions: make(map[string]*broadcastSession, 5),
+	}
+}
+
+func (b *Broadcaster) Join(ctx context.Context, code string) (string, *ports.Transfer, error) {
+	if !codeOk(code) {
+		return "", nil, ports.ErrTransferCodeInvalidLength
+	}
+
+	b.mu.Lock()
+	s, ok := b.sessions[code]
+	if !ok {
+		s = newBroadcastSession()
+		b.sessions[code] = s
+	}
+	b.mu.Unlock()
+
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return "", nil, ports.ErrBroadcastStarted
+	}
+	if len(s.receivers) >= maxBroadcastReceivers {
+		s.mu.Unlock()
+		return "", nil, ports.ErrBroadcastFull
+	}
+	s.nextID++
+	rcv := newBroadcastReceiver(strconv.FormatUint(s.nextID, 10))
+	s.receivers[rcv.id] = rcv
+	s.mu.Unlock()
+	s.notify()
+
+	select {
+	case <-s.start:
+		s.mu.Lock()
+		filename := s.filename
+		s.mu.Unlock()
+		return rcv.id, &ports.Transfer{Reader: &broadcastReader{rcv: rcv}, Filename: filename}, nil
+
+	case <-rcv.gone:
+		_, err := rcv.outcome()
+		if err == nil {
+			err = context.Canceled
+		}
+		return "", nil, err
+
+	case <-ctx.Done():
+		_ = b.Leave(code, rcv.id)
+		return "", nil, ctx.Err()
+	}
+}
+
+func (b *Broadcaster) Broadcast(ctx context.Context, code string, filename string, opts ports.BroadcastOptions, src io.Reader) (ports.BroadcastResult, error) {
+	if !codeOk(code) {
+		return ports.BroadcastResult{}, ports.ErrTransferCodeInvalidLength
+	}
+	if opts.Policy != ports.BroadcastPolicyStall && opts.Policy != ports.BroadcastPolicyDrop {
+		return ports.BroadcastResult{}, ports.ErrBroadcastInvalidPolicy
+	}
+
+	b.mu.Lock()
+	s, ok := b.sessions[code]
+	if !ok {
+		s = newBroadcastSession()
+		b.sessions[code] = s
+	}
+	b.mu.Unlock()
+
+	s.mu.Lock()
+	if s.hasSender {
+		s.mu.Unlock()
+		return ports.BroadcastResult{}, ports.ErrTransferCodeExists
+	}
+	s.hasSender = true
+	s.filename = filename
+	s.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		if cur, ok := b.sessions[code]; ok && cur == s {
+			delete(b.sessions, code)
+		}
+		b.mu.Unlock()
+	}()
+
+	receivers, err := b.waitForStart(ctx, s, opts.MinReceivers)
+	if err != nil {
+		s.mu.Lock()
+		for _, rcv := range s.receivers {
+			rcv.leave(err, false)
+		}
+		s.mu.Unlock()
+		return ports.BroadcastResult{}, err
+	}
+
+	hasher := sha256.New()
+	streamErr := b.stream(ctx, opts.Policy, receivers, io.TeeReader(src, hasher))
+	for _, rcv := range receivers {
+		if streamErr != nil {
+			rcv.mu.Lock()
+			if rcv.err == nil {
+				rcv.err = streamErr
+			}
+			rcv.mu.Unlock()
+		}
+		close(rcv.chunks)
+	}
+
+	if streamErr != nil {
+		for _, rcv := range receivers {
+			rcv.leave(streamErr, false)
+		}
+		return ports.BroadcastResult{}, streamErr
+	}
+
+	result := ports.BroadcastResult{
+		Receivers: len(receivers),
+		Digest:    hex.EncodeToString(hasher.Sum(nil)),
+	}
+	for _, rcv := range receivers {
+		select {
+		case <-rcv.gone:
+		case <-ctx.Done():
+			rcv.leave(ctx.Err(), false)
+		}
+
+		if completed, _ := rcv.outcome(); completed {
+			result.Completed++
+		} else {
+			result.Dropped++
+		}
+	}
+
+	return result, nil
+}
+
+func (b *Broadcaster) Start(code string) error {
+	if !codeOk(code) {
+		return ports.ErrTransferCodeInvalidLength
+	}
+
+	s, ok := b.session(code)
+	if !ok {
+		return ports.ErrTransferCodeNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return ports.ErrBroadcastStarted
+	}
+	if !s.hasSender {
+		return ports.ErrTransferCodeNotFound
+	}
+	if len(s.receivers) == 0 {
+		return ports.ErrBroadcastNoReceivers
+	}
+
+	s.started = true
+	close(s.start)
+	return nil
+}
+
+func (b *Broadcaster) Receivers(code string) (int, error) {
+	if !codeOk(code) {
+		return 0, ports.ErrTransferCodeInvalidLength
+	}
+
+	s, ok := b.session(code)
+	if !ok {
+		return 0, ports.ErrTransferCodeNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.receivers), nil
+}
+
+func (b *Broadcaster) Complete(code string, receiverID string) error {
+	rcv, err := b.receiver(code, receiverID)
+	if err != nil {
+		return err
+	}
+
+	rcv.leave(nil, true)
+	return nil
+}
+
+func (b *Broadcaster) Leave(code string, receiverID string) error {
+	if !codeOk(code) {
+		return ports.ErrTransferCodeInvalidLength
+	}
+
+	s, ok := b.session(code)
+	if !ok {
+		return ports.ErrTransferCodeNotFound
+	}
+
+	s.mu.Lock()
+	rcv, ok := s.receivers[receiverID]
+	if ok && !s.started {
+		delete(s.receivers, receiverID)
+	}
+	empty := len(s.receivers) == 0 && !s.hasSender
+	s.mu.Unlock()
+
+	if empty {
+		b.mu.Lock()
+		if cur, ok := b.sessions[code]; ok && cur == s {
+			delete(b.sessions, code)
+		}
+		b.mu.Unlock()
+	}
+
+	if !ok {
+		return ports.ErrTransferCodeNotFound
+	}
+
+	rcv.leave(context.Canceled, false)
+	s.notify()
+	return nil
+}
+
+func (b *Broadcaster) waitForStart(ctx context.Context, s *broadcastSession, minReceivers int) ([]*broadcastReceiver, error) {
+	for {
+		s.mu.Lock()
+		if !s.started && minReceivers > 0 && len(s.receivers) >= minReceivers {
+			s.started = true
+			close(s.start)
+		}
+		if s.started {
+			receivers := make([]*broadcastReceiver, 0, len(s.receivers))
+			for _, rcv := range s.receivers {
+				receivers = append(receivers, rcv)
+			}
+			s.mu.Unlock()
+			return receivers, nil
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-s.changed:
+		case <-s.start:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (b *Broadcaster) stream(ctx context.Context, policy ports.BroadcastPolicy, receivers []*broadcastReceiver, src io.Reader) error {
+	active := make([]*broadcastReceiver, len(receivers))
+	copy(active, receivers)
+
+	buf := make([]byte, broadcastChunkSize)
+	for {
+		n, readErr := src.Read(buf)
+		if n > 0 {
+			chunk := make([]byte, n)
+			copy(chunk, buf[:n])
+
+			next := active[:0]
+			for _, rcv := range active {
+				if b.deliver(ctx, policy, rcv, chunk) {
+					next = append(next, rcv)
+				}
+			}
+			active = next
+
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+// deliver hands one chunk to a receiver and reports whether it is still active.
+func (b *Broadcaster) deliver(ctx context.Context, policy ports.BroadcastPolicy, rcv *broadcastReceiver, chunk []byte) bool {
+	if policy == ports.BroadcastPolicyDrop {
+		select {
+		case <-rcv.gone:
+			return false
+		case rcv.chunks <- chunk:
+			return true
+		default:
+			rcv.leave(ports.ErrSlowReceiver, false)
+			return false
+		}
+	}
+
+	select {
+	case rcv.chunks <- chunk:
+		return true
+	case <-rcv.gone:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (b *Broadcaster) session(code string) (*broadcastSession, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.sessions[code]
+	return s, ok
+}
+
+func (b *Broadcaster) receiver(code string, receiverID string) (*broadcastReceiver, error) {
+	if !codeOk(code) {
+		return nil, ports.ErrTransferCodeInvalidLength
+	}
+
+	s, ok := b.session(code)
+	if !ok {
+		return nil, ports.ErrTransferCodeNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rcv, ok := s.receivers[receiverID]
+	if !ok {
+		return nil, ports.ErrTransferCodeNotFound
+	}
+	return rcv, nil
+}
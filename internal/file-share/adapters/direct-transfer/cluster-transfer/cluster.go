@@ -0,0 +1,257 @@
+package clustertransfer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/tracing"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	RelayPathPrefix = "/internal/v1/direct/"
+
+	codeTTL          = 30 * time.Second
+	relayWaitTimeout = 2 * time.Second
+	relayWaitStep    = 50 * time.Millisecond
+)
+
+// ClusterTransfer registers receiver codes in Redis together with the node that
+// holds the receiving connection. A sender landing on another node relays its
+// stream to the owner over an authenticated internal HTTP hop.
+type ClusterTransfer struct {
+	local  ports.DirectTransfer
+	db     *redis.Client
+	nodeID string
+	secret []byte
+	client *http.Client
+
+	mu       sync.Mutex
+	sessions map[string]context.CancelFunc
+}
+
+func New(local ports.DirectTransfer, db *redis.Client, nodeURL string, secret []byte) *ClusterTransfer {
+	return &ClusterTransfer{
+		local:    local,
+		db:       db,
+		nodeID:   strings.TrimSuffix(nodeURL, "/"),
+		secret:   secret,
+		client:   &http.Client{Transport: tracing.Transport(nil)},
+		sessions: make(map[string]context.CancelFunc, 5),
+	}
+}
+
+func (cT *ClusterTransfer) Receive(ctx context.Context, code string) (*ports.Transfer, error) {
+	if !codeOk(code) {
+		return nil, ports.ErrTransferCodeInvalidLength
+	}
+
+	ok, err := cT.db.SetNX(ctx, codeKey(code), cT.nodeID, codeTTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ports.ErrTransferCodeExists
+	}
+
+	sessionCtx, stop := context.WithCancel(context.Background())
+	cT.mu.Lock()
+	cT.sessions[code] = stop
+	cT.mu.Unlock()
+	go cT.keepAlive(sessionCtx, code)
+
+	tr, err := cT.local.Receive(ctx, code)
+	if err != nil {
+		cT.release(code)
+		return nil, err
+	}
+
+	return tr, nil
+}
+
+func (cT *ClusterTransfer) Send(ctx context.Context, code string, filename string, expectedDigest string, src io.Reader) (string, error) {
+	if !codeOk(code) {
+		return "", ports.ErrTransferCodeInvalidLength
+	}
+
+	owner, err := cT.db.Get(ctx, codeKey(code)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ports.ErrTransferCodeNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if owner == cT.nodeID {
+		return cT.local.Send(ctx, code, filename, expectedDigest, src)
+	}
+
+	return cT.relay(ctx, owner, code, filename, expectedDigest, src)
+}
+
+func (cT *ClusterTransfer) Complete(code string) error {
+	if !codeOk(code) {
+		return ports.ErrTransferCodeInvalidLength
+	}
+
+	cT.release(code)
+	return cT.local.Complete(code)
+}
+
+func (cT *ClusterTransfer) Cancel(code string) error {
+	if !codeOk(code) {
+		return ports.ErrTransferCodeInvalidLength
+	}
+
+	cT.release(code)
+	return cT.local.Cancel(code)
+}
+
+// Relay returns the transfer used behind the internal relay endpoint. Its Send
+// only targets receivers connected to this node and briefly waits for a
+// receiver that is registered in Redis but not yet paired locally.
+func (cT *ClusterTransfer) Relay() ports.DirectTransfer {
+	return relayTarget{local: cT.local}
+}
+
+// VerifyRelay checks the signature sent by another node on the relay hop.
+// The query string is part of the signed target, so none can be added.
+func (cT *ClusterTransfer) VerifyRelay(r *http.Request) error {
+	return verify(cT.secret, r.Method, r.URL.RequestURI(), r.Header, time.Now())
+}
+
+func (cT *ClusterTransfer) relay(ctx context.Context, owner, code, filename, expectedDigest string, src io.Reader) (string, error) {
+	path := RelayPathPrefix + code
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, owner+path, src)
+	if err != nil {
+		return "", err
+	}
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set(headerFilename, url.QueryEscape(filename))
+	req.Header.Set(headerTimestamp, ts)
+	if expectedDigest != "" {
+		req.Header.Set(headerDigest, formatDigest(expectedDigest))
+	}
+	req.Header.Set(headerSignature, sign(cT.secret, http.MethodPut, path, req.Header))
+
+	res, err := cT.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ports.ErrTransferRelayFailed, err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode == http.StatusNoContent || res.StatusCode == http.StatusOK {
+		return parseDigest(res.Header.Get(headerDigest)), nil
+	}
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	_ = json.NewDecoder(io.LimitReader(res.Body, 4096)).Decode(&body)
+
+	switch body.Code {
+	case "TRANSFER_CODE_NOT_FOUND":
+		return "", ports.ErrTransferCodeNotFound
+	case "TRANSFER_CODE_INVALID":
+		return "", ports.ErrTransferCodeInvalidLength
+	case "DIGEST_MISMATCH":
+		return "", ports.ErrDigestMismatch
+	case "TRANSFER_INCOMPLETE":
+		return "", ports.ErrTransferIncomplete
+	case "REQUEST_CANCELLED":
+		return "", context.Canceled
+	}
+
+	return "", fmt.Errorf("%w: owner responded with %d", ports.ErrTransferRelayFailed, res.StatusCode)
+}
+
+func (cT *ClusterTransfer) keepAlive(ctx context.Context, code string) {
+	ticker := time.NewTicker(codeTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = cT.db.Expire(ctx, codeKey(code), codeTTL).Err()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (cT *ClusterTransfer) release(code string) {
+	cT.mu.Lock()
+	stop, ok := cT.sessions[code]
+	delete(cT.sessions, code)
+	cT.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_ = releaseScript.Run(ctx, cT.db, []string{codeKey(code)}, cT.nodeID).Err()
+}
+
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+type relayTarget struct {
+	local ports.DirectTransfer
+}
+
+func (rT relayTarget) Receive(ctx context.Context, code string) (*ports.Transfer, error) {
+	return rT.local.Receive(ctx, code)
+}
+
+func (rT relayTarget) Send(ctx context.Context, code string, filename string, expectedDigest string, src io.Reader) (string, error) {
+	deadline := time.Now().Add(relayWaitTimeout)
+
+	for {
+		digest, err := rT.local.Send(ctx, code, filename, expectedDigest, src)
+		if !errors.Is(err, ports.ErrTransferCodeNotFound) || time.Now().After(deadline) {
+			return digest, err
+		}
+
+		select {
+		case <-time.After(relayWaitStep):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+func (rT relayTarget) Complete(code string) error {
+	return rT.local.Complete(code)
+}
+
+func (rT relayTarget) Cancel(code string) error {
+	return rT.local.Cancel(code)
+}
+
+func codeKey(code string) string {
+	return "direct:" + code
+}
+
+func codeOk(code string) bool {
+	return len(code) == 16
+}
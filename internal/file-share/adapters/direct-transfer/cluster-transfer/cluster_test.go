@@ -0,0 +1,296 @@
+package clustertransfer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/api/controllers"
+	"github.com/Miklakapi/go-file-share/internal/api/middleware"
+	directtransfer "github.com/Miklakapi/go-file-share/internal/file-share/adapters/direct-transfer"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func TestRelayBetweenNodes(t *testing.T) {
+	db := newFakeRedis(t)
+	owner := startNode(t, db)
+	sender := startNode(t, db)
+
+	payload := bytes.Repeat([]byte("relayed bytes\n"), 64<<10)
+	sum := sha256.Sum256(payload)
+	digest := hex.EncodeToString(sum[:])
+
+	const code = "relay00000000001"
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	type received struct {
+		filename string
+		data     []byte
+		err      error
+	}
+	got := make(chan received, 1)
+	go func() {
+		tr, err := owner.Receive(ctx, code)
+		if err != nil {
+			got <- received{err: err}
+			return
+		}
+		data, err := io.ReadAll(tr.Reader)
+		got <- received{filename: tr.Filename, data: data, err: err}
+		_ = owner.Complete(code)
+	}()
+	waitForCode(t, db, code)
+
+	sent, err := sender.Send(ctx, code, "report 1.txt", digest, bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if sent != digest {
+		t.Errorf("Send returned digest %q, want %q", sent, digest)
+	}
+
+	r := <-got
+	if r.err != nil {
+		t.Fatalf("receive: %v", r.err)
+	}
+	if r.filename != "report 1.txt" {
+		t.Errorf("filename = %q, want %q", r.filename, "report 1.txt")
+	}
+	if !bytes.Equal(r.data, payload) {
+		t.Errorf("received %d bytes differing from the %d sent", len(r.data), len(payload))
+	}
+}
+
+func TestRelayDigestMismatch(t *testing.T) {
+	db := newFakeRedis(t)
+	owner := startNode(t, db)
+	sender := startNode(t, db)
+
+	const code = "relay00000000002"
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	go func() {
+		tr, err := owner.Receive(ctx, code)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, tr.Reader)
+	}()
+	waitForCode(t, db, code)
+
+	wrong := strings.Repeat("00", sha256.Size)
+	_, err := sender.Send(ctx, code, "a.txt", wrong, strings.NewReader("not what was announced"))
+	if !errors.Is(err, ports.ErrDigestMismatch) {
+		t.Fatalf("Send error = %v, want %v", err, ports.ErrDigestMismatch)
+	}
+}
+
+func TestVerifyRelayCoversHeaders(t *testing.T) {
+	cT := New(directtransfer.New(), nil, "http://node", testSecret)
+	signed := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPut, RelayPathPrefix+"relay00000000003", nil)
+		req.Header.Set(headerFilename, "a.txt")
+		req.Header.Set(headerTimestamp, strconv.FormatInt(time.Now().Unix(), 10))
+		req.Header.Set(headerDigest, formatDigest(strings.Repeat("ab", sha256.Size)))
+		req.Header.Set(headerSignature, sign(testSecret, req.Method, req.URL.Path, req.Header))
+		return req
+	}
+
+	if err := cT.VerifyRelay(signed()); err != nil {
+		t.Fatalf("untouched request: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(r *http.Request)
+	}{
+		{"filename", func(r *http.Request) { r.Header.Set(headerFilename, "b.exe") }},
+		{"digest", func(r *http.Request) { r.Header.Set(headerDigest, formatDigest(strings.Repeat("cd", sha256.Size))) }},
+		{"digest removed", func(r *http.Request) { r.Header.Del(headerDigest) }},
+		{"query", func(r *http.Request) { r.URL.RawQuery = "filename=b.exe" }},
+		{"code", func(r *http.Request) { r.URL.Path = RelayPathPrefix + "relay00000000004" }},
+		{"stale", func(r *http.Request) {
+			r.Header.Set(headerTimestamp, strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+			r.Header.Set(headerSignature, sign(testSecret, r.Method, r.URL.Path, r.Header))
+		}},
+		{"other secret", func(r *http.Request) {
+			r.Header.Set(headerSignature, sign([]byte("another secret of thirty-two b.."), r.Method, r.URL.Path, r.Header))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := signed()
+			tt.tamper(req)
+			if err := cT.VerifyRelay(req); !errors.Is(err, ports.ErrInvalidRelaySignature) {
+				t.Fatalf("VerifyRelay = %v, want %v", err, ports.ErrInvalidRelaySignature)
+			}
+		})
+	}
+}
+
+// startNode runs a cluster node serving the internal relay endpoint the way
+// the application routes it.
+func startNode(t *testing.T, db *redis.Client) *ClusterTransfer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+	srv := httptest.NewUnstartedServer(engine)
+	cT := New(directtransfer.New(), db, "http://"+srv.Listener.Addr().String(), testSecret)
+
+	internal := engine.Group("/internal/v1", middleware.RelayAuthMiddleware(cT.VerifyRelay), middleware.ErrorMiddleware())
+	internal.PUT("/direct/:code", controllers.NewDirectController(cT.Relay()).UploadRaw)
+
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return cT
+}
+
+func waitForCode(t *testing.T, db *redis.Client, code string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if err := db.Get(context.Background(), codeKey(code)).Err(); err == nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("receiver for %s never registered", code)
+}
+
+// newFakeRedis serves the handful of commands ClusterTransfer uses over
+// RESP2, enough to run several nodes in one process.
+func newFakeRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{data: map[string]string{}}
+	go f.serve(ln)
+
+	db := redis.NewClient(&redis.Options{Addr: ln.Addr().String(), Protocol: 2, DisableIdentity: true})
+	t.Cleanup(func() {
+		_ = db.Close()
+		_ = ln.Close()
+	})
+	return db
+}
+
+type fakeRedis struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func (f *fakeRedis) serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, f.exec(args)); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeRedis) exec(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "SET":
+		nx := false
+		for _, opt := range args[3:] {
+			nx = nx || strings.EqualFold(opt, "NX")
+		}
+		if _, ok := f.data[args[1]]; ok && nx {
+			return "$-1\r\n"
+		}
+		f.data[args[1]] = args[2]
+		return "+OK\r\n"
+	case "GET":
+		v, ok := f.data[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	case "EXPIRE":
+		if _, ok := f.data[args[1]]; ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "EVALSHA":
+		return "-NOSCRIPT No matching script.\r\n"
+	case "EVAL":
+		// The only script is the compare-and-delete release.
+		if f.data[args[3]] == args[4] {
+			delete(f.data, args[3])
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("bad command header %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, fmt.Errorf("bad bulk header %q", line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
@@ -0,0 +1,84 @@
+package clustertransfer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+)
+
+const (
+	headerTimestamp = "X-Relay-Timestamp"
+	headerSignature = "X-Relay-Signature"
+	headerDigest    = "Repr-Digest"
+	headerFilename  = "X-Filename"
+
+	maxClockSkew = 30 * time.Second
+)
+
+// sign covers the request line and every header the owner acts on. The body
+// is covered through its Repr-Digest, which the owner checks the stream
+// against; a relay without one carries a body only the sender vouched for.
+func sign(secret []byte, method, path string, h http.Header) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.Join([]string{
+		method,
+		path,
+		h.Get(headerTimestamp),
+		h.Get(headerFilename),
+		h.Get(headerDigest),
+	}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func verify(secret []byte, method, path string, h http.Header, now time.Time) error {
+	timestamp, signature := h.Get(headerTimestamp), h.Get(headerSignature)
+	if len(secret) == 0 || timestamp == "" || signature == "" {
+		return ports.ErrInvalidRelaySignature
+	}
+
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ports.ErrInvalidRelaySignature
+	}
+	skew := now.Sub(time.Unix(sec, 0))
+	if skew > maxClockSkew || skew < -maxClockSkew {
+		return ports.ErrInvalidRelaySignature
+	}
+
+	expected := sign(secret, method, path, h)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ports.ErrInvalidRelaySignature
+	}
+
+	return nil
+}
+
+func formatDigest(hexDigest string) string {
+	raw, err := hex.DecodeString(hexDigest)
+	if err != nil {
+		return ""
+	}
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(raw) + ":"
+}
+
+func parseDigest(header string) string {
+	for _, item := range strings.Split(header, ",") {
+		algo, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || !strings.EqualFold(algo, "sha-256") {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(strings.Trim(value, ":"))
+		if err != nil || len(raw) != sha256.Size {
+			return ""
+		}
+		return hex.EncodeToString(raw)
+	}
+	return ""
+}
@@ -2,7 +2,10 @@ package directtransfer
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
+	"strings"
 	"sync"
 
 	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
@@ -14,6 +17,7 @@ type connection struct {
 	once        sync.Once
 	mu          sync.Mutex
 	pw          *io.PipeWriter
+	err         error
 }
 
 func newConnection() *connection {
@@ -26,6 +30,7 @@ func newConnection() *connection {
 func (c *connection) close(err error) {
 	c.once.Do(func() {
 		c.mu.Lock()
+		c.err = err
 		if c.pw != nil {
 			if err != nil {
 				_ = c.pw.CloseWithError(err)
@@ -40,6 +45,12 @@ func (c *connection) close(err error) {
 	})
 }
 
+func (c *connection) result() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
 func (c *connection) setWriter(pw *io.PipeWriter) {
 	c.mu.Lock()
 	c.pw = pw
@@ -97,16 +108,16 @@ func (dT *DirectTransfer) Receive(ctx context.Context, code string) (*ports.Tran
 	}
 }
 
-func (dT *DirectTransfer) Send(ctx context.Context, code string, filename string, src io.Reader) error {
+func (dT *DirectTransfer) Send(ctx context.Context, code string, filename string, expectedDigest string, src io.Reader) (string, error) {
 	if !codeOk(code) {
-		return ports.ErrTransferCodeInvalidLength
+		return "", ports.ErrTransferCodeInvalidLength
 	}
 
 	dT.mu.RLock()
 	c, ok := dT.connections[code]
 	dT.mu.RUnlock()
 	if !ok {
-		return ports.ErrTransferCodeNotFound
+		return "", ports.ErrTransferCodeNotFound
 	}
 
 	pr, pw := io.Pipe()
@@ -119,24 +130,28 @@ func (dT *DirectTransfer) Send(ctx context.Context, code string, filename string
 		c.mu.Unlock()
 	}()
 
-	tr := &ports.Transfer{Reader: pr, Filename: filename}
+	tr := &ports.Transfer{Reader: pr, Filename: filename, Digest: expectedDigest}
 	select {
 	case c.transfer <- tr:
 
 	case <-c.sessionDone:
 		_ = pr.Close()
 		_ = pw.CloseWithError(context.Canceled)
-		return context.Canceled
+		return "", context.Canceled
 	case <-ctx.Done():
 		_ = pr.Close()
 		_ = pw.CloseWithError(ctx.Err())
 		c.close(ctx.Err())
-		return ctx.Err()
+		return "", ctx.Err()
 	}
 
+	hasher := sha256.New()
 	copyDone := make(chan error, 1)
 	go func() {
-		_, err := io.Copy(pw, src)
+		_, err := io.Copy(pw, io.TeeReader(src, hasher))
+		if err == nil && expectedDigest != "" && !strings.EqualFold(expectedDigest, hex.EncodeToString(hasher.Sum(nil))) {
+			err = ports.ErrDigestMismatch
+		}
 		if err != nil {
 			_ = pw.CloseWithError(err)
 		} else {
@@ -149,29 +164,53 @@ func (dT *DirectTransfer) Send(ctx context.Context, code string, filename string
 	case err := <-copyDone:
 		if err != nil {
 			c.close(err)
-			return err
+			return "", err
 		}
-		c.close(nil)
-		return nil
 
 	case <-c.sessionDone:
 		_ = pw.CloseWithError(context.Canceled)
-		err := <-copyDone
-		if err == nil {
-			err = context.Canceled
+		if err := <-copyDone; err != nil {
+			return "", context.Canceled
 		}
-		c.close(err)
-		return context.Canceled
 
 	case <-ctx.Done():
 		_ = pw.CloseWithError(ctx.Err())
-		err := <-copyDone
+		<-copyDone
 		c.close(ctx.Err())
-		if err == nil {
-			return ctx.Err()
-		}
-		return ctx.Err()
+		return "", ctx.Err()
+	}
+
+	// The pipe only proves the receiver read every byte; wait until it reports
+	// that the bytes were also delivered.
+	select {
+	case <-c.sessionDone:
+	case <-ctx.Done():
+		c.close(ctx.Err())
+		return "", ctx.Err()
+	}
+
+	if c.result() != nil {
+		return "", ports.ErrTransferIncomplete
 	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func (dT *DirectTransfer) Complete(code string) error {
+	if !codeOk(code) {
+		return ports.ErrTransferCodeInvalidLength
+	}
+
+	dT.mu.RLock()
+	c, ok := dT.connections[code]
+	dT.mu.RUnlock()
+
+	if !ok {
+		return ports.ErrTransferCodeNotFound
+	}
+
+	c.close(nil)
+	return nil
 }
 
 func (dT *DirectTransfer) Cancel(code string) error {
@@ -0,0 +1,154 @@
+package directtransfer
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+)
+
+const (
+	signalBuffer     = 64
+	maxQueuedSignals = 64
+)
+
+type signalPeer struct {
+	ch chan ports.Signal
+}
+
+type signalSession struct {
+	peers  map[ports.SignalRole]*signalPeer
+	queued map[ports.SignalRole][]ports.Signal
+}
+
+// Signaling relays WebRTC negotiation messages between the two peers of a
+// direct transfer code. Signals sent before the other side joined are queued.
+type Signaling struct {
+	mu       sync.Mutex
+	sessions map[string]*signalSession
+}
+
+func NewSignaling() *Signaling {
+	return &Signaling{
+		sessions: make(map[string]*signalSession, 5),
+	}
+}
+
+func (sg *Signaling) Join(ctx context.Context, code string, role ports.SignalRole) (<-chan ports.Signal, ports.UnsubscribeFunc, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if !codeOk(code) {
+		return nil, nil, ports.ErrTransferCodeInvalidLength
+	}
+	other, ok := otherRole(role)
+	if !ok {
+		return nil, nil, ports.ErrInvalidSignal
+	}
+
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	s, ok := sg.sessions[code]
+	if !ok {
+		s = &signalSession{
+			peers:  make(map[ports.SignalRole]*signalPeer, 2),
+			queued: make(map[ports.SignalRole][]ports.Signal, 2),
+		}
+		sg.sessions[code] = s
+	}
+	if _, ok := s.peers[role]; ok {
+		return nil, nil, ports.ErrSignalPeerJoined
+	}
+
+	p := &signalPeer{ch: make(chan ports.Signal, signalBuffer)}
+	s.peers[role] = p
+
+	if otherPeer, ok := s.peers[other]; ok {
+		trySignal(otherPeer.ch, ports.Signal{Kind: ports.SignalPeerJoined, From: role})
+		trySignal(p.ch, ports.Signal{Kind: ports.SignalPeerJoined, From: other})
+	}
+	for _, queued := range s.queued[role] {
+		trySignal(p.ch, queued)
+	}
+	delete(s.queued, role)
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			sg.mu.Lock()
+			defer sg.mu.Unlock()
+
+			if cur, ok := s.peers[role]; ok && cur == p {
+				delete(s.peers, role)
+			}
+			if otherPeer, ok := s.peers[other]; ok {
+				trySignal(otherPeer.ch, ports.Signal{Kind: ports.SignalBye, From: role})
+			}
+			if len(s.peers) == 0 {
+				if cur, ok := sg.sessions[code]; ok && cur == s {
+					delete(sg.sessions, code)
+				}
+			}
+			close(p.ch)
+		})
+	}
+
+	return p.ch, unsubscribe, nil
+}
+
+func (sg *Signaling) Send(ctx context.Context, code string, signal ports.Signal) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !codeOk(code) {
+		return ports.ErrTransferCodeInvalidLength
+	}
+	to, ok := otherRole(signal.From)
+	if !ok {
+		return ports.ErrInvalidSignal
+	}
+
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	s, ok := sg.sessions[code]
+	if !ok {
+		return ports.ErrTransferCodeNotFound
+	}
+	if _, ok := s.peers[signal.From]; !ok {
+		return ports.ErrTransferCodeNotFound
+	}
+
+	if p, ok := s.peers[to]; ok {
+		if !trySignal(p.ch, signal) {
+			return ports.ErrSignalBufferFull
+		}
+		return nil
+	}
+
+	if len(s.queued[to]) >= maxQueuedSignals {
+		return ports.ErrSignalBufferFull
+	}
+	s.queued[to] = append(s.queued[to], signal)
+	return nil
+}
+
+func otherRole(role ports.SignalRole) (ports.SignalRole, bool) {
+	switch role {
+	case ports.SignalRoleSender:
+		return ports.SignalRoleReceiver, true
+	case ports.SignalRoleReceiver:
+		return ports.SignalRoleSender, true
+	}
+	return "", false
+}
+
+func trySignal(ch chan ports.Signal, signal ports.Signal) bool {
+	select {
+	case ch <- signal:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,173 @@
+package directtransfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+)
+
+const testCode = "signal0000000001"
+
+// peer is an in-process stand-in for a browser on one side of a code.
+type peer struct {
+	t           *testing.T
+	sg          *Signaling
+	role        ports.SignalRole
+	signals     <-chan ports.Signal
+	unsubscribe ports.UnsubscribeFunc
+}
+
+func join(t *testing.T, sg *Signaling, role ports.SignalRole) *peer {
+	t.Helper()
+	signals, unsubscribe, err := sg.Join(context.Background(), testCode, role)
+	if err != nil {
+		t.Fatalf("%s Join: %v", role, err)
+	}
+	t.Cleanup(unsubscribe)
+	return &peer{t: t, sg: sg, role: role, signals: signals, unsubscribe: unsubscribe}
+}
+
+func (p *peer) send(kind ports.SignalKind, payload string) {
+	p.t.Helper()
+	if err := p.sg.Send(context.Background(), testCode, ports.Signal{Kind: kind, From: p.role, Payload: payload}); err != nil {
+		p.t.Fatalf("%s sending %s: %v", p.role, kind, err)
+	}
+}
+
+func (p *peer) expect(kind ports.SignalKind, from ports.SignalRole, payload string) {
+	p.t.Helper()
+	select {
+	case got, ok := <-p.signals:
+		want := ports.Signal{Kind: kind, From: from, Payload: payload}
+		if !ok {
+			p.t.Fatalf("%s: channel closed, want %+v", p.role, want)
+		}
+		if got != want {
+			p.t.Fatalf("%s got %+v, want %+v", p.role, got, want)
+		}
+	case <-time.After(time.Second):
+		p.t.Fatalf("%s: no %s signal", p.role, kind)
+	}
+}
+
+func TestSignalingNegotiation(t *testing.T) {
+	sg := NewSignaling()
+	sender := join(t, sg, ports.SignalRoleSender)
+
+	// The offer and a candidate go out before the receiver is there.
+	sender.send(ports.SignalOffer, "v=0 offer")
+	sender.send(ports.SignalCandidate, "candidate:1")
+
+	receiver := join(t, sg, ports.SignalRoleReceiver)
+	sender.expect(ports.SignalPeerJoined, ports.SignalRoleReceiver, "")
+	receiver.expect(ports.SignalPeerJoined, ports.SignalRoleSender, "")
+	receiver.expect(ports.SignalOffer, ports.SignalRoleSender, "v=0 offer")
+	receiver.expect(ports.SignalCandidate, ports.SignalRoleSender, "candidate:1")
+
+	receiver.send(ports.SignalAnswer, "v=0 answer")
+	sender.expect(ports.SignalAnswer, ports.SignalRoleReceiver, "v=0 answer")
+
+	receiver.unsubscribe()
+	sender.expect(ports.SignalBye, ports.SignalRoleReceiver, "")
+	if _, ok := <-receiver.signals; ok {
+		t.Fatal("receiver channel still open after unsubscribe")
+	}
+
+	sender.unsubscribe()
+	err := sg.Send(context.Background(), testCode, ports.Signal{Kind: ports.SignalOffer, From: ports.SignalRoleSender})
+	if !errors.Is(err, ports.ErrTransferCodeNotFound) {
+		t.Fatalf("Send after both left = %v, want %v", err, ports.ErrTransferCodeNotFound)
+	}
+}
+
+func TestSignalingConcurrentPeers(t *testing.T) {
+	sg := NewSignaling()
+	const candidates = 20
+
+	done := make(chan error, 2)
+	run := func(role, other ports.SignalRole) {
+		signals, unsubscribe, err := sg.Join(context.Background(), testCode, role)
+		if err != nil {
+			done <- err
+			return
+		}
+		defer unsubscribe()
+
+		for i := range candidates {
+			signal := ports.Signal{Kind: ports.SignalCandidate, From: role, Payload: fmt.Sprint(i)}
+			if err := sg.Send(context.Background(), testCode, signal); err != nil {
+				done <- err
+				return
+			}
+		}
+
+		next := 0
+		timeout := time.After(2 * time.Second)
+		for next < candidates {
+			select {
+			case s := <-signals:
+				if s.Kind != ports.SignalCandidate {
+					continue
+				}
+				if s.From != other || s.Payload != fmt.Sprint(next) {
+					done <- fmt.Errorf("%s got %+v, want candidate %d from %s", role, s, next, other)
+					return
+				}
+				next++
+			case <-timeout:
+				done <- fmt.Errorf("%s got %d of %d candidates", role, next, candidates)
+				return
+			}
+		}
+		done <- nil
+	}
+	go run(ports.SignalRoleSender, ports.SignalRoleReceiver)
+	go run(ports.SignalRoleReceiver, ports.SignalRoleSender)
+
+	for range 2 {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSignalingRejects(t *testing.T) {
+	sg := NewSignaling()
+	ctx := context.Background()
+
+	if _, _, err := sg.Join(ctx, "short", ports.SignalRoleSender); !errors.Is(err, ports.ErrTransferCodeInvalidLength) {
+		t.Errorf("Join with short code = %v", err)
+	}
+	if _, _, err := sg.Join(ctx, testCode, "observer"); !errors.Is(err, ports.ErrInvalidSignal) {
+		t.Errorf("Join with unknown role = %v", err)
+	}
+
+	err := sg.Send(ctx, testCode, ports.Signal{Kind: ports.SignalOffer, From: ports.SignalRoleSender})
+	if !errors.Is(err, ports.ErrTransferCodeNotFound) {
+		t.Errorf("Send before joining = %v", err)
+	}
+
+	join(t, sg, ports.SignalRoleSender)
+	if _, _, err := sg.Join(ctx, testCode, ports.SignalRoleSender); !errors.Is(err, ports.ErrSignalPeerJoined) {
+		t.Errorf("second sender Join = %v", err)
+	}
+
+	err = sg.Send(ctx, testCode, ports.Signal{Kind: ports.SignalAnswer, From: ports.SignalRoleReceiver})
+	if !errors.Is(err, ports.ErrTransferCodeNotFound) {
+		t.Errorf("Send from a receiver that never joined = %v", err)
+	}
+
+	for i := range maxQueuedSignals {
+		if err := sg.Send(ctx, testCode, ports.Signal{Kind: ports.SignalCandidate, From: ports.SignalRoleSender}); err != nil {
+			t.Fatalf("queued signal %d: %v", i, err)
+		}
+	}
+	err = sg.Send(ctx, testCode, ports.Signal{Kind: ports.SignalCandidate, From: ports.SignalRoleSender})
+	if !errors.Is(err, ports.ErrSignalBufferFull) {
+		t.Errorf("Send past the queue limit = %v, want %v", err, ports.ErrSignalBufferFull)
+	}
+}
@@ -2,6 +2,7 @@ package eventbus
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
 )
@@ -10,6 +11,8 @@ type EventBus struct {
 	mu     sync.RWMutex
 	subs   map[ports.EventName]map[uint64]*sub
 	nextID uint64
+
+	dropped atomic.Uint64
 }
 
 type sub struct {
@@ -69,6 +72,7 @@ func (eb *EventBus) Publish(event ports.Event) error {
 		}
 
 		for tryDrainOne(s.ch) {
+			eb.dropped.Add(1)
 		}
 
 		_ = trySend(s.ch, event)
@@ -77,6 +81,12 @@ func (eb *EventBus) Publish(event ports.Event) error {
 	return nil
 }
 
+// Dropped reports how many queued events were discarded to make room for newer
+// ones because a subscriber was not keeping up.
+func (eb *EventBus) Dropped() uint64 {
+	return eb.dropped.Load()
+}
+
 func trySend(ch chan ports.Event, event ports.Event) (sent bool) {
 	defer func() {
 		_ = recover()
@@ -2,14 +2,20 @@ package filestore
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
 
 	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
 )
 
+// sniffLen is how much content http.DetectContentType considers.
+const sniffLen = 512
+
 type DiskStore struct{}
 
 func (DiskStore) ClearAll(ctx context.Context, uploadDir string) error {
@@ -53,45 +59,52 @@ func (DiskStore) ClearAll(ctx context.Context, uploadDir string) error {
 	return nil
 }
 
-func (DiskStore) Save(ctx context.Context, uploadDir, name string, r io.Reader) (string, int64, error) {
+func (DiskStore) Save(ctx context.Context, uploadDir, name string, r io.Reader) (ports.SavedFile, error) {
 	if err := ctx.Err(); err != nil {
-		return "", 0, err
+		return ports.SavedFile{}, err
 	}
 
 	if uploadDir == "" {
-		return "", 0, ports.ErrEmptyUploadDir
+		return ports.SavedFile{}, ports.ErrEmptyUploadDir
 	}
 
 	if name == "" {
-		return "", 0, ports.ErrEmptyFilename
+		return ports.SavedFile{}, ports.ErrEmptyFilename
 	}
 
 	if r == nil {
-		return "", 0, ports.ErrNilReader
+		return ports.SavedFile{}, ports.ErrNilReader
 	}
 
 	safeName := filepath.Base(name)
 	path := filepath.Join(uploadDir, safeName)
 
 	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return "", 0, err
+		return ports.SavedFile{}, err
 	}
 
 	dst, err := os.Create(path)
 	if err != nil {
-		return "", 0, err
+		return ports.SavedFile{}, err
 	}
 	defer func() {
 		_ = dst.Close()
 	}()
 
-	size, err := io.Copy(dst, r)
+	hasher := sha256.New()
+	head := &sniffBuffer{}
+	size, err := io.Copy(io.MultiWriter(dst, hasher, head), r)
 	if err != nil {
 		_ = os.Remove(path)
-		return "", 0, err
+		return ports.SavedFile{}, err
 	}
 
-	return path, size, nil
+	return ports.SavedFile{
+		Path:        path,
+		Size:        size,
+		SHA256:      hex.EncodeToString(hasher.Sum(nil)),
+		ContentType: http.DetectContentType(head.buf),
+	}, nil
 }
 
 func (DiskStore) Open(ctx context.Context, path string) (io.ReadCloser, error) {
@@ -142,3 +155,129 @@ func (DiskStore) Delete(ctx context.Context, path string) error {
 	}
 	return err
 }
+
+func (DiskStore) Move(ctx context.Context, path, dir string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if path == "" {
+		return "", os.ErrNotExist
+	}
+
+	if dir == "" {
+		return "", ports.ErrEmptyUploadDir
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	target := filepath.Join(dir, filepath.Base(path))
+	if err := os.Rename(path, target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// Copy hardlinks the file when the filesystem allows it and copies the bytes
+// otherwise.
+func (DiskStore) Copy(ctx context.Context, path, dir, name string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if path == "" {
+		return "", os.ErrNotExist
+	}
+
+	if dir == "" {
+		return "", ports.ErrEmptyUploadDir
+	}
+
+	if name == "" {
+		return "", ports.ErrEmptyFilename
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	target := filepath.Join(dir, filepath.Base(name))
+	if err := os.Link(path, target); err == nil {
+		return target, nil
+	} else if errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrExist) {
+		return "", err
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	dst, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(target)
+		return "", err
+	}
+	if err := dst.Close(); err != nil {
+		_ = os.Remove(target)
+		return "", err
+	}
+	return target, nil
+}
+
+func (DiskStore) List(ctx context.Context, uploadDir string) ([]ports.StoredFile, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if uploadDir == "" {
+		return nil, ports.ErrEmptyUploadDir
+	}
+
+	entries, err := os.ReadDir(uploadDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	files := make([]ports.StoredFile, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, ports.StoredFile{
+			Path:    filepath.Join(uploadDir, entry.Name()),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	}
+
+	return files, nil
+}
+
+// sniffBuffer keeps the bytes http.DetectContentType looks at.
+type sniffBuffer struct {
+	buf []byte
+}
+
+func (s *sniffBuffer) Write(p []byte) (int, error) {
+	if room := sniffLen - len(s.buf); room > 0 {
+		s.buf = append(s.buf, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,51 @@
+package filestore
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// DiskHealth reports the upload directory unhealthy when it cannot be written
+// or when less than MinFreeBytes are left on its file system.
+type DiskHealth struct {
+	Dir          string
+	MinFreeBytes uint64
+}
+
+func NewDiskHealth(dir string, minFreeBytes uint64) DiskHealth {
+	return DiskHealth{Dir: dir, MinFreeBytes: minFreeBytes}
+}
+
+func (DiskHealth) Name() string {
+	return "file_store"
+}
+
+func (h DiskHealth) Check(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	probe, err := os.CreateTemp(h.Dir, ".health-*")
+	if err != nil {
+		return fmt.Errorf("upload dir not writable: %w", err)
+	}
+	_, writeErr := probe.Write([]byte("ok"))
+	closeErr := probe.Close()
+	_ = os.Remove(probe.Name())
+	if writeErr != nil {
+		return fmt.Errorf("upload dir not writable: %w", writeErr)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("upload dir not writable: %w", closeErr)
+	}
+
+	free, ok, err := freeSpace(h.Dir)
+	if err != nil {
+		return err
+	}
+	if ok && free < h.MinFreeBytes {
+		return fmt.Errorf("only %d bytes free, need %d", free, h.MinFreeBytes)
+	}
+	return nil
+}
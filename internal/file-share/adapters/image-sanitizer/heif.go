@@ -0,0 +1,312 @@
+package imagesanitizer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+)
+
+const maxHEIFMeta = 4 << 20
+
+var heifBrands = map[string]bool{
+	"heic": true, "heix": true, "heim": true, "heis": true,
+	"hevc": true, "hevx": true, "mif1": true, "msf1": true, "avif": true,
+}
+
+// errMetadataAfterData means the Exif or XMP items sit before the meta box
+// that describes them, which cannot be fixed without buffering the file.
+var errMetadataAfterData = errors.New("heif metadata follows media data")
+
+type byteRange struct {
+	start, end int64
+}
+
+// sanitizeHEIF zeroes the Exif and XMP items in place. Their locations come
+// from the meta box, which precedes the media data in practice; orientation
+// lives in the irot and imir properties and is left alone.
+func sanitizeHEIF(w *bufio.Writer, r *bufio.Reader) error {
+	zw := &zeroingWriter{w: w}
+
+	for {
+		header := make([]byte, 8, 16)
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(header))
+		kind := string(header[4:8])
+		switch size {
+		case 0:
+			if _, err := zw.Write(header); err != nil {
+				return err
+			}
+			_, err := io.Copy(zw, r)
+			return err
+		case 1:
+			header = header[:16]
+			if _, err := io.ReadFull(r, header[8:]); err != nil {
+				return err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:]))
+		}
+		body := size - int64(len(header))
+		if body < 0 {
+			return errMalformed
+		}
+
+		if kind != "meta" {
+			if _, err := zw.Write(header); err != nil {
+				return err
+			}
+			if _, err := io.CopyN(zw, r, body); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if body > maxHEIFMeta {
+			return errMalformed
+		}
+		meta := make([]byte, body)
+		if _, err := io.ReadFull(r, meta); err != nil {
+			return err
+		}
+		ranges, err := metadataRanges(meta, zw.pos+int64(len(header)))
+		if err != nil {
+			return err
+		}
+		for _, rg := range ranges {
+			if rg.start < zw.pos {
+				return errMetadataAfterData
+			}
+		}
+		zw.ranges = append(zw.ranges, ranges...)
+		if _, err := zw.Write(header); err != nil {
+			return err
+		}
+		if _, err := zw.Write(meta); err != nil {
+			return err
+		}
+	}
+}
+
+// metadataRanges returns the absolute file ranges of the Exif and XMP items
+// described by a meta box body starting at offset.
+func metadataRanges(meta []byte, offset int64) ([]byteRange, error) {
+	if len(meta) < 4 {
+		return nil, errMalformed
+	}
+
+	var (
+		targets = map[uint32]bool{}
+		iloc    []byte
+		idat    = int64(-1)
+	)
+	err := eachBox(meta[4:], func(kind string, body []byte, at int) error {
+		switch kind {
+		case "iinf":
+			return parseIinf(body, targets)
+		case "iloc":
+			iloc = body
+		case "idat":
+			idat = offset + 4 + int64(at)
+		}
+		return nil
+	})
+	if err != nil || len(targets) == 0 || iloc == nil {
+		return nil, err
+	}
+	return parseIloc(iloc, targets, idat)
+}
+
+func eachBox(data []byte, fn func(kind string, body []byte, at int) error) error {
+	for pos := 0; pos+8 <= len(data); {
+		size := int(binary.BigEndian.Uint32(data[pos:]))
+		kind := string(data[pos+4 : pos+8])
+		headerLen := 8
+		switch size {
+		case 0:
+			size = len(data) - pos
+		case 1:
+			if pos+16 > len(data) {
+				return errMalformed
+			}
+			size = int(binary.BigEndian.Uint64(data[pos+8:]))
+			headerLen = 16
+		}
+		if size < headerLen || pos+size > len(data) {
+			return errMalformed
+		}
+		if err := fn(kind, data[pos+headerLen:pos+size], pos+headerLen); err != nil {
+			return err
+		}
+		pos += size
+	}
+	return nil
+}
+
+func parseIinf(body []byte, targets map[uint32]bool) error {
+	if len(body) < 6 {
+		return errMalformed
+	}
+	skip := 6
+	if body[0] != 0 {
+		skip = 8
+	}
+	if len(body) < skip {
+		return errMalformed
+	}
+	return eachBox(body[skip:], func(kind string, infe []byte, _ int) error {
+		if kind != "infe" || len(infe) < 4 || infe[0] < 2 {
+			return nil
+		}
+		rd := reader{buf: infe[4:]}
+		var id uint32
+		if infe[0] == 2 {
+			id = uint32(rd.uint(2))
+		} else {
+			id = uint32(rd.uint(4))
+		}
+		rd.uint(2)
+		itemType := string(rd.bytes(4))
+		if rd.err != nil {
+			return errMalformed
+		}
+		switch itemType {
+		case "Exif":
+			targets[id] = true
+		case "mime":
+			rd.cstring()
+			if ct := rd.cstring(); strings.Contains(ct, "rdf+xml") || strings.Contains(ct, "xmp") {
+				targets[id] = true
+			}
+		}
+		return nil
+	})
+}
+
+func parseIloc(body []byte, targets map[uint32]bool, idat int64) ([]byteRange, error) {
+	if len(body) < 4 {
+		return nil, errMalformed
+	}
+	version := body[0]
+	rd := reader{buf: body[4:]}
+	sizes := rd.uint(1)
+	offsetSize, lengthSize := int(sizes>>4), int(sizes&0x0F)
+	sizes = rd.uint(1)
+	baseOffsetSize, indexSize := int(sizes>>4), int(sizes&0x0F)
+	if version == 0 {
+		indexSize = 0
+	}
+
+	var count uint64
+	if version < 2 {
+		count = rd.uint(2)
+	} else {
+		count = rd.uint(4)
+	}
+
+	var ranges []byteRange
+	for i := uint64(0); i < count && rd.err == nil; i++ {
+		var id uint32
+		if version < 2 {
+			id = uint32(rd.uint(2))
+		} else {
+			id = uint32(rd.uint(4))
+		}
+		method := uint64(0)
+		if version > 0 {
+			method = rd.uint(2) & 0x0F
+		}
+		rd.uint(2)
+		base := int64(rd.uint(baseOffsetSize))
+		extents := rd.uint(2)
+		for e := uint64(0); e < extents && rd.err == nil; e++ {
+			rd.uint(indexSize)
+			off := base + int64(rd.uint(offsetSize))
+			length := int64(rd.uint(lengthSize))
+			if !targets[id] {
+				continue
+			}
+			switch {
+			case length == 0 || method > 1 || (method == 1 && idat < 0):
+				return nil, errMalformed
+			case method == 1:
+				off += idat
+			}
+			ranges = append(ranges, byteRange{start: off, end: off + length})
+		}
+	}
+	if rd.err != nil {
+		return nil, errMalformed
+	}
+	return ranges, nil
+}
+
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil || n > len(r.buf) {
+		r.err = errMalformed
+		return nil
+	}
+	out := r.buf[:n]
+	r.buf = r.buf[n:]
+	return out
+}
+
+func (r *reader) uint(n int) uint64 {
+	var v uint64
+	for _, b := range r.bytes(n) {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+func (r *reader) cstring() string {
+	for i, b := range r.buf {
+		if b == 0 {
+			s := string(r.buf[:i])
+			r.buf = r.buf[i+1:]
+			return s
+		}
+	}
+	s := string(r.buf)
+	r.buf = nil
+	return s
+}
+
+// zeroingWriter tracks the absolute output position and blanks any bytes
+// that fall inside ranges.
+type zeroingWriter struct {
+	w      io.Writer
+	pos    int64
+	ranges []byteRange
+}
+
+func (z *zeroingWriter) Write(p []byte) (int, error) {
+	start, end := z.pos, z.pos+int64(len(p))
+	var out []byte
+	for _, rg := range z.ranges {
+		if rg.end <= start || rg.start >= end {
+			continue
+		}
+		if out == nil {
+			out = append([]byte(nil), p...)
+		}
+		clear(out[max(rg.start, start)-start : min(rg.end, end)-start])
+	}
+	if out == nil {
+		out = p
+	}
+	n, err := z.w.Write(out)
+	z.pos += int64(n)
+	return n, err
+}
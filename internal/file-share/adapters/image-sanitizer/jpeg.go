@@ -0,0 +1,164 @@
+package imagesanitizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	markerSOS  = 0xDA
+	markerEOI  = 0xD9
+	markerTEM  = 0x01
+	markerAPP0 = 0xE0
+	markerAPP1 = 0xE1
+	markerAPP2 = 0xE2
+	markerAPPE = 0xEE
+	markerAPPF = 0xEF
+	markerCOM  = 0xFE
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// sanitizeJPEG walks the marker segments, dropping metadata segments, and
+// copies entropy-coded data as is. Anything after EOI, such as the extra
+// images and trailers phones append, is discarded.
+func sanitizeJPEG(w *bufio.Writer, r *bufio.Reader) error {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(soi[:]); err != nil {
+		return err
+	}
+
+	m, err := nextMarker(r)
+	for err == nil {
+		switch {
+		case m == markerEOI:
+			_, err = w.Write([]byte{0xFF, m})
+			return err
+		case m == markerTEM || (m >= 0xD0 && m <= 0xD7):
+			if _, err = w.Write([]byte{0xFF, m}); err == nil {
+				m, err = nextMarker(r)
+			}
+			continue
+		}
+
+		var payload []byte
+		if payload, err = readSegment(r); err != nil {
+			return err
+		}
+		if keep, rewritten := jpegSegment(m, payload); keep {
+			if err = writeSegment(w, m, rewritten); err != nil {
+				return err
+			}
+		}
+		if m == markerSOS {
+			m, err = copyEntropy(w, r)
+		} else {
+			m, err = nextMarker(r)
+		}
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// jpegSegment decides whether a segment survives. Colour profiles and the
+// JFIF and Adobe headers are kept; EXIF is reduced to the orientation.
+func jpegSegment(m byte, payload []byte) (bool, []byte) {
+	switch {
+	case m == markerAPP1 && bytes.HasPrefix(payload, exifHeader):
+		orient, ok := orientation(payload[len(exifHeader):])
+		if !ok {
+			return false, nil
+		}
+		return true, append(append([]byte{}, exifHeader...), minimalTIFF(orient, true)...)
+	case m == markerAPP0, m == markerAPPE:
+		return true, payload
+	case m == markerAPP2:
+		return bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00")), payload
+	case m > markerAPP0 && m <= markerAPPF, m == markerCOM:
+		return false, nil
+	}
+	return true, payload
+}
+
+func nextMarker(r *bufio.Reader) (byte, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != 0xFF {
+		return 0, errMalformed
+	}
+	for b == 0xFF {
+		if b, err = r.ReadByte(); err != nil {
+			return 0, err
+		}
+	}
+	return b, nil
+}
+
+func readSegment(r *bufio.Reader) ([]byte, error) {
+	var size [2]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	n := int(binary.BigEndian.Uint16(size[:]))
+	if n < 2 {
+		return nil, errMalformed
+	}
+	payload := make([]byte, n-2)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+func writeSegment(w *bufio.Writer, m byte, payload []byte) error {
+	if len(payload)+2 > 0xFFFF {
+		return errMalformed
+	}
+	header := []byte{0xFF, m, 0, 0}
+	binary.BigEndian.PutUint16(header[2:], uint16(len(payload)+2))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// copyEntropy copies scan data up to the next marker and returns it. Stuffed
+// 0xFF00 bytes and restart markers are part of the data.
+func copyEntropy(w *bufio.Writer, r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != 0xFF {
+			if err := w.WriteByte(b); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		next, err := r.ReadByte()
+		for err == nil && next == 0xFF {
+			next, err = r.ReadByte()
+		}
+		if err != nil {
+			return 0, err
+		}
+		if next != 0x00 && (next < 0xD0 || next > 0xD7) {
+			return next, nil
+		}
+		if _, err := w.Write([]byte{0xFF, next}); err != nil {
+			return 0, err
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package imagesanitizer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+const maxPNGExif = 1 << 20
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// sanitizePNG drops the text chunks, which carry XMP and free-form
+// metadata, and reduces eXIf to the orientation. Data after IEND is dropped.
+func sanitizePNG(w *bufio.Writer, r *bufio.Reader) error {
+	if _, err := io.CopyN(w, r, int64(len(pngSignature))); err != nil {
+		return err
+	}
+
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		kind := string(header[4:])
+
+		switch kind {
+		case "tEXt", "zTXt", "iTXt":
+			if _, err := r.Discard(int(size) + 4); err != nil {
+				return err
+			}
+			continue
+		case "eXIf":
+			if size > maxPNGExif {
+				return errMalformed
+			}
+			data := make([]byte, size+4)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return err
+			}
+			if orient, ok := orientation(data[:size]); ok {
+				if err := writePNGChunk(w, kind, minimalTIFF(orient, true)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if _, err := w.Write(header[:]); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(w, r, size+4); err != nil {
+			return err
+		}
+		if kind == "IEND" {
+			return nil
+		}
+	}
+}
+
+func writePNGChunk(w *bufio.Writer, kind string, data []byte) error {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], uint32(len(data)))
+	if _, err := w.Write(buf[:]); err != nil {
+		return err
+	}
+
+	crc := crc32.NewIEEE()
+	_, _ = crc.Write([]byte(kind))
+	_, _ = crc.Write(data)
+	if _, err := w.WriteString(kind); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint32(buf[:], crc.Sum32())
+	_, err := w.Write(buf[:])
+	return err
+}
@@ -0,0 +1,104 @@
+package imagesanitizer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var errMalformed = errors.New("malformed image")
+
+// Sanitizer rewrites JPEG, PNG, WebP and HEIF/HEIC metadata in a single pass
+// without decoding the image data.
+type Sanitizer struct{}
+
+func New() Sanitizer {
+	return Sanitizer{}
+}
+
+func (Sanitizer) Sanitize(ctx context.Context, w io.Writer, r io.Reader) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	br := bufio.NewReaderSize(r, 64<<10)
+	head, _ := br.Peek(12)
+
+	bw := bufio.NewWriterSize(w, 64<<10)
+	var err error
+	switch {
+	case bytes.HasPrefix(head, []byte{0xFF, 0xD8, 0xFF}):
+		err = sanitizeJPEG(bw, br)
+	case bytes.HasPrefix(head, pngSignature):
+		err = sanitizePNG(bw, br)
+	case len(head) == 12 && string(head[:4]) == "RIFF" && string(head[8:]) == "WEBP":
+		err = sanitizeWebP(bw, br)
+	case len(head) == 12 && string(head[4:8]) == "ftyp" && heifBrands[string(head[8:])]:
+		err = sanitizeHEIF(bw, br)
+	default:
+		if _, err := io.Copy(bw, br); err != nil {
+			return false, err
+		}
+		return false, bw.Flush()
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, bw.Flush()
+}
+
+const tagOrientation = 0x0112
+
+// orientation reads the Orientation tag from IFD0 of a TIFF structure, the
+// payload of every EXIF block.
+func orientation(tiff []byte) (uint16, bool) {
+	if len(tiff) < 8 {
+		return 0, false
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, false
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0, false
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := range count {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) != tagOrientation {
+			continue
+		}
+		value := order.Uint16(tiff[entry+8:])
+		if value >= 1 && value <= 8 {
+			return value, true
+		}
+	}
+	return 0, false
+}
+
+// minimalTIFF is an EXIF payload holding nothing but the orientation, or an
+// empty IFD when there is none to keep.
+func minimalTIFF(orient uint16, ok bool) []byte {
+	if !ok {
+		return []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 0, 0, 0, 0, 0}
+	}
+	return []byte{
+		'M', 'M', 0, 42, 0, 0, 0, 8,
+		0, 1,
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, byte(orient >> 8), byte(orient), 0, 0,
+		0, 0, 0, 0,
+	}
+}
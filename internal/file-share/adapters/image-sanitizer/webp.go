@@ -0,0 +1,102 @@
+package imagesanitizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	maxWebPExif = 1 << 20
+	vp8xXMPFlag = 0x04
+)
+
+// sanitizeWebP rewrites metadata in place so the RIFF sizes, written before
+// the metadata chunks are seen, stay valid: the EXIF chunk is overwritten
+// with the orientation and zero padding, and the XMP chunk becomes JUNK.
+func sanitizeWebP(w *bufio.Writer, r *bufio.Reader) error {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	remaining := int64(binary.LittleEndian.Uint32(header[4:8])) - 4
+
+	var chunk [8]byte
+	for remaining >= 8 {
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return err
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		padded := size + size&1
+		remaining -= 8 + padded
+
+		switch string(chunk[:4]) {
+		case "VP8X":
+			data := make([]byte, padded)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return err
+			}
+			if len(data) > 0 {
+				data[0] &^= vp8xXMPFlag
+			}
+			if err := writeRIFFChunk(w, chunk[:], data); err != nil {
+				return err
+			}
+		case "EXIF":
+			if size > maxWebPExif {
+				return errMalformed
+			}
+			data := make([]byte, padded)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return err
+			}
+			if err := writeRIFFChunk(w, chunk[:], scrubExif(data[:size], padded)); err != nil {
+				return err
+			}
+		case "XMP ":
+			copy(chunk[:4], "JUNK")
+			if _, err := r.Discard(int(padded)); err != nil {
+				return err
+			}
+			if err := writeRIFFChunk(w, chunk[:], make([]byte, padded)); err != nil {
+				return err
+			}
+		default:
+			if _, err := w.Write(chunk[:]); err != nil {
+				return err
+			}
+			if _, err := io.CopyN(w, r, padded); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// scrubExif replaces an EXIF payload with one of the same length holding only
+// the orientation.
+func scrubExif(data []byte, length int64) []byte {
+	out := make([]byte, length)
+	prefix := 0
+	if bytes.HasPrefix(data, exifHeader) {
+		prefix = copy(out, exifHeader)
+	}
+	orient, ok := orientation(data[prefix:])
+	tiff := minimalTIFF(orient, ok)
+	if int64(prefix+len(tiff)) <= length {
+		copy(out[prefix:], tiff)
+	}
+	return out
+}
+
+func writeRIFFChunk(w *bufio.Writer, header, data []byte) error {
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
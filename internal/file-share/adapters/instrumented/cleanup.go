@@ -0,0 +1,22 @@
+package instrumented
+
+import (
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/metrics"
+)
+
+// NewCleanupObserver returns a callback for the room cleanup job.
+func NewCleanupObserver(reg *metrics.Registry) func(duration time.Duration, deletedRooms int, err error) {
+	runs := reg.Counter("file_share_cleanup_runs_total", "Expired room cleanup runs.", "result")
+	duration := reg.Histogram("file_share_cleanup_duration_seconds", "Expired room cleanup run time.", metrics.DefBuckets)
+	deleted := reg.Counter("file_share_cleanup_deleted_rooms_total", "Rooms removed by the cleanup job.")
+	last := reg.Gauge("file_share_cleanup_last_run_timestamp_seconds", "Unix time of the last cleanup run.")
+
+	return func(d time.Duration, deletedRooms int, err error) {
+		runs.Inc(result(err))
+		duration.Observe(d.Seconds())
+		deleted.Add(float64(deletedRooms))
+		last.Set(float64(time.Now().Unix()))
+	}
+}
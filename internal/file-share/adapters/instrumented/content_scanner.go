@@ -0,0 +1,37 @@
+package instrumented
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/metrics"
+)
+
+type ContentScanner struct {
+	inner    ports.ContentScanner
+	duration *metrics.Histogram
+}
+
+func NewContentScanner(inner ports.ContentScanner, reg *metrics.Registry) *ContentScanner {
+	return &ContentScanner{
+		inner:    inner,
+		duration: reg.Histogram("file_share_content_scan_duration_seconds", "Upload content scan latency by verdict.", metrics.DefBuckets, "scanner", "result"),
+	}
+}
+
+func (cs *ContentScanner) Name() string {
+	return cs.inner.Name()
+}
+
+func (cs *ContentScanner) Scan(ctx context.Context, r io.Reader) (res ports.ScanResult, err error) {
+	defer func(start time.Time) {
+		outcome := result(err)
+		if err == nil && res.Infected {
+			outcome = "infected"
+		}
+		cs.duration.Observe(time.Since(start).Seconds(), cs.inner.Name(), outcome)
+	}(time.Now())
+	return cs.inner.Scan(ctx, r)
+}
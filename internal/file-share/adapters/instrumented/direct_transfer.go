@@ -0,0 +1,142 @@
+package instrumented
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/metrics"
+)
+
+// DirectTransfer tracks pipe sessions from the sender's side: one session is
+// one Send call, from pairing until the receiver acknowledged or failed.
+type DirectTransfer struct {
+	inner    ports.DirectTransfer
+	waiting  *metrics.Gauge
+	active   *metrics.Gauge
+	total    *metrics.Counter
+	duration *metrics.Histogram
+	bytes    *metrics.Counter
+}
+
+func NewDirectTransfer(inner ports.DirectTransfer, reg *metrics.Registry) *DirectTransfer {
+	return &DirectTransfer{
+		inner:    inner,
+		waiting:  reg.Gauge("file_share_direct_receivers_waiting", "Receivers waiting for a sender on a direct transfer code."),
+		active:   reg.Gauge("file_share_direct_transfers_active", "Direct transfer sessions currently streaming.", "mode"),
+		total:    reg.Counter("file_share_direct_transfers_total", "Finished direct transfer sessions.", "mode", "result"),
+		duration: reg.Histogram("file_share_direct_transfer_duration_seconds", "Direct transfer session duration.", metrics.TransferBuckets, "mode"),
+		bytes:    reg.Counter("file_share_direct_transfer_bytes_total", "Bytes streamed by direct transfer senders.", "mode"),
+	}
+}
+
+func (dt *DirectTransfer) Receive(ctx context.Context, code string) (*ports.Transfer, error) {
+	dt.waiting.Add(1)
+	defer dt.waiting.Add(-1)
+	return dt.inner.Receive(ctx, code)
+}
+
+func (dt *DirectTransfer) Send(ctx context.Context, code string, filename string, expectedDigest string, src io.Reader) (string, error) {
+	start := time.Now()
+	dt.active.Add(1, "pipe")
+	defer dt.active.Add(-1, "pipe")
+
+	digest, err := dt.inner.Send(ctx, code, filename, expectedDigest, &countingReader{r: src, counter: dt.bytes, labels: []string{"pipe"}})
+	dt.total.Inc("pipe", transferResult(err))
+	dt.duration.Observe(time.Since(start).Seconds(), "pipe")
+	return digest, err
+}
+
+func (dt *DirectTransfer) Complete(code string) error {
+	return dt.inner.Complete(code)
+}
+
+func (dt *DirectTransfer) Cancel(code string) error {
+	return dt.inner.Cancel(code)
+}
+
+// DirectBroadcast shares the direct transfer metric families with mode="broadcast".
+type DirectBroadcast struct {
+	inner     ports.DirectBroadcast
+	active    *metrics.Gauge
+	total     *metrics.Counter
+	duration  *metrics.Histogram
+	bytes     *metrics.Counter
+	receivers *metrics.Counter
+}
+
+func NewDirectBroadcast(inner ports.DirectBroadcast, reg *metrics.Registry) *DirectBroadcast {
+	return &DirectBroadcast{
+		inner:     inner,
+		active:    reg.Gauge("file_share_direct_transfers_active", "Direct transfer sessions currently streaming.", "mode"),
+		total:     reg.Counter("file_share_direct_transfers_total", "Finished direct transfer sessions.", "mode", "result"),
+		duration:  reg.Histogram("file_share_direct_transfer_duration_seconds", "Direct transfer session duration.", metrics.TransferBuckets, "mode"),
+		bytes:     reg.Counter("file_share_direct_transfer_bytes_total", "Bytes streamed by direct transfer senders.", "mode"),
+		receivers: reg.Counter("file_share_broadcast_receivers_total", "Broadcast receivers by outcome.", "outcome"),
+	}
+}
+
+func (db *DirectBroadcast) Join(ctx context.Context, code string) (string, *ports.Transfer, error) {
+	return db.inner.Join(ctx, code)
+}
+
+func (db *DirectBroadcast) Broadcast(ctx context.Context, code string, filename string, opts ports.BroadcastOptions, src io.Reader) (ports.BroadcastResult, error) {
+	start := time.Now()
+	db.active.Add(1, "broadcast")
+	defer db.active.Add(-1, "broadcast")
+
+	res, err := db.inner.Broadcast(ctx, code, filename, opts, &countingReader{r: src, counter: db.bytes, labels: []string{"broadcast"}})
+	db.total.Inc("broadcast", transferResult(err))
+	db.duration.Observe(time.Since(start).Seconds(), "broadcast")
+	if err == nil {
+		db.receivers.Add(float64(res.Completed), "completed")
+		db.receivers.Add(float64(res.Dropped), "dropped")
+	}
+	return res, err
+}
+
+func (db *DirectBroadcast) Start(code string) error {
+	return db.inner.Start(code)
+}
+
+func (db *DirectBroadcast) Receivers(code string) (int, error) {
+	return db.inner.Receivers(code)
+}
+
+func (db *DirectBroadcast) Complete(code string, receiverID string) error {
+	return db.inner.Complete(code, receiverID)
+}
+
+func (db *DirectBroadcast) Leave(code string, receiverID string) error {
+	return db.inner.Leave(code, receiverID)
+}
+
+func transferResult(err error) string {
+	switch {
+	case err == nil:
+		return "completed"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	case errors.Is(err, ports.ErrDigestMismatch):
+		return "digest_mismatch"
+	case errors.Is(err, ports.ErrTransferIncomplete):
+		return "incomplete"
+	}
+	return "error"
+}
+
+type countingReader struct {
+	r       io.Reader
+	counter *metrics.Counter
+	labels  []string
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		c.counter.Add(float64(n), c.labels...)
+	}
+	return n, err
+}
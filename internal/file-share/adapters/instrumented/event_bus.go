@@ -0,0 +1,57 @@
+package instrumented
+
+import (
+	"sync"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/metrics"
+)
+
+// EventBus counts published events and live subscriptions. Dropped events are
+// exposed when the wrapped bus reports them.
+type EventBus struct {
+	inner       ports.EventPublisherSubscriber
+	published   *metrics.Counter
+	failed      *metrics.Counter
+	subscribers *metrics.Gauge
+}
+
+func NewEventBus(inner ports.EventPublisherSubscriber, reg *metrics.Registry) *EventBus {
+	if d, ok := inner.(interface{ Dropped() uint64 }); ok {
+		reg.CounterFunc("file_share_events_dropped_total", "Events discarded because a subscriber was not keeping up.", func() float64 {
+			return float64(d.Dropped())
+		})
+	}
+
+	return &EventBus{
+		inner:       inner,
+		published:   reg.Counter("file_share_events_published_total", "Events published on the event bus.", "event"),
+		failed:      reg.Counter("file_share_events_publish_errors_total", "Events the event bus failed to publish.", "event"),
+		subscribers: reg.Gauge("file_share_event_subscribers", "Active event bus subscriptions.", "event"),
+	}
+}
+
+func (eb *EventBus) Publish(event ports.Event) error {
+	if err := eb.inner.Publish(event); err != nil {
+		eb.failed.Inc(string(event.Name))
+		return err
+	}
+	eb.published.Inc(string(event.Name))
+	return nil
+}
+
+func (eb *EventBus) Subscribe(name ports.EventName) (<-chan ports.Event, ports.UnsubscribeFunc, error) {
+	ch, unsubscribe, err := eb.inner.Subscribe(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	eb.subscribers.Add(1, string(name))
+	var once sync.Once
+	return ch, func() {
+		unsubscribe()
+		once.Do(func() {
+			eb.subscribers.Add(-1, string(name))
+		})
+	}, nil
+}
@@ -0,0 +1,109 @@
+package instrumented
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/metrics"
+)
+
+// FileStore times every store call and counts bytes written by Save and read
+// back through Open.
+type FileStore struct {
+	inner      ports.FileStore
+	duration   *metrics.Histogram
+	uploaded   *metrics.Counter
+	downloaded *metrics.Counter
+}
+
+func NewFileStore(inner ports.FileStore, reg *metrics.Registry) *FileStore {
+	return &FileStore{
+		inner:      inner,
+		duration:   reg.Histogram("file_share_file_store_duration_seconds", "File store call latency; save covers the whole upload stream.", metrics.DefBuckets, "op", "result"),
+		uploaded:   reg.Counter("file_share_upload_bytes_total", "Bytes saved to the file store."),
+		downloaded: reg.Counter("file_share_download_bytes_total", "Bytes read from the file store."),
+	}
+}
+
+func (fs *FileStore) observe(op string, start time.Time, err *error) {
+	fs.duration.Observe(time.Since(start).Seconds(), op, result(*err))
+}
+
+func (fs *FileStore) ClearAll(ctx context.Context, uploadDir string) (err error) {
+	defer fs.observe("clear_all", time.Now(), &err)
+	return fs.inner.ClearAll(ctx, uploadDir)
+}
+
+func (fs *FileStore) Save(ctx context.Context, uploadDir, name string, r io.Reader) (saved ports.SavedFile, err error) {
+	defer fs.observe("save", time.Now(), &err)
+
+	saved, err = fs.inner.Save(ctx, uploadDir, name, r)
+	if err == nil {
+		fs.uploaded.Add(float64(saved.Size))
+	}
+	return saved, err
+}
+
+func (fs *FileStore) Open(ctx context.Context, path string) (rc io.ReadCloser, err error) {
+	defer fs.observe("open", time.Now(), &err)
+
+	rc, err = fs.inner.Open(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return &countingReadCloser{ReadCloser: rc, counter: fs.downloaded}, nil
+}
+
+func (fs *FileStore) Exists(ctx context.Context, path string) (ok bool, err error) {
+	defer fs.observe("exists", time.Now(), &err)
+	return fs.inner.Exists(ctx, path)
+}
+
+func (fs *FileStore) Delete(ctx context.Context, path string) (err error) {
+	defer fs.observe("delete", time.Now(), &err)
+	return fs.inner.Delete(ctx, path)
+}
+
+func (fs *FileStore) Move(ctx context.Context, path, dir string) (_ string, err error) {
+	defer fs.observe("move", time.Now(), &err)
+	return fs.inner.Move(ctx, path, dir)
+}
+
+func (fs *FileStore) Copy(ctx context.Context, path, dir, name string) (_ string, err error) {
+	defer fs.observe("copy", time.Now(), &err)
+	return fs.inner.Copy(ctx, path, dir, name)
+}
+
+func (fs *FileStore) List(ctx context.Context, uploadDir string) (files []ports.StoredFile, err error) {
+	defer fs.observe("list", time.Now(), &err)
+	return fs.inner.List(ctx, uploadDir)
+}
+
+type countingReadCloser struct {
+	io.ReadCloser
+	counter *metrics.Counter
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 {
+		c.counter.Add(float64(n))
+	}
+	return n, err
+}
+
+// ReadAt is forwarded so archives can be read in place.
+func (c *countingReadCloser) ReadAt(p []byte, off int64) (int, error) {
+	ra, ok := c.ReadCloser.(io.ReaderAt)
+	if !ok {
+		return 0, errors.ErrUnsupported
+	}
+	n, err := ra.ReadAt(p, off)
+	if n > 0 {
+		c.counter.Add(float64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,39 @@
+package instrumented
+
+import (
+	"context"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/metrics"
+)
+
+type PasswordHasher struct {
+	inner    ports.PasswordHasher
+	duration *metrics.Histogram
+}
+
+func NewPasswordHasher(inner ports.PasswordHasher, reg *metrics.Registry) *PasswordHasher {
+	return &PasswordHasher{
+		inner:    inner,
+		duration: reg.Histogram("file_share_password_hash_duration_seconds", "Password hashing and verification latency.", metrics.DefBuckets, "op", "result"),
+	}
+}
+
+func (ph *PasswordHasher) Hash(ctx context.Context, plain string) (hash string, err error) {
+	defer func(start time.Time) {
+		ph.duration.Observe(time.Since(start).Seconds(), "hash", result(err))
+	}(time.Now())
+	return ph.inner.Hash(ctx, plain)
+}
+
+func (ph *PasswordHasher) Verify(ctx context.Context, plain, hash string) (ok bool, err error) {
+	defer func(start time.Time) {
+		outcome := result(err)
+		if err == nil && !ok {
+			outcome = "mismatch"
+		}
+		ph.duration.Observe(time.Since(start).Seconds(), "verify", outcome)
+	}(time.Now())
+	return ph.inner.Verify(ctx, plain, hash)
+}
@@ -0,0 +1,184 @@
+package instrumented
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/metrics"
+	"github.com/google/uuid"
+)
+
+const (
+	scrapeTimeout = 5 * time.Second
+	// statsMaxAge spaces out the repository sums behind the gauges, which
+	// may still mean a scan of every room.
+	statsMaxAge = 30 * time.Second
+)
+
+// RoomRepository times every repository call and, on scrape, refreshes the
+// room, token, file and stored byte gauges from the wrapped repository.
+type RoomRepository struct {
+	inner    ports.RoomRepository
+	duration *metrics.Histogram
+}
+
+func NewRoomRepository(inner ports.RoomRepository, reg *metrics.Registry) *RoomRepository {
+	rooms := reg.Gauge("file_share_rooms_active", "Rooms currently stored.")
+	tokens := reg.Gauge("file_share_tokens_active", "Room access tokens currently stored.")
+	files := reg.Gauge("file_share_files_stored", "Files currently stored across all rooms.")
+	bytes := reg.Gauge("file_share_stored_bytes", "Bytes of files currently stored across all rooms, every version included.")
+
+	var (
+		mu        sync.Mutex
+		refreshed time.Time
+	)
+	reg.OnScrape(func(ctx context.Context) {
+		mu.Lock()
+		defer mu.Unlock()
+		if time.Since(refreshed) < statsMaxAge {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, scrapeTimeout)
+		defer cancel()
+
+		stats, err := inner.Stats(ctx)
+		if err != nil {
+			return
+		}
+		refreshed = time.Now()
+		rooms.Set(float64(stats.Rooms))
+		tokens.Set(float64(stats.Tokens))
+		files.Set(float64(stats.Files))
+		bytes.Set(float64(stats.Bytes))
+	})
+
+	return &RoomRepository{
+		inner:    inner,
+		duration: reg.Histogram("file_share_repository_duration_seconds", "Room repository call latency.", metrics.DefBuckets, "op", "result"),
+	}
+}
+
+func (r *RoomRepository) observe(op string, start time.Time, err *error) {
+	r.duration.Observe(time.Since(start).Seconds(), op, result(*err))
+}
+
+func (r *RoomRepository) Get(ctx context.Context, roomID uuid.UUID) (room *domain.Room, ok bool, err error) {
+	defer r.observe("get", time.Now(), &err)
+	return r.inner.Get(ctx, roomID)
+}
+
+func (r *RoomRepository) List(ctx context.Context) (rooms []*domain.Room, err error) {
+	defer r.observe("list", time.Now(), &err)
+	return r.inner.List(ctx)
+}
+
+func (r *RoomRepository) Stats(ctx context.Context) (stats ports.RoomStats, err error) {
+	defer r.observe("stats", time.Now(), &err)
+	return r.inner.Stats(ctx)
+}
+
+func (r *RoomRepository) QueryRooms(ctx context.Context, query ports.RoomQuery) (page ports.RoomPage, err error) {
+	defer r.observe("query_rooms", time.Now(), &err)
+	return r.inner.QueryRooms(ctx, query)
+}
+
+func (r *RoomRepository) Create(ctx context.Context, room *domain.Room) (err error) {
+	defer r.observe("create", time.Now(), &err)
+	return r.inner.Create(ctx, room)
+}
+
+func (r *RoomRepository) Delete(ctx context.Context, roomID uuid.UUID) (paths []string, err error) {
+	defer r.observe("delete", time.Now(), &err)
+	return r.inner.Delete(ctx, roomID)
+}
+
+func (r *RoomRepository) DeleteExpired(ctx context.Context, now time.Time) (cleanups []domain.ExpiredCleanup, err error) {
+	defer r.observe("delete_expired", time.Now(), &err)
+	return r.inner.DeleteExpired(ctx, now)
+}
+
+func (r *RoomRepository) RemoveToken(ctx context.Context, roomID uuid.UUID, token string) (ok bool, err error) {
+	defer r.observe("remove_token", time.Now(), &err)
+	return r.inner.RemoveToken(ctx, roomID, token)
+}
+
+func (r *RoomRepository) AddToken(ctx context.Context, roomID uuid.UUID, token string) (err error) {
+	defer r.observe("add_token", time.Now(), &err)
+	return r.inner.AddToken(ctx, roomID, token)
+}
+
+func (r *RoomRepository) QueryFilesByToken(ctx context.Context, roomID uuid.UUID, token string, query ports.FileQuery) (page ports.FilePage, ok bool, err error) {
+	defer r.observe("query_files", time.Now(), &err)
+	return r.inner.QueryFilesByToken(ctx, roomID, token, query)
+}
+
+func (r *RoomRepository) AddFileByToken(ctx context.Context, roomID uuid.UUID, token string, file *domain.RoomFile, limits domain.RoomLimits) (ok bool, err error) {
+	defer r.observe("add_file", time.Now(), &err)
+	return r.inner.AddFileByToken(ctx, roomID, token, file, limits)
+}
+
+func (r *RoomRepository) AddFileVersionByToken(ctx context.Context, roomID, fileID uuid.UUID, token string, next *domain.RoomFile, limits domain.RoomLimits) (file *domain.RoomFile, ok bool, err error) {
+	defer r.observe("add_file_version", time.Now(), &err)
+	return r.inner.AddFileVersionByToken(ctx, roomID, fileID, token, next, limits)
+}
+
+func (r *RoomRepository) DeleteFileByToken(ctx context.Context, roomID, fileID uuid.UUID, token string) (paths []string, ok bool, err error) {
+	defer r.observe("delete_file", time.Now(), &err)
+	return r.inner.DeleteFileByToken(ctx, roomID, fileID, token)
+}
+
+func (r *RoomRepository) CountDownloadByToken(ctx context.Context, roomID, fileID uuid.UUID, token string, now time.Time) (file *domain.RoomFile, ok bool, err error) {
+	defer r.observe("count_download", time.Now(), &err)
+	return r.inner.CountDownloadByToken(ctx, roomID, fileID, token, now)
+}
+
+func (r *RoomRepository) DeleteExpiredFiles(ctx context.Context, now time.Time) (files []domain.ExpiredFile, err error) {
+	defer r.observe("delete_expired_files", time.Now(), &err)
+	return r.inner.DeleteExpiredFiles(ctx, now)
+}
+
+func (r *RoomRepository) SetFileDescriptionByToken(ctx context.Context, roomID, fileID uuid.UUID, token, description string, limits domain.RoomLimits) (file *domain.RoomFile, ok bool, err error) {
+	defer r.observe("set_file_description", time.Now(), &err)
+	return r.inner.SetFileDescriptionByToken(ctx, roomID, fileID, token, description, limits)
+}
+
+func (r *RoomRepository) AddMessageByToken(ctx context.Context, roomID uuid.UUID, token string, msg *domain.RoomMessage, limits domain.RoomLimits) (ok bool, err error) {
+	defer r.observe("add_message", time.Now(), &err)
+	return r.inner.AddMessageByToken(ctx, roomID, token, msg, limits)
+}
+
+func (r *RoomRepository) AddFolderByToken(ctx context.Context, roomID uuid.UUID, token, folder string) (ok bool, err error) {
+	defer r.observe("add_folder", time.Now(), &err)
+	return r.inner.AddFolderByToken(ctx, roomID, token, folder)
+}
+
+func (r *RoomRepository) RenameFolderByToken(ctx context.Context, roomID uuid.UUID, token, from, to string) (ok bool, err error) {
+	defer r.observe("rename_folder", time.Now(), &err)
+	return r.inner.RenameFolderByToken(ctx, roomID, token, from, to)
+}
+
+func (r *RoomRepository) DeleteFolderByToken(ctx context.Context, roomID uuid.UUID, token, folder string) (paths []string, ok bool, err error) {
+	defer r.observe("delete_folder", time.Now(), &err)
+	return r.inner.DeleteFolderByToken(ctx, roomID, token, folder)
+}
+
+func (r *RoomRepository) DeleteFile(ctx context.Context, roomID, fileID uuid.UUID) (paths []string, ok bool, err error) {
+	defer r.observe("delete_file_admin", time.Now(), &err)
+	return r.inner.DeleteFile(ctx, roomID, fileID)
+}
+
+func (r *RoomRepository) RemoveTokens(ctx context.Context, roomID uuid.UUID) (n int, err error) {
+	defer r.observe("remove_tokens", time.Now(), &err)
+	return r.inner.RemoveTokens(ctx, roomID)
+}
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
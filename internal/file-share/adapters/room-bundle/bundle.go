@@ -0,0 +1,186 @@
+package roombundle
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+)
+
+const (
+	formatVersion = 1
+
+	manifestName  = "manifest.json"
+	signatureName = "manifest.sig"
+	blobDir       = "blobs/"
+
+	maxManifestSize = 16 << 20
+)
+
+// Bundler writes rooms as tarballs: manifest.json first, then manifest.sig
+// holding its hex HMAC-SHA256 under the bundle key, then one entry per
+// stored content. The manifest carries each content's size and SHA-256, so
+// the signature covers the content too and an import can check it while
+// streaming.
+type Bundler struct {
+	secret []byte
+}
+
+func New(secret []byte) Bundler {
+	return Bundler{secret: secret}
+}
+
+func (b Bundler) Export(ctx context.Context, w io.Writer, room *domain.Room, open func(path string) (io.ReadCloser, error)) error {
+	m, paths := newManifest(room, time.Now())
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	blobs, _ := m.blobs()
+
+	tw := tar.NewWriter(w)
+	if err := writeEntry(tw, manifestName, data, m.ExportedAt); err != nil {
+		return err
+	}
+	if err := writeEntry(tw, signatureName, []byte(sign(b.secret, data)), m.ExportedAt); err != nil {
+		return err
+	}
+	for i, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name := blobDir + strconv.Itoa(i)
+		if err := writeBlob(tw, name, blobs[name].Size, m.ExportedAt, path, open); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func (b Bundler) Import(ctx context.Context, r io.Reader, check func(room *domain.Room) error, store func(r io.Reader) (string, error)) (*domain.Room, error) {
+	tr := tar.NewReader(r)
+	data, err := readEntry(tr, manifestName, maxManifestSize)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := readEntry(tr, signatureName, hex.EncodedLen(sha256.Size))
+	if err != nil {
+		return nil, err
+	}
+	if len(b.secret) == 0 || !hmac.Equal([]byte(sign(b.secret, data)), sig) {
+		return nil, ports.ErrBundleSignature
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%w: %w", ports.ErrBundleInvalid, err)
+	}
+	if m.Format != formatVersion {
+		return nil, fmt.Errorf("%w: unknown format %d", ports.ErrBundleInvalid, m.Format)
+	}
+	blobs, ok := m.blobs()
+	if !ok {
+		return nil, fmt.Errorf("%w: inconsistent file list", ports.ErrBundleInvalid)
+	}
+	described, err := m.domainRoom(nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ports.ErrBundleInvalid, err)
+	}
+	if check != nil {
+		if err := check(described); err != nil {
+			return nil, err
+		}
+	}
+
+	paths := make(map[string]string, len(blobs))
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ports.ErrBundleInvalid, err)
+		}
+
+		v, ok := blobs[hdr.Name]
+		if _, dup := paths[hdr.Name]; !ok || dup || hdr.Typeflag != tar.TypeReg || hdr.Size != v.Size {
+			return nil, fmt.Errorf("%w: unexpected entry %q", ports.ErrBundleInvalid, hdr.Name)
+		}
+
+		h := sha256.New()
+		path, err := store(io.TeeReader(tr, h))
+		if err != nil {
+			return nil, err
+		}
+		paths[hdr.Name] = path
+		if !strings.EqualFold(hex.EncodeToString(h.Sum(nil)), v.SHA256) {
+			return nil, fmt.Errorf("%w: %q does not match its digest", ports.ErrBundleInvalid, hdr.Name)
+		}
+	}
+	if len(paths) != len(blobs) {
+		return nil, fmt.Errorf("%w: content missing", ports.ErrBundleInvalid)
+	}
+
+	return m.domainRoom(paths)
+}
+
+func sign(secret, manifest []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(manifest)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func writeEntry(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func writeBlob(tw *tar.Writer, name string, size int64, modTime time.Time, path string, open func(string) (io.ReadCloser, error)) error {
+	src, err := open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	hdr := &tar.Header{Name: name, Mode: 0644, Size: size, ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, src, size)
+	return err
+}
+
+// readEntry reads the next entry, which must be a regular file called name
+// of at most limit bytes.
+func readEntry(tr *tar.Reader, name string, limit int) ([]byte, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ports.ErrBundleInvalid, err)
+	}
+	if hdr.Name != name || hdr.Typeflag != tar.TypeReg || hdr.Size > int64(limit) {
+		return nil, fmt.Errorf("%w: expected %s, got %q", ports.ErrBundleInvalid, name, hdr.Name)
+	}
+	data, err := io.ReadAll(tr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ports.ErrBundleInvalid, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,236 @@
+package roombundle
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/google/uuid"
+)
+
+// manifest describes a room without its password and tokens. Content is
+// referred to by blob name, never by the path it had on the exporting node.
+type manifest struct {
+	Format     int       `json:"format"`
+	ExportedAt time.Time `json:"exportedAt"`
+	Room       room      `json:"room"`
+}
+
+type room struct {
+	ID             uuid.UUID    `json:"id"`
+	CreatedAt      time.Time    `json:"createdAt"`
+	ExpiresAt      time.Time    `json:"expiresAt"`
+	Content        contentRules `json:"content"`
+	SanitizeImages bool         `json:"sanitizeImages"`
+	Versioning     bool         `json:"versioning"`
+	Folders        []string     `json:"folders"`
+	Files          []file       `json:"files"`
+	Messages       []message    `json:"messages"`
+}
+
+type contentRules struct {
+	AllowTypes      []string `json:"allowTypes,omitempty"`
+	DenyTypes       []string `json:"denyTypes,omitempty"`
+	AllowExtensions []string `json:"allowExtensions,omitempty"`
+	DenyExtensions  []string `json:"denyExtensions,omitempty"`
+}
+
+type file struct {
+	ID           uuid.UUID    `json:"id"`
+	Folder       string       `json:"folder,omitempty"`
+	Name         string       `json:"name"`
+	Description  string       `json:"description,omitempty"`
+	ExpiresAt    time.Time    `json:"expiresAt,omitzero"`
+	MaxDownloads int          `json:"maxDownloads,omitempty"`
+	Downloads    int          `json:"downloads,omitempty"`
+	Scan         scanVerdict  `json:"scan"`
+	Sanitization sanitization `json:"sanitization"`
+	// Versions lists every content of the file, the current one last.
+	Versions []version `json:"versions"`
+}
+
+type version struct {
+	Version     int       `json:"version"`
+	Blob        string    `json:"blob"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	ContentType string    `json:"contentType"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type scanVerdict struct {
+	Status    string    `json:"status"`
+	Scanner   string    `json:"scanner,omitempty"`
+	ScannedAt time.Time `json:"scannedAt,omitzero"`
+}
+
+type sanitization struct {
+	Applied        bool   `json:"applied"`
+	OriginalSize   int64  `json:"originalSize,omitempty"`
+	OriginalSHA256 string `json:"originalSha256,omitempty"`
+}
+
+type message struct {
+	ID        uuid.UUID `json:"id"`
+	Author    string    `json:"author,omitempty"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// newManifest describes r. Every stored path gets a blob name, returned with
+// the path it stands for in the order the blobs are written.
+func newManifest(r *domain.Room, now time.Time) (manifest, []string) {
+	blobs := make(map[string]string)
+	var paths []string
+	blobName := func(path string) string {
+		if name, ok := blobs[path]; ok {
+			return name
+		}
+		name := blobDir + strconv.Itoa(len(paths))
+		blobs[path] = name
+		paths = append(paths, path)
+		return name
+	}
+
+	m := manifest{
+		Format:     formatVersion,
+		ExportedAt: now,
+		Room: room{
+			ID:        r.ID,
+			CreatedAt: r.CreatedAt,
+			ExpiresAt: r.ExpiresAt,
+			Content: contentRules{
+				AllowTypes:      r.Content.AllowTypes,
+				DenyTypes:       r.Content.DenyTypes,
+				AllowExtensions: r.Content.AllowExtensions,
+				DenyExtensions:  r.Content.DenyExtensions,
+			},
+			SanitizeImages: r.SanitizeImages,
+			Versioning:     r.Versioning,
+			Folders:        r.ListFolders(),
+			Files:          make([]file, 0, len(r.Files)),
+			Messages:       make([]message, 0, len(r.Messages)),
+		},
+	}
+
+	for _, f := range r.ListFiles() {
+		mf := file{
+			ID:           f.ID,
+			Folder:       f.Folder,
+			Name:         f.Name,
+			Description:  f.Description,
+			ExpiresAt:    f.ExpiresAt,
+			MaxDownloads: f.MaxDownloads,
+			Downloads:    f.Downloads,
+			Scan:         scanVerdict(f.Scan),
+			Sanitization: sanitization(f.Sanitization),
+		}
+		for _, v := range f.AllVersions() {
+			mf.Versions = append(mf.Versions, version{
+				Version:     v.Version,
+				Blob:        blobName(v.Path),
+				Size:        v.Size,
+				SHA256:      v.SHA256,
+				ContentType: v.ContentType,
+				CreatedAt:   v.CreatedAt,
+			})
+		}
+		m.Room.Files = append(m.Room.Files, mf)
+	}
+
+	for _, msg := range r.Messages {
+		m.Room.Messages = append(m.Room.Messages, message(msg))
+	}
+	return m, paths
+}
+
+// blobs maps each blob name the manifest refers to onto the size and digest
+// it promises, failing when two references disagree.
+func (m manifest) blobs() (map[string]version, bool) {
+	out := make(map[string]version)
+	for _, f := range m.Room.Files {
+		if f.Name == "" || len(f.Versions) == 0 {
+			return nil, false
+		}
+		for _, v := range f.Versions {
+			if v.Size <= 0 || len(v.SHA256) != 64 {
+				return nil, false
+			}
+			if seen, ok := out[v.Blob]; ok && (seen.Size != v.Size || seen.SHA256 != v.SHA256) {
+				return nil, false
+			}
+			out[v.Blob] = v
+		}
+	}
+	return out, true
+}
+
+// domainRoom builds the room the manifest describes, with each content at
+// the path paths holds for its blob. The room has no password or tokens.
+func (m manifest) domainRoom(paths map[string]string) (*domain.Room, error) {
+	mr := m.Room
+	r := domain.HydrateRoom(mr.ID, "", mr.ExpiresAt)
+	r.CreatedAt = mr.CreatedAt
+	r.Content = domain.ContentRules{
+		AllowTypes:      mr.Content.AllowTypes,
+		DenyTypes:       mr.Content.DenyTypes,
+		AllowExtensions: mr.Content.AllowExtensions,
+		DenyExtensions:  mr.Content.DenyExtensions,
+	}
+	r.SanitizeImages = mr.SanitizeImages
+	r.Versioning = mr.Versioning
+
+	for _, folder := range mr.Folders {
+		folder, err := domain.NormalizeFolder(folder)
+		if err != nil {
+			return nil, err
+		}
+		r.AddFolder(folder)
+	}
+
+	for _, mf := range mr.Files {
+		folder, err := domain.NormalizeFolder(mf.Folder)
+		if err != nil {
+			return nil, err
+		}
+		f := &domain.RoomFile{
+			ID:           mf.ID,
+			Folder:       folder,
+			Name:         mf.Name,
+			Description:  mf.Description,
+			ExpiresAt:    mf.ExpiresAt,
+			MaxDownloads: mf.MaxDownloads,
+			Downloads:    mf.Downloads,
+			Scan:         domain.ScanVerdict(mf.Scan),
+			Sanitization: domain.Sanitization(mf.Sanitization),
+		}
+		for i, v := range mf.Versions {
+			fv := domain.FileVersion{
+				Version:     v.Version,
+				Path:        paths[v.Blob],
+				Size:        v.Size,
+				SHA256:      v.SHA256,
+				ContentType: v.ContentType,
+				CreatedAt:   v.CreatedAt,
+			}
+			if i < len(mf.Versions)-1 {
+				f.Versions = append(f.Versions, fv)
+				continue
+			}
+			f.Version = fv.Version
+			f.Path = fv.Path
+			f.Size = fv.Size
+			f.SHA256 = fv.SHA256
+			f.ContentType = fv.ContentType
+			f.CreatedAt = fv.CreatedAt
+		}
+		if err := r.AddFile(f); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, msg := range mr.Messages {
+		r.Messages = append(r.Messages, domain.RoomMessage(msg))
+	}
+	return r, nil
+}
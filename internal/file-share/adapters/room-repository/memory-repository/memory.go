@@ -2,6 +2,7 @@ package memoryrepository
 
 import (
 	"context"
+	"slices"
 	"sync"
 	"time"
 
@@ -59,6 +60,51 @@ func (r *MemoryRepo) List(ctx context.Context) ([]*domain.Room, error) {
 	return out, nil
 }
 
+func (r *MemoryRepo) Stats(ctx context.Context) (ports.RoomStats, error) {
+	if err := ctx.Err(); err != nil {
+		return ports.RoomStats{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var stats ports.RoomStats
+	for _, room := range r.rooms {
+		if room == nil {
+			continue
+		}
+		stats.Rooms++
+		stats.Tokens += room.TokensCount()
+		stats.Files += len(room.Files)
+		for _, f := range room.Files {
+			stats.Bytes += f.StoredSize()
+		}
+	}
+	return stats, nil
+}
+
+func (r *MemoryRepo) QueryRooms(ctx context.Context, query ports.RoomQuery) (ports.RoomPage, error) {
+	if err := ctx.Err(); err != nil {
+		return ports.RoomPage{}, err
+	}
+
+	r.mu.RLock()
+	rooms := make([]*domain.Room, 0, len(r.rooms))
+	for _, room := range r.rooms {
+		if room != nil && query.Matches(room) {
+			rooms = append(rooms, room.Clone())
+		}
+	}
+	r.mu.RUnlock()
+
+	cursorOf := func(room *domain.Room) ports.Cursor { return ports.RoomCursor(query.Sort, room) }
+	rooms, next, err := page(rooms, cursorOf, true, query.Desc, query.Cursor, query.Limit)
+	if err != nil {
+		return ports.RoomPage{}, err
+	}
+	return ports.RoomPage{Rooms: rooms, Next: next}, nil
+}
+
 func (r *MemoryRepo) Create(ctx context.Context, room *domain.Room) error {
 	if err := ctx.Err(); err != nil {
 		return err
@@ -98,9 +144,7 @@ func (r *MemoryRepo) Delete(ctx context.Context, roomID uuid.UUID) ([]string, er
 			if f == nil {
 				continue
 			}
-			if f.Path != "" {
-				paths = append(paths, f.Path)
-			}
+			paths = append(paths, f.Paths()...)
 		}
 	}
 
@@ -136,9 +180,7 @@ func (r *MemoryRepo) DeleteExpired(ctx context.Context, now time.Time) ([]domain
 			if f == nil {
 				continue
 			}
-			if f.Path != "" {
-				paths = append(paths, f.Path)
-			}
+			paths = append(paths, f.Paths()...)
 		}
 
 		delete(r.rooms, id)
@@ -198,7 +240,39 @@ func (r *MemoryRepo) AddToken(ctx context.Context, roomID uuid.UUID, token strin
 	return room.AddToken(token)
 }
 
-func (r *MemoryRepo) AddFileByToken(ctx context.Context, roomID uuid.UUID, token string, file *domain.RoomFile) (bool, error) {
+func (r *MemoryRepo) QueryFilesByToken(ctx context.Context, roomID uuid.UUID, token string, query ports.FileQuery) (ports.FilePage, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return ports.FilePage{}, false, err
+	}
+
+	r.mu.RLock()
+	room, ok := r.rooms[roomID]
+	if !ok || room == nil || !room.HasToken(token) {
+		r.mu.RUnlock()
+		return ports.FilePage{}, false, nil
+	}
+	if !room.HasFolder(query.Folder) {
+		r.mu.RUnlock()
+		return ports.FilePage{}, false, domain.ErrFolderNotFound
+	}
+	files := make([]*domain.RoomFile, 0, len(room.Files))
+	for _, f := range room.Files {
+		if f != nil && domain.InFolder(f.Folder, query.Folder) && query.Matches(f) {
+			cp := *f
+			files = append(files, &cp)
+		}
+	}
+	r.mu.RUnlock()
+
+	cursorOf := func(f *domain.RoomFile) ports.Cursor { return ports.FileCursor(query.Sort, f) }
+	files, next, err := page(files, cursorOf, query.Sort != ports.SortByName, query.Desc, query.Cursor, query.Limit)
+	if err != nil {
+		return ports.FilePage{}, false, err
+	}
+	return ports.FilePage{Files: files, Next: next}, true, nil
+}
+
+func (r *MemoryRepo) AddFileByToken(ctx context.Context, roomID uuid.UUID, token string, file *domain.RoomFile, limits domain.RoomLimits) (bool, error) {
 	if err := ctx.Err(); err != nil {
 		return false, err
 	}
@@ -217,19 +291,83 @@ func (r *MemoryRepo) AddFileByToken(ctx context.Context, roomID uuid.UUID, token
 	if !room.HasToken(token) {
 		return false, nil
 	}
+	if err := limits.Check(room.StoredFiles()+len(file.Versions)+1, room.TotalSize()+file.StoredSize()+int64(len(file.Description))); err != nil {
+		return false, err
+	}
 
 	cp := *file
+	cp.Versions = slices.Clone(file.Versions)
 	if room.Files == nil {
 		room.Files = make(map[uuid.UUID]*domain.RoomFile)
 	}
 	room.Files[cp.ID] = &cp
+	room.AddFolder(cp.Folder)
 
 	return true, nil
 }
 
-func (r *MemoryRepo) DeleteFileByToken(ctx context.Context, roomID, fileID uuid.UUID, token string) (string, bool, error) {
+func (r *MemoryRepo) AddFileVersionByToken(ctx context.Context, roomID, fileID uuid.UUID, token string, next *domain.RoomFile, limits domain.RoomLimits) (*domain.RoomFile, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	if next == nil {
+		return nil, false, domain.ErrInvalidFile
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, ok := r.rooms[roomID]
+	if !ok || room == nil || !room.HasToken(token) {
+		return nil, false, nil
+	}
+
+	f, ok := room.GetFile(fileID)
+	if !ok || f == nil {
+		return nil, false, nil
+	}
+	if err := limits.Check(room.StoredFiles()+1, room.TotalSize()+next.Size); err != nil {
+		return nil, false, err
+	}
+	f.AddVersion(next)
+
+	cp := *f
+	cp.Versions = slices.Clone(f.Versions)
+	return &cp, true, nil
+}
+
+func (r *MemoryRepo) SetFileDescriptionByToken(ctx context.Context, roomID, fileID uuid.UUID, token, description string, limits domain.RoomLimits) (*domain.RoomFile, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, ok := r.rooms[roomID]
+	if !ok || room == nil || !room.HasToken(token) {
+		return nil, false, nil
+	}
+
+	f, ok := room.GetFile(fileID)
+	if !ok || f == nil {
+		return nil, false, nil
+	}
+	if grow := int64(len(description) - len(f.Description)); grow > 0 {
+		if err := limits.CheckSize(room.TotalSize() + grow); err != nil {
+			return nil, false, err
+		}
+	}
+	f.Description = description
+
+	cp := *f
+	cp.Versions = slices.Clone(f.Versions)
+	return &cp, true, nil
+}
+
+func (r *MemoryRepo) DeleteFileByToken(ctx context.Context, roomID, fileID uuid.UUID, token string) ([]string, bool, error) {
 	if err := ctx.Err(); err != nil {
-		return "", false, err
+		return nil, false, err
 	}
 
 	r.mu.Lock()
@@ -237,20 +375,193 @@ func (r *MemoryRepo) DeleteFileByToken(ctx context.Context, roomID, fileID uuid.
 
 	room, ok := r.rooms[roomID]
 	if !ok || room == nil {
-		return "", false, nil
+		return nil, false, nil
 	}
 
 	if !room.HasToken(token) {
-		return "", false, nil
+		return nil, false, nil
 	}
 
 	f, ok := room.Files[fileID]
 	if !ok || f == nil {
-		return "", false, nil
+		return nil, false, nil
 	}
 
-	path := f.Path
 	delete(room.Files, fileID)
 
-	return path, true, nil
+	return f.Paths(), true, nil
+}
+
+func (r *MemoryRepo) CountDownloadByToken(ctx context.Context, roomID, fileID uuid.UUID, token string, now time.Time) (*domain.RoomFile, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, ok := r.rooms[roomID]
+	if !ok || room == nil || !room.HasToken(token) {
+		return nil, false, nil
+	}
+
+	f, err := room.CountDownload(fileID, now)
+	if err != nil {
+		return nil, false, nil
+	}
+	cp := *f
+	cp.Versions = slices.Clone(f.Versions)
+	return &cp, true, nil
+}
+
+func (r *MemoryRepo) DeleteExpiredFiles(ctx context.Context, now time.Time) ([]domain.ExpiredFile, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]domain.ExpiredFile, 0)
+	for id, room := range r.rooms {
+		if room == nil {
+			continue
+		}
+		for _, f := range room.DeleteExpiredFiles(now) {
+			out = append(out, domain.ExpiredFile{RoomID: id, FileID: f.ID, Paths: f.Paths()})
+		}
+	}
+	return out, nil
+}
+
+func (r *MemoryRepo) AddMessageByToken(ctx context.Context, roomID uuid.UUID, token string, msg *domain.RoomMessage, limits domain.RoomLimits) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if msg == nil {
+		return false, domain.ErrInvalidMessage
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, ok := r.rooms[roomID]
+	if !ok || room == nil || !room.HasToken(token) {
+		return false, nil
+	}
+	if err := limits.CheckMessages(len(room.Messages) + 1); err != nil {
+		return false, err
+	}
+	if err := limits.CheckSize(room.TotalSize() + msg.Size()); err != nil {
+		return false, err
+	}
+
+	room.Messages = append(room.Messages, *msg)
+	return true, nil
+}
+
+func (r *MemoryRepo) AddFolderByToken(ctx context.Context, roomID uuid.UUID, token, folder string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, ok := r.rooms[roomID]
+	if !ok || room == nil || !room.HasToken(token) {
+		return false, nil
+	}
+	if room.HasFolder(folder) {
+		return false, domain.ErrFolderExists
+	}
+
+	room.AddFolder(folder)
+	return true, nil
+}
+
+func (r *MemoryRepo) RenameFolderByToken(ctx context.Context, roomID uuid.UUID, token, from, to string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, ok := r.rooms[roomID]
+	if !ok || room == nil || !room.HasToken(token) {
+		return false, nil
+	}
+
+	if err := room.RenameFolder(from, to); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *MemoryRepo) DeleteFolderByToken(ctx context.Context, roomID uuid.UUID, token, folder string) ([]string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, ok := r.rooms[roomID]
+	if !ok || room == nil || !room.HasToken(token) {
+		return nil, false, nil
+	}
+
+	removed, err := room.DeleteFolder(folder)
+	if err != nil {
+		return nil, false, err
+	}
+
+	paths := make([]string, 0, len(removed))
+	for _, f := range removed {
+		paths = append(paths, f.Paths()...)
+	}
+	return paths, true, nil
+}
+
+func (r *MemoryRepo) DeleteFile(ctx context.Context, roomID, fileID uuid.UUID) ([]string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, ok := r.rooms[roomID]
+	if !ok || room == nil {
+		return nil, false, nil
+	}
+
+	f, err := room.DeleteFile(fileID)
+	if err != nil {
+		return nil, false, nil
+	}
+	return f.Paths(), true, nil
+}
+
+func (r *MemoryRepo) RemoveTokens(ctx context.Context, roomID uuid.UUID) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, ok := r.rooms[roomID]
+	if !ok || room == nil {
+		return 0, ports.ErrRoomNotFound
+	}
+
+	tokens := room.ListTokens()
+	for _, token := range tokens {
+		if err := room.RemoveToken(token); err != nil {
+			return 0, err
+		}
+	}
+	return len(tokens), nil
 }
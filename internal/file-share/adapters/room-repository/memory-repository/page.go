@@ -0,0 +1,34 @@
+package memoryrepository
+
+import (
+	"sort"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+)
+
+// page sorts items by their cursors and cuts out the page following after.
+func page[T any](items []T, cursorOf func(T) ports.Cursor, numeric, desc bool, after string, limit int) ([]T, string, error) {
+	less := func(a, b ports.Cursor) bool {
+		c := a.Compare(b, numeric)
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	sort.Slice(items, func(i, j int) bool { return less(cursorOf(items[i]), cursorOf(items[j])) })
+
+	if after != "" {
+		c, err := ports.ParseCursor(after)
+		if err != nil {
+			return nil, "", err
+		}
+		start := sort.Search(len(items), func(i int) bool { return less(c, cursorOf(items[i])) })
+		items = items[start:]
+	}
+
+	if limit <= 0 || len(items) <= limit {
+		return items, "", nil
+	}
+	items = items[:limit]
+	return items, cursorOf(items[limit-1]).String(), nil
+}
@@ -1,6 +1,20 @@
 package redisrepository
 
-import "github.com/google/uuid"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
 
 func roomKey(roomID uuid.UUID) string {
 	return "room:" + roomID.String()
@@ -13,3 +27,259 @@ func tokensKey(roomID uuid.UUID) string {
 func filesKey(roomID uuid.UUID) string {
 	return roomKey(roomID) + ":files"
 }
+
+func foldersKey(roomID uuid.UUID) string {
+	return roomKey(roomID) + ":folders"
+}
+
+// messagesKey holds the room's message thread as a list of JSON records,
+// oldest first.
+func messagesKey(roomID uuid.UUID) string {
+	return roomKey(roomID) + ":messages"
+}
+
+// isRoomKey tells room hashes apart from the per room sets, hashes and
+// indexes that share their prefix.
+func isRoomKey(key string) bool {
+	return strings.Count(key, ":") == 1
+}
+
+var fileSorts = []string{ports.SortByName, ports.SortBySize, ports.SortByCreatedAt}
+
+// Listings are kept in sorted sets with every score 0, ordered by member.
+// A member is the sort key, zero padded when numeric, then the ID, so the
+// lexicographic order is the listing order and a cursor maps to a member.
+func fileIndexKey(roomID uuid.UUID, sort string) string {
+	return filesKey(roomID) + ":by-" + sort
+}
+
+func roomIndexKey(sort string) string {
+	return "rooms:by-" + sort
+}
+
+func indexMember(c ports.Cursor, numeric bool) string {
+	key := c.Key
+	if numeric {
+		n, _ := c.Int()
+		key = fmt.Sprintf("%020d", n)
+	}
+	return key + "\x00" + c.ID.String()
+}
+
+func memberID(member string) string {
+	return member[strings.LastIndexByte(member, 0)+1:]
+}
+
+// expiringFilesKey scores "<room id>:<file id>" by the file's own expiry in
+// Unix seconds. Members of deleted rooms are dropped when they come due.
+const expiringFilesKey = "files:by-expiry"
+
+func expiringMember(roomID, fileID uuid.UUID) string {
+	return roomID.String() + ":" + fileID.String()
+}
+
+func indexFile(ctx context.Context, p redis.Pipeliner, roomID uuid.UUID, f *domain.RoomFile) {
+	for _, sort := range fileSorts {
+		p.ZAdd(ctx, fileIndexKey(roomID, sort), redis.Z{Member: indexMember(ports.FileCursor(sort, f), sort != ports.SortByName)})
+	}
+	if !f.ExpiresAt.IsZero() {
+		p.ZAdd(ctx, expiringFilesKey, redis.Z{Score: float64(f.ExpiresAt.Unix()), Member: expiringMember(roomID, f.ID)})
+	}
+}
+
+func unindexFile(ctx context.Context, p redis.Pipeliner, roomID uuid.UUID, f *domain.RoomFile) {
+	for _, sort := range fileSorts {
+		p.ZRem(ctx, fileIndexKey(roomID, sort), indexMember(ports.FileCursor(sort, f), sort != ports.SortByName))
+	}
+	if !f.ExpiresAt.IsZero() {
+		p.ZRem(ctx, expiringFilesKey, expiringMember(roomID, f.ID))
+	}
+}
+
+func fileIndexKeys(roomID uuid.UUID) []string {
+	keys := make([]string, 0, len(fileSorts))
+	for _, sort := range fileSorts {
+		keys = append(keys, fileIndexKey(roomID, sort))
+	}
+	return keys
+}
+
+// roomIndexMembers indexes a room by the whole seconds its hash stores, so
+// the members match cursors of rooms read back from it.
+func roomIndexMembers(roomID uuid.UUID, createdAtSec, expiresAtSec int64) map[string]string {
+	room := domain.HydrateRoom(roomID, "", time.Unix(expiresAtSec, 0))
+	room.CreatedAt = time.Unix(createdAtSec, 0)
+	return map[string]string{
+		roomIndexKey(ports.SortByCreatedAt): indexMember(ports.RoomCursor(ports.SortByCreatedAt, room), true),
+		roomIndexKey(ports.SortByExpiresAt): indexMember(ports.RoomCursor(ports.SortByExpiresAt, room), true),
+	}
+}
+
+func folderArgs(folders []string) []any {
+	out := make([]any, 0, len(folders))
+	for _, f := range folders {
+		out = append(out, f)
+	}
+	return out
+}
+
+// loadFolderTree reads only the folders and files of a room, enough to apply
+// folder operations with the domain methods.
+func loadFolderTree(ctx context.Context, c redis.Cmdable, roomID uuid.UUID) (*domain.Room, error) {
+	room := domain.HydrateRoom(roomID, "", time.Time{})
+
+	folders, err := c.SMembers(ctx, foldersKey(roomID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range folders {
+		room.AddFolder(f)
+	}
+
+	files, err := c.HGetAll(ctx, filesKey(roomID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	for field, raw := range files {
+		var f domain.RoomFile
+		if err := json.Unmarshal([]byte(raw), &f); err != nil {
+			slog.WarnContext(ctx, "skipping unreadable file record", slog.String("room_id", roomID.String()), slog.String("file_id", field), slog.Any("error", err))
+			continue
+		}
+		_ = room.AddFile(&f)
+	}
+	return room, nil
+}
+
+func loadMessages(ctx context.Context, c redis.Cmdable, roomID uuid.UUID) ([]domain.RoomMessage, error) {
+	raws, err := c.LRange(ctx, messagesKey(roomID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	var out []domain.RoomMessage
+	for _, raw := range raws {
+		var m domain.RoomMessage
+		if err := json.Unmarshal([]byte(raw), &m); err != nil {
+			slog.WarnContext(ctx, "skipping unreadable message record", slog.String("room_id", roomID.String()), slog.Any("error", err))
+			continue
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// encodeFolderFiles returns HSET arguments for every file in folder.
+func encodeFolderFiles(room *domain.Room, folder string) ([]any, error) {
+	var out []any
+	for id, f := range room.Files {
+		if !domain.InFolder(f.Folder, folder) {
+			continue
+		}
+		raw, err := json.Marshal(f)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, id.String(), string(raw))
+	}
+	return out, nil
+}
+
+// Room content rules are stored as JSON, empty when the room has none.
+func encodeContentRules(rules domain.ContentRules) (string, error) {
+	if rules.IsZero() {
+		return "", nil
+	}
+	b, err := json.Marshal(rules)
+	return string(b), err
+}
+
+func decodeContentRules(raw string) (domain.ContentRules, error) {
+	var rules domain.ContentRules
+	if raw == "" {
+		return rules, nil
+	}
+	err := json.Unmarshal([]byte(raw), &rules)
+	return rules, err
+}
+
+func sanitizeFlag(on bool) string {
+	if on {
+		return "1"
+	}
+	return "0"
+}
+
+func unindexRoom(ctx context.Context, p redis.Pipeliner, roomID uuid.UUID, createdAt, expiresAt string) {
+	created, _ := strconv.ParseInt(createdAt, 10, 64)
+	expires, _ := strconv.ParseInt(expiresAt, 10, 64)
+	for key, member := range roomIndexMembers(roomID, created, expires) {
+		p.ZRem(ctx, key, member)
+	}
+}
+
+// unixField reads a Unix seconds field of a room hash, 0 when missing.
+func unixField(m map[string]string, field string) int64 {
+	n, _ := strconv.ParseInt(m[field], 10, 64)
+	return n
+}
+
+// checkRoomLimits holds the room to limits once files more versions taking
+// size more bytes are added. Callers watch filesKey and messagesKey, so the
+// check and their write happen together.
+func checkRoomLimits(ctx context.Context, c redis.Cmdable, roomID uuid.UUID, limits domain.RoomLimits, files int, size int64) error {
+	if limits.IsZero() {
+		return nil
+	}
+	room, err := loadUsage(ctx, c, roomID)
+	if err != nil {
+		return err
+	}
+	return limits.Check(room.StoredFiles()+files, room.TotalSize()+size)
+}
+
+// loadUsage loads what counts against the room limits: its files and
+// messages.
+func loadUsage(ctx context.Context, c redis.Cmdable, roomID uuid.UUID) (*domain.Room, error) {
+	room, err := loadFolderTree(ctx, c, roomID)
+	if err != nil {
+		return nil, err
+	}
+	if room.Messages, err = loadMessages(ctx, c, roomID); err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+// removeFile deletes a file record and its index entries, with whatever also
+// queues, and returns the record; nil when there is none. tx watches
+// filesKey, so a version added meanwhile fails the transaction instead of
+// being dropped from the returned paths.
+func removeFile(ctx context.Context, tx *redis.Tx, roomID uuid.UUID, field string, also func(redis.Pipeliner)) (*domain.RoomFile, error) {
+	fk := filesKey(roomID)
+	raw, err := tx.HGet(ctx, fk, field).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f domain.RoomFile
+	if err := json.Unmarshal([]byte(raw), &f); err != nil {
+		_, _ = tx.HDel(ctx, fk, field).Result()
+		return nil, err
+	}
+
+	_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.HDel(ctx, fk, field)
+		unindexFile(ctx, p, roomID, &f)
+		if also != nil {
+			also(p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"log/slog"
 	"strconv"
 	"strings"
 	"time"
@@ -53,6 +54,12 @@ func (r *RedisRepo) Get(ctx context.Context, roomID uuid.UUID) (*domain.Room, bo
 		m["password_hash"],
 		time.Unix(expiresAt, 0),
 	)
+	room.CreatedAt = time.Unix(unixField(m, "created_at"), 0)
+	if room.Content, err = decodeContentRules(m["content_rules"]); err != nil {
+		return nil, false, err
+	}
+	room.SanitizeImages = m["sanitize_images"] == "1"
+	room.Versioning = m["versioning"] == "1"
 
 	tokens, err := r.db.SMembers(ctx, k+":tokens").Result()
 	if err != nil {
@@ -68,11 +75,25 @@ func (r *RedisRepo) Get(ctx context.Context, roomID uuid.UUID) (*domain.Room, bo
 	if err != nil {
 		return nil, false, err
 	}
-	for _, raw := range files {
+	for field, raw := range files {
 		var f domain.RoomFile
-		if err := json.Unmarshal([]byte(raw), &f); err == nil {
-			_ = room.AddFile(&f)
+		if err := json.Unmarshal([]byte(raw), &f); err != nil {
+			slog.WarnContext(ctx, "skipping unreadable file record", slog.String("room_id", roomID.String()), slog.String("file_id", field), slog.Any("error", err))
+			continue
 		}
+		_ = room.AddFile(&f)
+	}
+
+	folders, err := r.db.SMembers(ctx, k+":folders").Result()
+	if err != nil {
+		return nil, false, err
+	}
+	for _, f := range folders {
+		room.AddFolder(f)
+	}
+
+	if room.Messages, err = loadMessages(ctx, r.db, roomID); err != nil {
+		return nil, false, err
 	}
 
 	return room, true, nil
@@ -89,7 +110,7 @@ func (r *RedisRepo) List(ctx context.Context) ([]*domain.Room, error) {
 	for iter.Next(ctx) {
 		key := iter.Val()
 
-		if strings.HasSuffix(key, ":tokens") || strings.HasSuffix(key, ":files") {
+		if !isRoomKey(key) {
 			continue
 		}
 
@@ -121,6 +142,12 @@ func (r *RedisRepo) List(ctx context.Context) ([]*domain.Room, error) {
 			m["password_hash"],
 			time.Unix(expiresAt, 0),
 		)
+		room.CreatedAt = time.Unix(unixField(m, "created_at"), 0)
+		if room.Content, err = decodeContentRules(m["content_rules"]); err != nil {
+			continue
+		}
+		room.SanitizeImages = m["sanitize_images"] == "1"
+		room.Versioning = m["versioning"] == "1"
 
 		tokens, err := r.db.SMembers(ctx, key+":tokens").Result()
 		if err != nil {
@@ -134,11 +161,25 @@ func (r *RedisRepo) List(ctx context.Context) ([]*domain.Room, error) {
 		if err != nil {
 			return nil, err
 		}
-		for _, raw := range files {
+		for field, raw := range files {
 			var f domain.RoomFile
-			if err := json.Unmarshal([]byte(raw), &f); err == nil {
-				_ = room.AddFile(&f)
+			if err := json.Unmarshal([]byte(raw), &f); err != nil {
+				slog.WarnContext(ctx, "skipping unreadable file record", slog.String("room_id", room.ID.String()), slog.String("file_id", field), slog.Any("error", err))
+				continue
 			}
+			_ = room.AddFile(&f)
+		}
+
+		folders, err := r.db.SMembers(ctx, key+":folders").Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range folders {
+			room.AddFolder(f)
+		}
+
+		if room.Messages, err = loadMessages(ctx, r.db, room.ID); err != nil {
+			return nil, err
 		}
 
 		rooms = append(rooms, room)
@@ -151,6 +192,108 @@ func (r *RedisRepo) List(ctx context.Context) ([]*domain.Room, error) {
 	return rooms, nil
 }
 
+// QueryRooms walks the room index from the cursor, skipping rooms that are
+// gone or filtered out, until it has a page.
+// statsBatch is how many rooms Stats looks up per round trip.
+const statsBatch = 100
+
+func (r *RedisRepo) Stats(ctx context.Context) (ports.RoomStats, error) {
+	if err := ctx.Err(); err != nil {
+		return ports.RoomStats{}, err
+	}
+
+	var stats ports.RoomStats
+	batch := make([]string, 0, statsBatch)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		tokens := make([]*redis.IntCmd, len(batch))
+		files := make([]*redis.StringSliceCmd, len(batch))
+		_, err := r.db.Pipelined(ctx, func(p redis.Pipeliner) error {
+			for i, key := range batch {
+				tokens[i] = p.SCard(ctx, key+":tokens")
+				files[i] = p.HVals(ctx, key+":files")
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for i := range batch {
+			stats.Rooms++
+			stats.Tokens += int(tokens[i].Val())
+			for _, raw := range files[i].Val() {
+				var f domain.RoomFile
+				if err := json.Unmarshal([]byte(raw), &f); err != nil {
+					continue
+				}
+				stats.Files++
+				stats.Bytes += f.StoredSize()
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	iter := r.db.Scan(ctx, 0, "room:*", 0).Iterator()
+	for iter.Next(ctx) {
+		if key := iter.Val(); isRoomKey(key) {
+			batch = append(batch, key)
+		}
+		if len(batch) == statsBatch {
+			if err := flush(); err != nil {
+				return ports.RoomStats{}, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return ports.RoomStats{}, err
+	}
+	if err := flush(); err != nil {
+		return ports.RoomStats{}, err
+	}
+	return stats, nil
+}
+
+func (r *RedisRepo) QueryRooms(ctx context.Context, query ports.RoomQuery) (ports.RoomPage, error) {
+	if err := ctx.Err(); err != nil {
+		return ports.RoomPage{}, err
+	}
+
+	var rooms []*domain.Room
+	err := r.walkIndex(ctx, roomIndexKey(query.Sort), query.Cursor, true, query.Desc, func(ids []string) (bool, error) {
+		for _, idStr := range ids {
+			id, err := uuid.Parse(idStr)
+			if err != nil {
+				continue
+			}
+			room, ok, err := r.Get(ctx, id)
+			if err != nil {
+				return false, err
+			}
+			if ok && query.Matches(room) {
+				rooms = append(rooms, room)
+				if len(rooms) > query.Limit {
+					return false, nil
+				}
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return ports.RoomPage{}, err
+	}
+
+	var page ports.RoomPage
+	if len(rooms) > query.Limit {
+		rooms = rooms[:query.Limit]
+		page.Next = ports.RoomCursor(query.Sort, rooms[len(rooms)-1]).String()
+	}
+	page.Rooms = rooms
+	return page, nil
+}
+
 func (r *RedisRepo) Create(ctx context.Context, room *domain.Room) error {
 	if err := ctx.Err(); err != nil {
 		return err
@@ -171,14 +314,27 @@ func (r *RedisRepo) Create(ctx context.Context, room *domain.Room) error {
 			return ports.ErrRoomAlreadyExists
 		}
 
-		nowSec := time.Now().Unix()
+		createdAt := room.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = time.Now()
+		}
+		rules, err := encodeContentRules(room.Content)
+		if err != nil {
+			return err
+		}
 
 		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
 			p.HSet(ctx, k,
 				"password_hash", room.Password(),
 				"expires_at", room.ExpiresAt.Unix(),
-				"created_at", nowSec,
+				"content_rules", rules,
+				"sanitize_images", sanitizeFlag(room.SanitizeImages),
+				"versioning", sanitizeFlag(room.Versioning),
+				"created_at", createdAt.Unix(),
 			)
+			for key, member := range roomIndexMembers(room.ID, createdAt.Unix(), room.ExpiresAt.Unix()) {
+				p.ZAdd(ctx, key, redis.Z{Member: member})
+			}
 
 			tokens := room.ListTokens()
 			if len(tokens) > 0 {
@@ -210,33 +366,52 @@ func (r *RedisRepo) Delete(ctx context.Context, roomID uuid.UUID) ([]string, err
 	kRoom := roomKey(roomID)
 	kFiles := filesKey(roomID)
 	kTokens := tokensKey(roomID)
+	kFolders := foldersKey(roomID)
+	kMessages := messagesKey(roomID)
+
+	// Watching the files makes a file added meanwhile fail the transaction
+	// instead of losing its paths.
+	var paths []string
+	remove := func(tx *redis.Tx) error {
+		times, err := tx.HMGet(ctx, kRoom, "created_at", "expires_at").Result()
+		if err != nil {
+			return err
+		}
+		if times[1] == nil {
+			return ports.ErrRoomNotFound
+		}
 
-	ex, err := r.db.Exists(ctx, kRoom).Result()
-	if err != nil {
-		return nil, err
-	}
-	if ex == 0 {
-		return nil, ports.ErrRoomNotFound
-	}
-
-	files, err := r.db.HGetAll(ctx, kFiles).Result()
-	if err != nil {
-		return nil, err
-	}
-	paths := make([]string, 0, len(files))
-	for _, raw := range files {
-		var f domain.RoomFile
-		if err := json.Unmarshal([]byte(raw), &f); err == nil {
-			if f.Path != "" {
-				paths = append(paths, f.Path)
+		files, err := tx.HGetAll(ctx, kFiles).Result()
+		if err != nil {
+			return err
+		}
+		paths = make([]string, 0, len(files))
+		for _, raw := range files {
+			var f domain.RoomFile
+			if err := json.Unmarshal([]byte(raw), &f); err == nil {
+				paths = append(paths, f.Paths()...)
 			}
 		}
+
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.Del(ctx, append([]string{kRoom, kFiles, kTokens, kFolders, kMessages}, fileIndexKeys(roomID)...)...)
+			created, _ := times[0].(string)
+			expires, _ := times[1].(string)
+			unindexRoom(ctx, p, roomID, created, expires)
+			return nil
+		})
+		return err
 	}
 
-	if err := r.db.Del(ctx, kRoom, kFiles, kTokens).Err(); err != nil {
+	var err error
+	for range maxTxRetries {
+		if err = r.db.Watch(ctx, remove, kRoom, kFiles); !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
+	if err != nil {
 		return nil, err
 	}
-
 	return paths, nil
 }
 
@@ -254,7 +429,7 @@ func (r *RedisRepo) DeleteExpired(ctx context.Context, now time.Time) ([]domain.
 	for iter.Next(ctx) {
 		key := iter.Val()
 
-		if strings.HasSuffix(key, ":tokens") || strings.HasSuffix(key, ":files") {
+		if !isRoomKey(key) {
 			continue
 		}
 
@@ -291,13 +466,20 @@ func (r *RedisRepo) DeleteExpired(ctx context.Context, now time.Time) ([]domain.
 		for _, raw := range files {
 			var f domain.RoomFile
 			if err := json.Unmarshal([]byte(raw), &f); err == nil {
-				if f.Path != "" {
-					paths = append(paths, f.Path)
-				}
+				paths = append(paths, f.Paths()...)
 			}
 		}
 
-		if err := r.db.Del(ctx, key, fk, key+":tokens").Err(); err != nil {
+		created, err := r.db.HGet(ctx, key, "created_at").Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		_, err = r.db.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.Del(ctx, append([]string{key, fk, key + ":tokens", key + ":folders", messagesKey(roomID)}, fileIndexKeys(roomID)...)...)
+			unindexRoom(ctx, p, roomID, created, expStr)
+			return nil
+		})
+		if err != nil {
 			return nil, err
 		}
 
@@ -350,7 +532,161 @@ func (r *RedisRepo) AddToken(ctx context.Context, roomID uuid.UUID, token string
 	return r.db.SAdd(ctx, tokensKey(roomID), token).Err()
 }
 
-func (r *RedisRepo) AddFileByToken(ctx context.Context, roomID uuid.UUID, token string, file *domain.RoomFile) (bool, error) {
+func (r *RedisRepo) QueryFilesByToken(ctx context.Context, roomID uuid.UUID, token string, query ports.FileQuery) (ports.FilePage, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return ports.FilePage{}, false, err
+	}
+
+	ok, err := r.hasToken(ctx, roomID, token)
+	if err != nil || !ok {
+		return ports.FilePage{}, false, err
+	}
+	if query.Folder != "" {
+		exists, err := r.db.SIsMember(ctx, foldersKey(roomID), query.Folder).Result()
+		if err != nil {
+			return ports.FilePage{}, false, err
+		}
+		if !exists {
+			return ports.FilePage{}, false, domain.ErrFolderNotFound
+		}
+	}
+
+	fk := filesKey(roomID)
+	var files []*domain.RoomFile
+	err = r.walkIndex(ctx, fileIndexKey(roomID, query.Sort), query.Cursor, query.Sort != ports.SortByName, query.Desc, func(ids []string) (bool, error) {
+		raws, err := r.db.HMGet(ctx, fk, ids...).Result()
+		if err != nil {
+			return false, err
+		}
+		for _, raw := range raws {
+			s, ok := raw.(string)
+			if !ok {
+				continue
+			}
+			var f domain.RoomFile
+			if err := json.Unmarshal([]byte(s), &f); err != nil {
+				continue
+			}
+			if domain.InFolder(f.Folder, query.Folder) && query.Matches(&f) {
+				files = append(files, &f)
+				if len(files) > query.Limit {
+					return false, nil
+				}
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return ports.FilePage{}, false, err
+	}
+
+	var page ports.FilePage
+	if len(files) > query.Limit {
+		files = files[:query.Limit]
+		page.Next = ports.FileCursor(query.Sort, files[len(files)-1]).String()
+	}
+	page.Files = files
+	return page, true, nil
+}
+
+// walkIndex hands fn the IDs in a listing index in batches, starting after
+// cursor, until fn returns false or the index ends. Members whose item is
+// gone are left to fn to skip.
+func (r *RedisRepo) walkIndex(ctx context.Context, key, cursor string, numeric, desc bool, fn func(ids []string) (bool, error)) error {
+	const batch = 200
+
+	from := "-"
+	if desc {
+		from = "+"
+	}
+	if cursor != "" {
+		c, err := ports.ParseCursor(cursor)
+		if err != nil {
+			return err
+		}
+		if numeric {
+			if _, err := c.Int(); err != nil {
+				return err
+			}
+		}
+		from = "(" + indexMember(c, numeric)
+	}
+
+	for {
+		args := redis.ZRangeArgs{Key: key, Start: from, Stop: "+", ByLex: true, Count: batch}
+		if desc {
+			args.Start, args.Stop, args.Rev = "-", from, true
+		}
+		members, err := r.db.ZRangeArgs(ctx, args).Result()
+		if err != nil {
+			return err
+		}
+		if len(members) == 0 {
+			return nil
+		}
+
+		ids := make([]string, 0, len(members))
+		for _, m := range members {
+			ids = append(ids, memberID(m))
+		}
+		more, err := fn(ids)
+		if err != nil || !more || len(members) < batch {
+			return err
+		}
+		from = "(" + members[len(members)-1]
+	}
+}
+
+// Reindex rebuilds the listing indexes of every room, for data written
+// before they existed. It is safe to run at any time.
+func (r *RedisRepo) Reindex(ctx context.Context) error {
+	iter := r.db.Scan(ctx, 0, "room:*", 0).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		if !isRoomKey(key) {
+			continue
+		}
+		roomID, err := uuid.Parse(strings.TrimPrefix(key, "room:"))
+		if err != nil {
+			continue
+		}
+
+		m, err := r.db.HMGet(ctx, key, "created_at", "expires_at").Result()
+		if err != nil {
+			return err
+		}
+		created, _ := m[0].(string)
+		expires, _ := m[1].(string)
+		if expires == "" {
+			continue
+		}
+		files, err := r.db.HGetAll(ctx, filesKey(roomID)).Result()
+		if err != nil {
+			return err
+		}
+
+		_, err = r.db.Pipelined(ctx, func(p redis.Pipeliner) error {
+			createdSec, _ := strconv.ParseInt(created, 10, 64)
+			expiresSec, _ := strconv.ParseInt(expires, 10, 64)
+			for key, member := range roomIndexMembers(roomID, createdSec, expiresSec) {
+				p.ZAdd(ctx, key, redis.Z{Member: member})
+			}
+			for _, raw := range files {
+				var f domain.RoomFile
+				if err := json.Unmarshal([]byte(raw), &f); err == nil {
+					indexFile(ctx, p, roomID, &f)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+func (r *RedisRepo) AddFileByToken(ctx context.Context, roomID uuid.UUID, token string, file *domain.RoomFile, limits domain.RoomLimits) (bool, error) {
 	if err := ctx.Err(); err != nil {
 		return false, err
 	}
@@ -359,80 +695,547 @@ func (r *RedisRepo) AddFileByToken(ctx context.Context, roomID uuid.UUID, token
 		return false, domain.ErrInvalidFile
 	}
 
-	exists, err := r.db.Exists(ctx, roomKey(roomID)).Result()
+	raw, err := json.Marshal(file)
 	if err != nil {
 		return false, err
 	}
-	if exists == 0 {
-		return false, nil
+
+	var added bool
+	add := func(tx *redis.Tx) error {
+		added = false
+		ok, err := roomHasToken(ctx, tx, roomID, token)
+		if err != nil || !ok {
+			return err
+		}
+		if err := checkRoomLimits(ctx, tx, roomID, limits, len(file.Versions)+1, file.StoredSize()+int64(len(file.Description))); err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.HSet(ctx, filesKey(roomID), file.ID.String(), string(raw))
+			indexFile(ctx, p, roomID, file)
+			if file.Folder != "" {
+				p.SAdd(ctx, foldersKey(roomID), folderArgs(domain.FolderAncestors(file.Folder))...)
+			}
+			return nil
+		})
+		added = err == nil
+		return err
 	}
 
-	ok, err := r.db.SIsMember(ctx, tokensKey(roomID), token).Result()
+	for range maxTxRetries {
+		if err = r.db.Watch(ctx, add, roomKey(roomID), tokensKey(roomID), filesKey(roomID), messagesKey(roomID)); !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
 	if err != nil {
 		return false, err
 	}
-	if !ok {
-		return false, nil
+	return added, nil
+}
+
+func (r *RedisRepo) DeleteFileByToken(ctx context.Context, roomID, fileID uuid.UUID, token string) ([]string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
 	}
 
-	raw, err := json.Marshal(file)
+	var f *domain.RoomFile
+	remove := func(tx *redis.Tx) error {
+		f = nil
+		ok, err := roomHasToken(ctx, tx, roomID, token)
+		if err != nil || !ok {
+			return err
+		}
+		f, err = removeFile(ctx, tx, roomID, fileID.String(), nil)
+		return err
+	}
+
+	var err error
+	for range maxTxRetries {
+		if err = r.db.Watch(ctx, remove, roomKey(roomID), tokensKey(roomID), filesKey(roomID)); !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
+	if err != nil || f == nil {
+		return nil, false, err
+	}
+	return f.Paths(), true, nil
+}
+
+func (r *RedisRepo) AddFileVersionByToken(ctx context.Context, roomID, fileID uuid.UUID, token string, next *domain.RoomFile, limits domain.RoomLimits) (*domain.RoomFile, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	if next == nil {
+		return nil, false, domain.ErrInvalidFile
+	}
+
+	ok, err := r.hasToken(ctx, roomID, token)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	fk := filesKey(roomID)
+	field := fileID.String()
+
+	var file *domain.RoomFile
+	add := func(tx *redis.Tx) error {
+		file = nil
+		raw, err := tx.HGet(ctx, fk, field).Result()
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var old, f domain.RoomFile
+		if err := json.Unmarshal([]byte(raw), &old); err != nil {
+			return err
+		}
+		if err := checkRoomLimits(ctx, tx, roomID, limits, 1, next.Size); err != nil {
+			return err
+		}
+		f = old
+		f.AddVersion(next)
+		encoded, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			unindexFile(ctx, p, roomID, &old)
+			p.HSet(ctx, fk, field, encoded)
+			indexFile(ctx, p, roomID, &f)
+			return nil
+		})
+		if err == nil {
+			file = &f
+		}
+		return err
+	}
+
+	for range maxTxRetries {
+		if err = r.db.Watch(ctx, add, fk, messagesKey(roomID)); !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return file, file != nil, nil
+}
+
+func (r *RedisRepo) SetFileDescriptionByToken(ctx context.Context, roomID, fileID uuid.UUID, token, description string, limits domain.RoomLimits) (*domain.RoomFile, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	ok, err := r.hasToken(ctx, roomID, token)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	fk := filesKey(roomID)
+	field := fileID.String()
+
+	var file *domain.RoomFile
+	set := func(tx *redis.Tx) error {
+		file = nil
+		raw, err := tx.HGet(ctx, fk, field).Result()
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var f domain.RoomFile
+		if err := json.Unmarshal([]byte(raw), &f); err != nil {
+			return err
+		}
+		if grow := int64(len(description) - len(f.Description)); grow > 0 && limits.MaxBytes > 0 {
+			room, err := loadUsage(ctx, tx, roomID)
+			if err != nil {
+				return err
+			}
+			if err := limits.CheckSize(room.TotalSize() + grow); err != nil {
+				return err
+			}
+		}
+		f.Description = description
+		encoded, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.HSet(ctx, fk, field, encoded)
+			return nil
+		})
+		if err == nil {
+			file = &f
+		}
+		return err
+	}
+
+	for range maxTxRetries {
+		if err = r.db.Watch(ctx, set, fk, messagesKey(roomID)); !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
 	if err != nil {
+		return nil, false, err
+	}
+	return file, file != nil, nil
+}
+
+// maxTxRetries bounds how often a transaction is retried when a watched key
+// changed under it.
+const maxTxRetries = 10
+
+func (r *RedisRepo) CountDownloadByToken(ctx context.Context, roomID, fileID uuid.UUID, token string, now time.Time) (*domain.RoomFile, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	ok, err := r.hasToken(ctx, roomID, token)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	fk := filesKey(roomID)
+	field := fileID.String()
+
+	var file *domain.RoomFile
+	count := func(tx *redis.Tx) error {
+		file = nil
+		raw, err := tx.HGet(ctx, fk, field).Result()
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var f domain.RoomFile
+		if err := json.Unmarshal([]byte(raw), &f); err != nil {
+			return err
+		}
+		if f.IsExpired(now) || f.Exhausted() {
+			return nil
+		}
+		f.Downloads++
+		encoded, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			if f.Exhausted() {
+				p.HDel(ctx, fk, field)
+				unindexFile(ctx, p, roomID, &f)
+			} else {
+				p.HSet(ctx, fk, field, encoded)
+			}
+			return nil
+		})
+		if err == nil {
+			file = &f
+		}
+		return err
+	}
+
+	for range maxTxRetries {
+		if err = r.db.Watch(ctx, count, fk); !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return file, file != nil, nil
+}
+
+func (r *RedisRepo) DeleteExpiredFiles(ctx context.Context, now time.Time) ([]domain.ExpiredFile, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	due, err := r.db.ZRangeByScore(ctx, expiringFilesKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: "(" + strconv.FormatInt(now.Unix(), 10),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]domain.ExpiredFile, 0, len(due))
+	for _, member := range due {
+		roomIDStr, fileIDStr, _ := strings.Cut(member, ":")
+		roomID, err1 := uuid.Parse(roomIDStr)
+		fileID, err2 := uuid.Parse(fileIDStr)
+		if err1 != nil || err2 != nil {
+			_ = r.db.ZRem(ctx, expiringFilesKey, member).Err()
+			continue
+		}
+
+		var f *domain.RoomFile
+		remove := func(tx *redis.Tx) error {
+			var err error
+			f, err = removeFile(ctx, tx, roomID, fileIDStr, func(p redis.Pipeliner) {
+				p.ZRem(ctx, expiringFilesKey, member)
+			})
+			return err
+		}
+		for range maxTxRetries {
+			if err = r.db.Watch(ctx, remove, filesKey(roomID)); !errors.Is(err, redis.TxFailedErr) {
+				break
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if f == nil {
+			_ = r.db.ZRem(ctx, expiringFilesKey, member).Err()
+			continue
+		}
+		out = append(out, domain.ExpiredFile{RoomID: roomID, FileID: fileID, Paths: f.Paths()})
+	}
+	return out, nil
+}
+
+func (r *RedisRepo) AddMessageByToken(ctx context.Context, roomID uuid.UUID, token string, msg *domain.RoomMessage, limits domain.RoomLimits) (bool, error) {
+	if err := ctx.Err(); err != nil {
 		return false, err
 	}
+	if msg == nil {
+		return false, domain.ErrInvalidMessage
+	}
 
-	if err := r.db.HSet(ctx, filesKey(roomID), file.ID.String(), string(raw)).Err(); err != nil {
+	raw, err := json.Marshal(msg)
+	if err != nil {
 		return false, err
 	}
-	return true, nil
+
+	var added bool
+	add := func(tx *redis.Tx) error {
+		added = false
+		ok, err := roomHasToken(ctx, tx, roomID, token)
+		if err != nil || !ok {
+			return err
+		}
+		if !limits.IsZero() {
+			room, err := loadUsage(ctx, tx, roomID)
+			if err != nil {
+				return err
+			}
+			if err := limits.CheckMessages(len(room.Messages) + 1); err != nil {
+				return err
+			}
+			if err := limits.CheckSize(room.TotalSize() + msg.Size()); err != nil {
+				return err
+			}
+		}
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.RPush(ctx, messagesKey(roomID), string(raw))
+			return nil
+		})
+		added = err == nil
+		return err
+	}
+
+	for range maxTxRetries {
+		if err = r.db.Watch(ctx, add, roomKey(roomID), tokensKey(roomID), filesKey(roomID), messagesKey(roomID)); !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+	return added, nil
 }
 
-func (r *RedisRepo) DeleteFileByToken(ctx context.Context, roomID, fileID uuid.UUID, token string) (string, bool, error) {
+func (r *RedisRepo) AddFolderByToken(ctx context.Context, roomID uuid.UUID, token, folder string) (bool, error) {
 	if err := ctx.Err(); err != nil {
-		return "", false, err
+		return false, err
 	}
 
-	exists, err := r.db.Exists(ctx, roomKey(roomID)).Result()
+	ok, err := r.hasToken(ctx, roomID, token)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if folder == "" {
+		return false, domain.ErrFolderExists
+	}
+	added, err := r.db.SAdd(ctx, foldersKey(roomID), folder).Result()
 	if err != nil {
-		return "", false, err
+		return false, err
 	}
-	if exists == 0 {
-		return "", false, nil
+	if added == 0 {
+		return false, domain.ErrFolderExists
+	}
+
+	if parents := domain.FolderAncestors(domain.ParentFolder(folder)); len(parents) > 0 {
+		if err := r.db.SAdd(ctx, foldersKey(roomID), folderArgs(parents)...).Err(); err != nil {
+			return false, err
+		}
 	}
+	return true, nil
+}
+
+func (r *RedisRepo) RenameFolderByToken(ctx context.Context, roomID uuid.UUID, token, from, to string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	ok, err := r.hasToken(ctx, roomID, token)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	dk, fk := foldersKey(roomID), filesKey(roomID)
+	err = r.db.Watch(ctx, func(tx *redis.Tx) error {
+		room, err := loadFolderTree(ctx, tx, roomID)
+		if err != nil {
+			return err
+		}
+		before := room.ListFolders()
+		if err := room.RenameFolder(from, to); err != nil {
+			return err
+		}
+
+		files, err := encodeFolderFiles(room, to)
+		if err != nil {
+			return err
+		}
 
-	ok, err := r.db.SIsMember(ctx, tokensKey(roomID), token).Result()
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.SRem(ctx, dk, folderArgs(before)...)
+			p.SAdd(ctx, dk, folderArgs(room.ListFolders())...)
+			if len(files) > 0 {
+				p.HSet(ctx, fk, files...)
+			}
+			return nil
+		})
+		return err
+	}, dk, fk)
 	if err != nil {
-		return "", false, err
+		return false, err
 	}
-	if !ok {
-		return "", false, nil
+	return true, nil
+}
+
+func (r *RedisRepo) DeleteFolderByToken(ctx context.Context, roomID uuid.UUID, token, folder string) ([]string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
 	}
 
-	fk := filesKey(roomID)
-	field := fileID.String()
+	ok, err := r.hasToken(ctx, roomID, token)
+	if err != nil || !ok {
+		return nil, false, err
+	}
 
-	raw, err := r.db.HGet(ctx, fk, field).Result()
+	var paths []string
+	dk, fk := foldersKey(roomID), filesKey(roomID)
+	err = r.db.Watch(ctx, func(tx *redis.Tx) error {
+		room, err := loadFolderTree(ctx, tx, roomID)
+		if err != nil {
+			return err
+		}
+		before := room.ListFolders()
+		removed, err := room.DeleteFolder(folder)
+		if err != nil {
+			return err
+		}
+
+		fields := make([]string, 0, len(removed))
+		paths = make([]string, 0, len(removed))
+		for _, f := range removed {
+			fields = append(fields, f.ID.String())
+			paths = append(paths, f.Paths()...)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.SRem(ctx, dk, folderArgs(before)...)
+			if kept := room.ListFolders(); len(kept) > 0 {
+				p.SAdd(ctx, dk, folderArgs(kept)...)
+			}
+			if len(fields) > 0 {
+				p.HDel(ctx, fk, fields...)
+			}
+			for _, f := range removed {
+				unindexFile(ctx, p, roomID, f)
+			}
+			return nil
+		})
+		return err
+	}, dk, fk)
 	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			return "", false, nil
+		return nil, false, err
+	}
+	return paths, true, nil
+}
+
+func (r *RedisRepo) hasToken(ctx context.Context, roomID uuid.UUID, token string) (bool, error) {
+	return roomHasToken(ctx, r.db, roomID, token)
+}
+
+// roomHasToken reports whether the room exists and token grants access.
+// Run on a transaction watching roomKey and tokensKey, a room deleted or a
+// token removed meanwhile fails the transaction.
+func roomHasToken(ctx context.Context, c redis.Cmdable, roomID uuid.UUID, token string) (bool, error) {
+	exists, err := c.Exists(ctx, roomKey(roomID)).Result()
+	if err != nil || exists == 0 {
+		return false, err
+	}
+	return c.SIsMember(ctx, tokensKey(roomID), token).Result()
+}
+
+func (r *RedisRepo) DeleteFile(ctx context.Context, roomID, fileID uuid.UUID) ([]string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	var f *domain.RoomFile
+	remove := func(tx *redis.Tx) error {
+		var err error
+		f, err = removeFile(ctx, tx, roomID, fileID.String(), nil)
+		return err
+	}
+
+	var err error
+	for range maxTxRetries {
+		if err = r.db.Watch(ctx, remove, filesKey(roomID)); !errors.Is(err, redis.TxFailedErr) {
+			break
 		}
-		return "", false, err
 	}
+	if err != nil || f == nil {
+		return nil, false, err
+	}
+	return f.Paths(), true, nil
+}
 
-	var f domain.RoomFile
-	if err := json.Unmarshal([]byte(raw), &f); err != nil {
-		_, _ = r.db.HDel(ctx, fk, field).Result()
-		return "", false, err
+func (r *RedisRepo) RemoveTokens(ctx context.Context, roomID uuid.UUID) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
 	}
 
-	removed, err := r.db.HDel(ctx, fk, field).Result()
+	exists, err := r.db.Exists(ctx, roomKey(roomID)).Result()
 	if err != nil {
-		return "", false, err
+		return 0, err
 	}
-	if removed == 0 {
-		return "", false, nil
+	if exists == 0 {
+		return 0, ports.ErrRoomNotFound
 	}
 
-	return f.Path, true, nil
+	pipe := r.db.TxPipeline()
+	count := pipe.SCard(ctx, tokensKey(roomID))
+	pipe.Del(ctx, tokensKey(roomID))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return int(count.Val()), nil
 }
 
 func (r *RedisRepo) WipeAll(ctx context.Context) error {
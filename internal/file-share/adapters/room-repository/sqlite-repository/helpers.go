@@ -3,8 +3,13 @@ package sqliterepository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/google/uuid"
 )
 
 func readMaxSQLVars(ctx context.Context, db *sql.DB, fallback int) int {
@@ -68,3 +73,318 @@ func argsFromStrings(in []string) []any {
 	}
 	return out
 }
+
+const (
+	roomColumns    = `id, password_hash, created_at, expires_at, content_rules, sanitize_images, versioning`
+	fileColumns    = `id, path, folder, name, size, sha256, content_type, created_at, scan_status, scan_scanner, scanned_at, sanitized, original_size, original_sha256, expires_at, max_downloads, downloads, version, description`
+	versionColumns = `version, path, size, sha256, content_type, created_at`
+)
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanRoom reads roomColumns into a room without files, tokens or folders.
+func scanRoom(row rowScanner) (*domain.Room, error) {
+	var (
+		idStr        string
+		passwordHash string
+		createdAtSec int64
+		expiresAtSec int64
+		rulesJSON    string
+		sanitize     bool
+		versioning   bool
+	)
+	if err := row.Scan(&idStr, &passwordHash, &createdAtSec, &expiresAtSec, &rulesJSON, &sanitize, &versioning); err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	room := domain.HydrateRoom(id, passwordHash, time.Unix(expiresAtSec, 0))
+	room.CreatedAt = time.Unix(createdAtSec, 0)
+	room.SanitizeImages = sanitize
+	room.Versioning = versioning
+	if room.Content, err = decodeContentRules(rulesJSON); err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+func queryRooms(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]*domain.Room, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rooms := make([]*domain.Room, 0, 50)
+	for rows.Next() {
+		room, err := scanRoom(rows)
+		if err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms, rows.Err()
+}
+
+// scanFile reads fileColumns, after any leading columns given in before.
+func scanFile(row rowScanner, before ...any) (*domain.RoomFile, error) {
+	var (
+		f            domain.RoomFile
+		fileIDStr    string
+		createdAtSec int64
+		expiresAtSec int64
+		scan         scanColumns
+	)
+	dest := append(before, &fileIDStr, &f.Path, &f.Folder, &f.Name, &f.Size, &f.SHA256, &f.ContentType, &createdAtSec,
+		&scan.status, &scan.scanner, &scan.at, &f.Sanitization.Applied, &f.Sanitization.OriginalSize, &f.Sanitization.OriginalSHA256,
+		&expiresAtSec, &f.MaxDownloads, &f.Downloads, &f.Version, &f.Description)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.Parse(fileIDStr)
+	if err != nil {
+		return nil, err
+	}
+	f.ID = id
+	f.CreatedAt = time.Unix(createdAtSec, 0)
+	f.Scan = scan.verdict()
+	if expiresAtSec > 0 {
+		f.ExpiresAt = time.Unix(expiresAtSec, 0)
+	}
+	return &f, nil
+}
+
+// scanVersion reads versionColumns, after any leading columns given in before.
+func scanVersion(row rowScanner, before ...any) (domain.FileVersion, error) {
+	var (
+		v            domain.FileVersion
+		createdAtSec int64
+	)
+	dest := append(before, &v.Version, &v.Path, &v.Size, &v.SHA256, &v.ContentType, &createdAtSec)
+	if err := row.Scan(dest...); err != nil {
+		return domain.FileVersion{}, err
+	}
+	v.CreatedAt = time.Unix(createdAtSec, 0)
+	return v, nil
+}
+
+// fileVersions loads the earlier versions of a file, oldest first.
+func fileVersions(ctx context.Context, tx *sql.Tx, fileID string) ([]domain.FileVersion, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT `+versionColumns+` FROM room_file_versions WHERE file_id = ? ORDER BY version`, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []domain.FileVersion
+	for rows.Next() {
+		v, err := scanVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func insertVersion(ctx context.Context, tx *sql.Tx, roomID, fileID string, v domain.FileVersion) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO room_file_versions (file_id, room_id, `+versionColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, fileID, roomID, v.Version, v.Path, v.Size, v.SHA256, v.ContentType, v.CreatedAt.Unix())
+	return err
+}
+
+// storedVersion is an earlier version removed together with its file.
+type storedVersion struct {
+	roomID string
+	fileID string
+	path   string
+}
+
+// deleteVersions removes the earlier versions matching where. Callers run it
+// before deleting the files themselves, so where may select by file.
+func deleteVersions(ctx context.Context, tx *sql.Tx, where string, args ...any) ([]storedVersion, error) {
+	rows, err := tx.QueryContext(ctx, `DELETE FROM room_file_versions WHERE `+where+` RETURNING room_id, file_id, path`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []storedVersion
+	for rows.Next() {
+		var v storedVersion
+		if err := rows.Scan(&v.roomID, &v.fileID, &v.path); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+func versionPaths(versions []storedVersion) []string {
+	paths := make([]string, 0, len(versions))
+	for _, v := range versions {
+		if v.path != "" {
+			paths = append(paths, v.path)
+		}
+	}
+	return paths
+}
+
+// keyset is the condition selecting rows after the cursor (key, id) in a
+// listing ordered by column and then id.
+func keyset(column string, key any, id uuid.UUID, desc bool) (string, []any) {
+	op := ">"
+	if desc {
+		op = "<"
+	}
+	cond := fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, op)
+	return cond, []any{key, key, id.String()}
+}
+
+func orderBy(column string, desc bool) string {
+	if desc {
+		return " ORDER BY " + column + " DESC, id DESC"
+	}
+	return " ORDER BY " + column + ", id"
+}
+
+// scanColumns holds the scan verdict columns of room_files; scanned_at is 0
+// for files that were never scanned.
+type scanColumns struct {
+	status  string
+	scanner string
+	at      int64
+}
+
+func (c scanColumns) verdict() domain.ScanVerdict {
+	v := domain.ScanVerdict{Status: c.status, Scanner: c.scanner}
+	if c.at > 0 {
+		v.ScannedAt = time.Unix(c.at, 0)
+	}
+	return v
+}
+
+// unixColumn stores an optional time as Unix seconds, 0 when unset.
+func unixColumn(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// Room content rules are stored as JSON, empty when the room has none.
+func encodeContentRules(rules domain.ContentRules) (string, error) {
+	if rules.IsZero() {
+		return "", nil
+	}
+	b, err := json.Marshal(rules)
+	return string(b), err
+}
+
+func decodeContentRules(raw string) (domain.ContentRules, error) {
+	var rules domain.ContentRules
+	if raw == "" {
+		return rules, nil
+	}
+	err := json.Unmarshal([]byte(raw), &rules)
+	return rules, err
+}
+
+// hasRoomToken reports whether the room exists and token is one of its tokens.
+func hasRoomToken(ctx context.Context, tx *sql.Tx, roomID, token string) (bool, error) {
+	var exists int
+	err := tx.QueryRowContext(ctx, `
+		SELECT 1
+		FROM room_tokens
+		WHERE room_id = ? AND token = ?
+		LIMIT 1
+	`, roomID, token).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func hasFolder(ctx context.Context, tx *sql.Tx, roomID, folder string) (bool, error) {
+	if folder == "" {
+		return true, nil
+	}
+	var exists int
+	err := tx.QueryRowContext(ctx, `
+		SELECT 1
+		FROM room_folders
+		WHERE room_id = ? AND path = ?
+		LIMIT 1
+	`, roomID, folder).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// insertFolders records folder and all of its parents.
+func insertFolders(ctx context.Context, tx *sql.Tx, roomID, folder string) error {
+	for _, f := range domain.FolderAncestors(folder) {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO room_folders (room_id, path)
+			VALUES (?, ?)
+		`, roomID, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// roomFilesSQL and roomSizeSQL count what domain.Room.StoredFiles and
+// TotalSize count for the room bound to ?1.
+const (
+	roomFilesSQL = `
+		(SELECT COUNT(*) FROM room_files WHERE room_id = ?1)
+			+ (SELECT COUNT(*) FROM room_file_versions WHERE room_id = ?1)`
+	roomSizeSQL = `
+		(SELECT COALESCE(SUM(size + LENGTH(CAST(description AS BLOB))), 0) FROM room_files WHERE room_id = ?1)
+			+ (SELECT COALESCE(SUM(size), 0) FROM room_file_versions WHERE room_id = ?1)
+			+ (SELECT COALESCE(SUM(LENGTH(CAST(author AS BLOB)) + LENGTH(CAST(text AS BLOB))), 0) FROM room_messages WHERE room_id = ?1)`
+)
+
+// checkRoomLimits holds the room to limits after a write in tx. The write
+// took the database lock, so no other writer can slip in between.
+func checkRoomLimits(ctx context.Context, tx *sql.Tx, roomID string, limits domain.RoomLimits) error {
+	if limits.IsZero() {
+		return nil
+	}
+	var files int
+	var size int64
+	err := tx.QueryRowContext(ctx, `SELECT `+roomFilesSQL+`, `+roomSizeSQL, roomID).Scan(&files, &size)
+	if err != nil {
+		return err
+	}
+	return limits.Check(files, size)
+}
+
+// checkMessageLimits is checkRoomLimits after a message was added in tx.
+func checkMessageLimits(ctx context.Context, tx *sql.Tx, roomID string, limits domain.RoomLimits) error {
+	if limits.IsZero() {
+		return nil
+	}
+	var messages int
+	var size int64
+	err := tx.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) FROM room_messages WHERE room_id = ?1), `+roomSizeSQL, roomID).Scan(&messages, &size)
+	if err != nil {
+		return err
+	}
+	if err := limits.CheckMessages(messages); err != nil {
+		return err
+	}
+	return limits.CheckSize(size)
+}
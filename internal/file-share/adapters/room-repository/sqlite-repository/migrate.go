@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -127,6 +128,7 @@ func executeMigration(ctx context.Context, conn *sql.DB, migrationsDir string, m
 		return err
 	}
 
+	slog.InfoContext(ctx, "migration applied", slog.String("migration", migration))
 	return nil
 }
 
@@ -42,27 +42,18 @@ func (r *SqliteRepo) Get(ctx context.Context, roomID uuid.UUID) (*domain.Room, b
 		return nil, false, err
 	}
 
-	roomIdString := roomID.String()
-
 	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
 	if err != nil {
 		return nil, false, err
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	var (
-		idStr        string
-		passwordHash string
-		expiresAtSec int64
-	)
-
-	err = tx.QueryRowContext(ctx, `
-		SELECT id, password_hash, expires_at
+	room, err := scanRoom(tx.QueryRowContext(ctx, `
+		SELECT `+roomColumns+`
 		FROM rooms
 		WHERE id = ?
 		LIMIT 1
-	`, roomIdString).Scan(&idStr, &passwordHash, &expiresAtSec)
-
+	`, roomID.String()))
 	if err == sql.ErrNoRows {
 		return nil, false, nil
 	}
@@ -70,72 +61,7 @@ func (r *SqliteRepo) Get(ctx context.Context, roomID uuid.UUID) (*domain.Room, b
 		return nil, false, err
 	}
 
-	id, err := uuid.Parse(idStr)
-	if err != nil {
-		return nil, false, err
-	}
-
-	room := domain.HydrateRoom(id, passwordHash, time.Unix(expiresAtSec, 0))
-
-	tokenRows, err := tx.QueryContext(ctx, `
-		SELECT token
-		FROM room_tokens
-		WHERE room_id = ?
-	`, roomIdString)
-	if err != nil {
-		return nil, false, err
-	}
-	defer tokenRows.Close()
-
-	for tokenRows.Next() {
-		var t string
-		if err := tokenRows.Scan(&t); err != nil {
-			return nil, false, err
-		}
-		if err := room.AddToken(t); err != nil {
-			return nil, false, err
-		}
-	}
-	if err := tokenRows.Err(); err != nil {
-		return nil, false, err
-	}
-
-	fileRows, err := tx.QueryContext(ctx, `
-		SELECT id, path, name, size, created_at
-		FROM room_files
-		WHERE room_id = ?
-	`, roomIdString)
-	if err != nil {
-		return nil, false, err
-	}
-	defer fileRows.Close()
-
-	for fileRows.Next() {
-		var (
-			fileIDStr    string
-			path         string
-			name         string
-			size         int64
-			createdAtSec int64
-		)
-		if err := fileRows.Scan(&fileIDStr, &path, &name, &size, &createdAtSec); err != nil {
-			return nil, false, err
-		}
-
-		fid, err := uuid.Parse(fileIDStr)
-		if err != nil {
-			return nil, false, err
-		}
-
-		_ = room.AddFile(&domain.RoomFile{
-			ID:        fid,
-			Path:      path,
-			Name:      name,
-			Size:      size,
-			CreatedAt: time.Unix(createdAtSec, 0),
-		})
-	}
-	if err := fileRows.Err(); err != nil {
+	if err := r.hydrateRooms(ctx, tx, []*domain.Room{room}); err != nil {
 		return nil, false, err
 	}
 
@@ -157,100 +83,194 @@ func (r *SqliteRepo) List(ctx context.Context) ([]*domain.Room, error) {
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	rows, err := tx.QueryContext(ctx, `
-		SELECT id, password_hash, expires_at
+	rooms, err := queryRooms(ctx, tx, `
+		SELECT `+roomColumns+`
 		FROM rooms
 		ORDER BY created_at DESC
 	`)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	roomByID := make(map[string]*domain.Room, 50)
-	roomIDs := make([]string, 0, 50)
+	if err := r.hydrateRooms(ctx, tx, rooms); err != nil {
+		return nil, err
+	}
 
-	for rows.Next() {
-		var (
-			idStr        string
-			passwordHash string
-			expiresAtSec int64
-		)
-		if err := rows.Scan(&idStr, &passwordHash, &expiresAtSec); err != nil {
-			return nil, err
-		}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+func (r *SqliteRepo) Stats(ctx context.Context) (ports.RoomStats, error) {
+	if err := ctx.Err(); err != nil {
+		return ports.RoomStats{}, err
+	}
+
+	var stats ports.RoomStats
+	err := r.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM rooms),
+			(SELECT COUNT(*) FROM room_tokens),
+			(SELECT COUNT(*) FROM room_files),
+			(SELECT COALESCE(SUM(size), 0) FROM room_files)
+				+ (SELECT COALESCE(SUM(size), 0) FROM room_file_versions)
+	`).Scan(&stats.Rooms, &stats.Tokens, &stats.Files, &stats.Bytes)
+	if err != nil {
+		return ports.RoomStats{}, err
+	}
+	return stats, nil
+}
+
+func (r *SqliteRepo) QueryRooms(ctx context.Context, query ports.RoomQuery) (ports.RoomPage, error) {
+	if err := ctx.Err(); err != nil {
+		return ports.RoomPage{}, err
+	}
+
+	var where []string
+	var args []any
+	if !query.ExpiresAfter.IsZero() {
+		where = append(where, "expires_at >= ?")
+		args = append(args, query.ExpiresAfter.Unix())
+	}
+	if !query.ExpiresBefore.IsZero() {
+		where = append(where, "expires_at <= ?")
+		args = append(args, query.ExpiresBefore.Unix())
+	}
 
-		id, err := uuid.Parse(idStr)
+	column := "created_at"
+	if query.Sort == ports.SortByExpiresAt {
+		column = "expires_at"
+	}
+	if query.Cursor != "" {
+		c, err := ports.ParseCursor(query.Cursor)
 		if err != nil {
-			return nil, err
+			return ports.RoomPage{}, err
+		}
+		nanos, err := c.Int()
+		if err != nil {
+			return ports.RoomPage{}, err
 		}
+		cond, condArgs := keyset(column, nanos/int64(time.Second), c.ID, query.Desc)
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
 
-		room := domain.HydrateRoom(id, passwordHash, time.Unix(expiresAtSec, 0))
-		roomByID[idStr] = room
-		roomIDs = append(roomIDs, idStr)
+	q := `SELECT ` + roomColumns + ` FROM rooms`
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
 	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+	q += orderBy(column, query.Desc) + " LIMIT ?"
+	args = append(args, query.Limit+1)
+
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return ports.RoomPage{}, err
 	}
+	defer func() { _ = tx.Rollback() }()
 
-	if len(roomIDs) == 0 {
-		if err := tx.Commit(); err != nil {
-			return nil, err
-		}
-		return []*domain.Room{}, nil
+	rooms, err := queryRooms(ctx, tx, q, args...)
+	if err != nil {
+		return ports.RoomPage{}, err
+	}
+
+	var page ports.RoomPage
+	if len(rooms) > query.Limit {
+		rooms = rooms[:query.Limit]
+		page.Next = ports.RoomCursor(query.Sort, rooms[len(rooms)-1]).String()
+	}
+	if err := r.hydrateRooms(ctx, tx, rooms); err != nil {
+		return ports.RoomPage{}, err
+	}
+	page.Rooms = rooms
+
+	if err := tx.Commit(); err != nil {
+		return ports.RoomPage{}, err
+	}
+	return page, nil
+}
+
+// hydrateRooms loads the files with their versions, tokens and folders of
+// rooms.
+func (r *SqliteRepo) hydrateRooms(ctx context.Context, tx *sql.Tx, rooms []*domain.Room) error {
+	if len(rooms) == 0 {
+		return nil
+	}
+
+	roomByID := make(map[string]*domain.Room, len(rooms))
+	roomIDs := make([]string, 0, len(rooms))
+	for _, room := range rooms {
+		idStr := room.ID.String()
+		roomByID[idStr] = room
+		roomIDs = append(roomIDs, idStr)
 	}
 
 	chunks := chunkStrings(roomIDs, r.inLimit)
 	for _, ch := range chunks {
 		q := fmt.Sprintf(`
-			SELECT room_id, id, path, name, size, created_at
+			SELECT room_id, %s
 			FROM room_files
 			WHERE room_id IN (%s)
-		`, makePlaceholders(len(ch)))
+		`, fileColumns, makePlaceholders(len(ch)))
 
 		fRows, err := tx.QueryContext(ctx, q, argsFromStrings(ch)...)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		for fRows.Next() {
-			var (
-				roomIDStr    string
-				fileIDStr    string
-				path         string
-				name         string
-				size         int64
-				createdAtSec int64
-			)
-			if err := fRows.Scan(&roomIDStr, &fileIDStr, &path, &name, &size, &createdAtSec); err != nil {
+			var roomIDStr string
+			f, err := scanFile(fRows, &roomIDStr)
+			if err != nil {
 				_ = fRows.Close()
-				return nil, err
+				return err
+			}
+			if room := roomByID[roomIDStr]; room != nil {
+				_ = room.AddFile(f)
 			}
+		}
+		if err := fRows.Err(); err != nil {
+			_ = fRows.Close()
+			return err
+		}
+		_ = fRows.Close()
+	}
+
+	for _, ch := range chunks {
+		q := fmt.Sprintf(`
+			SELECT room_id, file_id, %s
+			FROM room_file_versions
+			WHERE room_id IN (%s)
+			ORDER BY file_id, version
+		`, versionColumns, makePlaceholders(len(ch)))
+
+		vRows, err := tx.QueryContext(ctx, q, argsFromStrings(ch)...)
+		if err != nil {
+			return err
+		}
 
+		for vRows.Next() {
+			var roomIDStr, fileIDStr string
+			v, err := scanVersion(vRows, &roomIDStr, &fileIDStr)
+			if err != nil {
+				_ = vRows.Close()
+				return err
+			}
 			room := roomByID[roomIDStr]
 			if room == nil {
 				continue
 			}
-
-			fid, err := uuid.Parse(fileIDStr)
-			if err != nil {
-				_ = fRows.Close()
-				return nil, err
+			if fileID, err := uuid.Parse(fileIDStr); err == nil {
+				if f, ok := room.GetFile(fileID); ok {
+					f.Versions = append(f.Versions, v)
+				}
 			}
-
-			_ = room.AddFile(&domain.RoomFile{
-				ID:        fid,
-				Path:      path,
-				Name:      name,
-				Size:      size,
-				CreatedAt: time.Unix(createdAtSec, 0),
-			})
 		}
-		if err := fRows.Err(); err != nil {
-			_ = fRows.Close()
-			return nil, err
+		if err := vRows.Err(); err != nil {
+			_ = vRows.Close()
+			return err
 		}
-		_ = fRows.Close()
+		_ = vRows.Close()
 	}
 
 	for _, ch := range chunks {
@@ -262,7 +282,7 @@ func (r *SqliteRepo) List(ctx context.Context) ([]*domain.Room, error) {
 
 		tRows, err := tx.QueryContext(ctx, q, argsFromStrings(ch)...)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		for tRows.Next() {
@@ -272,7 +292,7 @@ func (r *SqliteRepo) List(ctx context.Context) ([]*domain.Room, error) {
 			)
 			if err := tRows.Scan(&roomIDStr, &token); err != nil {
 				_ = tRows.Close()
-				return nil, err
+				return err
 			}
 
 			room := roomByID[roomIDStr]
@@ -281,28 +301,83 @@ func (r *SqliteRepo) List(ctx context.Context) ([]*domain.Room, error) {
 			}
 			if err := room.AddToken(token); err != nil {
 				_ = tRows.Close()
-				return nil, err
+				return err
 			}
 		}
 		if err := tRows.Err(); err != nil {
 			_ = tRows.Close()
-			return nil, err
+			return err
 		}
 		_ = tRows.Close()
 	}
 
-	if err := tx.Commit(); err != nil {
-		return nil, err
+	for _, ch := range chunks {
+		q := fmt.Sprintf(`
+			SELECT room_id, path
+			FROM room_folders
+			WHERE room_id IN (%s)
+		`, makePlaceholders(len(ch)))
+
+		dRows, err := tx.QueryContext(ctx, q, argsFromStrings(ch)...)
+		if err != nil {
+			return err
+		}
+
+		for dRows.Next() {
+			var roomIDStr, folder string
+			if err := dRows.Scan(&roomIDStr, &folder); err != nil {
+				_ = dRows.Close()
+				return err
+			}
+			if room := roomByID[roomIDStr]; room != nil {
+				room.AddFolder(folder)
+			}
+		}
+		if err := dRows.Err(); err != nil {
+			_ = dRows.Close()
+			return err
+		}
+		_ = dRows.Close()
 	}
 
-	out := make([]*domain.Room, 0, len(roomIDs))
-	for _, idStr := range roomIDs {
-		if room := roomByID[idStr]; room != nil {
-			out = append(out, room)
+	for _, ch := range chunks {
+		q := fmt.Sprintf(`
+			SELECT room_id, id, author, text, created_at
+			FROM room_messages
+			WHERE room_id IN (%s)
+			ORDER BY created_at, rowid
+		`, makePlaceholders(len(ch)))
+
+		mRows, err := tx.QueryContext(ctx, q, argsFromStrings(ch)...)
+		if err != nil {
+			return err
+		}
+
+		for mRows.Next() {
+			var roomIDStr, idStr string
+			var createdAtSec int64
+			var msg domain.RoomMessage
+			if err := mRows.Scan(&roomIDStr, &idStr, &msg.Author, &msg.Text, &createdAtSec); err != nil {
+				_ = mRows.Close()
+				return err
+			}
+			room := roomByID[roomIDStr]
+			id, err := uuid.Parse(idStr)
+			if room == nil || err != nil {
+				continue
+			}
+			msg.ID = id
+			msg.CreatedAt = time.Unix(createdAtSec, 0)
+			room.Messages = append(room.Messages, msg)
 		}
+		if err := mRows.Err(); err != nil {
+			_ = mRows.Close()
+			return err
+		}
+		_ = mRows.Close()
 	}
 
-	return out, nil
+	return nil
 }
 
 func (r *SqliteRepo) Create(ctx context.Context, room *domain.Room) error {
@@ -319,10 +394,20 @@ func (r *SqliteRepo) Create(ctx context.Context, room *domain.Room) error {
 	}
 	defer func() { _ = tx.Rollback() }()
 
+	rulesJSON, err := encodeContentRules(room.Content)
+	if err != nil {
+		return err
+	}
+
+	createdAt := room.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
 	_, err = tx.ExecContext(ctx, `
-		INSERT INTO rooms (id, password_hash, expires_at, created_at)
-		VALUES (?, ?, ?, CAST(strftime('%s','now') AS INTEGER))
-	`, room.ID.String(), room.Password(), room.ExpiresAt.Unix())
+		INSERT INTO rooms (id, password_hash, expires_at, content_rules, sanitize_images, versioning, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, room.ID.String(), room.Password(), room.ExpiresAt.Unix(), rulesJSON, room.SanitizeImages, room.Versioning, createdAt.Unix())
 	if err != nil {
 		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
 			return ports.ErrRoomAlreadyExists
@@ -403,6 +488,13 @@ func (r *SqliteRepo) Delete(ctx context.Context, roomID uuid.UUID) ([]string, er
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
+	_ = rows.Close()
+
+	versions, err := deleteVersions(ctx, tx, `room_id = ?`, roomIDString)
+	if err != nil {
+		return nil, err
+	}
+	paths = append(paths, versionPaths(versions)...)
 
 	res, err := tx.ExecContext(ctx, `DELETE FROM rooms WHERE id = ?`, roomIDString)
 	if err != nil {
@@ -495,6 +587,16 @@ func (r *SqliteRepo) DeleteExpired(ctx context.Context, now time.Time) ([]domain
 	}
 
 	for _, ch := range chunks {
+		versions, err := deleteVersions(ctx, tx, fmt.Sprintf(`room_id IN (%s)`, makePlaceholders(len(ch))), argsFromStrings(ch)...)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range versions {
+			if v.path != "" {
+				pathsByRoom[v.roomID] = append(pathsByRoom[v.roomID], v.path)
+			}
+		}
+
 		q := fmt.Sprintf(`DELETE FROM rooms WHERE id IN (%s)`, makePlaceholders(len(ch)))
 		if _, err := tx.ExecContext(ctx, q, argsFromStrings(ch)...); err != nil {
 			return nil, err
@@ -584,7 +686,119 @@ func (r *SqliteRepo) AddToken(ctx context.Context, roomID uuid.UUID, token strin
 	return nil
 }
 
-func (r *SqliteRepo) AddFileByToken(ctx context.Context, roomID uuid.UUID, token string, file *domain.RoomFile) (bool, error) {
+func (r *SqliteRepo) QueryFilesByToken(ctx context.Context, roomID uuid.UUID, token string, query ports.FileQuery) (ports.FilePage, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return ports.FilePage{}, false, err
+	}
+
+	roomIDString := roomID.String()
+	where := []string{"room_id = ?"}
+	args := []any{roomIDString}
+
+	if query.Folder != "" {
+		where = append(where, "(folder = ? OR substr(folder, 1, length(?) + 1) = ? || '/')")
+		args = append(args, query.Folder, query.Folder, query.Folder)
+	}
+	if query.Name != "" {
+		where = append(where, "instr(lower(name), ?) > 0")
+		args = append(args, ports.FoldName(query.Name))
+	}
+	if family, ok := strings.CutSuffix(query.ContentType, "/*"); ok {
+		where = append(where, "substr(content_type, 1, length(?) + 1) = ? || '/'")
+		args = append(args, family, family)
+	} else if query.ContentType != "" {
+		// Stored types may carry parameters, as in "text/plain; charset=utf-8".
+		where = append(where, "(content_type = ? OR substr(content_type, 1, length(?) + 1) = ? || ';')")
+		args = append(args, query.ContentType, query.ContentType, query.ContentType)
+	}
+	if query.MinSize > 0 {
+		where = append(where, "size >= ?")
+		args = append(args, query.MinSize)
+	}
+	if query.MaxSize > 0 {
+		where = append(where, "size <= ?")
+		args = append(args, query.MaxSize)
+	}
+
+	column := "name COLLATE NOCASE"
+	switch query.Sort {
+	case ports.SortBySize:
+		column = "size"
+	case ports.SortByCreatedAt:
+		column = "created_at"
+	}
+	if query.Cursor != "" {
+		c, err := ports.ParseCursor(query.Cursor)
+		if err != nil {
+			return ports.FilePage{}, false, err
+		}
+		var key any = c.Key
+		if query.Sort != ports.SortByName {
+			n, err := c.Int()
+			if err != nil {
+				return ports.FilePage{}, false, err
+			}
+			if query.Sort == ports.SortByCreatedAt {
+				n /= int64(time.Second)
+			}
+			key = n
+		}
+		cond, condArgs := keyset(column, key, c.ID, query.Desc)
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+
+	q := `SELECT ` + fileColumns + ` FROM room_files WHERE ` + strings.Join(where, " AND ") + orderBy(column, query.Desc) + ` LIMIT ?`
+	args = append(args, query.Limit+1)
+
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return ports.FilePage{}, false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	ok, err := hasRoomToken(ctx, tx, roomIDString, token)
+	if err != nil || !ok {
+		return ports.FilePage{}, false, err
+	}
+	exists, err := hasFolder(ctx, tx, roomIDString, query.Folder)
+	if err != nil {
+		return ports.FilePage{}, false, err
+	}
+	if !exists {
+		return ports.FilePage{}, false, domain.ErrFolderNotFound
+	}
+
+	rows, err := tx.QueryContext(ctx, q, args...)
+	if err != nil {
+		return ports.FilePage{}, false, err
+	}
+	defer rows.Close()
+
+	var page ports.FilePage
+	for rows.Next() {
+		f, err := scanFile(rows)
+		if err != nil {
+			return ports.FilePage{}, false, err
+		}
+		page.Files = append(page.Files, f)
+	}
+	if err := rows.Err(); err != nil {
+		return ports.FilePage{}, false, err
+	}
+
+	if len(page.Files) > query.Limit {
+		page.Files = page.Files[:query.Limit]
+		page.Next = ports.FileCursor(query.Sort, page.Files[query.Limit-1]).String()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ports.FilePage{}, false, err
+	}
+	return page, true, nil
+}
+
+func (r *SqliteRepo) AddFileByToken(ctx context.Context, roomID uuid.UUID, token string, file *domain.RoomFile, limits domain.RoomLimits) (bool, error) {
 	if err := ctx.Err(); err != nil {
 		return false, err
 	}
@@ -600,33 +814,35 @@ func (r *SqliteRepo) AddFileByToken(ctx context.Context, roomID uuid.UUID, token
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	var exists int
-	err = tx.QueryRowContext(ctx, `SELECT 1 FROM rooms WHERE id = ? LIMIT 1`, roomIDString).Scan(&exists)
-	if err == sql.ErrNoRows {
-		return false, nil
+	// The insert is the first statement, so the transaction holds the write
+	// lock before the quota is read; the token check is part of it.
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO room_files (`+fileColumns+`, room_id)
+		SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		WHERE EXISTS (SELECT 1 FROM room_tokens WHERE room_id = ? AND token = ?)
+	`, file.ID.String(), file.Path, file.Folder, file.Name, file.Size, file.SHA256, file.ContentType, file.CreatedAt.Unix(), file.Scan.Status, file.Scan.Scanner, unixColumn(file.Scan.ScannedAt),
+		file.Sanitization.Applied, file.Sanitization.OriginalSize, file.Sanitization.OriginalSHA256, unixColumn(file.ExpiresAt), file.MaxDownloads, file.Downloads, max(file.Version, 1),
+		file.Description, roomIDString, roomIDString, token)
+	if err != nil {
+		return false, err
 	}
+	aff, err := res.RowsAffected()
 	if err != nil {
 		return false, err
 	}
-
-	err = tx.QueryRowContext(ctx, `
-		SELECT 1
-		FROM room_tokens
-		WHERE room_id = ? AND token = ?
-		LIMIT 1
-	`, roomIDString, token).Scan(&exists)
-	if err == sql.ErrNoRows {
+	if aff == 0 {
 		return false, nil
 	}
-	if err != nil {
-		return false, err
+	for _, v := range file.Versions {
+		if err := insertVersion(ctx, tx, roomIDString, file.ID.String(), v); err != nil {
+			return false, err
+		}
 	}
 
-	_, err = tx.ExecContext(ctx, `
-		INSERT INTO room_files (id, room_id, path, name, size, created_at)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`, file.ID.String(), roomIDString, file.Path, file.Name, file.Size, file.CreatedAt.Unix())
-	if err != nil {
+	if err := insertFolders(ctx, tx, roomIDString, file.Folder); err != nil {
+		return false, err
+	}
+	if err := checkRoomLimits(ctx, tx, roomIDString, limits); err != nil {
 		return false, err
 	}
 
@@ -636,26 +852,157 @@ func (r *SqliteRepo) AddFileByToken(ctx context.Context, roomID uuid.UUID, token
 	return true, nil
 }
 
-func (r *SqliteRepo) DeleteFileByToken(ctx context.Context, roomID, fileID uuid.UUID, token string) (string, bool, error) {
+func (r *SqliteRepo) AddFileVersionByToken(ctx context.Context, roomID, fileID uuid.UUID, token string, next *domain.RoomFile, limits domain.RoomLimits) (*domain.RoomFile, bool, error) {
 	if err := ctx.Err(); err != nil {
-		return "", false, err
+		return nil, false, err
+	}
+	if next == nil {
+		return nil, false, domain.ErrInvalidFile
 	}
 
 	roomIDString := roomID.String()
+	fileIDString := fileID.String()
 
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return "", false, err
+		return nil, false, err
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	var exists int
+	// Keeping the current content as a version is the first write, so the
+	// transaction holds the write lock before it reads; the token check is
+	// part of it.
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO room_file_versions (file_id, room_id, `+versionColumns+`)
+		SELECT id, room_id, `+versionColumns+`
+		FROM room_files
+		WHERE room_id = ? AND id = ?
+			AND EXISTS (SELECT 1 FROM room_tokens WHERE room_id = ? AND token = ?)
+	`, roomIDString, fileIDString, roomIDString, token)
+	if err != nil {
+		return nil, false, err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+	if aff == 0 {
+		return nil, false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE room_files
+		SET path = ?, size = ?, sha256 = ?, content_type = ?, created_at = ?, scan_status = ?, scan_scanner = ?, scanned_at = ?,
+			sanitized = ?, original_size = ?, original_sha256 = ?, expires_at = ?, max_downloads = ?, downloads = 0, version = version + 1
+		WHERE room_id = ? AND id = ?
+	`, next.Path, next.Size, next.SHA256, next.ContentType, next.CreatedAt.Unix(), next.Scan.Status, next.Scan.Scanner, unixColumn(next.Scan.ScannedAt),
+		next.Sanitization.Applied, next.Sanitization.OriginalSize, next.Sanitization.OriginalSHA256, unixColumn(next.ExpiresAt), next.MaxDownloads,
+		roomIDString, fileIDString)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := checkRoomLimits(ctx, tx, roomIDString, limits); err != nil {
+		return nil, false, err
+	}
+
+	file, err := scanFile(tx.QueryRowContext(ctx, `SELECT `+fileColumns+` FROM room_files WHERE room_id = ? AND id = ?`, roomIDString, fileIDString))
+	if err != nil {
+		return nil, false, err
+	}
+	if file.Versions, err = fileVersions(ctx, tx, fileIDString); err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return file, true, nil
+}
+
+func (r *SqliteRepo) SetFileDescriptionByToken(ctx context.Context, roomID, fileID uuid.UUID, token, description string, limits domain.RoomLimits) (*domain.RoomFile, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	roomIDString := roomID.String()
+	fileIDString := fileID.String()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Reading the old description first would not take the write lock, so
+	// the quota is checked by the update itself.
+	res, err := tx.ExecContext(ctx, `
+		UPDATE room_files
+		SET description = ?4
+		WHERE room_id = ?1 AND id = ?2
+			AND EXISTS (SELECT 1 FROM room_tokens WHERE room_id = ?1 AND token = ?3)
+			AND (?5 <= 0
+				OR LENGTH(CAST(?4 AS BLOB)) <= LENGTH(CAST(description AS BLOB))
+				OR (`+roomSizeSQL+`) + LENGTH(CAST(?4 AS BLOB)) - LENGTH(CAST(description AS BLOB)) <= ?5)
+	`, roomIDString, fileIDString, token, description, limits.MaxBytes)
+	if err != nil {
+		return nil, false, err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+	if aff == 0 {
+		var exists bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM room_files
+				WHERE room_id = ?1 AND id = ?2
+					AND EXISTS (SELECT 1 FROM room_tokens WHERE room_id = ?1 AND token = ?3)
+			)
+		`, roomIDString, fileIDString, token).Scan(&exists)
+		if err != nil {
+			return nil, false, err
+		}
+		if exists {
+			return nil, false, domain.ErrRoomQuotaExceeded
+		}
+		return nil, false, nil
+	}
+
+	file, err := scanFile(tx.QueryRowContext(ctx, `SELECT `+fileColumns+` FROM room_files WHERE room_id = ? AND id = ?`, roomIDString, fileIDString))
+	if err != nil {
+		return nil, false, err
+	}
+	if file.Versions, err = fileVersions(ctx, tx, fileIDString); err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return file, true, nil
+}
+
+func (r *SqliteRepo) DeleteFileByToken(ctx context.Context, roomID, fileID uuid.UUID, token string) ([]string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	roomIDString := roomID.String()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var exists int
 	err = tx.QueryRowContext(ctx, `SELECT 1 FROM rooms WHERE id = ? LIMIT 1`, roomIDString).Scan(&exists)
 	if err == sql.ErrNoRows {
-		return "", false, nil
+		return nil, false, nil
 	}
 	if err != nil {
-		return "", false, err
+		return nil, false, err
 	}
 
 	err = tx.QueryRowContext(ctx, `
@@ -665,10 +1012,10 @@ func (r *SqliteRepo) DeleteFileByToken(ctx context.Context, roomID, fileID uuid.
 		LIMIT 1
 	`, roomIDString, token).Scan(&exists)
 	if err == sql.ErrNoRows {
-		return "", false, nil
+		return nil, false, nil
 	}
 	if err != nil {
-		return "", false, err
+		return nil, false, err
 	}
 
 	var path string
@@ -679,10 +1026,15 @@ func (r *SqliteRepo) DeleteFileByToken(ctx context.Context, roomID, fileID uuid.
 		LIMIT 1
 	`, roomIDString, fileID.String()).Scan(&path)
 	if err == sql.ErrNoRows {
-		return "", false, nil
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
 	}
+
+	versions, err := deleteVersions(ctx, tx, `file_id = ?`, fileID.String())
 	if err != nil {
-		return "", false, err
+		return nil, false, err
 	}
 
 	res, err := tx.ExecContext(ctx, `
@@ -690,20 +1042,437 @@ func (r *SqliteRepo) DeleteFileByToken(ctx context.Context, roomID, fileID uuid.
 		WHERE room_id = ? AND id = ?
 	`, roomIDString, fileID.String())
 	if err != nil {
-		return "", false, err
+		return nil, false, err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+	if aff == 0 {
+		return nil, false, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return append([]string{path}, versionPaths(versions)...), true, nil
+}
+
+func (r *SqliteRepo) CountDownloadByToken(ctx context.Context, roomID, fileID uuid.UUID, token string, now time.Time) (*domain.RoomFile, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	roomIDString := roomID.String()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// The update comes first so the transaction takes the write lock before
+	// reading anything; the token check is part of it.
+	res, err := tx.ExecContext(ctx, `
+		UPDATE room_files
+		SET downloads = downloads + 1
+		WHERE room_id = ? AND id = ?
+			AND (max_downloads = 0 OR downloads < max_downloads)
+			AND (expires_at = 0 OR expires_at >= ?)
+			AND EXISTS (SELECT 1 FROM room_tokens WHERE room_id = ? AND token = ?)
+	`, roomIDString, fileID.String(), now.Unix(), roomIDString, token)
+	if err != nil {
+		return nil, false, err
 	}
 	aff, err := res.RowsAffected()
 	if err != nil {
-		return "", false, err
+		return nil, false, err
 	}
 	if aff == 0 {
-		return "", false, nil
+		return nil, false, nil
+	}
+
+	row := tx.QueryRowContext(ctx, `SELECT `+fileColumns+` FROM room_files WHERE room_id = ? AND id = ?`, roomIDString, fileID.String())
+	file, err := scanFile(row)
+	if err != nil {
+		return nil, false, err
+	}
+	if file.Exhausted() {
+		if file.Versions, err = fileVersions(ctx, tx, fileID.String()); err != nil {
+			return nil, false, err
+		}
+		if _, err := deleteVersions(ctx, tx, `file_id = ?`, fileID.String()); err != nil {
+			return nil, false, err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM room_files WHERE room_id = ? AND id = ?`, roomIDString, fileID.String()); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return file, true, nil
+}
+
+func (r *SqliteRepo) DeleteExpiredFiles(ctx context.Context, now time.Time) ([]domain.ExpiredFile, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	nowSec := now.Unix()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT room_id, id, path
+		FROM room_files
+		WHERE expires_at > 0 AND expires_at < ?
+	`, nowSec)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.ExpiredFile, 0)
+	for rows.Next() {
+		var roomIDStr, fileIDStr, path string
+		var f domain.ExpiredFile
+		if err := rows.Scan(&roomIDStr, &fileIDStr, &path); err != nil {
+			return nil, err
+		}
+		if path != "" {
+			f.Paths = append(f.Paths, path)
+		}
+		if f.RoomID, err = uuid.Parse(roomIDStr); err != nil {
+			return nil, err
+		}
+		if f.FileID, err = uuid.Parse(fileIDStr); err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	_ = rows.Close()
+
+	if len(out) == 0 {
+		return out, nil
+	}
+
+	versions, err := deleteVersions(ctx, tx, `file_id IN (SELECT id FROM room_files WHERE expires_at > 0 AND expires_at < ?)`, nowSec)
+	if err != nil {
+		return nil, err
+	}
+	byFile := make(map[string][]string, len(versions))
+	for _, v := range versions {
+		if v.path != "" {
+			byFile[v.fileID] = append(byFile[v.fileID], v.path)
+		}
+	}
+	for i := range out {
+		out[i].Paths = append(out[i].Paths, byFile[out[i].FileID.String()]...)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM room_files WHERE expires_at > 0 AND expires_at < ?`, nowSec); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *SqliteRepo) AddMessageByToken(ctx context.Context, roomID uuid.UUID, token string, msg *domain.RoomMessage, limits domain.RoomLimits) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if msg == nil {
+		return false, domain.ErrInvalidMessage
+	}
+
+	roomIDString := roomID.String()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO room_messages (id, room_id, author, text, created_at)
+		SELECT ?, ?, ?, ?, ?
+		WHERE EXISTS (SELECT 1 FROM room_tokens WHERE room_id = ? AND token = ?)
+	`, msg.ID.String(), roomIDString, msg.Author, msg.Text, msg.CreatedAt.Unix(), roomIDString, token)
+	if err != nil {
+		return false, err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if aff == 0 {
+		return false, nil
+	}
+	if err := checkMessageLimits(ctx, tx, roomIDString, limits); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *SqliteRepo) AddFolderByToken(ctx context.Context, roomID uuid.UUID, token, folder string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	roomIDString := roomID.String()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	ok, err := hasRoomToken(ctx, tx, roomIDString, token)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	exists, err := hasFolder(ctx, tx, roomIDString, folder)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return false, domain.ErrFolderExists
+	}
+
+	if err := insertFolders(ctx, tx, roomIDString, folder); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *SqliteRepo) RenameFolderByToken(ctx context.Context, roomID uuid.UUID, token, from, to string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if from == "" || to == "" || domain.InFolder(to, from) {
+		return false, domain.ErrInvalidFolder
+	}
+
+	roomIDString := roomID.String()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	ok, err := hasRoomToken(ctx, tx, roomIDString, token)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	exists, err := hasFolder(ctx, tx, roomIDString, from)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, domain.ErrFolderNotFound
+	}
+	if exists, err = hasFolder(ctx, tx, roomIDString, to); err != nil {
+		return false, err
+	}
+	if exists {
+		return false, domain.ErrFolderExists
+	}
+
+	// from and to are matched and spliced by character count, the unit
+	// substr and length work in for TEXT.
+	_, err = tx.ExecContext(ctx, `
+		UPDATE room_folders
+		SET path = ? || substr(path, length(?) + 1)
+		WHERE room_id = ? AND (path = ? OR substr(path, 1, length(?) + 1) = ? || '/')
+	`, to, from, roomIDString, from, from, from)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE room_files
+		SET folder = ? || substr(folder, length(?) + 1)
+		WHERE room_id = ? AND (folder = ? OR substr(folder, 1, length(?) + 1) = ? || '/')
+	`, to, from, roomIDString, from, from, from)
+	if err != nil {
+		return false, err
+	}
+
+	if err := insertFolders(ctx, tx, roomIDString, to); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *SqliteRepo) DeleteFolderByToken(ctx context.Context, roomID uuid.UUID, token, folder string) ([]string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	if folder == "" {
+		return nil, false, domain.ErrInvalidFolder
+	}
+
+	roomIDString := roomID.String()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	ok, err := hasRoomToken(ctx, tx, roomIDString, token)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	exists, err := hasFolder(ctx, tx, roomIDString, folder)
+	if err != nil {
+		return nil, false, err
+	}
+	if !exists {
+		return nil, false, domain.ErrFolderNotFound
+	}
+
+	versions, err := deleteVersions(ctx, tx, `file_id IN (
+		SELECT id FROM room_files WHERE room_id = ? AND (folder = ? OR substr(folder, 1, length(?) + 1) = ? || '/')
+	)`, roomIDString, folder, folder, folder)
+	if err != nil {
+		return nil, false, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		DELETE FROM room_files
+		WHERE room_id = ? AND (folder = ? OR substr(folder, 1, length(?) + 1) = ? || '/')
+		RETURNING path
+	`, roomIDString, folder, folder, folder)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	paths := versionPaths(versions)
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, false, err
+		}
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM room_folders
+		WHERE room_id = ? AND (path = ? OR substr(path, 1, length(?) + 1) = ? || '/')
+	`, roomIDString, folder, folder, folder)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return paths, true, nil
+}
+
+func (r *SqliteRepo) DeleteFile(ctx context.Context, roomID, fileID uuid.UUID) ([]string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	versions, err := deleteVersions(ctx, tx, `room_id = ? AND file_id = ?`, roomID.String(), fileID.String())
+	if err != nil {
+		return nil, false, err
+	}
+
+	var path string
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM room_files
+		WHERE room_id = ? AND id = ?
+		RETURNING path
+	`, roomID.String(), fileID.String()).Scan(&path)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return append([]string{path}, versionPaths(versions)...), true, nil
+}
+
+func (r *SqliteRepo) RemoveTokens(ctx context.Context, roomID uuid.UUID) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var exists int
+	err = tx.QueryRowContext(ctx, `SELECT 1 FROM rooms WHERE id = ? LIMIT 1`, roomID.String()).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return 0, ports.ErrRoomNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM room_tokens WHERE room_id = ?`, roomID.String())
+	if err != nil {
+		return 0, err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
 	}
 
 	if err := tx.Commit(); err != nil {
-		return "", false, err
+		return 0, err
 	}
-	return path, true, nil
+	return int(aff), nil
 }
 
 func (r *SqliteRepo) WipeAll(ctx context.Context) error {
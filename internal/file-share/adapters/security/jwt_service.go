@@ -64,7 +64,26 @@ func (s *JwtService) ValidateWithRoom(ctx context.Context, roomID uuid.UUID, tok
 	return nil
 }
 
-func (s *JwtService) parseClaims(ctx context.Context, tokenString string) (*Claims, error) {
+func (s *JwtService) Inspect(ctx context.Context, tokenString string) (ports.TokenInfo, error) {
+	claims, err := s.parseClaims(ctx, tokenString, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return ports.TokenInfo{}, err
+	}
+
+	info := ports.TokenInfo{}
+	if id, err := uuid.Parse(claims.RoomID); err == nil {
+		info.RoomID = id
+	}
+	if claims.IssuedAt != nil {
+		info.IssuedAt = claims.IssuedAt.Time
+	}
+	if claims.ExpiresAt != nil {
+		info.ExpiresAt = claims.ExpiresAt.Time
+	}
+	return info, nil
+}
+
+func (s *JwtService) parseClaims(ctx context.Context, tokenString string, opts ...jwt.ParserOption) (*Claims, error) {
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
@@ -75,7 +94,7 @@ func (s *JwtService) parseClaims(ctx context.Context, tokenString string) (*Clai
 			return nil, ports.ErrTokenSignAlgo
 		}
 		return s.secret, nil
-	})
+	}, opts...)
 
 	if err != nil {
 		switch {
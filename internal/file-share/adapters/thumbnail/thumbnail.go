@@ -0,0 +1,110 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const jpegQuality = 80
+
+var supported = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Generator renders thumbnails with the pure Go image decoders. At most
+// workers thumbnails are rendered at once; further calls wait for a slot.
+// Transparent areas are flattened onto white since the output is JPEG.
+type Generator struct {
+	slots     chan struct{}
+	maxPixels int
+}
+
+func New(workers int, maxPixels int) *Generator {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Generator{
+		slots:     make(chan struct{}, workers),
+		maxPixels: maxPixels,
+	}
+}
+
+func (g *Generator) Supports(contentType string) bool {
+	return supported[contentType]
+}
+
+func (g *Generator) Inspect(ctx context.Context, r io.Reader) (ports.ImageInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return ports.ImageInfo{}, err
+	}
+
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return ports.ImageInfo{}, err
+	}
+	return ports.ImageInfo{Width: cfg.Width, Height: cfg.Height}, nil
+}
+
+func (g *Generator) Thumbnail(ctx context.Context, r io.Reader, size int) ([]byte, error) {
+	select {
+	case g.slots <- struct{}{}:
+		defer func() { <-g.slots }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	// Check the dimensions before decoding so a small file claiming a huge
+	// canvas cannot exhaust memory. The header bytes are replayed for Decode.
+	var header bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+	if err != nil {
+		return nil, err
+	}
+	if g.maxPixels > 0 && cfg.Width*cfg.Height > g.maxPixels {
+		return nil, ports.ErrImageTooLarge
+	}
+
+	src, _, err := image.Decode(io.MultiReader(&header, r))
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	w, h := fit(bounds.Dx(), bounds.Dy(), size)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, dst, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// fit scales w x h down so the longest side is at most size, never up.
+func fit(w, h, size int) (int, int) {
+	if w <= size && h <= size {
+		return max(w, 1), max(h, 1)
+	}
+	if w >= h {
+		return size, max(h*size/w, 1)
+	}
+	return max(w*size/h, 1), size
+}
@@ -0,0 +1,30 @@
+package traced
+
+import (
+	"context"
+	"io"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/tracing"
+)
+
+type ContentScanner struct {
+	inner ports.ContentScanner
+}
+
+func NewContentScanner(inner ports.ContentScanner) *ContentScanner {
+	return &ContentScanner{inner: inner}
+}
+
+func (cs *ContentScanner) Name() string {
+	return cs.inner.Name()
+}
+
+func (cs *ContentScanner) Scan(ctx context.Context, r io.Reader) (res ports.ScanResult, err error) {
+	ctx, span := tracing.Start(ctx, "ContentScanner.Scan", tracing.String("scanner", cs.inner.Name()))
+	defer func() { span.Finish(err) }()
+
+	res, err = cs.inner.Scan(ctx, r)
+	span.SetAttributes(tracing.Bool("scan.infected", res.Infected))
+	return res, err
+}
@@ -0,0 +1,113 @@
+package traced
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/tracing"
+)
+
+// FileStore opens a span around every store call. The Open span stays open
+// until the returned reader is closed, so it covers the whole download read.
+type FileStore struct {
+	inner ports.FileStore
+}
+
+func NewFileStore(inner ports.FileStore) *FileStore {
+	return &FileStore{inner: inner}
+}
+
+func (fs *FileStore) ClearAll(ctx context.Context, uploadDir string) (err error) {
+	ctx, span := tracing.Start(ctx, "FileStore.ClearAll")
+	defer func() { span.Finish(err) }()
+	return fs.inner.ClearAll(ctx, uploadDir)
+}
+
+func (fs *FileStore) Save(ctx context.Context, uploadDir, name string, r io.Reader) (saved ports.SavedFile, err error) {
+	ctx, span := tracing.Start(ctx, "FileStore.Save", tracing.String("file.name", name))
+	defer func() { span.Finish(err) }()
+
+	saved, err = fs.inner.Save(ctx, uploadDir, name, r)
+	span.SetAttributes(tracing.Int64("file.bytes_written", saved.Size))
+	return saved, err
+}
+
+func (fs *FileStore) Open(ctx context.Context, path string) (io.ReadCloser, error) {
+	ctx, span := tracing.Start(ctx, "FileStore.Open", tracing.String("file.path", path))
+
+	rc, err := fs.inner.Open(ctx, path)
+	if err != nil {
+		span.Finish(err)
+		return nil, err
+	}
+	return &tracedReadCloser{ReadCloser: rc, span: span}, nil
+}
+
+func (fs *FileStore) Exists(ctx context.Context, path string) (ok bool, err error) {
+	ctx, span := tracing.Start(ctx, "FileStore.Exists", tracing.String("file.path", path))
+	defer func() { span.Finish(err) }()
+	return fs.inner.Exists(ctx, path)
+}
+
+func (fs *FileStore) Delete(ctx context.Context, path string) (err error) {
+	ctx, span := tracing.Start(ctx, "FileStore.Delete", tracing.String("file.path", path))
+	defer func() { span.Finish(err) }()
+	return fs.inner.Delete(ctx, path)
+}
+
+func (fs *FileStore) Move(ctx context.Context, path, dir string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "FileStore.Move", tracing.String("file.path", path))
+	defer func() { span.Finish(err) }()
+	return fs.inner.Move(ctx, path, dir)
+}
+
+func (fs *FileStore) Copy(ctx context.Context, path, dir, name string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "FileStore.Copy", tracing.String("file.path", path))
+	defer func() { span.Finish(err) }()
+	return fs.inner.Copy(ctx, path, dir, name)
+}
+
+func (fs *FileStore) List(ctx context.Context, uploadDir string) (files []ports.StoredFile, err error) {
+	ctx, span := tracing.Start(ctx, "FileStore.List")
+	defer func() { span.Finish(err) }()
+
+	files, err = fs.inner.List(ctx, uploadDir)
+	span.SetAttributes(tracing.Int("files.count", len(files)))
+	return files, err
+}
+
+type tracedReadCloser struct {
+	io.ReadCloser
+	span *tracing.Span
+	read int64
+	once sync.Once
+}
+
+func (t *tracedReadCloser) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	t.read += int64(n)
+	return n, err
+}
+
+// ReadAt is forwarded so archives can be read in place.
+func (t *tracedReadCloser) ReadAt(p []byte, off int64) (int, error) {
+	ra, ok := t.ReadCloser.(io.ReaderAt)
+	if !ok {
+		return 0, errors.ErrUnsupported
+	}
+	n, err := ra.ReadAt(p, off)
+	t.read += int64(n)
+	return n, err
+}
+
+func (t *tracedReadCloser) Close() error {
+	err := t.ReadCloser.Close()
+	t.once.Do(func() {
+		t.span.SetAttributes(tracing.Int64("file.bytes_read", t.read))
+		t.span.Finish(err)
+	})
+	return err
+}
@@ -0,0 +1,31 @@
+package traced
+
+import (
+	"context"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/tracing"
+)
+
+type PasswordHasher struct {
+	inner ports.PasswordHasher
+}
+
+func NewPasswordHasher(inner ports.PasswordHasher) *PasswordHasher {
+	return &PasswordHasher{inner: inner}
+}
+
+func (ph *PasswordHasher) Hash(ctx context.Context, plain string) (hash string, err error) {
+	ctx, span := tracing.Start(ctx, "PasswordHasher.Hash")
+	defer func() { span.Finish(err) }()
+	return ph.inner.Hash(ctx, plain)
+}
+
+func (ph *PasswordHasher) Verify(ctx context.Context, plain, hash string) (ok bool, err error) {
+	ctx, span := tracing.Start(ctx, "PasswordHasher.Verify")
+	defer func() { span.Finish(err) }()
+
+	ok, err = ph.inner.Verify(ctx, plain, hash)
+	span.SetAttributes(tracing.Bool("password.match", ok))
+	return ok, err
+}
@@ -0,0 +1,190 @@
+package traced
+
+import (
+	"context"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/tracing"
+	"github.com/google/uuid"
+)
+
+// RoomRepository opens a span around every repository call.
+type RoomRepository struct {
+	inner ports.RoomRepository
+}
+
+func NewRoomRepository(inner ports.RoomRepository) *RoomRepository {
+	return &RoomRepository{inner: inner}
+}
+
+func (r *RoomRepository) Get(ctx context.Context, roomID uuid.UUID) (room *domain.Room, ok bool, err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.Get", roomAttr(roomID))
+	defer func() { span.Finish(err) }()
+
+	room, ok, err = r.inner.Get(ctx, roomID)
+	span.SetAttributes(tracing.Bool("room.found", ok))
+	if ok && room != nil {
+		span.SetAttributes(tracing.Int("room.files", len(room.Files)))
+	}
+	return room, ok, err
+}
+
+func (r *RoomRepository) List(ctx context.Context) (rooms []*domain.Room, err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.List")
+	defer func() { span.Finish(err) }()
+
+	rooms, err = r.inner.List(ctx)
+	span.SetAttributes(tracing.Int("rooms.count", len(rooms)))
+	return rooms, err
+}
+
+func (r *RoomRepository) Stats(ctx context.Context) (stats ports.RoomStats, err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.Stats")
+	defer func() { span.Finish(err) }()
+	return r.inner.Stats(ctx)
+}
+
+func (r *RoomRepository) QueryRooms(ctx context.Context, query ports.RoomQuery) (page ports.RoomPage, err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.QueryRooms", tracing.String("query.sort", query.Sort))
+	defer func() { span.Finish(err) }()
+
+	page, err = r.inner.QueryRooms(ctx, query)
+	span.SetAttributes(tracing.Int("rooms.count", len(page.Rooms)))
+	return page, err
+}
+
+func (r *RoomRepository) Create(ctx context.Context, room *domain.Room) (err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.Create", roomAttr(room.ID))
+	defer func() { span.Finish(err) }()
+	return r.inner.Create(ctx, room)
+}
+
+func (r *RoomRepository) Delete(ctx context.Context, roomID uuid.UUID) (paths []string, err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.Delete", roomAttr(roomID))
+	defer func() { span.Finish(err) }()
+
+	paths, err = r.inner.Delete(ctx, roomID)
+	span.SetAttributes(tracing.Int("room.files", len(paths)))
+	return paths, err
+}
+
+func (r *RoomRepository) DeleteExpired(ctx context.Context, now time.Time) (cleanups []domain.ExpiredCleanup, err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.DeleteExpired")
+	defer func() { span.Finish(err) }()
+
+	cleanups, err = r.inner.DeleteExpired(ctx, now)
+	span.SetAttributes(tracing.Int("rooms.deleted", len(cleanups)))
+	return cleanups, err
+}
+
+func (r *RoomRepository) RemoveToken(ctx context.Context, roomID uuid.UUID, token string) (ok bool, err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.RemoveToken", roomAttr(roomID))
+	defer func() { span.Finish(err) }()
+	return r.inner.RemoveToken(ctx, roomID, token)
+}
+
+func (r *RoomRepository) AddToken(ctx context.Context, roomID uuid.UUID, token string) (err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.AddToken", roomAttr(roomID))
+	defer func() { span.Finish(err) }()
+	return r.inner.AddToken(ctx, roomID, token)
+}
+
+func (r *RoomRepository) QueryFilesByToken(ctx context.Context, roomID uuid.UUID, token string, query ports.FileQuery) (page ports.FilePage, ok bool, err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.QueryFilesByToken", roomAttr(roomID), tracing.String("query.sort", query.Sort))
+	defer func() { span.Finish(err) }()
+
+	page, ok, err = r.inner.QueryFilesByToken(ctx, roomID, token, query)
+	span.SetAttributes(tracing.Int("files.count", len(page.Files)))
+	return page, ok, err
+}
+
+func (r *RoomRepository) AddFileByToken(ctx context.Context, roomID uuid.UUID, token string, file *domain.RoomFile, limits domain.RoomLimits) (ok bool, err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.AddFileByToken", roomAttr(roomID), fileAttr(file.ID), tracing.Int64("file.size", file.Size))
+	defer func() { span.Finish(err) }()
+	return r.inner.AddFileByToken(ctx, roomID, token, file, limits)
+}
+
+func (r *RoomRepository) AddFileVersionByToken(ctx context.Context, roomID, fileID uuid.UUID, token string, next *domain.RoomFile, limits domain.RoomLimits) (file *domain.RoomFile, ok bool, err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.AddFileVersionByToken", roomAttr(roomID), fileAttr(fileID))
+	defer func() { span.Finish(err) }()
+	return r.inner.AddFileVersionByToken(ctx, roomID, fileID, token, next, limits)
+}
+
+func (r *RoomRepository) DeleteFileByToken(ctx context.Context, roomID, fileID uuid.UUID, token string) (paths []string, ok bool, err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.DeleteFileByToken", roomAttr(roomID), fileAttr(fileID))
+	defer func() { span.Finish(err) }()
+	return r.inner.DeleteFileByToken(ctx, roomID, fileID, token)
+}
+
+func (r *RoomRepository) CountDownloadByToken(ctx context.Context, roomID, fileID uuid.UUID, token string, now time.Time) (file *domain.RoomFile, ok bool, err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.CountDownloadByToken", roomAttr(roomID), fileAttr(fileID))
+	defer func() { span.Finish(err) }()
+	return r.inner.CountDownloadByToken(ctx, roomID, fileID, token, now)
+}
+
+func (r *RoomRepository) DeleteExpiredFiles(ctx context.Context, now time.Time) (files []domain.ExpiredFile, err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.DeleteExpiredFiles")
+	defer func() { span.Finish(err) }()
+
+	files, err = r.inner.DeleteExpiredFiles(ctx, now)
+	span.SetAttributes(tracing.Int("files.deleted", len(files)))
+	return files, err
+}
+
+func (r *RoomRepository) SetFileDescriptionByToken(ctx context.Context, roomID, fileID uuid.UUID, token, description string, limits domain.RoomLimits) (file *domain.RoomFile, ok bool, err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.SetFileDescriptionByToken", roomAttr(roomID), fileAttr(fileID))
+	defer func() { span.Finish(err) }()
+	return r.inner.SetFileDescriptionByToken(ctx, roomID, fileID, token, description, limits)
+}
+
+func (r *RoomRepository) AddMessageByToken(ctx context.Context, roomID uuid.UUID, token string, msg *domain.RoomMessage, limits domain.RoomLimits) (ok bool, err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.AddMessageByToken", roomAttr(roomID))
+	defer func() { span.Finish(err) }()
+	return r.inner.AddMessageByToken(ctx, roomID, token, msg, limits)
+}
+
+func (r *RoomRepository) AddFolderByToken(ctx context.Context, roomID uuid.UUID, token, folder string) (ok bool, err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.AddFolderByToken", roomAttr(roomID))
+	defer func() { span.Finish(err) }()
+	return r.inner.AddFolderByToken(ctx, roomID, token, folder)
+}
+
+func (r *RoomRepository) RenameFolderByToken(ctx context.Context, roomID uuid.UUID, token, from, to string) (ok bool, err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.RenameFolderByToken", roomAttr(roomID))
+	defer func() { span.Finish(err) }()
+	return r.inner.RenameFolderByToken(ctx, roomID, token, from, to)
+}
+
+func (r *RoomRepository) DeleteFolderByToken(ctx context.Context, roomID uuid.UUID, token, folder string) (paths []string, ok bool, err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.DeleteFolderByToken", roomAttr(roomID))
+	defer func() { span.Finish(err) }()
+
+	paths, ok, err = r.inner.DeleteFolderByToken(ctx, roomID, token, folder)
+	span.SetAttributes(tracing.Int("room.files", len(paths)))
+	return paths, ok, err
+}
+
+func (r *RoomRepository) DeleteFile(ctx context.Context, roomID, fileID uuid.UUID) (paths []string, ok bool, err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.DeleteFile", roomAttr(roomID), fileAttr(fileID))
+	defer func() { span.Finish(err) }()
+	return r.inner.DeleteFile(ctx, roomID, fileID)
+}
+
+func (r *RoomRepository) RemoveTokens(ctx context.Context, roomID uuid.UUID) (n int, err error) {
+	ctx, span := tracing.Start(ctx, "RoomRepository.RemoveTokens", roomAttr(roomID))
+	defer func() { span.Finish(err) }()
+
+	n, err = r.inner.RemoveTokens(ctx, roomID)
+	span.SetAttributes(tracing.Int("tokens.removed", n))
+	return n, err
+}
+
+func roomAttr(id uuid.UUID) tracing.Attr {
+	return tracing.String("room.id", id.String())
+}
+
+func fileAttr(id uuid.UUID) tracing.Attr {
+	return tracing.String("file.id", id.String())
+}
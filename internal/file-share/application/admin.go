@@ -0,0 +1,204 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/tracing"
+	"github.com/google/uuid"
+)
+
+// The Admin* methods serve operators. They skip the per-room token checks,
+// so callers must authenticate the operator first. Every change is logged as
+// an admin action and audited.
+
+type AdminToken struct {
+	Fingerprint string
+	IssuedAt    time.Time
+	ExpiresAt   time.Time
+	// Valid is false when the token no longer verifies, e.g. after a restart
+	// generated a new signing key.
+	Valid bool
+}
+
+type AdminRoom struct {
+	Room   *domain.Room
+	Bytes  int64
+	Tokens []AdminToken
+}
+
+type StorageStats struct {
+	Rooms  int
+	Tokens int
+	Files  int
+	// Versions counts the older versions kept besides the current files.
+	Versions int
+	Bytes    int64
+	// DiskFiles and DiskBytes count what is in the upload directory, which
+	// includes orphans not referenced by any room.
+	DiskFiles int
+	DiskBytes int64
+}
+
+func (s *Service) AdminRooms(ctx context.Context) (_ []AdminRoom, err error) {
+	ctx, span := tracing.Start(ctx, "Service.AdminRooms")
+	defer func() { span.Finish(err) }()
+
+	rooms, err := s.rooms.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]AdminRoom, 0, len(rooms))
+	for _, room := range rooms {
+		out = append(out, s.adminRoom(ctx, room))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Room.ExpiresAt.Before(out[j].Room.ExpiresAt) })
+	return out, nil
+}
+
+func (s *Service) AdminRoom(ctx context.Context, id uuid.UUID) (_ AdminRoom, err error) {
+	ctx, span := tracing.Start(ctx, "Service.AdminRoom", roomAttr(id))
+	defer func() { span.Finish(err) }()
+
+	room, ok, err := s.rooms.Get(ctx, id)
+	if err != nil {
+		return AdminRoom{}, err
+	}
+	if !ok || room == nil {
+		return AdminRoom{}, domain.ErrRoomNotFound
+	}
+	return s.adminRoom(ctx, room), nil
+}
+
+func (s *Service) AdminDeleteRoom(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := tracing.Start(ctx, "Service.AdminDeleteRoom", roomAttr(id))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditAdminRoom, RoomID: id}
+	defer func() { s.audit(ctx, &rec, err) }()
+
+	if _, ok, err := s.rooms.Get(ctx, id); err != nil {
+		return err
+	} else if !ok {
+		return domain.ErrRoomNotFound
+	}
+
+	paths, err := s.rooms.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	rec.Detail = fmt.Sprintf("deleted room with %d files", len(paths))
+	s.adminAction(ctx, "room.delete", slog.String("room_id", id.String()), slog.Int("files", len(paths)))
+
+	var joined error
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if err := s.removeFile(ctx, path); err != nil {
+			joined = errors.Join(joined, err)
+		}
+	}
+	return joined
+}
+
+func (s *Service) AdminDeleteFile(ctx context.Context, roomId, fileId uuid.UUID) (err error) {
+	ctx, span := tracing.Start(ctx, "Service.AdminDeleteFile", roomAttr(roomId), fileAttr(fileId))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditAdminFile, RoomID: roomId, FileID: fileId}
+	defer func() { s.audit(ctx, &rec, err) }()
+
+	paths, ok, err := s.rooms.DeleteFile(ctx, roomId, fileId)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return domain.ErrFileNotFound
+	}
+	s.adminAction(ctx, "file.delete", slog.String("room_id", roomId.String()), slog.String("file_id", fileId.String()))
+
+	return s.removeFiles(ctx, paths)
+}
+
+// AdminRevokeTokens logs everyone out of a room. The password still works.
+func (s *Service) AdminRevokeTokens(ctx context.Context, id uuid.UUID) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "Service.AdminRevokeTokens", roomAttr(id))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditAdminTokens, RoomID: id}
+	defer func() { s.audit(ctx, &rec, err) }()
+
+	n, err := s.rooms.RemoveTokens(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	rec.Detail = fmt.Sprintf("revoked %d tokens", n)
+	s.adminAction(ctx, "tokens.revoke", slog.String("room_id", id.String()), slog.Int("tokens", n))
+	return n, nil
+}
+
+// AdminAudit returns audit records matching query, newest first.
+func (s *Service) AdminAudit(ctx context.Context, query ports.AuditQuery) (_ []ports.AuditRecord, err error) {
+	ctx, span := tracing.Start(ctx, "Service.AdminAudit")
+	defer func() { span.Finish(err) }()
+
+	return s.auditSink.Query(ctx, query)
+}
+
+func (s *Service) AdminStats(ctx context.Context) (_ StorageStats, err error) {
+	ctx, span := tracing.Start(ctx, "Service.AdminStats")
+	defer func() { span.Finish(err) }()
+
+	rooms, err := s.rooms.List(ctx)
+	if err != nil {
+		return StorageStats{}, err
+	}
+
+	stats := StorageStats{Rooms: len(rooms)}
+	for _, room := range rooms {
+		stats.Tokens += room.TokensCount()
+		for _, f := range room.Files {
+			stats.Files++
+			stats.Versions += len(f.Versions)
+			stats.Bytes += f.StoredSize()
+		}
+	}
+
+	stored, err := s.files.List(ctx, s.Policy().UploadDir)
+	if err != nil {
+		return StorageStats{}, err
+	}
+	for _, f := range stored {
+		stats.DiskFiles++
+		stats.DiskBytes += f.Size
+	}
+	return stats, nil
+}
+
+func (s *Service) adminRoom(ctx context.Context, room *domain.Room) AdminRoom {
+	out := AdminRoom{Room: room, Bytes: room.TotalSize()}
+
+	for _, token := range room.ListTokens() {
+		t := AdminToken{Fingerprint: domain.TokenFingerprint(token)}
+		if info, err := s.tokenIssuer.Inspect(ctx, token); err == nil {
+			t.IssuedAt = info.IssuedAt
+			t.ExpiresAt = info.ExpiresAt
+			t.Valid = true
+		}
+		out.Tokens = append(out.Tokens, t)
+	}
+	sort.Slice(out.Tokens, func(i, j int) bool { return out.Tokens[i].IssuedAt.Before(out.Tokens[j].IssuedAt) })
+	return out
+}
+
+func (s *Service) adminAction(ctx context.Context, action string, attrs ...any) {
+	slog.InfoContext(ctx, "admin action", append([]any{slog.String("action", action)}, attrs...)...)
+}
@@ -0,0 +1,293 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"strings"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/tracing"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxArchiveEntries caps the entries read from one archive, whether
+	// browsed or extracted.
+	maxArchiveEntries = 10000
+	// Entries inflating more than this many times over, past bombRatioFloor
+	// bytes, are treated as zip bombs.
+	maxCompressionRatio = 100
+	bombRatioFloor      = 1 << 20
+)
+
+var errStopWalk = errors.New("stop archive walk")
+
+// UseArchives enables browsing and extracting archives and downloading
+// folders as one.
+func (s *Service) UseArchives(archives ports.Archiver) {
+	s.archives = archives
+}
+
+func (s *Service) ArchiveEntries(ctx context.Context, roomId, fileId uuid.UUID, token string) (_ *domain.RoomFile, _ []ports.ArchiveEntry, err error) {
+	ctx, span := tracing.Start(ctx, "Service.ArchiveEntries", roomAttr(roomId), fileAttr(fileId))
+	defer func() { span.Finish(err) }()
+
+	file, err := s.contentFile(ctx, roomId, fileId, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var entries []ports.ArchiveEntry
+	err = s.walkArchive(ctx, file, func(e ports.ArchiveEntry, _ func() (io.Reader, error)) error {
+		if len(entries) == maxArchiveEntries {
+			return ports.ErrArchiveTooLarge
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	span.SetAttributes(tracing.Int("archive.entries", len(entries)))
+	return file, entries, nil
+}
+
+// OpenArchiveEntry streams a single regular file out of an archive.
+func (s *Service) OpenArchiveEntry(ctx context.Context, roomId, fileId uuid.UUID, token, name string) (_ ports.ArchiveEntry, _ io.ReadCloser, err error) {
+	ctx, span := tracing.Start(ctx, "Service.OpenArchiveEntry", roomAttr(roomId), fileAttr(fileId))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditFileDownload, RoomID: roomId, FileID: fileId, TokenFingerprint: domain.TokenFingerprint(token), Detail: name}
+	defer func() { s.audit(ctx, &rec, err) }()
+
+	file, err := s.contentFile(ctx, roomId, fileId, token)
+	if err != nil {
+		return ports.ArchiveEntry{}, nil, err
+	}
+
+	// The entry is copied out while the archive is walked, so the walk runs
+	// alongside the caller reading the pipe.
+	pr, pw := io.Pipe()
+	found := make(chan ports.ArchiveEntry, 1)
+	go func() {
+		err := s.walkArchive(context.WithoutCancel(ctx), file, func(e ports.ArchiveEntry, open func() (io.Reader, error)) error {
+			if !e.Regular || strings.TrimPrefix(e.Name, "./") != name {
+				return nil
+			}
+			found <- e
+			r, err := open()
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(pw, io.LimitReader(r, e.Size)); err != nil {
+				return err
+			}
+			return errStopWalk
+		})
+		if errors.Is(err, errStopWalk) {
+			err = nil
+		}
+		close(found)
+		_ = pw.CloseWithError(err)
+	}()
+
+	entry, ok := <-found
+	if !ok {
+		_, err := io.Copy(io.Discard, pr)
+		if err == nil {
+			err = ports.ErrArchiveEntryNotFound
+		}
+		return ports.ArchiveEntry{}, nil, err
+	}
+	return entry, pr, nil
+}
+
+// ExtractArchive adds every regular file of an archive to the room as a new
+// file, keeping the archive's directories as folders below the archive's own
+// folder. It fails as a whole: files already extracted are removed again.
+// Entries never become versions of existing files, even in versioning rooms,
+// so that rollback cannot touch what was there before.
+func (s *Service) ExtractArchive(ctx context.Context, roomId, fileId uuid.UUID, token string) (_ []*domain.RoomFile, err error) {
+	ctx, span := tracing.Start(ctx, "Service.ExtractArchive", roomAttr(roomId), fileAttr(fileId))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditFileExtract, RoomID: roomId, FileID: fileId, TokenFingerprint: domain.TokenFingerprint(token)}
+	defer func() { s.audit(ctx, &rec, err) }()
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, domain.ErrEmptyToken
+	}
+
+	room, ok, err := s.rooms.Get(ctx, roomId)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || room == nil || !room.HasToken(token) {
+		return nil, domain.ErrRoomNotFound
+	}
+	file, ok := room.GetFile(fileId)
+	if !ok || file == nil || file.IsExpired(s.now()) {
+		return nil, domain.ErrFileNotFound
+	}
+	if file.Limited() {
+		return nil, domain.ErrFileDownloadLimited
+	}
+
+	quota := newRoomQuota(s.Policy(), room)
+	target := room.Clone()
+	target.Versioning = false
+	var (
+		extracted []*domain.RoomFile
+		dirs      []string
+		entries   int
+	)
+	err = s.walkArchive(ctx, file, func(e ports.ArchiveEntry, open func() (io.Reader, error)) error {
+		if entries == maxArchiveEntries {
+			return ports.ErrArchiveTooLarge
+		}
+		entries++
+		if !e.Dir && !e.Regular {
+			return nil
+		}
+		folder, name, ok := entryName(file.Folder, e.Name, e.Dir)
+		if !ok {
+			return fmt.Errorf("%w: %q", ports.ErrArchiveUnsafe, e.Name)
+		}
+		if e.Dir {
+			dirs = append(dirs, folder)
+			return nil
+		}
+		if e.PackedSize > 0 && e.Size > bombRatioFloor && e.Size/e.PackedSize > maxCompressionRatio {
+			return fmt.Errorf("%w: %q inflates %dx", ports.ErrArchiveTooLarge, e.Name, e.Size/e.PackedSize)
+		}
+		if quota.bytes >= 0 && e.Size > quota.bytes {
+			return domain.ErrRoomQuotaExceeded
+		}
+
+		r, err := open()
+		if err != nil {
+			return err
+		}
+		meta, err := s.storeUpload(ctx, target, token, name, UploadOptions{Folder: folder}, &exactReader{r: r, left: e.Size}, &quota)
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.Name, err)
+		}
+		extracted = append(extracted, meta)
+		return nil
+	})
+	if err != nil {
+		s.removeExtracted(ctx, roomId, extracted)
+		return nil, err
+	}
+
+	// Directories are only created once every file made it in, so a failed
+	// extraction leaves no empty folders behind.
+	for _, dir := range dirs {
+		if room.HasFolder(dir) {
+			continue
+		}
+		if _, err := s.rooms.AddFolderByToken(ctx, roomId, token, dir); err != nil && !errors.Is(err, domain.ErrFolderExists) {
+			s.removeExtracted(ctx, roomId, extracted)
+			return nil, err
+		}
+	}
+
+	rec.Detail = fmt.Sprintf("extracted %d files", len(extracted))
+	span.SetAttributes(tracing.Int("archive.extracted", len(extracted)))
+	slog.InfoContext(ctx, "archive extracted", slog.String("room_id", roomId.String()), slog.String("file_id", fileId.String()), slog.Int("files", len(extracted)))
+	return extracted, nil
+}
+
+func (s *Service) walkArchive(ctx context.Context, file *domain.RoomFile, fn func(ports.ArchiveEntry, func() (io.Reader, error)) error) error {
+	if s.archives == nil {
+		return ports.ErrNotArchive
+	}
+	format := s.archives.Format(file.Name, domain.MediaType(file.ContentType))
+	if format == "" {
+		return ports.ErrNotArchive
+	}
+
+	rc, err := s.files.Open(ctx, file.Path)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	return s.archives.Walk(ctx, format, rc, file.Size, fn)
+}
+
+func (s *Service) removeExtracted(ctx context.Context, roomId uuid.UUID, files []*domain.RoomFile) {
+	for _, f := range files {
+		paths, ok, err := s.rooms.DeleteFile(ctx, roomId, f.ID)
+		if err == nil && ok {
+			err = s.removeFiles(ctx, paths)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "cannot roll back extracted file", slog.String("file_id", f.ID.String()), slog.Any("error", err))
+		}
+	}
+}
+
+// entryName places an archive path below folder, splitting it into the
+// file's folder and name; for directories name is empty. It refuses absolute
+// paths and anything climbing out of the archive root.
+func entryName(folder, name string, dir bool) (string, string, bool) {
+	if name == "" || strings.ContainsAny(name, "\\\x00") || path.IsAbs(name) {
+		return "", "", false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", "", false
+		}
+	}
+	clean := path.Clean(name)
+	if dir && clean == "." {
+		return folder, "", true
+	}
+	if clean == "." || clean == "/" {
+		return "", "", false
+	}
+
+	dirPart, base := path.Dir(clean), path.Base(clean)
+	if dir {
+		dirPart, base = clean, ""
+	}
+	if dirPart == "." {
+		dirPart = ""
+	}
+	folder, err := domain.NormalizeFolder(domain.JoinFolder(folder, dirPart))
+	if err != nil {
+		return "", "", false
+	}
+	return folder, base, true
+}
+
+// exactReader refuses entries that inflate past the size their header
+// declared, so a lying header cannot slip past the quota checks.
+type exactReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (e *exactReader) Read(p []byte) (int, error) {
+	if e.left <= 0 {
+		var one [1]byte
+		if n, _ := e.r.Read(one[:]); n > 0 {
+			return 0, fmt.Errorf("%w: entry larger than declared", ports.ErrArchiveTooLarge)
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > e.left {
+		p = p[:e.left]
+	}
+	n, err := e.r.Read(p)
+	e.left -= int64(n)
+	return n, err
+}
@@ -0,0 +1,92 @@
+package application
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/adapters/archive"
+	auditsink "github.com/Miklakapi/go-file-share/internal/file-share/adapters/audit-sink"
+	filestore "github.com/Miklakapi/go-file-share/internal/file-share/adapters/file-store"
+	memoryrepository "github.com/Miklakapi/go-file-share/internal/file-share/adapters/room-repository/memory-repository"
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+)
+
+func TestExtractArchiveEntryLimit(t *testing.T) {
+	ctx := context.Background()
+	uploadDir := t.TempDir()
+	repo := memoryrepository.New()
+	s := NewService(repo, filestore.DiskStore{}, nil, nil, auditsink.Nop{}, domain.Policy{UploadDir: uploadDir})
+	s.UseArchives(archive.New(0))
+
+	// A few files the extraction stores, then more entries than allowed.
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i := range 3 {
+		w, err := zw.Create(fmt.Sprintf("file-%d.txt", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte("content"))
+	}
+	for i := range maxArchiveEntries {
+		if _, err := zw.Create(fmt.Sprintf("dir-%05d/", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	room, err := domain.NewRoom("hash", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const token = "token"
+	if err := room.AddToken(token); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Create(ctx, room); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := filestore.DiskStore{}.Save(ctx, uploadDir, "archive", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := domain.NewRoomFile(saved.Path, "many.zip", saved.Size, saved.SHA256, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.ContentType = "application/zip"
+	if _, err := repo.AddFileByToken(ctx, room.ID, token, file, domain.RoomLimits{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ExtractArchive(ctx, room.ID, file.ID, token); !errors.Is(err, ports.ErrArchiveTooLarge) {
+		t.Fatalf("ExtractArchive = %v, want %v", err, ports.ErrArchiveTooLarge)
+	}
+
+	got, _, err := repo.Get(ctx, room.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files := got.ListFiles(); len(files) != 1 || files[0].ID != file.ID {
+		t.Fatalf("room holds %d files after the failed extraction, want only the archive", len(files))
+	}
+	if folders := got.ListFolders(); len(folders) > 0 {
+		t.Fatalf("failed extraction left folders %v", folders)
+	}
+	entries, err := os.ReadDir(uploadDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("upload dir holds %d entries after rollback, want only the archive", len(entries))
+	}
+}
@@ -0,0 +1,53 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/logging"
+)
+
+// audit completes rec with the caller, request and outcome and hands it to
+// the audit sink. A failing sink is logged but never fails the action.
+func (s *Service) audit(ctx context.Context, rec *ports.AuditRecord, err error) {
+	actor := ports.ActorFromContext(ctx)
+	rec.Time = s.now()
+	rec.Actor = actor.Name
+	rec.ActorIP = actor.IP
+	rec.RequestID = logging.RequestID(ctx)
+	rec.Result = auditResult(err)
+
+	// Record even when the request was cancelled half way.
+	if sinkErr := s.auditSink.Record(context.WithoutCancel(ctx), *rec); sinkErr != nil {
+		slog.ErrorContext(ctx, "cannot write audit record", slog.String("action", rec.Action), slog.Any("error", sinkErr))
+	}
+}
+
+func auditResult(err error) string {
+	switch {
+	case err == nil:
+		return ports.AuditResultOK
+	case errors.Is(err, domain.ErrInvalidPassword),
+		errors.Is(err, domain.ErrEmptyPassword),
+		errors.Is(err, domain.ErrEmptyToken),
+		errors.Is(err, domain.ErrTokenNotFound),
+		errors.Is(err, domain.ErrRoomNotFound),
+		errors.Is(err, domain.ErrFileNotFound),
+		errors.Is(err, domain.ErrFolderNotFound),
+		errors.Is(err, ports.ErrRoomNotFound),
+		errors.Is(err, ports.ErrFileInfected),
+		errors.Is(err, domain.ErrFileTypeNotAllowed),
+		errors.Is(err, domain.ErrRoomQuotaExceeded),
+		errors.Is(err, ports.ErrArchiveEntryNotFound),
+		errors.Is(err, ports.ErrArchiveUnsafe),
+		errors.Is(err, ports.ErrArchiveTooLarge),
+		errors.Is(err, ports.ErrBundleSignature),
+		errors.Is(err, ports.ErrInvalidToken),
+		errors.Is(err, ports.ErrTokenExpired):
+		return ports.AuditResultDenied
+	}
+	return ports.AuditResultError
+}
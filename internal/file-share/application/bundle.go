@@ -0,0 +1,252 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/tracing"
+	"github.com/google/uuid"
+)
+
+// UseBundles enables exporting rooms and importing them again, here or on
+// another node holding the same bundle key.
+func (s *Service) UseBundles(bundles ports.RoomBundler) {
+	s.bundles = bundles
+}
+
+// ExportRoom streams the room as a signed bundle. Files with a download
+// limit are left out, as they are only handed out through counted
+// downloads.
+func (s *Service) ExportRoom(ctx context.Context, id uuid.UUID, token string) (_ string, _ io.ReadCloser, err error) {
+	ctx, span := tracing.Start(ctx, "Service.ExportRoom", roomAttr(id))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditRoomExport, RoomID: id, TokenFingerprint: domain.TokenFingerprint(token)}
+	defer func() { s.audit(ctx, &rec, err) }()
+
+	room, err := s.tokenRoom(ctx, id, token)
+	if err != nil {
+		return "", nil, err
+	}
+	name, rc, files := s.exportRoom(ctx, room, false)
+	rec.Detail = fmt.Sprintf("exported %d files", files)
+	return name, rc, nil
+}
+
+// AdminExportRoom exports a room without a token, files with a download
+// limit included. It backs the export command.
+func (s *Service) AdminExportRoom(ctx context.Context, id uuid.UUID) (_ string, _ io.ReadCloser, err error) {
+	ctx, span := tracing.Start(ctx, "Service.AdminExportRoom", roomAttr(id))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditRoomExport, RoomID: id}
+	defer func() { s.audit(ctx, &rec, err) }()
+
+	room, ok, err := s.rooms.Get(ctx, id)
+	if err != nil {
+		return "", nil, err
+	}
+	if !ok || room == nil {
+		return "", nil, domain.ErrRoomNotFound
+	}
+	name, rc, files := s.exportRoom(ctx, room, true)
+	rec.Detail = fmt.Sprintf("exported %d files", files)
+	s.adminAction(ctx, "room.export", slog.String("room_id", id.String()), slog.Int("files", files))
+	return name, rc, nil
+}
+
+func (s *Service) exportRoom(ctx context.Context, room *domain.Room, limited bool) (string, io.ReadCloser, int) {
+	room = room.Clone()
+	now := s.now()
+	for id, f := range room.Files {
+		if f == nil || f.IsExpired(now) || (f.Limited() && !limited) {
+			delete(room.Files, id)
+		}
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	open := func(path string) (io.ReadCloser, error) {
+		return s.files.Open(ctx, path)
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(s.bundles.Export(ctx, pw, room, open))
+	}()
+	return "room-" + room.ID.String() + ".tar", pr, len(room.Files)
+}
+
+// ImportRoom recreates the room a bundle describes under a new ID, password
+// and lifespan, with its files, versions, folders and messages. The bundle's
+// signature and every content digest are checked, and the room must fit the
+// current policy. Content keeps the scan verdict it was exported with; the
+// signature vouches that a node holding the key accepted it.
+func (s *Service) ImportRoom(ctx context.Context, r io.Reader, password string, lifespan time.Duration) (_ *domain.Room, _ string, err error) {
+	ctx, span := tracing.Start(ctx, "Service.ImportRoom")
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditRoomImport}
+	defer func() { s.audit(ctx, &rec, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	if r == nil {
+		return nil, "", ports.ErrNilReader
+	}
+
+	password = strings.TrimSpace(password)
+	if password == "" {
+		return nil, "", domain.ErrEmptyPassword
+	}
+
+	policy := s.Policy()
+	if lifespan <= 0 {
+		lifespan = policy.DefaultRoomTTL
+	}
+	if policy.MaxRoomLifespan > 0 && lifespan > policy.MaxRoomLifespan {
+		return nil, "", domain.ErrRoomLifespanTooLong
+	}
+
+	hashedPassword, err := s.hasher.Hash(ctx, password)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var stored []string
+	discard := func() {
+		for _, path := range stored {
+			s.discardFile(ctx, path)
+		}
+	}
+	store := func(r io.Reader) (string, error) {
+		saved, err := s.files.Save(ctx, policy.UploadDir, uuid.NewString(), r)
+		if err != nil {
+			return "", err
+		}
+		stored = append(stored, saved.Path)
+		return saved.Path, nil
+	}
+	check := func(room *domain.Room) error {
+		return checkImport(policy, room)
+	}
+
+	source, err := s.bundles.Import(ctx, r, check, store)
+	if err != nil {
+		discard()
+		return nil, "", err
+	}
+	rec.Detail = "from room " + source.ID.String()
+
+	room, err := domain.NewRoom(hashedPassword, lifespan)
+	if err != nil {
+		discard()
+		return nil, "", err
+	}
+	room.Content = source.Content
+	room.SanitizeImages = source.SanitizeImages
+	room.Versioning = source.Versioning
+	rec.RoomID = room.ID
+
+	token, _, err := s.tokenIssuer.Issue(ctx, room.ID, lifespan)
+	if err != nil {
+		discard()
+		return nil, "", err
+	}
+	rec.TokenFingerprint = domain.TokenFingerprint(token)
+	if err := room.AddToken(token); err != nil {
+		discard()
+		return nil, "", err
+	}
+
+	if err := s.rooms.Create(ctx, room); err != nil {
+		discard()
+		return nil, "", err
+	}
+	if err := s.fillRoom(ctx, room, token, source); err != nil {
+		if _, delErr := s.rooms.Delete(ctx, room.ID); delErr != nil {
+			slog.WarnContext(ctx, "cannot roll back imported room", slog.String("room_id", room.ID.String()), slog.Any("error", delErr))
+		}
+		discard()
+		return nil, "", err
+	}
+
+	rec.Detail += fmt.Sprintf(" with %d files", len(room.Files))
+	slog.InfoContext(ctx, "room imported", slog.String("room_id", room.ID.String()), slog.String("source_room_id", source.ID.String()),
+		slog.Int("files", len(room.Files)), slog.Int("messages", len(room.Messages)))
+	return room, token, nil
+}
+
+// fillRoom adds the folders, files and messages of source to the stored
+// room, under new IDs, and mirrors them on room.
+func (s *Service) fillRoom(ctx context.Context, room *domain.Room, token string, source *domain.Room) error {
+	for _, folder := range source.ListFolders() {
+		if room.HasFolder(folder) {
+			continue
+		}
+		if _, err := s.rooms.AddFolderByToken(ctx, room.ID, token, folder); err != nil && !errors.Is(err, domain.ErrFolderExists) {
+			return err
+		}
+		room.AddFolder(folder)
+	}
+
+	limits := s.Policy().RoomLimits()
+	for _, f := range source.ListFiles() {
+		f.ID = uuid.New()
+		ok, err := s.rooms.AddFileByToken(ctx, room.ID, token, f, limits)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return domain.ErrRoomNotFound
+		}
+		if err := room.AddFile(f); err != nil {
+			return err
+		}
+	}
+
+	for _, msg := range source.Messages {
+		msg.ID = uuid.New()
+		ok, err := s.rooms.AddMessageByToken(ctx, room.ID, token, &msg, limits)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return domain.ErrRoomNotFound
+		}
+		room.Messages = append(room.Messages, msg)
+	}
+	return nil
+}
+
+// checkImport holds the room a bundle describes to the limits and content
+// rules an upload to it would face.
+func checkImport(policy domain.Policy, room *domain.Room) error {
+	if policy.MaxFiles > 0 && room.StoredFiles() > policy.MaxFiles {
+		return domain.ErrRoomQuotaExceeded
+	}
+	if policy.MaxRoomBytes > 0 && room.TotalSize() > policy.MaxRoomBytes {
+		return domain.ErrRoomQuotaExceeded
+	}
+	if policy.MaxMessages > 0 && len(room.Messages) > policy.MaxMessages {
+		return domain.ErrRoomQuotaExceeded
+	}
+
+	for _, f := range room.ListFiles() {
+		if err := checkName(f.Name, policy.Content, room.Content); err != nil {
+			return err
+		}
+		for _, v := range f.AllVersions() {
+			if err := checkType(v.ContentType, f.Name, policy.Content, room.Content); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/tracing"
+	"github.com/google/uuid"
+)
+
+// CopyOptions tune CopyFile.
+type CopyOptions struct {
+	// Folder places the copy in a virtual folder of the target room, created
+	// when missing.
+	Folder string
+	// Move removes the source file, all its versions included, once the copy
+	// is stored.
+	Move bool
+}
+
+// CopyFile stores the current content of a file again in the target room,
+// which targetToken must grant access to. The target room's content rules,
+// quotas and versioning apply as for an upload. The content is shared with
+// the source where the store allows it, unless the target room strips image
+// metadata the source kept.
+func (s *Service) CopyFile(ctx context.Context, roomId, fileId uuid.UUID, token string, targetId uuid.UUID, targetToken string, opts CopyOptions) (_ *domain.RoomFile, err error) {
+	spanName, action := "Service.CopyFile", ports.AuditFileCopy
+	if opts.Move {
+		spanName, action = "Service.MoveFile", ports.AuditFileMove
+	}
+	ctx, span := tracing.Start(ctx, spanName, roomAttr(roomId), fileAttr(fileId), tracing.String("target.room.id", targetId.String()))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: action, RoomID: roomId, FileID: fileId, TokenFingerprint: domain.TokenFingerprint(token), Detail: "to room " + targetId.String()}
+	defer func() { s.audit(ctx, &rec, err) }()
+
+	if opts.Folder, err = domain.NormalizeFolder(opts.Folder); err != nil {
+		return nil, err
+	}
+	if opts.Move && targetId == roomId {
+		return nil, domain.ErrSameRoom
+	}
+
+	source, err := s.tokenRoom(ctx, roomId, token)
+	if err != nil {
+		return nil, err
+	}
+	file, ok := source.GetFile(fileId)
+	if !ok || file == nil || file.IsExpired(s.now()) {
+		return nil, domain.ErrFileNotFound
+	}
+	// A copy would hand out the content past the download limit; a move
+	// takes the limit along.
+	if file.Limited() && !opts.Move {
+		return nil, domain.ErrFileDownloadLimited
+	}
+
+	targetToken = strings.TrimSpace(targetToken)
+	if targetToken == "" || s.tokenIssuer.ValidateWithRoom(ctx, targetId, targetToken) != nil {
+		return nil, domain.ErrTargetAccessDenied
+	}
+	target, err := s.tokenRoom(ctx, targetId, targetToken)
+	if errors.Is(err, domain.ErrRoomNotFound) {
+		return nil, domain.ErrTargetAccessDenied
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	policy := s.Policy()
+	if err := checkName(file.Name, policy.Content, target.Content); err != nil {
+		return nil, err
+	}
+	if err := checkType(file.ContentType, file.Name, policy.Content, target.Content); err != nil {
+		return nil, err
+	}
+	quota := newRoomQuota(policy, target)
+	if quota.files == 0 || (quota.bytes >= 0 && file.Size > quota.bytes) {
+		return nil, domain.ErrRoomQuotaExceeded
+	}
+
+	previous, err := s.replacedFile(target, uuid.Nil, opts.Folder, file.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := s.copyContent(ctx, file, target.SanitizeImages)
+	if err != nil {
+		return nil, err
+	}
+	meta.Folder = opts.Folder
+	meta.ExpiresAt = file.ExpiresAt
+	meta.Description = file.Description
+	if opts.Move {
+		meta.MaxDownloads = file.MaxDownloads
+		meta.Downloads = file.Downloads
+	}
+
+	copied, err := s.addFile(ctx, targetId, targetToken, previous, meta)
+	if err != nil {
+		s.discardFile(ctx, meta.Path)
+		return nil, err
+	}
+	rec.Detail += " as file " + copied.ID.String()
+
+	if opts.Move {
+		paths, _, err := s.rooms.DeleteFileByToken(ctx, roomId, fileId, token)
+		if err != nil {
+			// The copy is in place, so the caller can retry the delete.
+			return nil, err
+		}
+		if err := s.removeFiles(ctx, paths); err != nil {
+			slog.WarnContext(ctx, "cannot remove moved file content", slog.String("file_id", fileId.String()), slog.Any("error", err))
+		}
+	}
+
+	slog.InfoContext(ctx, "file copied", slog.String("room_id", roomId.String()), slog.String("file_id", fileId.String()),
+		slog.String("target_room_id", targetId.String()), slog.String("target_file_id", copied.ID.String()), slog.Bool("move", opts.Move))
+	return copied, nil
+}
+
+// copyContent stores the current content of file under a new path and
+// returns a new file describing it, stripping image metadata on the way when
+// sanitize is set and the original still has it.
+func (s *Service) copyContent(ctx context.Context, file *domain.RoomFile, sanitize bool) (*domain.RoomFile, error) {
+	uploadDir := s.Policy().UploadDir
+	name := uuid.NewString()
+	now := s.now()
+
+	if !sanitize || file.Sanitization.Applied || s.sanitizer == nil {
+		path, err := s.files.Copy(ctx, file.Path, uploadDir, name)
+		if err != nil {
+			return nil, err
+		}
+		meta, err := domain.NewRoomFile(path, file.Name, file.Size, file.SHA256, now)
+		if err != nil {
+			s.discardFile(ctx, path)
+			return nil, err
+		}
+		meta.ContentType = file.ContentType
+		meta.Scan = file.Scan
+		meta.Sanitization = file.Sanitization
+		return meta, nil
+	}
+
+	rc, err := s.files.Open(ctx, file.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rc.Close() }()
+
+	saved, sanitization, err := s.saveUpload(ctx, uploadDir, name, rc, true)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := domain.NewRoomFile(saved.Path, file.Name, saved.Size, saved.SHA256, now)
+	if err != nil {
+		s.discardFile(ctx, saved.Path)
+		return nil, err
+	}
+	meta.ContentType = file.ContentType
+	meta.Scan = file.Scan
+	meta.Sanitization = sanitization
+	return meta, nil
+}
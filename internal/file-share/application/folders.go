@@ -0,0 +1,297 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/tracing"
+	"github.com/google/uuid"
+)
+
+// Folders lists the folders below prefix, which must exist; "" lists every
+// folder of the room.
+func (s *Service) Folders(ctx context.Context, id uuid.UUID, token, prefix string) (_ []string, err error) {
+	ctx, span := tracing.Start(ctx, "Service.Folders", roomAttr(id))
+	defer func() { span.Finish(err) }()
+
+	room, prefix, err := s.roomFolder(ctx, id, token, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]string, 0)
+	for _, f := range room.ListFolders() {
+		if f != prefix && domain.InFolder(f, prefix) {
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+// CreateFolder creates folder and any missing parents, returning its
+// normalized path.
+func (s *Service) CreateFolder(ctx context.Context, id uuid.UUID, token, folder string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "Service.CreateFolder", roomAttr(id))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditFolderCreate, RoomID: id, TokenFingerprint: domain.TokenFingerprint(token), Detail: folder}
+	defer func() { s.audit(ctx, &rec, err) }()
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", domain.ErrEmptyToken
+	}
+	folder, err = domain.NormalizeFolder(folder)
+	if err != nil {
+		return "", err
+	}
+	if folder == "" {
+		return "", domain.ErrFolderExists
+	}
+	rec.Detail = folder
+
+	ok, err := s.rooms.AddFolderByToken(ctx, id, token, folder)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", domain.ErrRoomNotFound
+	}
+
+	slog.InfoContext(ctx, "folder created", slog.String("room_id", id.String()), slog.String("folder", folder))
+	return folder, nil
+}
+
+// RenameFolder gives folder a new name within its parent.
+func (s *Service) RenameFolder(ctx context.Context, id uuid.UUID, token, folder, name string) (_ string, err error) {
+	name = strings.TrimSpace(name)
+	if !domain.ValidFolderName(name) {
+		return "", domain.ErrInvalidFolder
+	}
+	folder, err = domain.NormalizeFolder(folder)
+	if err != nil {
+		return "", err
+	}
+	return s.moveFolder(ctx, id, token, folder, domain.JoinFolder(domain.ParentFolder(folder), name))
+}
+
+// MoveFolder moves folder, keeping its name, into parent; "" is the room root.
+func (s *Service) MoveFolder(ctx context.Context, id uuid.UUID, token, folder, parent string) (_ string, err error) {
+	folder, err = domain.NormalizeFolder(folder)
+	if err != nil {
+		return "", err
+	}
+	parent, err = domain.NormalizeFolder(parent)
+	if err != nil {
+		return "", err
+	}
+	if folder == "" {
+		return "", domain.ErrInvalidFolder
+	}
+	return s.moveFolder(ctx, id, token, folder, domain.JoinFolder(parent, domain.FolderName(folder)))
+}
+
+func (s *Service) moveFolder(ctx context.Context, id uuid.UUID, token, from, to string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "Service.MoveFolder", roomAttr(id))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditFolderMove, RoomID: id, TokenFingerprint: domain.TokenFingerprint(token), Detail: from + " -> " + to}
+	defer func() { s.audit(ctx, &rec, err) }()
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", domain.ErrEmptyToken
+	}
+	if _, err := domain.NormalizeFolder(to); err != nil {
+		return "", err
+	}
+
+	ok, err := s.rooms.RenameFolderByToken(ctx, id, token, from, to)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", domain.ErrRoomNotFound
+	}
+
+	slog.InfoContext(ctx, "folder moved", slog.String("room_id", id.String()), slog.String("from", from), slog.String("to", to))
+	return to, nil
+}
+
+// DeleteFolder removes folder together with its subfolders and files.
+func (s *Service) DeleteFolder(ctx context.Context, id uuid.UUID, token, folder string) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "Service.DeleteFolder", roomAttr(id))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditFolderDelete, RoomID: id, TokenFingerprint: domain.TokenFingerprint(token), Detail: folder}
+	defer func() { s.audit(ctx, &rec, err) }()
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return 0, domain.ErrEmptyToken
+	}
+	folder, err = domain.NormalizeFolder(folder)
+	if err != nil {
+		return 0, err
+	}
+	if folder == "" {
+		return 0, domain.ErrInvalidFolder
+	}
+
+	paths, ok, err := s.rooms.DeleteFolderByToken(ctx, id, token, folder)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, domain.ErrRoomNotFound
+	}
+	rec.Detail = fmt.Sprintf("%s (%d files)", folder, len(paths))
+
+	var joined error
+	for _, path := range paths {
+		if err := s.removeFile(ctx, path); err != nil {
+			joined = errors.Join(joined, err)
+		}
+	}
+
+	slog.InfoContext(ctx, "folder deleted", slog.String("room_id", id.String()), slog.String("folder", folder), slog.Int("files", len(paths)))
+	return len(paths), joined
+}
+
+// DownloadFolder streams folder, or the whole room for "", as a ZIP archive
+// keeping the folder structure. It returns the archive's file name.
+func (s *Service) DownloadFolder(ctx context.Context, id uuid.UUID, token, folder string) (_ string, _ io.ReadCloser, err error) {
+	ctx, span := tracing.Start(ctx, "Service.DownloadFolder", roomAttr(id))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditFolderDownload, RoomID: id, TokenFingerprint: domain.TokenFingerprint(token), Detail: folder}
+	defer func() { s.audit(ctx, &rec, err) }()
+
+	if s.archives == nil {
+		return "", nil, ports.ErrNotArchive
+	}
+	room, folder, err := s.roomFolder(ctx, id, token, folder)
+	if err != nil {
+		return "", nil, err
+	}
+	rec.Detail = folder
+
+	entries := s.packEntries(ctx, room, folder)
+	span.SetAttributes(tracing.Int("archive.entries", len(entries)))
+
+	name := "room.zip"
+	if folder != "" {
+		name = domain.FolderName(folder) + ".zip"
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(s.archives.Pack(context.WithoutCancel(ctx), pw, entries))
+	}()
+	return name, pr, nil
+}
+
+// packEntries lays out the files and empty folders of folder relative to it,
+// folders first so extracting tools create them before their content.
+func (s *Service) packEntries(ctx context.Context, room *domain.Room, folder string) []ports.PackEntry {
+	relative := func(p string) string {
+		if folder == "" {
+			return p
+		}
+		return strings.TrimPrefix(strings.TrimPrefix(p, folder), "/")
+	}
+
+	// Files with a download limit are left out; they are only handed out
+	// through counted downloads.
+	now := s.now()
+	nonEmpty := make(map[string]bool)
+	files := make([]*domain.RoomFile, 0, len(room.Files))
+	for _, f := range room.ListFiles() {
+		if !domain.InFolder(f.Folder, folder) || f.Limited() || f.IsExpired(now) {
+			continue
+		}
+		files = append(files, f)
+		for _, a := range domain.FolderAncestors(f.Folder) {
+			nonEmpty[a] = true
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].FullName() < files[j].FullName() })
+
+	var entries []ports.PackEntry
+	for _, f := range room.ListFolders() {
+		if f == folder || !domain.InFolder(f, folder) || nonEmpty[f] {
+			continue
+		}
+		entries = append(entries, ports.PackEntry{Name: relative(f), Dir: true})
+	}
+
+	// Rooms may hold several files under the same name; later ones get a
+	// numbered name so none is lost.
+	seen := make(map[string]int, len(files))
+	for _, f := range files {
+		name := relative(f.FullName())
+		if n := seen[name]; n > 0 {
+			seen[name]++
+			name = numberedName(name, n)
+		} else {
+			seen[name] = 1
+		}
+
+		path := f.Path
+		entries = append(entries, ports.PackEntry{
+			Name:    name,
+			ModTime: f.CreatedAt,
+			Open:    func() (io.ReadCloser, error) { return s.files.Open(ctx, path) },
+		})
+	}
+	return entries
+}
+
+// roomFolder checks the token and that folder exists in the room, returning
+// the normalized folder.
+func (s *Service) roomFolder(ctx context.Context, id uuid.UUID, token, folder string) (*domain.Room, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, "", domain.ErrEmptyToken
+	}
+	folder, err := domain.NormalizeFolder(folder)
+	if err != nil {
+		return nil, "", err
+	}
+
+	room, ok, err := s.rooms.Get(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if !ok || room == nil || !room.HasToken(token) {
+		return nil, "", domain.ErrRoomNotFound
+	}
+	if !room.HasFolder(folder) {
+		return nil, "", domain.ErrFolderNotFound
+	}
+	return room, folder, nil
+}
+
+// numberedName turns "dir/report.pdf" into "dir/report (n).pdf".
+func numberedName(name string, n int) string {
+	dir, base := "", name
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		dir, base = name[:i+1], name[i+1:]
+	}
+	ext := ""
+	if i := strings.LastIndexByte(base, '.'); i > 0 {
+		base, ext = base[:i], base[i:]
+	}
+	return fmt.Sprintf("%s%s (%d)%s", dir, base, n, ext)
+}
@@ -0,0 +1,122 @@
+package application
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/tracing"
+	"github.com/google/uuid"
+)
+
+// SetFileDescription replaces the description of a file; "" clears it.
+func (s *Service) SetFileDescription(ctx context.Context, roomId, fileId uuid.UUID, token, description string) (_ *domain.RoomFile, err error) {
+	ctx, span := tracing.Start(ctx, "Service.SetFileDescription", roomAttr(roomId), fileAttr(fileId))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditFileDescribe, RoomID: roomId, FileID: fileId, TokenFingerprint: domain.TokenFingerprint(token)}
+	defer func() { s.audit(ctx, &rec, err) }()
+
+	description, err = domain.NormalizeDescription(description)
+	if err != nil {
+		return nil, err
+	}
+
+	room, err := s.tokenRoom(ctx, roomId, token)
+	if err != nil {
+		return nil, err
+	}
+	file, ok := room.GetFile(fileId)
+	if !ok || file == nil || file.IsExpired(s.now()) {
+		return nil, domain.ErrFileNotFound
+	}
+	// An early reject; the repository checks the limits again as it writes.
+	policy := s.Policy()
+	grow := int64(len(description) - len(file.Description))
+	if quota := newRoomQuota(policy, room); quota.bytes >= 0 && grow > quota.bytes {
+		return nil, domain.ErrRoomQuotaExceeded
+	}
+
+	file, ok, err = s.rooms.SetFileDescriptionByToken(ctx, roomId, fileId, strings.TrimSpace(token), description, policy.RoomLimits())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.ErrFileNotFound
+	}
+	return file, nil
+}
+
+// Messages returns the room's message thread, oldest first.
+func (s *Service) Messages(ctx context.Context, id uuid.UUID, token string) (_ []domain.RoomMessage, err error) {
+	ctx, span := tracing.Start(ctx, "Service.Messages", roomAttr(id))
+	defer func() { span.Finish(err) }()
+
+	room, err := s.tokenRoom(ctx, id, token)
+	if err != nil {
+		return nil, err
+	}
+	return room.Messages, nil
+}
+
+// PostMessage adds a message to the room's thread. Messages count against
+// the room's message limit and, with their author, its size quota.
+func (s *Service) PostMessage(ctx context.Context, id uuid.UUID, token, author, text string) (_ *domain.RoomMessage, err error) {
+	ctx, span := tracing.Start(ctx, "Service.PostMessage", roomAttr(id))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditMessagePost, RoomID: id, TokenFingerprint: domain.TokenFingerprint(token)}
+	defer func() { s.audit(ctx, &rec, err) }()
+
+	msg, err := domain.NewRoomMessage(author, text, s.now())
+	if err != nil {
+		return nil, err
+	}
+
+	room, err := s.tokenRoom(ctx, id, token)
+	if err != nil {
+		return nil, err
+	}
+	// An early reject; the repository checks the limits again as it writes.
+	policy := s.Policy()
+	if policy.MaxMessages > 0 && len(room.Messages) >= policy.MaxMessages {
+		return nil, domain.ErrRoomQuotaExceeded
+	}
+	if quota := newRoomQuota(policy, room); quota.bytes >= 0 && msg.Size() > quota.bytes {
+		return nil, domain.ErrRoomQuotaExceeded
+	}
+
+	ok, err := s.rooms.AddMessageByToken(ctx, id, strings.TrimSpace(token), msg, policy.RoomLimits())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.ErrRoomNotFound
+	}
+
+	slog.InfoContext(ctx, "message posted", slog.String("room_id", id.String()), slog.String("message_id", msg.ID.String()))
+	return msg, nil
+}
+
+// tokenRoom loads a room the token grants access to.
+func (s *Service) tokenRoom(ctx context.Context, id uuid.UUID, token string) (*domain.Room, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, domain.ErrEmptyToken
+	}
+
+	room, ok, err := s.rooms.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || room == nil || !room.HasToken(token) {
+		return nil, domain.ErrRoomNotFound
+	}
+	return room, nil
+}
@@ -0,0 +1,298 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/tracing"
+	"github.com/google/uuid"
+)
+
+const (
+	PreviewImage = "image"
+	PreviewText  = "text"
+	PreviewIcon  = "icon"
+)
+
+const (
+	DefaultThumbnailSize = 256
+	previewTextLimit     = 16 << 10
+)
+
+// ThumbnailSizes are the sizes thumbnails are rendered and cached at.
+// Requested sizes are rounded up to the nearest one.
+var ThumbnailSizes = []int{64, 128, 256, 512}
+
+type Preview struct {
+	Kind      string
+	Text      string
+	Truncated bool
+	Width     int
+	Height    int
+	Icon      string
+}
+
+type thumbnailFlight struct {
+	done chan struct{}
+	err  error
+}
+
+// UseThumbnailer enables image thumbnails. Without one, images get an icon.
+func (s *Service) UseThumbnailer(thumbnailer ports.Thumbnailer) {
+	s.thumbnailer = thumbnailer
+}
+
+func (s *Service) Preview(ctx context.Context, roomId, fileId uuid.UUID, token string) (_ *domain.RoomFile, _ Preview, err error) {
+	ctx, span := tracing.Start(ctx, "Service.Preview", roomAttr(roomId), fileAttr(fileId))
+	defer func() { span.Finish(err) }()
+
+	file, err := s.File(ctx, roomId, fileId, token)
+	if err != nil {
+		return nil, Preview{}, err
+	}
+
+	contentType := domain.MediaType(file.ContentType)
+	switch {
+	case file.Limited():
+		// Previews would show the content without counting a download.
+		return file, Preview{Kind: PreviewIcon, Icon: iconFor(contentType)}, nil
+	case s.canThumbnail(contentType):
+		preview, err := s.imagePreview(ctx, file)
+		if err != nil {
+			slog.WarnContext(ctx, "cannot inspect image", slog.String("file_id", fileId.String()), slog.Any("error", err))
+			return file, Preview{Kind: PreviewIcon, Icon: iconFor(contentType)}, nil
+		}
+		return file, preview, nil
+	case isTextType(contentType):
+		preview, err := s.textPreview(ctx, file)
+		if err != nil {
+			return nil, Preview{}, err
+		}
+		return file, preview, nil
+	default:
+		return file, Preview{Kind: PreviewIcon, Icon: iconFor(contentType)}, nil
+	}
+}
+
+// Thumbnail returns a JPEG thumbnail of an image file, rendering and caching
+// it next to the original on first use.
+func (s *Service) Thumbnail(ctx context.Context, roomId, fileId uuid.UUID, token string, size int) (_ io.ReadCloser, err error) {
+	ctx, span := tracing.Start(ctx, "Service.Thumbnail", roomAttr(roomId), fileAttr(fileId), tracing.Int("thumbnail.size", size))
+	defer func() { span.Finish(err) }()
+
+	file, err := s.contentFile(ctx, roomId, fileId, token)
+	if err != nil {
+		return nil, err
+	}
+	if !s.canThumbnail(domain.MediaType(file.ContentType)) {
+		return nil, ports.ErrThumbnailUnsupported
+	}
+
+	size = snapThumbnailSize(size)
+	path := thumbnailPath(file.Path, size)
+	if err := s.ensureThumbnail(ctx, file.Path, path, size); err != nil {
+		return nil, err
+	}
+	return s.files.Open(ctx, path)
+}
+
+func (s *Service) canThumbnail(contentType string) bool {
+	return s.thumbnailer != nil && s.thumbnailer.Supports(contentType)
+}
+
+// ensureThumbnail makes sure the thumbnail at path exists. Concurrent
+// requests for the same thumbnail wait for a single render.
+func (s *Service) ensureThumbnail(ctx context.Context, source, path string, size int) error {
+	s.thumbnailMu.Lock()
+	if flight, ok := s.thumbnailFlights[path]; ok {
+		s.thumbnailMu.Unlock()
+		select {
+		case <-flight.done:
+			return flight.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	flight := &thumbnailFlight{done: make(chan struct{})}
+	s.thumbnailFlights[path] = flight
+	s.thumbnailMu.Unlock()
+
+	// Waiters share the result, so one caller going away must not fail them.
+	flight.err = s.renderThumbnail(context.WithoutCancel(ctx), source, path, size)
+
+	s.thumbnailMu.Lock()
+	delete(s.thumbnailFlights, path)
+	s.thumbnailMu.Unlock()
+	close(flight.done)
+
+	return flight.err
+}
+
+func (s *Service) renderThumbnail(ctx context.Context, source, path string, size int) error {
+	ok, err := s.files.Exists(ctx, path)
+	if err != nil || ok {
+		return err
+	}
+
+	rc, err := s.files.Open(ctx, source)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	data, err := s.thumbnailer.Thumbnail(ctx, rc, size)
+	if err != nil {
+		if errors.Is(err, ports.ErrImageTooLarge) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", ports.ErrThumbnailUnsupported, err)
+	}
+
+	_, err = s.files.Save(ctx, filepath.Dir(path), filepath.Base(path), bytes.NewReader(data))
+	return err
+}
+
+func (s *Service) imagePreview(ctx context.Context, file *domain.RoomFile) (Preview, error) {
+	rc, err := s.files.Open(ctx, file.Path)
+	if err != nil {
+		return Preview{}, err
+	}
+	defer rc.Close()
+
+	info, err := s.thumbnailer.Inspect(ctx, rc)
+	if err != nil {
+		return Preview{}, err
+	}
+	return Preview{Kind: PreviewImage, Width: info.Width, Height: info.Height}, nil
+}
+
+func (s *Service) textPreview(ctx context.Context, file *domain.RoomFile) (Preview, error) {
+	rc, err := s.files.Open(ctx, file.Path)
+	if err != nil {
+		return Preview{}, err
+	}
+	defer rc.Close()
+
+	buf, err := io.ReadAll(io.LimitReader(rc, previewTextLimit+1))
+	if err != nil {
+		return Preview{}, err
+	}
+	truncated := len(buf) > previewTextLimit
+	if truncated {
+		buf = buf[:previewTextLimit]
+		// Drop a rune cut in half by the limit.
+		for i := 0; i < utf8.UTFMax && len(buf) > 0 && !utf8.Valid(buf); i++ {
+			buf = buf[:len(buf)-1]
+		}
+	}
+	if bytes.IndexByte(buf, 0) >= 0 {
+		return Preview{Kind: PreviewIcon, Icon: "file"}, nil
+	}
+
+	return Preview{Kind: PreviewText, Text: sanitizeText(buf), Truncated: truncated}, nil
+}
+
+// sanitizeText replaces invalid UTF-8 and drops control characters other than
+// line breaks and tabs, so the preview is safe to show as plain text.
+func sanitizeText(buf []byte) string {
+	text := strings.ToValidUTF8(string(buf), string(utf8.RuneError))
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) || r == ' ' || r == ' ' || unicode.Is(unicode.Bidi_Control, r) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+func isTextType(contentType string) bool {
+	if strings.HasPrefix(contentType, "text/") || strings.HasSuffix(contentType, "+json") || strings.HasSuffix(contentType, "+xml") {
+		return true
+	}
+	switch contentType {
+	case "application/json", "application/xml", "application/javascript", "application/x-javascript",
+		"application/ecmascript", "application/x-sh", "application/x-yaml", "application/yaml",
+		"application/toml", "application/sql", "application/x-httpd-php":
+		return true
+	}
+	return false
+}
+
+func iconFor(contentType string) string {
+	major, _, _ := strings.Cut(contentType, "/")
+	switch major {
+	case "image", "audio", "video":
+		return major
+	}
+	if isTextType(contentType) {
+		return "text"
+	}
+
+	switch contentType {
+	case "application/pdf":
+		return "pdf"
+	case "application/zip", "application/gzip", "application/x-gzip", "application/x-tar",
+		"application/x-7z-compressed", "application/x-rar-compressed", "application/vnd.rar",
+		"application/x-bzip2", "application/x-xz", "application/zstd":
+		return "archive"
+	case "application/msword", "application/rtf", "application/vnd.ms-excel", "application/vnd.ms-powerpoint":
+		return "document"
+	}
+	if strings.HasPrefix(contentType, "application/vnd.openxmlformats-officedocument.") ||
+		strings.HasPrefix(contentType, "application/vnd.oasis.opendocument.") {
+		return "document"
+	}
+	return "file"
+}
+
+func snapThumbnailSize(size int) int {
+	for _, s := range ThumbnailSizes {
+		if size <= s {
+			return s
+		}
+	}
+	return ThumbnailSizes[len(ThumbnailSizes)-1]
+}
+
+// thumbnailPath places cached thumbnails next to the original so they share
+// its directory and lifetime.
+func thumbnailPath(path string, size int) string {
+	return fmt.Sprintf("%s.thumb-%d.jpg", path, size)
+}
+
+// removeFile deletes a stored file together with its cached thumbnails.
+func (s *Service) removeFile(ctx context.Context, path string) error {
+	joined := s.files.Delete(ctx, path)
+	for _, size := range ThumbnailSizes {
+		if err := s.files.Delete(ctx, thumbnailPath(path, size)); err != nil {
+			joined = errors.Join(joined, err)
+		}
+	}
+	return joined
+}
+
+// removeFiles is removeFile for every path, skipping empty ones.
+func (s *Service) removeFiles(ctx context.Context, paths []string) error {
+	var joined error
+	for _, path := range paths {
+		if strings.TrimSpace(path) == "" {
+			continue
+		}
+		if err := s.removeFile(ctx, path); err != nil {
+			joined = errors.Join(joined, err)
+		}
+	}
+	return joined
+}
@@ -0,0 +1,59 @@
+package application
+
+import (
+	"io"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+)
+
+// roomQuota is what a room may still take under the policy limits, as of
+// the snapshot it was made from. It rejects oversized uploads early; the
+// repository enforces the limits for good when the file is added. A
+// negative value means no limit.
+type roomQuota struct {
+	files int
+	bytes int64
+}
+
+func newRoomQuota(policy domain.Policy, room *domain.Room) roomQuota {
+	q := roomQuota{files: -1, bytes: -1}
+	if policy.MaxFiles > 0 {
+		q.files = max(policy.MaxFiles-room.StoredFiles(), 0)
+	}
+	if policy.MaxRoomBytes > 0 {
+		q.bytes = max(policy.MaxRoomBytes-room.TotalSize(), 0)
+	}
+	return q
+}
+
+// limit fails reads past the remaining byte allowance, so an oversized
+// upload is cut off instead of filling the disk first.
+func (q *roomQuota) limit(r io.Reader) io.Reader {
+	if q.bytes < 0 {
+		return r
+	}
+	return &quotaReader{r: r, left: q.bytes}
+}
+
+func (q *roomQuota) charge(size int64) {
+	if q.files > 0 {
+		q.files--
+	}
+	if q.bytes >= 0 {
+		q.bytes = max(q.bytes-size, 0)
+	}
+}
+
+type quotaReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (q *quotaReader) Read(p []byte) (int, error) {
+	n, err := q.r.Read(p)
+	q.left -= int64(n)
+	if q.left < 0 {
+		return n, domain.ErrRoomQuotaExceeded
+	}
+	return n, err
+}
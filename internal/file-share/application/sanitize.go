@@ -0,0 +1,92 @@
+package application
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+)
+
+// UseSanitizer enables stripping image metadata from uploads that ask for it.
+func (s *Service) UseSanitizer(sanitizer ports.ImageSanitizer) {
+	s.sanitizer = sanitizer
+}
+
+// saveUpload stores r, passing it through the image sanitizer on the way when
+// sanitize is set so the original never touches the disk.
+func (s *Service) saveUpload(ctx context.Context, dir, name string, r io.Reader, sanitize bool) (ports.SavedFile, domain.Sanitization, error) {
+	if !sanitize || s.sanitizer == nil {
+		saved, err := s.files.Save(ctx, dir, name, r)
+		return saved, domain.Sanitization{}, err
+	}
+
+	original := &digestReader{r: r, hash: sha256.New()}
+	pr, pw := io.Pipe()
+	type result struct {
+		applied bool
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		applied, err := s.sanitizer.Sanitize(ctx, pw, original)
+		// The sanitizer may stop early, e.g. at a JPEG's EOI, so read the
+		// rest for the original's size and digest.
+		if err == nil {
+			_, err = io.Copy(io.Discard, original)
+		}
+		_ = pw.CloseWithError(err)
+		done <- result{applied, err}
+	}()
+
+	saved, err := s.files.Save(ctx, dir, name, pr)
+	// Unblock the sanitizer if Save gave up early.
+	_ = pr.CloseWithError(io.ErrClosedPipe)
+	res := <-done
+	if res.err != nil {
+		if saved.Path != "" {
+			s.discardFile(ctx, saved.Path)
+		}
+		if ctx.Err() != nil {
+			return ports.SavedFile{}, domain.Sanitization{}, ctx.Err()
+		}
+		// Failing to read the upload is not the image's fault.
+		if original.err != nil {
+			return ports.SavedFile{}, domain.Sanitization{}, original.err
+		}
+		return ports.SavedFile{}, domain.Sanitization{}, fmt.Errorf("%w: %v", ports.ErrSanitizeFailed, res.err)
+	}
+	if err != nil {
+		return ports.SavedFile{}, domain.Sanitization{}, err
+	}
+	if !res.applied {
+		return saved, domain.Sanitization{}, nil
+	}
+
+	return saved, domain.Sanitization{
+		Applied:        true,
+		OriginalSize:   original.size,
+		OriginalSHA256: hex.EncodeToString(original.hash.Sum(nil)),
+	}, nil
+}
+
+type digestReader struct {
+	r    io.Reader
+	hash hash.Hash
+	size int64
+	err  error
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.size += int64(n)
+	d.hash.Write(p[:n])
+	if err != nil && err != io.EOF {
+		d.err = err
+	}
+	return n, err
+}
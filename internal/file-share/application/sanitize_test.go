@@ -0,0 +1,48 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/rand/v2"
+	"testing"
+
+	filestore "github.com/Miklakapi/go-file-share/internal/file-share/adapters/file-store"
+	imagesanitizer "github.com/Miklakapi/go-file-share/internal/file-share/adapters/image-sanitizer"
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+)
+
+func TestSaveUploadDigestsWholeOriginal(t *testing.T) {
+	s := NewService(nil, filestore.DiskStore{}, nil, nil, nil, domain.Policy{})
+	s.UseSanitizer(imagesanitizer.New())
+
+	jpeg := []byte{
+		0xFF, 0xD8,
+		0xFF, 0xE1, 0x00, 0x0A, 'E', 'x', 'i', 'f', 0, 0, 'M', 'M',
+		0xFF, 0xDA, 0x00, 0x08, 0x01, 0x01, 0x00, 0x00, 0x3F, 0x00,
+		0x12, 0x34, 0xFF, 0x00, 0x56,
+		0xFF, 0xD9,
+	}
+	// Data after EOI, like an appended thumbnail, well past the
+	// sanitizer's read-ahead.
+	trailer := make([]byte, 200<<10)
+	rng := rand.NewChaCha8([32]byte{})
+	_, _ = rng.Read(trailer)
+	upload := append(append([]byte{}, jpeg...), trailer...)
+
+	saved, sanitization, err := s.saveUpload(context.Background(), t.TempDir(), "photo.jpg", bytes.NewReader(upload), true)
+	if err != nil {
+		t.Fatalf("saveUpload: %v", err)
+	}
+	if !sanitization.Applied {
+		t.Fatal("sanitizer did not apply")
+	}
+	sum := sha256.Sum256(upload)
+	if sanitization.OriginalSize != int64(len(upload)) || sanitization.OriginalSHA256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("original recorded as %d bytes %s, want %d bytes %x", sanitization.OriginalSize, sanitization.OriginalSHA256, len(upload), sum)
+	}
+	if saved.Size >= int64(len(jpeg)) {
+		t.Fatalf("stored %d bytes, want the image without metadata or trailer", saved.Size)
+	}
+}
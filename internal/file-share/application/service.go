@@ -3,12 +3,18 @@ package application
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"log/slog"
+	"mime"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
 	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/tracing"
 	"github.com/google/uuid"
 )
 
@@ -17,22 +23,60 @@ type Service struct {
 	files       ports.FileStore
 	hasher      ports.PasswordHasher
 	tokenIssuer ports.TokenService
+	auditSink   ports.AuditSink
+	scanner     ports.ContentScanner
+	failOpen    bool
+	thumbnailer ports.Thumbnailer
+	sanitizer   ports.ImageSanitizer
+	archives    ports.Archiver
+	bundles     ports.RoomBundler
+	policyMu    sync.RWMutex
 	policy      domain.Policy
 	now         func() time.Time
+
+	thumbnailMu      sync.Mutex
+	thumbnailFlights map[string]*thumbnailFlight
 }
 
-func NewService(rooms ports.RoomRepository, files ports.FileStore, hasher ports.PasswordHasher, tokenIssuer ports.TokenService, policy domain.Policy) *Service {
+func NewService(rooms ports.RoomRepository, files ports.FileStore, hasher ports.PasswordHasher, tokenIssuer ports.TokenService, auditSink ports.AuditSink, policy domain.Policy) *Service {
 	return &Service{
 		rooms:       rooms,
 		files:       files,
 		hasher:      hasher,
 		tokenIssuer: tokenIssuer,
+		auditSink:   auditSink,
 		policy:      policy,
 		now:         time.Now,
+
+		thumbnailFlights: make(map[string]*thumbnailFlight),
 	}
 }
 
-func (s *Service) Room(ctx context.Context, id uuid.UUID) (*domain.Room, bool, error) {
+// UseScanner makes uploads wait in quarantine until scanner passes them.
+// With failOpen, uploads the scanner cannot judge are accepted as unverified
+// instead of rejected.
+func (s *Service) UseScanner(scanner ports.ContentScanner, failOpen bool) {
+	s.scanner = scanner
+	s.failOpen = failOpen
+}
+
+func (s *Service) Policy() domain.Policy {
+	s.policyMu.RLock()
+	defer s.policyMu.RUnlock()
+	return s.policy
+}
+
+// SetPolicy swaps the limits and TTLs used by later calls, e.g. on reload.
+func (s *Service) SetPolicy(policy domain.Policy) {
+	s.policyMu.Lock()
+	defer s.policyMu.Unlock()
+	s.policy = policy
+}
+
+func (s *Service) Room(ctx context.Context, id uuid.UUID) (_ *domain.Room, _ bool, err error) {
+	ctx, span := tracing.Start(ctx, "Service.Room", roomAttr(id))
+	defer func() { span.Finish(err) }()
+
 	if err := ctx.Err(); err != nil {
 		return nil, false, err
 	}
@@ -48,7 +92,10 @@ func (s *Service) Room(ctx context.Context, id uuid.UUID) (*domain.Room, bool, e
 	return room, true, nil
 }
 
-func (s *Service) CheckRoomAccess(ctx context.Context, id uuid.UUID, token string) (bool, error) {
+func (s *Service) CheckRoomAccess(ctx context.Context, id uuid.UUID, token string) (_ bool, err error) {
+	ctx, span := tracing.Start(ctx, "Service.CheckRoomAccess", roomAttr(id))
+	defer func() { span.Finish(err) }()
+
 	if err := ctx.Err(); err != nil {
 		return false, err
 	}
@@ -64,15 +111,67 @@ func (s *Service) CheckRoomAccess(ctx context.Context, id uuid.UUID, token strin
 	return true, nil
 }
 
-func (s *Service) Rooms(ctx context.Context) ([]*domain.Room, error) {
+// Rooms returns one page of rooms, sorted by creation time unless query
+// says otherwise.
+func (s *Service) Rooms(ctx context.Context, query ports.RoomQuery) (_ ports.RoomPage, err error) {
+	ctx, span := tracing.Start(ctx, "Service.Rooms")
+	defer func() { span.Finish(err) }()
+
 	if err := ctx.Err(); err != nil {
-		return nil, err
+		return ports.RoomPage{}, err
+	}
+
+	switch query.Sort {
+	case "":
+		query.Sort = ports.SortByCreatedAt
+	case ports.SortByCreatedAt, ports.SortByExpiresAt:
+	default:
+		return ports.RoomPage{}, ports.ErrInvalidQuery
 	}
+	if err := checkPage(&query.Limit, query.Cursor); err != nil {
+		return ports.RoomPage{}, err
+	}
+	if !query.ExpiresAfter.IsZero() && !query.ExpiresBefore.IsZero() && query.ExpiresBefore.Before(query.ExpiresAfter) {
+		return ports.RoomPage{}, ports.ErrInvalidQuery
+	}
+
+	return s.rooms.QueryRooms(ctx, query)
+}
 
-	return s.rooms.List(ctx)
+// RoomOptions are the per-room settings chosen at creation.
+type RoomOptions struct {
+	// Content restricts uploads further than the server-wide policy; the
+	// zero value adds no restriction.
+	Content        domain.ContentRules
+	SanitizeImages bool
+	// Versioning keeps older content when a file is uploaded again under
+	// the same name.
+	Versioning bool
 }
 
-func (s *Service) CreateRoom(ctx context.Context, password string, lifespan time.Duration) (*domain.Room, string, error) {
+// UploadOptions tune a single upload.
+type UploadOptions struct {
+	// ExpectedDigest is the hex SHA-256 the client computed, checked when set.
+	ExpectedDigest string
+	// Sanitize strips image metadata even if the room does not require it.
+	Sanitize bool
+	// Folder places the file in a virtual folder, created when missing.
+	Folder string
+	// Lifespan removes the file before its room when positive.
+	Lifespan time.Duration
+	// MaxDownloads removes the file after that many downloads when positive.
+	MaxDownloads int
+	// VersionOf uploads a new version of that file instead of a new file.
+	VersionOf uuid.UUID
+}
+
+func (s *Service) CreateRoom(ctx context.Context, password string, lifespan time.Duration, opts RoomOptions) (_ *domain.Room, _ string, err error) {
+	ctx, span := tracing.Start(ctx, "Service.CreateRoom")
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditRoomCreate}
+	defer func() { s.audit(ctx, &rec, err) }()
+
 	if err := ctx.Err(); err != nil {
 		return nil, "", err
 	}
@@ -82,10 +181,11 @@ func (s *Service) CreateRoom(ctx context.Context, password string, lifespan time
 		return nil, "", domain.ErrEmptyPassword
 	}
 
+	policy := s.Policy()
 	if lifespan <= 0 {
-		lifespan = s.policy.DefaultRoomTTL
+		lifespan = policy.DefaultRoomTTL
 	}
-	if s.policy.MaxRoomLifespan > 0 && lifespan > s.policy.MaxRoomLifespan {
+	if policy.MaxRoomLifespan > 0 && lifespan > policy.MaxRoomLifespan {
 		return nil, "", domain.ErrRoomLifespanTooLong
 	}
 
@@ -98,11 +198,17 @@ func (s *Service) CreateRoom(ctx context.Context, password string, lifespan time
 	if err != nil {
 		return nil, "", err
 	}
+	room.Content = opts.Content
+	room.SanitizeImages = opts.SanitizeImages
+	room.Versioning = opts.Versioning
+
+	rec.RoomID = room.ID
 
 	token, _, err := s.tokenIssuer.Issue(ctx, room.ID, lifespan)
 	if err != nil {
 		return nil, "", err
 	}
+	rec.TokenFingerprint = domain.TokenFingerprint(token)
 	if err := room.AddToken(token); err != nil {
 		return nil, "", err
 	}
@@ -111,10 +217,17 @@ func (s *Service) CreateRoom(ctx context.Context, password string, lifespan time
 		return nil, "", err
 	}
 
+	slog.InfoContext(ctx, "room created", slog.String("room_id", room.ID.String()), slog.Time("expires_at", room.ExpiresAt))
 	return room, token, nil
 }
 
-func (s *Service) DeleteRoom(ctx context.Context, id uuid.UUID, token string) error {
+func (s *Service) DeleteRoom(ctx context.Context, id uuid.UUID, token string) (err error) {
+	ctx, span := tracing.Start(ctx, "Service.DeleteRoom", roomAttr(id))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditRoomDelete, RoomID: id, TokenFingerprint: domain.TokenFingerprint(token)}
+	defer func() { s.audit(ctx, &rec, err) }()
+
 	if err := ctx.Err(); err != nil {
 		return err
 	}
@@ -136,13 +249,14 @@ func (s *Service) DeleteRoom(ctx context.Context, id uuid.UUID, token string) er
 	if err != nil {
 		return err
 	}
+	slog.InfoContext(ctx, "room deleted", slog.String("room_id", id.String()), slog.Int("files", len(paths)))
 
 	var joined error
 	for _, path := range paths {
 		if path == "" {
 			continue
 		}
-		if err := s.files.Delete(ctx, path); err != nil {
+		if err := s.removeFile(ctx, path); err != nil {
 			joined = errors.Join(joined, err)
 		}
 	}
@@ -150,7 +264,13 @@ func (s *Service) DeleteRoom(ctx context.Context, id uuid.UUID, token string) er
 	return joined
 }
 
-func (s *Service) AuthRoom(ctx context.Context, id uuid.UUID, password string, lifespan time.Duration) (string, time.Time, error) {
+func (s *Service) AuthRoom(ctx context.Context, id uuid.UUID, password string, lifespan time.Duration) (_ string, _ time.Time, err error) {
+	ctx, span := tracing.Start(ctx, "Service.AuthRoom", roomAttr(id))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditRoomAuth, RoomID: id}
+	defer func() { s.audit(ctx, &rec, err) }()
+
 	if err := ctx.Err(); err != nil {
 		return "", time.Time{}, err
 	}
@@ -160,10 +280,11 @@ func (s *Service) AuthRoom(ctx context.Context, id uuid.UUID, password string, l
 		return "", time.Time{}, domain.ErrEmptyPassword
 	}
 
+	policy := s.Policy()
 	if lifespan <= 0 {
-		lifespan = s.policy.DefaultTokenTTL
+		lifespan = policy.DefaultTokenTTL
 	}
-	if s.policy.MaxTokenLifespan > 0 && lifespan > s.policy.MaxTokenLifespan {
+	if policy.MaxTokenLifespan > 0 && lifespan > policy.MaxTokenLifespan {
 		return "", time.Time{}, domain.ErrTokenLifespanTooLong
 	}
 
@@ -181,6 +302,7 @@ func (s *Service) AuthRoom(ctx context.Context, id uuid.UUID, password string, l
 		return "", time.Time{}, err
 	}
 	if !ok {
+		slog.InfoContext(ctx, "room auth failed", slog.String("room_id", id.String()))
 		return "", time.Time{}, domain.ErrInvalidPassword
 	}
 
@@ -188,6 +310,7 @@ func (s *Service) AuthRoom(ctx context.Context, id uuid.UUID, password string, l
 	if err != nil {
 		return "", time.Time{}, err
 	}
+	rec.TokenFingerprint = domain.TokenFingerprint(token)
 
 	if err := s.rooms.AddToken(ctx, id, token); err != nil {
 		return "", time.Time{}, err
@@ -196,7 +319,13 @@ func (s *Service) AuthRoom(ctx context.Context, id uuid.UUID, password string, l
 	return token, expiresAt, nil
 }
 
-func (s *Service) LogoutRoom(ctx context.Context, id uuid.UUID, token string) error {
+func (s *Service) LogoutRoom(ctx context.Context, id uuid.UUID, token string) (err error) {
+	ctx, span := tracing.Start(ctx, "Service.LogoutRoom", roomAttr(id))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditRoomLogout, RoomID: id, TokenFingerprint: domain.TokenFingerprint(token)}
+	defer func() { s.audit(ctx, &rec, err) }()
+
 	if err := ctx.Err(); err != nil {
 		return err
 	}
@@ -217,7 +346,10 @@ func (s *Service) LogoutRoom(ctx context.Context, id uuid.UUID, token string) er
 	return nil
 }
 
-func (s *Service) File(ctx context.Context, roomId, fileId uuid.UUID, token string) (*domain.RoomFile, error) {
+func (s *Service) File(ctx context.Context, roomId, fileId uuid.UUID, token string) (_ *domain.RoomFile, err error) {
+	ctx, span := tracing.Start(ctx, "Service.File", roomAttr(roomId), fileAttr(fileId))
+	defer func() { span.Finish(err) }()
+
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
@@ -236,14 +368,33 @@ func (s *Service) File(ctx context.Context, roomId, fileId uuid.UUID, token stri
 	}
 
 	f, ok := room.GetFile(fileId)
-	if !ok || f == nil {
+	if !ok || f == nil || f.IsExpired(s.now()) {
 		return nil, domain.ErrFileNotFound
 	}
 
 	return f, nil
 }
 
-func (s *Service) DownloadFile(ctx context.Context, roomId, fileId uuid.UUID, token string) (*domain.RoomFile, io.ReadCloser, error) {
+// contentFile is File for everything that hands out a file's content other
+// than a counted download, which files with a download limit refuse.
+func (s *Service) contentFile(ctx context.Context, roomId, fileId uuid.UUID, token string) (*domain.RoomFile, error) {
+	file, err := s.File(ctx, roomId, fileId, token)
+	if err != nil {
+		return nil, err
+	}
+	if file.Limited() {
+		return nil, domain.ErrFileDownloadLimited
+	}
+	return file, nil
+}
+
+func (s *Service) DownloadFile(ctx context.Context, roomId, fileId uuid.UUID, token string) (_ *domain.RoomFile, _ io.ReadCloser, err error) {
+	ctx, span := tracing.Start(ctx, "Service.DownloadFile", roomAttr(roomId), fileAttr(fileId))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditFileDownload, RoomID: roomId, FileID: fileId, TokenFingerprint: domain.TokenFingerprint(token)}
+	defer func() { s.audit(ctx, &rec, err) }()
+
 	if err := ctx.Err(); err != nil {
 		return nil, nil, err
 	}
@@ -262,41 +413,93 @@ func (s *Service) DownloadFile(ctx context.Context, roomId, fileId uuid.UUID, to
 	}
 
 	file, ok := room.GetFile(fileId)
-	if !ok || file == nil {
+	if !ok || file == nil || file.IsExpired(s.now()) {
 		return nil, nil, domain.ErrFileNotFound
 	}
 
+	// The content is opened before the download is counted, so a failure
+	// does not use up a limited file.
 	rc, err := s.files.Open(ctx, file.Path)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	file, ok, err = s.rooms.CountDownloadByToken(ctx, roomId, fileId, token, s.now())
+	if err != nil || !ok {
+		_ = rc.Close()
+		if err == nil {
+			err = domain.ErrFileNotFound
+		}
+		return nil, nil, err
+	}
+
+	if file.Exhausted() {
+		rec.Detail = "last download"
+		slog.InfoContext(ctx, "file used up", slog.String("room_id", roomId.String()), slog.String("file_id", fileId.String()))
+		paths := file.Paths()
+		rc = &closeHook{ReadCloser: rc, after: func() {
+			_ = s.removeFiles(context.WithoutCancel(ctx), paths)
+		}}
+	}
+
 	return file, rc, nil
 }
 
-func (s *Service) Files(ctx context.Context, id uuid.UUID, token string) ([]*domain.RoomFile, error) {
+// Files returns one page of the room's files in query.Folder and below,
+// sorted by name unless query says otherwise.
+func (s *Service) Files(ctx context.Context, id uuid.UUID, token string, query ports.FileQuery) (_ ports.FilePage, err error) {
+	ctx, span := tracing.Start(ctx, "Service.Files", roomAttr(id))
+	defer func() { span.Finish(err) }()
+
 	if err := ctx.Err(); err != nil {
-		return nil, err
+		return ports.FilePage{}, err
 	}
 
 	token = strings.TrimSpace(token)
 	if token == "" {
-		return nil, domain.ErrEmptyToken
+		return ports.FilePage{}, domain.ErrEmptyToken
 	}
-
-	room, ok, err := s.rooms.Get(ctx, id)
+	query.Folder, err = domain.NormalizeFolder(query.Folder)
 	if err != nil {
-		return nil, err
+		return ports.FilePage{}, err
 	}
-	if !ok || room == nil || !room.HasToken(token) {
-		return nil, domain.ErrRoomNotFound
+
+	switch query.Sort {
+	case "":
+		query.Sort = ports.SortByName
+	case ports.SortByName, ports.SortBySize, ports.SortByCreatedAt:
+	default:
+		return ports.FilePage{}, ports.ErrInvalidQuery
+	}
+	if err := checkPage(&query.Limit, query.Cursor); err != nil {
+		return ports.FilePage{}, err
+	}
+	if query.MinSize < 0 || query.MaxSize < 0 || (query.MaxSize > 0 && query.MaxSize < query.MinSize) {
+		return ports.FilePage{}, ports.ErrInvalidQuery
+	}
+	query.Name = strings.TrimSpace(query.Name)
+	query.ContentType = strings.ToLower(strings.TrimSpace(query.ContentType))
+	if query.ContentType != "" && !strings.Contains(query.ContentType, "/") {
+		return ports.FilePage{}, ports.ErrInvalidQuery
 	}
 
-	files := room.ListFiles()
-	return files, nil
+	page, ok, err := s.rooms.QueryFilesByToken(ctx, id, token, query)
+	if err != nil {
+		return ports.FilePage{}, err
+	}
+	if !ok {
+		return ports.FilePage{}, domain.ErrRoomNotFound
+	}
+	return page, nil
 }
 
-func (s *Service) UploadFile(ctx context.Context, roomId uuid.UUID, token string, filename string, r io.Reader) (*domain.RoomFile, error) {
+func (s *Service) UploadFile(ctx context.Context, roomId uuid.UUID, token string, filename string, opts UploadOptions, r io.Reader) (_ *domain.RoomFile, err error) {
+	ctx, span := tracing.Start(ctx, "Service.UploadFile", roomAttr(roomId), tracing.String("file.name", filename))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditFileUpload, RoomID: roomId, TokenFingerprint: domain.TokenFingerprint(token), Detail: filename}
+	defer func() { s.audit(ctx, &rec, err) }()
+
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
@@ -313,34 +516,176 @@ func (s *Service) UploadFile(ctx context.Context, roomId uuid.UUID, token string
 	if r == nil {
 		return nil, ports.ErrNilReader
 	}
+	if opts.Folder, err = domain.NormalizeFolder(opts.Folder); err != nil {
+		return nil, err
+	}
+	if opts.Lifespan < 0 || opts.MaxDownloads < 0 {
+		return nil, domain.ErrInvalidFileLimits
+	}
+
+	room, ok, err := s.rooms.Get(ctx, roomId)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || room == nil {
+		return nil, domain.ErrRoomNotFound
+	}
+
+	quota := newRoomQuota(s.Policy(), room)
+	meta, err := s.storeUpload(ctx, room, token, filename, opts, r, &quota)
+	if err != nil {
+		if errors.Is(err, ports.ErrFileInfected) {
+			rec.Detail += " (" + err.Error() + ")"
+		}
+		return nil, err
+	}
+	rec.FileID = meta.ID
+	if meta.Version > 1 {
+		rec.Detail += fmt.Sprintf(" (version %d)", meta.Version)
+	}
+
+	span.SetAttributes(tracing.Int64("file.size", meta.Size))
+	slog.InfoContext(ctx, "file uploaded", slog.String("room_id", roomId.String()), slog.String("file_id", meta.ID.String()), slog.Int64("size", meta.Size))
+	return meta, nil
+}
+
+// storeUpload saves r as a new file of room, or as a new version of the file
+// it replaces, running it through the content rules, sanitizer and scanner,
+// and charges it against quota.
+func (s *Service) storeUpload(ctx context.Context, room *domain.Room, token, filename string, opts UploadOptions, r io.Reader, quota *roomQuota) (*domain.RoomFile, error) {
+	if quota.files == 0 {
+		return nil, domain.ErrRoomQuotaExceeded
+	}
+	policy := s.Policy()
+	if err := checkName(filename, policy.Content, room.Content); err != nil {
+		return nil, err
+	}
+
+	previous, err := s.replacedFile(room, opts.VersionOf, opts.Folder, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	uploadDir := policy.UploadDir
+	saveDir := uploadDir
+	if s.scanner != nil {
+		saveDir = quarantineDir(uploadDir)
+	}
 
 	uuid := uuid.New()
-	path, size, err := s.files.Save(ctx, s.policy.UploadDir, uuid.String(), r)
+	saved, sanitization, err := s.saveUpload(ctx, saveDir, uuid.String(), quota.limit(r), opts.Sanitize || room.SanitizeImages)
 	if err != nil {
 		return nil, err
 	}
+	path := saved.Path
+
+	// The client hashes what it sent, which differs from what was stored
+	// when metadata was stripped.
+	sentDigest := saved.SHA256
+	if sanitization.Applied {
+		sentDigest = sanitization.OriginalSHA256
+	}
+	if opts.ExpectedDigest != "" && !strings.EqualFold(opts.ExpectedDigest, sentDigest) {
+		s.discardFile(ctx, path)
+		return nil, ports.ErrDigestMismatch
+	}
+
+	contentType := detectContentType(saved.ContentType, filename)
+	if err := checkType(contentType, filename, policy.Content, room.Content); err != nil {
+		s.discardFile(ctx, path)
+		return nil, err
+	}
+
+	verdict, err := s.scan(ctx, path)
+	if err != nil {
+		s.discardFile(ctx, path)
+		return nil, err
+	}
+	if saveDir != uploadDir {
+		moved, err := s.files.Move(ctx, path, uploadDir)
+		if err != nil {
+			s.discardFile(ctx, path)
+			return nil, err
+		}
+		path = moved
+	}
 
 	now := s.now()
-	meta, err := domain.NewRoomFile(path, filename, size, now)
+	meta, err := domain.NewRoomFile(path, filename, saved.Size, saved.SHA256, now)
 	if err != nil {
-		_ = s.files.Delete(ctx, path)
+		s.discardFile(ctx, path)
 		return nil, err
 	}
+	meta.Folder = opts.Folder
+	meta.ContentType = contentType
+	meta.Scan = verdict
+	meta.Sanitization = sanitization
+	if opts.Lifespan > 0 {
+		meta.ExpiresAt = now.Add(opts.Lifespan)
+	}
+	meta.MaxDownloads = opts.MaxDownloads
 
-	ok, err := s.rooms.AddFileByToken(ctx, roomId, token, meta)
+	added, err := s.addFile(ctx, room.ID, token, previous, meta)
+	if err != nil {
+		s.discardFile(ctx, path)
+		return nil, err
+	}
+
+	quota.charge(meta.Size)
+	return added, nil
+}
+
+// replacedFile is the file a new upload to folder/name becomes a version of:
+// versionOf when set, otherwise a same-named file in a versioning room.
+func (s *Service) replacedFile(room *domain.Room, versionOf uuid.UUID, folder, name string) (*domain.RoomFile, error) {
+	if versionOf != uuid.Nil {
+		f, ok := room.GetFile(versionOf)
+		if !ok || f == nil || f.IsExpired(s.now()) {
+			return nil, domain.ErrFileNotFound
+		}
+		return f, nil
+	}
+	if room.Versioning {
+		if f, ok := room.FindFile(folder, name); ok && !f.IsExpired(s.now()) {
+			return f, nil
+		}
+	}
+	return nil, nil
+}
+
+// addFile stores meta in the room, as a new version of previous when set.
+// The repository holds the room to the quota as it adds the file, so
+// concurrent uploads cannot overshoot it together.
+func (s *Service) addFile(ctx context.Context, roomId uuid.UUID, token string, previous, meta *domain.RoomFile) (*domain.RoomFile, error) {
+	limits := s.Policy().RoomLimits()
+	if previous != nil {
+		versioned, ok, err := s.rooms.AddFileVersionByToken(ctx, roomId, previous.ID, token, meta, limits)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, domain.ErrFileNotFound
+		}
+		return versioned, nil
+	}
+
+	ok, err := s.rooms.AddFileByToken(ctx, roomId, token, meta, limits)
 	if err != nil {
-		_ = s.files.Delete(ctx, path)
 		return nil, err
 	}
 	if !ok {
-		_ = s.files.Delete(ctx, path)
 		return nil, domain.ErrRoomNotFound
 	}
-
 	return meta, nil
 }
 
-func (s *Service) DeleteFile(ctx context.Context, roomId, fileId uuid.UUID, token string) error {
+func (s *Service) DeleteFile(ctx context.Context, roomId, fileId uuid.UUID, token string) (err error) {
+	ctx, span := tracing.Start(ctx, "Service.DeleteFile", roomAttr(roomId), fileAttr(fileId))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditFileDelete, RoomID: roomId, FileID: fileId, TokenFingerprint: domain.TokenFingerprint(token)}
+	defer func() { s.audit(ctx, &rec, err) }()
+
 	if err := ctx.Err(); err != nil {
 		return err
 	}
@@ -350,7 +695,7 @@ func (s *Service) DeleteFile(ctx context.Context, roomId, fileId uuid.UUID, toke
 		return domain.ErrEmptyToken
 	}
 
-	path, ok, err := s.rooms.DeleteFileByToken(ctx, roomId, fileId, token)
+	paths, ok, err := s.rooms.DeleteFileByToken(ctx, roomId, fileId, token)
 	if err != nil {
 		return err
 	}
@@ -358,14 +703,18 @@ func (s *Service) DeleteFile(ctx context.Context, roomId, fileId uuid.UUID, toke
 		return domain.ErrFileNotFound
 	}
 
-	if err := s.files.Delete(ctx, path); err != nil {
+	if err := s.removeFiles(ctx, paths); err != nil {
 		return err
 	}
 
+	slog.InfoContext(ctx, "file deleted", slog.String("room_id", roomId.String()), slog.String("file_id", fileId.String()))
 	return nil
 }
 
-func (s *Service) CleanupExpired(ctx context.Context) ([]uuid.UUID, error) {
+func (s *Service) CleanupExpired(ctx context.Context) (_ []uuid.UUID, err error) {
+	ctx, span := tracing.Start(ctx, "Service.CleanupExpired")
+	defer func() { span.Finish(err) }()
+
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
@@ -385,7 +734,7 @@ func (s *Service) CleanupExpired(ctx context.Context) ([]uuid.UUID, error) {
 			if strings.TrimSpace(path) == "" {
 				continue
 			}
-			if err := s.files.Delete(ctx, path); err != nil {
+			if err := s.removeFile(ctx, path); err != nil {
 				joined = errors.Join(joined, err)
 			}
 		}
@@ -393,3 +742,226 @@ func (s *Service) CleanupExpired(ctx context.Context) ([]uuid.UUID, error) {
 
 	return deleted, joined
 }
+
+// CleanupExpiredFiles removes the files whose own lifespan ended before
+// their room's.
+func (s *Service) CleanupExpiredFiles(ctx context.Context) (_ []domain.ExpiredFile, err error) {
+	ctx, span := tracing.Start(ctx, "Service.CleanupExpiredFiles")
+	defer func() { span.Finish(err) }()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	expired, err := s.rooms.DeleteExpiredFiles(ctx, s.now())
+	if err != nil {
+		return nil, err
+	}
+
+	var joined error
+	for _, f := range expired {
+		if err := s.removeFiles(ctx, f.Paths); err != nil {
+			joined = errors.Join(joined, err)
+		}
+	}
+	return expired, joined
+}
+
+// RemoveOrphanFiles deletes stored blobs no room references any more, such as
+// leftovers of interrupted uploads. Files younger than grace are kept so
+// uploads still in flight are not touched.
+func (s *Service) RemoveOrphanFiles(ctx context.Context, grace time.Duration) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "Service.RemoveOrphanFiles")
+	defer func() { span.Finish(err) }()
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	uploadDir := s.Policy().UploadDir
+	stored, err := s.files.List(ctx, uploadDir)
+	if err != nil {
+		return 0, err
+	}
+	// Quarantined uploads are never referenced by a room.
+	quarantined, err := s.files.List(ctx, quarantineDir(uploadDir))
+	if err != nil {
+		return 0, err
+	}
+	stored = append(stored, quarantined...)
+	if len(stored) == 0 {
+		return 0, nil
+	}
+
+	rooms, err := s.rooms.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	referenced := make(map[string]struct{})
+	for _, room := range rooms {
+		for _, f := range room.Files {
+			for _, path := range f.Paths() {
+				referenced[filepath.Clean(path)] = struct{}{}
+				for _, size := range ThumbnailSizes {
+					referenced[filepath.Clean(thumbnailPath(path, size))] = struct{}{}
+				}
+			}
+		}
+	}
+
+	cutoff := s.now().Add(-grace)
+	removed := 0
+	var joined error
+	for _, f := range stored {
+		if _, ok := referenced[filepath.Clean(f.Path)]; ok || f.ModTime.After(cutoff) {
+			continue
+		}
+		if err := s.files.Delete(ctx, f.Path); err != nil {
+			joined = errors.Join(joined, err)
+			continue
+		}
+		removed++
+	}
+
+	span.SetAttributes(tracing.Int("files.removed", removed))
+	return removed, joined
+}
+
+// scan runs the content scanner over a quarantined upload.
+func (s *Service) scan(ctx context.Context, path string) (domain.ScanVerdict, error) {
+	if s.scanner == nil {
+		return domain.ScanVerdict{Status: domain.ScanStatusUnscanned}, nil
+	}
+
+	rc, err := s.files.Open(ctx, path)
+	if err != nil {
+		return domain.ScanVerdict{}, err
+	}
+	defer rc.Close()
+
+	result, err := s.scanner.Scan(ctx, rc)
+	verdict := domain.ScanVerdict{Scanner: s.scanner.Name(), ScannedAt: s.now()}
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return verdict, ctxErr
+		}
+		if !s.failOpen {
+			return verdict, fmt.Errorf("%w: %w", ports.ErrScanFailed, err)
+		}
+		slog.WarnContext(ctx, "content scan failed, accepting upload unverified", slog.String("scanner", verdict.Scanner), slog.Any("error", err))
+		verdict.Status = domain.ScanStatusUnverified
+		return verdict, nil
+	}
+	if result.Infected {
+		slog.WarnContext(ctx, "upload rejected by content scanner", slog.String("scanner", verdict.Scanner), slog.String("signature", result.Signature))
+		return verdict, fmt.Errorf("%w: %s", ports.ErrFileInfected, result.Signature)
+	}
+
+	verdict.Status = domain.ScanStatusClean
+	return verdict, nil
+}
+
+// detectContentType prefers the sniffed type and falls back to the extension
+// when sniffing found nothing more specific than binary data, plain text or
+// XML. SVG and scripts sniff as text, so their extension says more.
+func detectContentType(sniffed, filename string) string {
+	switch domain.MediaType(sniffed) {
+	case "", "application/octet-stream", "text/plain", "text/xml", "application/xml":
+		if byExt := extensionType(filename); byExt != "" {
+			return byExt
+		}
+	default:
+		return sniffed
+	}
+	if sniffed != "" {
+		return sniffed
+	}
+	return "application/octet-stream"
+}
+
+func extensionType(filename string) string {
+	return mime.TypeByExtension(strings.ToLower(filepath.Ext(filename)))
+}
+
+func checkName(filename string, rules ...domain.ContentRules) error {
+	for _, r := range rules {
+		if err := r.CheckName(filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkType applies the type lists to the detected content type and to the
+// type the file's extension implies, so content cannot slip past a denied
+// type by sniffing as something more generic.
+func checkType(contentType, filename string, rules ...domain.ContentRules) error {
+	byExt := extensionType(filename)
+	for _, r := range rules {
+		if err := r.CheckType(contentType); err != nil {
+			return err
+		}
+		if byExt != "" {
+			if err := r.CheckType(byExt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// quarantineDir holds uploads while they are scanned. It sits inside the
+// upload directory so moving a file out of it is a rename.
+func quarantineDir(uploadDir string) string {
+	return filepath.Join(uploadDir, ".quarantine")
+}
+
+// discardFile removes a stored blob that never made it into a room.
+func (s *Service) discardFile(ctx context.Context, path string) {
+	if err := s.files.Delete(ctx, path); err != nil {
+		slog.WarnContext(ctx, "cannot remove orphaned file", slog.String("path", path), slog.Any("error", err))
+	}
+}
+
+func roomAttr(id uuid.UUID) tracing.Attr {
+	return tracing.String("room.id", id.String())
+}
+
+func fileAttr(id uuid.UUID) tracing.Attr {
+	return tracing.String("file.id", id.String())
+}
+
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// checkPage defaults the page size and rejects a malformed cursor before it
+// reaches the repository.
+func checkPage(limit *int, cursor string) error {
+	if *limit < 0 || *limit > MaxPageSize {
+		return ports.ErrInvalidQuery
+	}
+	if *limit == 0 {
+		*limit = DefaultPageSize
+	}
+	if cursor != "" {
+		if _, err := ports.ParseCursor(cursor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeHook runs after once the wrapped reader is closed.
+type closeHook struct {
+	io.ReadCloser
+	after func()
+	once  sync.Once
+}
+
+func (c *closeHook) Close() error {
+	err := c.ReadCloser.Close()
+	c.once.Do(c.after)
+	return err
+}
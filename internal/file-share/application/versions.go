@@ -0,0 +1,54 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/tracing"
+	"github.com/google/uuid"
+)
+
+// FileVersions lists every version of a file, the newest first.
+func (s *Service) FileVersions(ctx context.Context, roomId, fileId uuid.UUID, token string) (_ *domain.RoomFile, _ []domain.FileVersion, err error) {
+	ctx, span := tracing.Start(ctx, "Service.FileVersions", roomAttr(roomId), fileAttr(fileId))
+	defer func() { span.Finish(err) }()
+
+	file, err := s.File(ctx, roomId, fileId, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	versions := file.AllVersions()
+	slices.Reverse(versions)
+	return file, versions, nil
+}
+
+// DownloadFileVersion opens one version of a file. Files with a download
+// limit only hand out their current content, through DownloadFile.
+func (s *Service) DownloadFileVersion(ctx context.Context, roomId, fileId uuid.UUID, token string, version int) (_ *domain.RoomFile, _ domain.FileVersion, _ io.ReadCloser, err error) {
+	ctx, span := tracing.Start(ctx, "Service.DownloadFileVersion", roomAttr(roomId), fileAttr(fileId), tracing.Int("file.version", version))
+	defer func() { span.Finish(err) }()
+
+	rec := ports.AuditRecord{Action: ports.AuditFileDownload, RoomID: roomId, FileID: fileId, TokenFingerprint: domain.TokenFingerprint(token), Detail: fmt.Sprintf("version %d", version)}
+	defer func() { s.audit(ctx, &rec, err) }()
+
+	file, err := s.contentFile(ctx, roomId, fileId, token)
+	if err != nil {
+		return nil, domain.FileVersion{}, nil, err
+	}
+
+	v, ok := file.FindVersion(version)
+	if !ok {
+		return nil, domain.FileVersion{}, nil, domain.ErrVersionNotFound
+	}
+
+	rc, err := s.files.Open(ctx, v.Path)
+	if err != nil {
+		return nil, domain.FileVersion{}, nil, err
+	}
+	return file, v, rc, nil
+}
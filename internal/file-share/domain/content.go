@@ -0,0 +1,151 @@
+package domain
+
+import (
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+// ContentRules restricts uploads by MIME type and file extension. Types may
+// end in a wildcard subtype such as image/*. Deny entries win over allow
+// entries and an empty allow list allows everything.
+type ContentRules struct {
+	AllowTypes      []string
+	DenyTypes       []string
+	AllowExtensions []string
+	DenyExtensions  []string
+}
+
+// NewContentRules normalises the lists: types are lowercased and
+// extensions get a leading dot.
+func NewContentRules(allowTypes, denyTypes, allowExtensions, denyExtensions []string) (ContentRules, error) {
+	var rules ContentRules
+	var err error
+	if rules.AllowTypes, err = normalizeTypes(allowTypes); err != nil {
+		return ContentRules{}, err
+	}
+	if rules.DenyTypes, err = normalizeTypes(denyTypes); err != nil {
+		return ContentRules{}, err
+	}
+	rules.AllowExtensions = normalizeExtensions(allowExtensions)
+	rules.DenyExtensions = normalizeExtensions(denyExtensions)
+	return rules, nil
+}
+
+func (c ContentRules) IsZero() bool {
+	return len(c.AllowTypes) == 0 && len(c.DenyTypes) == 0 && len(c.AllowExtensions) == 0 && len(c.DenyExtensions) == 0
+}
+
+// CheckName applies the extension lists to filename.
+func (c ContentRules) CheckName(filename string) error {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if contains(c.DenyExtensions, ext) {
+		return fmt.Errorf("%w: extension %q is denied", ErrFileTypeNotAllowed, ext)
+	}
+	if len(c.AllowExtensions) > 0 && !contains(c.AllowExtensions, ext) {
+		return fmt.Errorf("%w: extension %q is not allowed", ErrFileTypeNotAllowed, ext)
+	}
+	return nil
+}
+
+// CheckType applies the MIME type lists to a detected content type.
+func (c ContentRules) CheckType(contentType string) error {
+	mediaType := MediaType(contentType)
+	if matchesType(c.DenyTypes, mediaType) {
+		return fmt.Errorf("%w: type %q is denied", ErrFileTypeNotAllowed, mediaType)
+	}
+	if len(c.AllowTypes) > 0 && !matchesType(c.AllowTypes, mediaType) {
+		return fmt.Errorf("%w: type %q is not allowed", ErrFileTypeNotAllowed, mediaType)
+	}
+	return nil
+}
+
+// MediaType strips parameters such as charset from a content type.
+func MediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, _, _ = strings.Cut(contentType, ";")
+	}
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+// IsActiveContentType reports types a browser would execute or render with
+// scripts, which must never be served inline.
+func IsActiveContentType(contentType string) bool {
+	switch mediaType := MediaType(contentType); mediaType {
+	case "text/html", "application/xhtml+xml", "image/svg+xml",
+		"text/xml", "application/xml", "text/javascript", "application/javascript",
+		"application/ecmascript", "text/ecmascript", "application/x-shockwave-flash",
+		"application/pdf", "text/xsl", "":
+		return true
+	default:
+		return strings.HasSuffix(mediaType, "+xml")
+	}
+}
+
+func normalizeTypes(types []string) ([]string, error) {
+	var out []string
+	for _, t := range types {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" {
+			continue
+		}
+		major, minor, ok := strings.Cut(t, "/")
+		if !ok || major == "" || minor == "" || major == "*" {
+			return nil, fmt.Errorf("%w: %q is not a MIME type", ErrInvalidContentRules, t)
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func normalizeExtensions(extensions []string) []string {
+	var out []string
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		out = append(out, ext)
+	}
+	return out
+}
+
+// typeAliases groups media types that name the same content, so that a rule
+// for one of them applies to all.
+var typeAliases = [][]string{
+	{"text/javascript", "application/javascript", "application/x-javascript", "application/ecmascript", "text/ecmascript"},
+	{"text/xml", "application/xml"},
+}
+
+func matchesType(patterns []string, mediaType string) bool {
+	names := []string{mediaType}
+	for _, group := range typeAliases {
+		if contains(group, mediaType) {
+			names = group
+			break
+		}
+	}
+	for _, name := range names {
+		major, _, _ := strings.Cut(name, "/")
+		for _, p := range patterns {
+			if p == name || p == major+"/*" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -8,3 +8,10 @@ type ExpiredCleanup struct {
 	RoomID uuid.UUID
 	Paths  []string
 }
+
+// ExpiredFile is a file removed on its own before its room.
+type ExpiredFile struct {
+	RoomID uuid.UUID
+	FileID uuid.UUID
+	Paths  []string
+}
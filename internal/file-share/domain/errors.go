@@ -12,9 +12,24 @@ var (
 	ErrEmptyToken           = errors.New("token is empty")
 	ErrTokenLifespanTooLong = errors.New("token lifespan too long")
 
-	ErrFileNotFound = errors.New("file not found")
-	ErrInvalidFile  = errors.New("invalid file")
+	ErrFileNotFound        = errors.New("file not found")
+	ErrInvalidFile         = errors.New("invalid file")
+	ErrInvalidFileLimits   = errors.New("file lifespan and download limit must not be negative")
+	ErrFileDownloadLimited = errors.New("file with a download limit can only be downloaded")
+	ErrVersionNotFound     = errors.New("file version not found")
+	ErrInvalidDescription  = errors.New("invalid file description")
+	ErrInvalidMessage      = errors.New("invalid message")
+
+	ErrFileTypeNotAllowed  = errors.New("file type not allowed")
+	ErrInvalidContentRules = errors.New("invalid content rules")
 
 	ErrRoomLifespanTooLong = errors.New("room lifespan too long")
 	ErrRoomNotFound        = errors.New("room not found")
+	ErrRoomQuotaExceeded   = errors.New("room file or size limit reached")
+	ErrTargetAccessDenied  = errors.New("no access to the target room")
+	ErrSameRoom            = errors.New("file is already in that room")
+
+	ErrInvalidFolder  = errors.New("invalid folder path")
+	ErrFolderNotFound = errors.New("folder not found")
+	ErrFolderExists   = errors.New("folder already exists")
 )
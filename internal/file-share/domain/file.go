@@ -2,20 +2,77 @@ package domain
 
 import (
 	"path/filepath"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const (
+	ScanStatusUnscanned = "unscanned"
+	ScanStatusClean     = "clean"
+	// ScanStatusUnverified marks files let through because the scanner failed
+	// and scanning is configured to fail open.
+	ScanStatusUnverified = "unverified"
+)
+
+// ScanVerdict records how the content scanner judged a file before it was
+// added to its room.
+type ScanVerdict struct {
+	Status    string
+	Scanner   string
+	ScannedAt time.Time
+}
+
+// Sanitization records that identifying metadata was stripped from an image
+// on upload. OriginalSize and OriginalSHA256 describe the file as it was sent.
+type Sanitization struct {
+	Applied        bool
+	OriginalSize   int64
+	OriginalSHA256 string
+}
+
+// FileVersion is an earlier content of a file, kept when an upload replaced
+// it.
+type FileVersion struct {
+	Version     int
+	Path        string
+	Size        int64
+	SHA256      string
+	ContentType string
+	CreatedAt   time.Time
+}
+
 type RoomFile struct {
-	ID        uuid.UUID
-	Path      string
-	Name      string
-	Size      int64
-	CreatedAt time.Time
+	ID   uuid.UUID
+	Path string
+	// Folder is the normalized virtual folder holding the file, "" for the
+	// room root. Path is where the content is stored and is unrelated.
+	Folder string
+	Name   string
+	Size   int64
+	SHA256 string
+	// ContentType is detected from the content, or the extension when the
+	// content is not recognised.
+	ContentType  string
+	CreatedAt    time.Time
+	Scan         ScanVerdict
+	Sanitization Sanitization
+	// ExpiresAt removes the file before its room when set.
+	ExpiresAt time.Time
+	// MaxDownloads removes the file after that many downloads; 0 allows any
+	// number. Downloads counts the downloads so far.
+	MaxDownloads int
+	Downloads    int
+	// Version numbers the uploads of the file from 1; Versions holds the
+	// earlier ones, oldest first. Files stored before versioning have 0.
+	Version  int
+	Versions []FileVersion
+	// Description is a free text note shown next to the file.
+	Description string
 }
 
-func NewRoomFile(path, name string, size int64, now time.Time) (*RoomFile, error) {
+func NewRoomFile(path, name string, size int64, sha256 string, now time.Time) (*RoomFile, error) {
 	if path == "" || name == "" || size <= 0 {
 		return nil, ErrInvalidFile
 	}
@@ -27,6 +84,95 @@ func NewRoomFile(path, name string, size int64, now time.Time) (*RoomFile, error
 		Path:      path,
 		Name:      safeName,
 		Size:      size,
+		SHA256:    sha256,
 		CreatedAt: now,
+		Scan:      ScanVerdict{Status: ScanStatusUnscanned},
+		Version:   1,
 	}, nil
 }
+
+// FullName is the file's path within the room, folder included.
+func (f *RoomFile) FullName() string {
+	return JoinFolder(f.Folder, f.Name)
+}
+
+func (f *RoomFile) IsExpired(now time.Time) bool {
+	return !f.ExpiresAt.IsZero() && now.After(f.ExpiresAt)
+}
+
+// Limited reports whether the file has a download limit. Its content is then
+// only handed out through counted downloads.
+func (f *RoomFile) Limited() bool {
+	return f.MaxDownloads > 0
+}
+
+// Exhausted reports whether the file has no downloads left.
+func (f *RoomFile) Exhausted() bool {
+	return f.Limited() && f.Downloads >= f.MaxDownloads
+}
+
+// CurrentVersion describes the file's current content as a version.
+func (f *RoomFile) CurrentVersion() FileVersion {
+	return FileVersion{
+		Version:     max(f.Version, 1),
+		Path:        f.Path,
+		Size:        f.Size,
+		SHA256:      f.SHA256,
+		ContentType: f.ContentType,
+		CreatedAt:   f.CreatedAt,
+	}
+}
+
+// AllVersions lists every version of the file, the current one last.
+func (f *RoomFile) AllVersions() []FileVersion {
+	return append(slices.Clone(f.Versions), f.CurrentVersion())
+}
+
+// FindVersion returns version n of the file, the current one included.
+func (f *RoomFile) FindVersion(n int) (FileVersion, bool) {
+	for _, v := range f.AllVersions() {
+		if v.Version == n {
+			return v, true
+		}
+	}
+	return FileVersion{}, false
+}
+
+// AddVersion makes the content of next the current one, keeping the old
+// content as a version. The file keeps its ID, name and folder; limits come
+// from next and the download count starts over.
+func (f *RoomFile) AddVersion(next *RoomFile) {
+	f.Versions = f.AllVersions()
+	f.Version = max(f.Version, 1) + 1
+
+	f.Path = next.Path
+	f.Size = next.Size
+	f.SHA256 = next.SHA256
+	f.ContentType = next.ContentType
+	f.CreatedAt = next.CreatedAt
+	f.Scan = next.Scan
+	f.Sanitization = next.Sanitization
+	f.ExpiresAt = next.ExpiresAt
+	f.MaxDownloads = next.MaxDownloads
+	f.Downloads = 0
+}
+
+// Paths are where all versions of the file are stored.
+func (f *RoomFile) Paths() []string {
+	paths := make([]string, 0, len(f.Versions)+1)
+	for _, v := range f.AllVersions() {
+		if v.Path != "" {
+			paths = append(paths, v.Path)
+		}
+	}
+	return paths
+}
+
+// StoredSize is the size of all versions of the file together.
+func (f *RoomFile) StoredSize() int64 {
+	size := f.Size
+	for _, v := range f.Versions {
+		size += v.Size
+	}
+	return size
+}
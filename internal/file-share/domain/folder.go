@@ -0,0 +1,186 @@
+package domain
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxFolderDepth   = 32
+	maxFolderLength  = 1024
+	maxSegmentLength = 255
+)
+
+// NormalizeFolder turns a client supplied folder path into its canonical
+// form: slash separated, no leading or trailing slash, no empty, "." or ".."
+// segments. The root folder is "".
+func NormalizeFolder(folder string) (string, error) {
+	folder = strings.TrimSpace(folder)
+	if strings.ContainsRune(folder, '\\') {
+		return "", ErrInvalidFolder
+	}
+
+	parts := strings.Split(strings.Trim(folder, "/"), "/")
+	out := parts[:0]
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !validSegment(part) {
+			return "", ErrInvalidFolder
+		}
+		out = append(out, part)
+	}
+	if len(out) > maxFolderDepth {
+		return "", ErrInvalidFolder
+	}
+
+	normalized := strings.Join(out, "/")
+	if len(normalized) > maxFolderLength {
+		return "", ErrInvalidFolder
+	}
+	return normalized, nil
+}
+
+// ValidFolderName reports whether name can be a single folder segment.
+func ValidFolderName(name string) bool {
+	return validSegment(name)
+}
+
+func validSegment(part string) bool {
+	if part == "." || part == ".." || len(part) > maxSegmentLength || strings.ContainsRune(part, '/') {
+		return false
+	}
+	return !strings.ContainsFunc(part, unicode.IsControl)
+}
+
+// JoinFolder appends a segment or relative path to a normalized folder.
+func JoinFolder(parent, child string) string {
+	if parent == "" {
+		return child
+	}
+	if child == "" {
+		return parent
+	}
+	return parent + "/" + child
+}
+
+// ParentFolder is the folder containing folder; the root is its own parent.
+func ParentFolder(folder string) string {
+	i := strings.LastIndexByte(folder, '/')
+	if i < 0 {
+		return ""
+	}
+	return folder[:i]
+}
+
+// FolderName is the last segment of folder.
+func FolderName(folder string) string {
+	return folder[strings.LastIndexByte(folder, '/')+1:]
+}
+
+// InFolder reports whether path is folder itself or lies somewhere below it.
+func InFolder(path, folder string) bool {
+	return folder == "" || path == folder || strings.HasPrefix(path, folder+"/")
+}
+
+// FolderAncestors lists folder and every folder above it, root excluded,
+// outermost first.
+func FolderAncestors(folder string) []string {
+	if folder == "" {
+		return nil
+	}
+	var out []string
+	for i, c := range folder {
+		if c == '/' {
+			out = append(out, folder[:i])
+		}
+	}
+	return append(out, folder)
+}
+
+// MoveFolderPath rewrites path, which lies in from, to the same place in to.
+func MoveFolderPath(path, from, to string) string {
+	return to + strings.TrimPrefix(path, from)
+}
+
+func (r *Room) HasFolder(folder string) bool {
+	if folder == "" {
+		return true
+	}
+	return r.folders[folder]
+}
+
+// AddFolder creates folder together with any missing parents.
+func (r *Room) AddFolder(folder string) {
+	if r.folders == nil {
+		r.folders = make(map[string]bool)
+	}
+	for _, f := range FolderAncestors(folder) {
+		r.folders[f] = true
+	}
+}
+
+// ListFolders returns every folder in the room, sorted.
+func (r *Room) ListFolders() []string {
+	out := make([]string, 0, len(r.folders))
+	for f := range r.folders {
+		out = append(out, f)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// RenameFolder moves from, with everything inside it, to to.
+func (r *Room) RenameFolder(from, to string) error {
+	if from == "" || to == "" || InFolder(to, from) {
+		return ErrInvalidFolder
+	}
+	if !r.HasFolder(from) {
+		return ErrFolderNotFound
+	}
+	if r.HasFolder(to) {
+		return ErrFolderExists
+	}
+
+	for f := range r.folders {
+		if InFolder(f, from) {
+			delete(r.folders, f)
+			r.folders[MoveFolderPath(f, from, to)] = true
+		}
+	}
+	r.AddFolder(to)
+	for _, file := range r.Files {
+		if InFolder(file.Folder, from) {
+			file.Folder = MoveFolderPath(file.Folder, from, to)
+		}
+	}
+	return nil
+}
+
+// DeleteFolder removes folder with all of its subfolders and files, returning
+// the removed files.
+func (r *Room) DeleteFolder(folder string) ([]*RoomFile, error) {
+	if folder == "" {
+		return nil, ErrInvalidFolder
+	}
+	if !r.HasFolder(folder) {
+		return nil, ErrFolderNotFound
+	}
+
+	for f := range r.folders {
+		if InFolder(f, folder) {
+			delete(r.folders, f)
+		}
+	}
+	var removed []*RoomFile
+	for id, file := range r.Files {
+		if InFolder(file.Folder, folder) {
+			removed = append(removed, file)
+			delete(r.Files, id)
+		}
+	}
+	return removed, nil
+}
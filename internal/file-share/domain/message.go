@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MaxMessageLength     = 2000
+	MaxAuthorLength      = 64
+	MaxDescriptionLength = 1000
+)
+
+// RoomMessage is a note left in a room's message thread. Author is an
+// optional name the sender chose; rooms have no user accounts.
+type RoomMessage struct {
+	ID        uuid.UUID
+	Author    string
+	Text      string
+	CreatedAt time.Time
+}
+
+func NewRoomMessage(author, text string, now time.Time) (*RoomMessage, error) {
+	author = strings.TrimSpace(author)
+	text = strings.TrimSpace(text)
+	if text == "" || !validNote(text, MaxMessageLength) {
+		return nil, ErrInvalidMessage
+	}
+	if !validNote(author, MaxAuthorLength) || strings.ContainsAny(author, "\r\n") {
+		return nil, ErrInvalidMessage
+	}
+
+	return &RoomMessage{
+		ID:        uuid.New(),
+		Author:    author,
+		Text:      text,
+		CreatedAt: now,
+	}, nil
+}
+
+// Size is what the message takes from the room's size quota.
+func (m RoomMessage) Size() int64 {
+	return int64(len(m.Author) + len(m.Text))
+}
+
+// NormalizeDescription trims a file description and checks its length; ""
+// clears the description.
+func NormalizeDescription(description string) (string, error) {
+	description = strings.TrimSpace(description)
+	if !validNote(description, MaxDescriptionLength) {
+		return "", ErrInvalidDescription
+	}
+	return description, nil
+}
+
+// validNote allows up to limit characters of valid UTF-8 text, with line
+// breaks and tabs as the only control characters.
+func validNote(s string, limit int) bool {
+	if !utf8.ValidString(s) || utf8.RuneCountInString(s) > limit {
+		return false
+	}
+	return !strings.ContainsFunc(s, func(r rune) bool {
+		return unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t'
+	})
+}
@@ -8,28 +8,78 @@ type Policy struct {
 	DefaultRoomTTL   time.Duration
 	DefaultTokenTTL  time.Duration
 	MaxFiles         int
+	MaxMessages      int
 	MaxRoomBytes     int64
 	MaxRoomLifespan  time.Duration
 	MaxTokenLifespan time.Duration
 	UploadDir        string
+	// Content applies to every upload, on top of any room's own rules.
+	Content ContentRules
 }
 
 func NewPolicy(
 	defaultRoomTTL time.Duration,
 	defaultTokenTTL time.Duration,
 	maxFiles int,
+	maxMessages int,
 	maxRoomBytes int64,
 	maxRoomLifespan time.Duration,
 	maxTokenLifespan time.Duration,
 	uploadDir string,
+	content ContentRules,
 ) Policy {
 	return Policy{
 		DefaultRoomTTL:   defaultRoomTTL,
 		DefaultTokenTTL:  defaultTokenTTL,
 		MaxFiles:         maxFiles,
+		MaxMessages:      maxMessages,
 		MaxRoomBytes:     maxRoomBytes,
 		MaxRoomLifespan:  maxRoomLifespan,
 		MaxTokenLifespan: maxTokenLifespan,
 		UploadDir:        uploadDir,
+		Content:          content,
 	}
 }
+
+// RoomLimits returns the file, size and message limits every room is held to.
+func (p Policy) RoomLimits() RoomLimits {
+	return RoomLimits{MaxFiles: p.MaxFiles, MaxBytes: p.MaxRoomBytes, MaxMessages: p.MaxMessages}
+}
+
+// RoomLimits caps the stored file versions of a room, its total size as
+// Room.TotalSize counts it and its messages. Zero means no limit.
+type RoomLimits struct {
+	MaxFiles    int
+	MaxBytes    int64
+	MaxMessages int
+}
+
+func (l RoomLimits) IsZero() bool {
+	return l.MaxFiles <= 0 && l.MaxBytes <= 0 && l.MaxMessages <= 0
+}
+
+// Check fails with ErrRoomQuotaExceeded when a room storing files versions
+// of size bytes in total is over the limits.
+func (l RoomLimits) Check(files int, size int64) error {
+	if l.MaxFiles > 0 && files > l.MaxFiles {
+		return ErrRoomQuotaExceeded
+	}
+	return l.CheckSize(size)
+}
+
+// CheckSize is Check for writes that add no file versions.
+func (l RoomLimits) CheckSize(size int64) error {
+	if l.MaxBytes > 0 && size > l.MaxBytes {
+		return ErrRoomQuotaExceeded
+	}
+	return nil
+}
+
+// CheckMessages fails with ErrRoomQuotaExceeded when a room holding
+// messages messages is over the limit.
+func (l RoomLimits) CheckMessages(messages int) error {
+	if l.MaxMessages > 0 && messages > l.MaxMessages {
+		return ErrRoomQuotaExceeded
+	}
+	return nil
+}
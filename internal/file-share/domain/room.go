@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -8,10 +9,21 @@ import (
 
 type Room struct {
 	ID        uuid.UUID
+	CreatedAt time.Time
 	ExpiresAt time.Time
 	Files     map[uuid.UUID]*RoomFile
+	// Content narrows what may be uploaded to this room.
+	Content ContentRules
+	// SanitizeImages strips metadata from every image uploaded to the room.
+	SanitizeImages bool
+	// Versioning turns an upload named like an existing file into a new
+	// version of that file.
+	Versioning bool
+	// Messages is the room's message thread, oldest first.
+	Messages []RoomMessage
 
 	tokens   map[string]bool
+	folders  map[string]bool
 	password string
 }
 
@@ -27,9 +39,11 @@ func NewRoom(hashedPassword string, lifespan time.Duration) (*Room, error) {
 
 	r := &Room{
 		ID:        uuid.New(),
+		CreatedAt: now,
 		ExpiresAt: now.Add(lifespan),
 		Files:     make(map[uuid.UUID]*RoomFile),
 		tokens:    make(map[string]bool, 1),
+		folders:   make(map[string]bool),
 		password:  hashedPassword,
 	}
 
@@ -42,6 +56,7 @@ func HydrateRoom(id uuid.UUID, passwordHash string, expiresAt time.Time) *Room {
 		ExpiresAt: expiresAt,
 		Files:     make(map[uuid.UUID]*RoomFile),
 		tokens:    make(map[string]bool),
+		folders:   make(map[string]bool),
 		password:  passwordHash,
 	}
 }
@@ -106,6 +121,43 @@ func (r *Room) ListFiles() []*RoomFile {
 	return files
 }
 
+// TotalSize is the stored size of all files in the room, versions included,
+// together with their descriptions and the room's messages.
+func (r *Room) TotalSize() int64 {
+	var total int64
+	for _, f := range r.Files {
+		total += f.StoredSize() + int64(len(f.Description))
+	}
+	for _, m := range r.Messages {
+		total += m.Size()
+	}
+	return total
+}
+
+// StoredFiles counts the stored versions of all files in the room.
+func (r *Room) StoredFiles() int {
+	n := 0
+	for _, f := range r.Files {
+		n += len(f.Versions) + 1
+	}
+	return n
+}
+
+// FindFile returns the file called name in folder, the newest one when the
+// room holds several.
+func (r *Room) FindFile(folder, name string) (*RoomFile, bool) {
+	var found *RoomFile
+	for _, f := range r.Files {
+		if f == nil || f.Folder != folder || f.Name != name {
+			continue
+		}
+		if found == nil || f.CreatedAt.After(found.CreatedAt) {
+			found = f
+		}
+	}
+	return found, found != nil
+}
+
 func (r *Room) ListTokens() []string {
 	if r.tokens == nil {
 		return nil
@@ -125,6 +177,7 @@ func (r *Room) AddFile(file *RoomFile) error {
 		r.Files = make(map[uuid.UUID]*RoomFile)
 	}
 	r.Files[file.ID] = file
+	r.AddFolder(file.Folder)
 	return nil
 }
 
@@ -142,6 +195,33 @@ func (r *Room) DeleteFile(id uuid.UUID) (*RoomFile, error) {
 	return file, nil
 }
 
+// CountDownload records a download of file id and removes the file when it
+// was the last one allowed. Expired and used up files are not found.
+func (r *Room) CountDownload(id uuid.UUID, now time.Time) (*RoomFile, error) {
+	file, ok := r.GetFile(id)
+	if !ok || file == nil || file.IsExpired(now) || file.Exhausted() {
+		return nil, ErrFileNotFound
+	}
+
+	file.Downloads++
+	if file.Exhausted() {
+		delete(r.Files, id)
+	}
+	return file, nil
+}
+
+// DeleteExpiredFiles removes the files whose own expiry has passed.
+func (r *Room) DeleteExpiredFiles(now time.Time) []*RoomFile {
+	var removed []*RoomFile
+	for id, f := range r.Files {
+		if f != nil && f.IsExpired(now) {
+			removed = append(removed, f)
+			delete(r.Files, id)
+		}
+	}
+	return removed
+}
+
 func (r *Room) IsExpired(now time.Time) bool {
 	return now.After(r.ExpiresAt)
 }
@@ -153,10 +233,17 @@ func (r *Room) Clone() *Room {
 
 	cp := &Room{
 		ID:        r.ID,
+		CreatedAt: r.CreatedAt,
 		ExpiresAt: r.ExpiresAt,
 		Files:     make(map[uuid.UUID]*RoomFile, len(r.Files)),
 		tokens:    make(map[string]bool, len(r.tokens)),
+		folders:   make(map[string]bool, len(r.folders)),
 		password:  r.password,
+
+		Content:        r.Content,
+		SanitizeImages: r.SanitizeImages,
+		Versioning:     r.Versioning,
+		Messages:       slices.Clone(r.Messages),
 	}
 
 	for id, f := range r.Files {
@@ -164,12 +251,16 @@ func (r *Room) Clone() *Room {
 			continue
 		}
 		ff := *f
+		ff.Versions = slices.Clone(f.Versions)
 		cp.Files[id] = &ff
 	}
 
 	for t := range r.tokens {
 		cp.tokens[t] = true
 	}
+	for f := range r.folders {
+		cp.folders[f] = true
+	}
 
 	return cp
 }
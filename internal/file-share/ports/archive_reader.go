@@ -0,0 +1,49 @@
+package ports
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+type ArchiveEntry struct {
+	// Name is the path recorded in the archive, with forward slashes.
+	Name string
+	Size int64
+	// PackedSize is the compressed size, 0 when the format does not record
+	// one per entry.
+	PackedSize int64
+	ModTime    time.Time
+	Dir        bool
+	// Regular is false for links, devices and other special entries.
+	Regular bool
+}
+
+// ArchiveReader lists and reads archives without unpacking them to disk.
+type ArchiveReader interface {
+	// Format names the archive format of a file, "" when it is not one.
+	Format(name, contentType string) string
+	// Walk calls fn for each entry in archive order. open reads the entry and
+	// is only valid until fn returns. Formats with an index need r to be an
+	// io.ReaderAt of the given size.
+	Walk(ctx context.Context, format string, r io.Reader, size int64, fn func(entry ArchiveEntry, open func() (io.Reader, error)) error) error
+}
+
+// PackEntry is one file or, when Dir is set, one empty folder to pack.
+type PackEntry struct {
+	// Name is the path inside the archive, with forward slashes.
+	Name    string
+	ModTime time.Time
+	Dir     bool
+	Open    func() (io.ReadCloser, error)
+}
+
+// ArchiveWriter packs room content into a single ZIP archive.
+type ArchiveWriter interface {
+	Pack(ctx context.Context, w io.Writer, entries []PackEntry) error
+}
+
+type Archiver interface {
+	ArchiveReader
+	ArchiveWriter
+}
@@ -0,0 +1,99 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AuditRoomCreate     = "room.create"
+	AuditRoomDelete     = "room.delete"
+	AuditRoomAuth       = "room.auth"
+	AuditRoomLogout     = "room.logout"
+	AuditRoomExport     = "room.export"
+	AuditRoomImport     = "room.import"
+	AuditFileUpload     = "file.upload"
+	AuditFileDownload   = "file.download"
+	AuditFileDelete     = "file.delete"
+	AuditFileExtract    = "file.extract"
+	AuditFileDescribe   = "file.describe"
+	AuditFileCopy       = "file.copy"
+	AuditFileMove       = "file.move"
+	AuditMessagePost    = "message.post"
+	AuditFolderCreate   = "folder.create"
+	AuditFolderMove     = "folder.move"
+	AuditFolderDelete   = "folder.delete"
+	AuditFolderDownload = "folder.download"
+	AuditAdminRoom      = "admin.room.delete"
+	AuditAdminFile      = "admin.file.delete"
+	AuditAdminTokens    = "admin.tokens.revoke"
+	AuditDirectSend     = "direct.send"
+	AuditDirectReceive  = "direct.receive"
+	AuditBroadcastSend  = "broadcast.send"
+	AuditBroadcastJoin  = "broadcast.join"
+)
+
+const (
+	AuditResultOK     = "ok"
+	AuditResultDenied = "denied"
+	AuditResultError  = "error"
+)
+
+const (
+	AuditActorAnonymous = "anonymous"
+	AuditActorAdmin     = "admin"
+)
+
+// AuditRecord describes one security relevant action. Tokens are only ever
+// stored as fingerprints.
+type AuditRecord struct {
+	Time             time.Time
+	Action           string
+	Result           string
+	Actor            string
+	ActorIP          string
+	TokenFingerprint string
+	RoomID           uuid.UUID
+	FileID           uuid.UUID
+	RequestID        string
+	Detail           string
+}
+
+type AuditQuery struct {
+	Since  time.Time
+	Until  time.Time
+	Action string
+	RoomID uuid.UUID
+	// Limit caps the result, newest records first.
+	Limit int
+}
+
+type AuditSink interface {
+	Record(ctx context.Context, record AuditRecord) error
+	Query(ctx context.Context, query AuditQuery) ([]AuditRecord, error)
+	// Prune drops records older than before and reports how many went.
+	Prune(ctx context.Context, before time.Time) (int, error)
+	Close() error
+}
+
+// Actor is who is calling, as seen by the HTTP layer.
+type Actor struct {
+	Name string
+	IP   string
+}
+
+type actorKey struct{}
+
+func ContextWithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	if actor.Name == "" {
+		actor.Name = AuditActorAnonymous
+	}
+	return actor
+}
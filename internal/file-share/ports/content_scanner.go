@@ -0,0 +1,20 @@
+package ports
+
+import (
+	"context"
+	"io"
+)
+
+// ScanResult is the scanner's verdict on one file. Signature names the match
+// when Infected is set.
+type ScanResult struct {
+	Infected  bool
+	Signature string
+}
+
+// ContentScanner inspects uploaded content before it becomes visible in a
+// room. An error means the content could not be judged, not that it is bad.
+type ContentScanner interface {
+	Name() string
+	Scan(ctx context.Context, r io.Reader) (ScanResult, error)
+}
@@ -0,0 +1,38 @@
+package ports
+
+import (
+	"context"
+	"io"
+)
+
+type BroadcastPolicy string
+
+const (
+	// BroadcastPolicyStall slows the sender down to the slowest receiver.
+	BroadcastPolicyStall BroadcastPolicy = "stall"
+	// BroadcastPolicyDrop disconnects receivers whose buffer is full.
+	BroadcastPolicyDrop BroadcastPolicy = "drop"
+)
+
+type BroadcastOptions struct {
+	Policy BroadcastPolicy
+	// MinReceivers starts the stream once that many receivers joined.
+	// Zero waits for an explicit Start.
+	MinReceivers int
+}
+
+type BroadcastResult struct {
+	Receivers int
+	Completed int
+	Dropped   int
+	Digest    string
+}
+
+type DirectBroadcast interface {
+	Join(ctx context.Context, code string) (receiverID string, transfer *Transfer, err error)
+	Broadcast(ctx context.Context, code string, filename string, opts BroadcastOptions, src io.Reader) (BroadcastResult, error)
+	Start(code string) error
+	Receivers(code string) (int, error)
+	Complete(code string, receiverID string) error
+	Leave(code string, receiverID string) error
+}
@@ -8,10 +8,13 @@ import (
 type Transfer struct {
 	Reader   io.Reader
 	Filename string
+	// Digest is the hex SHA-256 announced by the sender, empty when none was sent.
+	Digest string
 }
 
 type DirectTransfer interface {
 	Receive(ctx context.Context, code string) (*Transfer, error)
-	Send(ctx context.Context, code string, filename string, src io.Reader) error
+	Send(ctx context.Context, code string, filename string, expectedDigest string, src io.Reader) (digest string, err error)
+	Complete(code string) error
 	Cancel(code string) error
 }
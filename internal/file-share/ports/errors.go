@@ -11,6 +11,25 @@ var (
 	ErrEmptyUploadDir   = errors.New("upload dir is empty")
 	ErrInvalidUploadDir = errors.New("invalid upload dir")
 
+	ErrInvalidDigest  = errors.New("digest invalid")
+	ErrDigestMismatch = errors.New("digest mismatch")
+
+	ErrFileInfected = errors.New("file rejected by content scanner")
+	ErrScanFailed   = errors.New("content scan failed")
+
+	ErrThumbnailUnsupported = errors.New("no thumbnail for this file type")
+	ErrImageTooLarge        = errors.New("image too large to thumbnail")
+	ErrSanitizeFailed       = errors.New("image metadata could not be removed")
+
+	ErrNotArchive           = errors.New("file is not a supported archive")
+	ErrArchiveUnreadable    = errors.New("archive is corrupt or unreadable")
+	ErrArchiveTooLarge      = errors.New("archive expands beyond the allowed limits")
+	ErrArchiveUnsafe        = errors.New("archive contains unsafe paths")
+	ErrArchiveEntryNotFound = errors.New("archive entry not found")
+
+	ErrBundleInvalid   = errors.New("room bundle is corrupt or unreadable")
+	ErrBundleSignature = errors.New("room bundle signature invalid")
+
 	ErrInvalidToken      = errors.New("token invalid")
 	ErrTokenSignAlgo     = errors.New("unexpected signing method")
 	ErrTokenExpired      = errors.New("token expired")
@@ -22,4 +41,17 @@ var (
 	ErrTransferCodeInvalidLength = errors.New("transfer code invalid length")
 	ErrTransferCodeNotFound      = errors.New("transfer code not found")
 	ErrTransferCodeExists        = errors.New("transfer code exists")
+	ErrTransferRelayFailed       = errors.New("transfer relay failed")
+	ErrInvalidRelaySignature     = errors.New("relay signature invalid")
+	ErrTransferIncomplete        = errors.New("transfer not completed by receiver")
+
+	ErrBroadcastStarted       = errors.New("broadcast already started")
+	ErrBroadcastFull          = errors.New("broadcast receiver limit reached")
+	ErrBroadcastNoReceivers   = errors.New("broadcast has no receivers")
+	ErrBroadcastInvalidPolicy = errors.New("broadcast policy invalid")
+	ErrSlowReceiver           = errors.New("receiver too slow, dropped")
+
+	ErrInvalidSignal    = errors.New("signal invalid")
+	ErrSignalPeerJoined = errors.New("signal role already joined")
+	ErrSignalBufferFull = errors.New("signal buffer full")
 )
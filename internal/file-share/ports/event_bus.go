@@ -5,6 +5,11 @@ type EventName string
 const (
 	EventRoomCreate EventName = "RoomCreate"
 	EventRoomDelete EventName = "RoomDelete"
+	// EventFileDelete carries the domain.ExpiredFile of a file that was
+	// removed on its own, by expiry or after its last download.
+	EventFileDelete EventName = "FileDelete"
+	// EventRoomMessage carries the uuid.UUID of a room with a new message.
+	EventRoomMessage EventName = "RoomMessage"
 )
 
 type Event struct {
@@ -3,12 +3,35 @@ package ports
 import (
 	"context"
 	"io"
+	"time"
 )
 
+type SavedFile struct {
+	Path   string
+	Size   int64
+	SHA256 string
+	// ContentType is sniffed from the leading bytes.
+	ContentType string
+}
+
+type StoredFile struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
 type FileStore interface {
 	ClearAll(ctx context.Context, uploadDir string) error
-	Save(ctx context.Context, uploadDir, name string, r io.Reader) (path string, size int64, err error)
+	Save(ctx context.Context, uploadDir, name string, r io.Reader) (SavedFile, error)
 	Open(ctx context.Context, path string) (io.ReadCloser, error)
 	Exists(ctx context.Context, path string) (bool, error)
 	Delete(ctx context.Context, path string) error
+	// Move relocates a stored file into dir under the same name and returns
+	// the new path.
+	Move(ctx context.Context, path, dir string) (string, error)
+	// Copy stores the content of path again as name in dir and returns the
+	// new path. Stored files are never modified, so the copy may share the
+	// original's data.
+	Copy(ctx context.Context, path, dir, name string) (string, error)
+	List(ctx context.Context, uploadDir string) ([]StoredFile, error)
 }
@@ -0,0 +1,10 @@
+package ports
+
+import "context"
+
+// HealthChecker probes one dependency. Check returns nil when the dependency
+// is usable and an error describing the problem otherwise.
+type HealthChecker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
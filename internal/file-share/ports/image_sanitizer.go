@@ -0,0 +1,14 @@
+package ports
+
+import (
+	"context"
+	"io"
+)
+
+// ImageSanitizer strips identifying metadata from images while they stream.
+type ImageSanitizer interface {
+	// Sanitize copies r to w, dropping EXIF, XMP and IPTC metadata from the
+	// image formats it knows while keeping the orientation. Anything else is
+	// copied unchanged and reported as not applied.
+	Sanitize(ctx context.Context, w io.Writer, r io.Reader) (applied bool, err error)
+}
@@ -0,0 +1,172 @@
+package ports
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidQuery  = errors.New("invalid list query")
+	ErrInvalidCursor = errors.New("invalid page cursor")
+)
+
+const (
+	SortByName      = "name"
+	SortBySize      = "size"
+	SortByCreatedAt = "createdAt"
+	SortByExpiresAt = "expiresAt"
+)
+
+// FileQuery selects one page of a room's files. Files are ordered by Sort and
+// then by ID; Cursor resumes after the last file of the previous page.
+type FileQuery struct {
+	// Folder limits the page to a folder and everything below it.
+	Folder string
+	// Name is a case-insensitive substring of the file name.
+	Name string
+	// ContentType is a media type such as "image/png", or "image/*" for a
+	// whole family.
+	ContentType string
+	MinSize     int64
+	// MaxSize is inclusive; 0 leaves the size unbounded.
+	MaxSize int64
+	Sort    string
+	Desc    bool
+	Cursor  string
+	Limit   int
+}
+
+type FilePage struct {
+	Files []*domain.RoomFile
+	// Next is the cursor of the following page, empty on the last one.
+	Next string
+}
+
+// RoomQuery selects one page of rooms, ordered by Sort and then by ID.
+type RoomQuery struct {
+	ExpiresAfter  time.Time
+	ExpiresBefore time.Time
+	Sort          string
+	Desc          bool
+	Cursor        string
+	Limit         int
+}
+
+type RoomPage struct {
+	Rooms []*domain.Room
+	Next  string
+}
+
+// Matches applies the filters of q, but not its folder, to f.
+func (q FileQuery) Matches(f *domain.RoomFile) bool {
+	if q.Name != "" && !strings.Contains(FoldName(f.Name), FoldName(q.Name)) {
+		return false
+	}
+	if q.MinSize > 0 && f.Size < q.MinSize {
+		return false
+	}
+	if q.MaxSize > 0 && f.Size > q.MaxSize {
+		return false
+	}
+	if q.ContentType != "" {
+		mt := domain.MediaType(f.ContentType)
+		if family, ok := strings.CutSuffix(q.ContentType, "/*"); ok {
+			return strings.HasPrefix(mt, family+"/")
+		}
+		return mt == q.ContentType
+	}
+	return true
+}
+
+func (q RoomQuery) Matches(r *domain.Room) bool {
+	if !q.ExpiresAfter.IsZero() && r.ExpiresAt.Before(q.ExpiresAfter) {
+		return false
+	}
+	if !q.ExpiresBefore.IsZero() && r.ExpiresAt.After(q.ExpiresBefore) {
+		return false
+	}
+	return true
+}
+
+// FoldName folds ASCII letters to lower case, matching SQLite's NOCASE
+// collation, so every backend sorts names the same way.
+func FoldName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, name)
+}
+
+// Cursor is a position in a sorted listing: the sort key and ID of the last
+// item returned. Numeric keys are decimal, times are Unix nanoseconds.
+type Cursor struct {
+	Key string    `json:"k"`
+	ID  uuid.UUID `json:"id"`
+}
+
+func (c Cursor) String() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// Int is the key of a numeric or time sort.
+func (c Cursor) Int() (int64, error) {
+	n, err := strconv.ParseInt(c.Key, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	return n, nil
+}
+
+func ParseCursor(s string) (Cursor, error) {
+	var c Cursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(raw, &c) != nil || c.ID == uuid.Nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// FileCursor is the cursor just after f in a listing sorted by sort.
+func FileCursor(sort string, f *domain.RoomFile) Cursor {
+	switch sort {
+	case SortBySize:
+		return Cursor{Key: strconv.FormatInt(f.Size, 10), ID: f.ID}
+	case SortByCreatedAt:
+		return Cursor{Key: strconv.FormatInt(f.CreatedAt.UnixNano(), 10), ID: f.ID}
+	}
+	return Cursor{Key: FoldName(f.Name), ID: f.ID}
+}
+
+func RoomCursor(sort string, r *domain.Room) Cursor {
+	if sort == SortByExpiresAt {
+		return Cursor{Key: strconv.FormatInt(r.ExpiresAt.UnixNano(), 10), ID: r.ID}
+	}
+	return Cursor{Key: strconv.FormatInt(r.CreatedAt.UnixNano(), 10), ID: r.ID}
+}
+
+// Compare orders two cursors of the same sort ascending.
+func (c Cursor) Compare(other Cursor, numeric bool) int {
+	if numeric {
+		a, _ := strconv.ParseInt(c.Key, 10, 64)
+		b, _ := strconv.ParseInt(other.Key, 10, 64)
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	} else if k := strings.Compare(c.Key, other.Key); k != 0 {
+		return k
+	}
+	return strings.Compare(c.ID.String(), other.ID.String())
+}
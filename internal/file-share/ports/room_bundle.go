@@ -0,0 +1,23 @@
+package ports
+
+import (
+	"context"
+	"io"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/domain"
+)
+
+// RoomBundler moves rooms between deployments as a single signed stream
+// holding the room's settings, files, versions, folders and messages.
+// Passwords and tokens are never part of a bundle.
+type RoomBundler interface {
+	// Export writes room, reading the content of each stored path through
+	// open.
+	Export(ctx context.Context, w io.Writer, room *domain.Room, open func(path string) (io.ReadCloser, error)) error
+	// Import reads a bundle written by Export with the same key. Once the
+	// signature checks out, check may refuse the room it describes before
+	// any content is read. store then saves each content and returns its
+	// path, which the returned room refers to. Content differing from what
+	// the bundle promised fails the import.
+	Import(ctx context.Context, r io.Reader, check func(room *domain.Room) error, store func(r io.Reader) (string, error)) (*domain.Room, error)
+}
@@ -11,11 +11,53 @@ import (
 type RoomRepository interface {
 	Get(ctx context.Context, roomID uuid.UUID) (*domain.Room, bool, error)
 	List(ctx context.Context) ([]*domain.Room, error)
+	// Stats sums up all rooms without loading them.
+	Stats(ctx context.Context) (RoomStats, error)
+	// QueryRooms and QueryFilesByToken get a validated query with Sort and
+	// Limit set and a normalized Folder.
+	QueryRooms(ctx context.Context, query RoomQuery) (RoomPage, error)
 	Create(ctx context.Context, room *domain.Room) error
 	Delete(ctx context.Context, roomID uuid.UUID) ([]string, error)
 	DeleteExpired(ctx context.Context, now time.Time) ([]domain.ExpiredCleanup, error)
 	RemoveToken(ctx context.Context, roomID uuid.UUID, token string) (bool, error)
 	AddToken(ctx context.Context, roomID uuid.UUID, token string) error
-	AddFileByToken(ctx context.Context, roomID uuid.UUID, token string, file *domain.RoomFile) (bool, error)
-	DeleteFileByToken(ctx context.Context, roomID, fileID uuid.UUID, token string) (string, bool, error)
+	QueryFilesByToken(ctx context.Context, roomID uuid.UUID, token string, query FileQuery) (FilePage, bool, error)
+	// AddFileByToken and AddFileVersionByToken fail with
+	// domain.ErrRoomQuotaExceeded when the room would exceed limits, checked
+	// in the same atomic step as the write.
+	AddFileByToken(ctx context.Context, roomID uuid.UUID, token string, file *domain.RoomFile, limits domain.RoomLimits) (bool, error)
+	// AddFileVersionByToken makes next the current version of an existing
+	// file and returns the updated file.
+	AddFileVersionByToken(ctx context.Context, roomID, fileID uuid.UUID, token string, next *domain.RoomFile, limits domain.RoomLimits) (*domain.RoomFile, bool, error)
+	// DeleteFileByToken and DeleteFile return the paths of every version.
+	DeleteFileByToken(ctx context.Context, roomID, fileID uuid.UUID, token string) ([]string, bool, error)
+	// SetFileDescriptionByToken and AddMessageByToken check limits the same
+	// way; a description that does not grow is always accepted.
+	SetFileDescriptionByToken(ctx context.Context, roomID, fileID uuid.UUID, token, description string, limits domain.RoomLimits) (*domain.RoomFile, bool, error)
+	// CountDownloadByToken atomically records a download and returns the file
+	// with the new count. A file that has no downloads left afterwards is
+	// removed; expired and used up files are not found.
+	CountDownloadByToken(ctx context.Context, roomID, fileID uuid.UUID, token string, now time.Time) (*domain.RoomFile, bool, error)
+	// DeleteExpiredFiles removes files whose own expiry has passed.
+	DeleteExpiredFiles(ctx context.Context, now time.Time) ([]domain.ExpiredFile, error)
+	// Folder paths are normalized by the caller. AddFolderByToken creates
+	// missing parents; RenameFolderByToken and DeleteFolderByToken also apply
+	// to everything below the folder and return domain folder errors.
+	AddFolderByToken(ctx context.Context, roomID uuid.UUID, token, folder string) (bool, error)
+	RenameFolderByToken(ctx context.Context, roomID uuid.UUID, token, from, to string) (bool, error)
+	DeleteFolderByToken(ctx context.Context, roomID uuid.UUID, token, folder string) ([]string, bool, error)
+	// AddMessageByToken appends to the room's message thread.
+	AddMessageByToken(ctx context.Context, roomID uuid.UUID, token string, msg *domain.RoomMessage, limits domain.RoomLimits) (bool, error)
+	// DeleteFile and RemoveTokens skip the token check; they back the admin API.
+	DeleteFile(ctx context.Context, roomID, fileID uuid.UUID) ([]string, bool, error)
+	RemoveTokens(ctx context.Context, roomID uuid.UUID) (int, error)
+}
+
+// RoomStats counts what all rooms hold. Bytes covers every stored version,
+// as domain.RoomFile.StoredSize does.
+type RoomStats struct {
+	Rooms  int
+	Tokens int
+	Files  int
+	Bytes  int64
 }
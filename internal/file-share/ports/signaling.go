@@ -0,0 +1,35 @@
+package ports
+
+import "context"
+
+type SignalRole string
+
+const (
+	SignalRoleSender   SignalRole = "sender"
+	SignalRoleReceiver SignalRole = "receiver"
+)
+
+type SignalKind string
+
+const (
+	SignalOffer      SignalKind = "offer"
+	SignalAnswer     SignalKind = "answer"
+	SignalCandidate  SignalKind = "candidate"
+	SignalFallback   SignalKind = "fallback"
+	SignalBye        SignalKind = "bye"
+	SignalPeerJoined SignalKind = "peer-joined"
+)
+
+type Signal struct {
+	Kind    SignalKind
+	From    SignalRole
+	Payload string
+}
+
+// Signaling pairs a sender and a receiver on a direct transfer code so they can
+// exchange WebRTC offers, answers and ICE candidates before falling back to
+// the piped DirectTransfer stream.
+type Signaling interface {
+	Join(ctx context.Context, code string, role SignalRole) (<-chan Signal, UnsubscribeFunc, error)
+	Send(ctx context.Context, code string, signal Signal) error
+}
@@ -0,0 +1,19 @@
+package ports
+
+import (
+	"context"
+	"io"
+)
+
+type ImageInfo struct {
+	Width  int
+	Height int
+}
+
+// Thumbnailer renders JPEG thumbnails of images.
+type Thumbnailer interface {
+	Supports(contentType string) bool
+	Inspect(ctx context.Context, r io.Reader) (ImageInfo, error)
+	// Thumbnail returns a JPEG whose longest side is at most size pixels.
+	Thumbnail(ctx context.Context, r io.Reader, size int) ([]byte, error)
+}
@@ -7,8 +7,17 @@ import (
 	"github.com/google/uuid"
 )
 
+// TokenInfo is what a token says about itself once its signature checks out.
+type TokenInfo struct {
+	RoomID    uuid.UUID
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
 type TokenService interface {
 	Issue(ctx context.Context, roomID uuid.UUID, ttl time.Duration) (token string, expiresAt time.Time, err error)
 	Validate(ctx context.Context, token string) error
 	ValidateWithRoom(ctx context.Context, roomID uuid.UUID, token string) error
+	// Inspect verifies the signature only, so expired tokens can still be shown.
+	Inspect(ctx context.Context, token string) (TokenInfo, error)
 }
@@ -0,0 +1,62 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+)
+
+const auditPruneInterval = time.Hour
+
+// AuditRetentionJob drops audit records older than the retention period.
+type AuditRetentionJob struct {
+	sink      ports.AuditSink
+	retention time.Duration
+}
+
+func NewAuditRetention(sink ports.AuditSink, retention time.Duration) *AuditRetentionJob {
+	return &AuditRetentionJob{
+		sink:      sink,
+		retention: retention,
+	}
+}
+
+// Prune runs one pass. A zero retention keeps everything.
+func (a *AuditRetentionJob) Prune(ctx context.Context) (int, error) {
+	if a.retention <= 0 {
+		return 0, nil
+	}
+	return a.sink.Prune(ctx, time.Now().Add(-a.retention))
+}
+
+// Run prunes once right away and then every hour until ctx is cancelled or
+// the returned function is called.
+func (a *AuditRetentionJob) Run(ctx context.Context) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	if a.retention <= 0 {
+		return cancel
+	}
+
+	go func() {
+		ticker := time.NewTicker(auditPruneInterval)
+		defer ticker.Stop()
+
+		for {
+			removed, err := a.Prune(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "audit prune failed", slog.Any("error", err))
+			} else if removed > 0 {
+				slog.InfoContext(ctx, "audit records pruned", slog.Int("count", removed))
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return cancel
+}
@@ -2,20 +2,33 @@ package jobs
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	fileShare "github.com/Miklakapi/go-file-share/internal/file-share/application"
 	"github.com/Miklakapi/go-file-share/internal/file-share/ports"
+	"github.com/Miklakapi/go-file-share/internal/logging"
 	"github.com/google/uuid"
 )
 
+// heartbeatTolerance is how many intervals may pass without a run before the
+// job is reported unhealthy.
+const heartbeatTolerance = 3
+
+// CleanupObserver is called after every cleanup run.
+type CleanupObserver func(duration time.Duration, deletedRooms int, err error)
+
 type RoomCleanupJob struct {
 	fileShareService *fileShare.Service
 	eventPublisher   ports.EventPublisher
 	cleanupInterval  time.Duration
+	observer         CleanupObserver
+	heartbeat        atomic.Int64
 }
 
 func New(fileShareService *fileShare.Service, eventPublisher ports.EventPublisher, cleanupInterval time.Duration) *RoomCleanupJob {
@@ -26,6 +39,10 @@ func New(fileShareService *fileShare.Service, eventPublisher ports.EventPublishe
 	}
 }
 
+func (r *RoomCleanupJob) Observe(observer CleanupObserver) {
+	r.observer = observer
+}
+
 func (r *RoomCleanupJob) Run(ctx context.Context) (func(), error) {
 	closeChannel := make(chan struct{}, 1)
 	var once sync.Once
@@ -36,6 +53,7 @@ func (r *RoomCleanupJob) Run(ctx context.Context) (func(), error) {
 		})
 	}
 
+	r.beat()
 	go r.cleanup(ctx, closeChannel, r.cleanupInterval)
 
 	return close, nil
@@ -44,24 +62,15 @@ func (r *RoomCleanupJob) Run(ctx context.Context) (func(), error) {
 func (r *RoomCleanupJob) cleanup(ctx context.Context, close chan struct{}, duration time.Duration) {
 	cleanupTicker := time.NewTicker(duration)
 	defer cleanupTicker.Stop()
+	defer r.heartbeat.Store(0)
 
 	for {
 		select {
 		case <-cleanupTicker.C:
-			deletedRooms, err := r.fileShareService.CleanupExpired(ctx)
-			if err != nil {
-				log.Printf("file error: %v\n", err)
-				continue
-			}
-			if len(deletedRooms) == 0 {
-				continue
-			}
-
-			deletedRoomsString := uuidsToString(deletedRooms)
-			if err := r.eventPublisher.Publish(ports.Event{Name: ports.EventRoomDelete, Data: deletedRoomsString}); err != nil {
-				log.Printf("file error: %v\n", err)
-				continue
-			}
+			runCtx := logging.WithRequestID(ctx, "cleanup-"+uuid.NewString())
+			r.cleanupRooms(runCtx)
+			r.cleanupFiles(runCtx)
+			r.beat()
 		case <-close:
 			return
 
@@ -71,6 +80,67 @@ func (r *RoomCleanupJob) cleanup(ctx context.Context, close chan struct{}, durat
 	}
 }
 
+func (r *RoomCleanupJob) cleanupRooms(ctx context.Context) {
+	start := time.Now()
+	deletedRooms, err := r.fileShareService.CleanupExpired(ctx)
+	if r.observer != nil {
+		r.observer(time.Since(start), len(deletedRooms), err)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "room cleanup failed", slog.Any("error", err))
+		return
+	}
+	if len(deletedRooms) == 0 {
+		return
+	}
+	slog.InfoContext(ctx, "expired rooms removed", slog.Int("count", len(deletedRooms)))
+
+	deletedRoomsString := uuidsToString(deletedRooms)
+	if err := r.eventPublisher.Publish(ports.Event{Name: ports.EventRoomDelete, Data: deletedRoomsString}); err != nil {
+		slog.ErrorContext(ctx, "cannot publish room delete event", slog.Any("error", err))
+	}
+}
+
+// cleanupFiles removes files that expired before their room. Files whose
+// blob could not be removed are gone from their room all the same, so they
+// are announced either way.
+func (r *RoomCleanupJob) cleanupFiles(ctx context.Context) {
+	deletedFiles, err := r.fileShareService.CleanupExpiredFiles(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "file cleanup failed", slog.Any("error", err))
+	}
+	if len(deletedFiles) == 0 {
+		return
+	}
+	slog.InfoContext(ctx, "expired files removed", slog.Int("count", len(deletedFiles)))
+
+	for _, f := range deletedFiles {
+		if err := r.eventPublisher.Publish(ports.Event{Name: ports.EventFileDelete, Data: f}); err != nil {
+			slog.ErrorContext(ctx, "cannot publish file delete event", slog.Any("error", err))
+		}
+	}
+}
+
+func (r *RoomCleanupJob) beat() {
+	r.heartbeat.Store(time.Now().UnixNano())
+}
+
+func (r *RoomCleanupJob) Name() string {
+	return "cleanup_job"
+}
+
+// Check fails when the job is not running or has missed several ticks.
+func (r *RoomCleanupJob) Check(ctx context.Context) error {
+	last := r.heartbeat.Load()
+	if last == 0 {
+		return errors.New("not running")
+	}
+	if age := time.Since(time.Unix(0, last)); age > heartbeatTolerance*r.cleanupInterval {
+		return fmt.Errorf("last run %s ago", age.Round(time.Second))
+	}
+	return nil
+}
+
 func uuidsToString(uuids []uuid.UUID) string {
 	var b strings.Builder
 	for i, u := range uuids {
@@ -0,0 +1,130 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// level is shared by every logger built with New, so SetLevel takes effect
+// without rebuilding handlers.
+var level slog.LevelVar
+
+type Options struct {
+	Format string
+	Level  string
+
+	File       string
+	MaxSize    int64
+	MaxAge     time.Duration
+	MaxBackups int
+}
+
+// New builds the process logger. Records go to stdout and, when a file is
+// configured, to a size/age rotated log file which the returned closer closes.
+func New(opts Options) (*slog.Logger, io.Closer, error) {
+	if err := SetLevel(opts.Level); err != nil {
+		return nil, nil, err
+	}
+
+	var out io.Writer = os.Stdout
+	var closer io.Closer = nopCloser{}
+	if opts.File != "" {
+		file, err := NewRotatingFile(opts.File, opts.MaxSize, opts.MaxAge, opts.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		out = io.MultiWriter(os.Stdout, file)
+		closer = file
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: &level}
+	var handler slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "json":
+		handler = slog.NewJSONHandler(out, handlerOpts)
+	case "", "text":
+		handler = slog.NewTextHandler(out, handlerOpts)
+	default:
+		_ = closer.Close()
+		return nil, nil, fmt.Errorf("unknown log format %q", opts.Format)
+	}
+
+	return slog.New(&contextHandler{Handler: handler}), closer, nil
+}
+
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", s)
+	}
+	return level, nil
+}
+
+// SetLevel changes the minimum level of the process loggers.
+func SetLevel(s string) error {
+	l, err := ParseLevel(s)
+	if err != nil {
+		return err
+	}
+	level.Set(l)
+	return nil
+}
+
+// Writer adapts the logger for libraries that only accept an io.Writer, such
+// as gin's debug output. Every write becomes one record at the given level.
+func Writer(logger *slog.Logger, level slog.Level) io.Writer {
+	return slog.NewLogLogger(logger.Handler(), level).Writer()
+}
+
+// Fatal logs err and exits, like log.Fatal.
+func Fatal(msg string, err error) {
+	slog.Error(msg, slog.Any("error", err))
+	os.Exit(1)
+}
+
+type requestIDKey struct{}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler adds the request ID carried by the context to every record
+// logged through the *Context methods.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
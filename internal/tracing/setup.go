@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type Options struct {
+	Exporter string
+	Service  string
+	Endpoint string
+	Headers  map[string]string
+	File     string
+}
+
+// New builds a tracer for the configured exporter: "otlp", "stdout" or
+// "file". "none" or an empty exporter returns a nil tracer.
+func New(opts Options) (*Tracer, error) {
+	var exporter Exporter
+	switch opts.Exporter {
+	case "", "none":
+		return nil, nil
+	case "otlp":
+		exporter = NewOTLPExporter(opts.Endpoint, opts.Headers)
+	case "stdout":
+		exporter = NewWriterExporter(nopWriteCloser{os.Stdout})
+	case "file":
+		if dir := filepath.Dir(opts.File); dir != "." {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return nil, fmt.Errorf("cannot create trace dir: %w", err)
+			}
+		}
+		f, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open trace file: %w", err)
+		}
+		exporter = NewWriterExporter(f)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", opts.Exporter)
+	}
+
+	return NewTracer(opts.Service, exporter), nil
+}
+
+// nopWriteCloser keeps the exporter from closing stdout on shutdown.
+type nopWriteCloser struct {
+	w *os.File
+}
+
+func (n nopWriteCloser) Write(p []byte) (int, error) {
+	return n.w.Write(p)
+}
@@ -0,0 +1,186 @@
+package tracing
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	queueSize     = 2048
+	batchSize     = 256
+	flushInterval = 5 * time.Second
+)
+
+// SpanData is an ended span as handed to exporters.
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	TraceID       TraceID
+	SpanID        SpanID
+	ParentSpanID  SpanID
+	Start         time.Time
+	End           time.Time
+	Attrs         []Attr
+	Status        StatusCode
+	StatusMessage string
+}
+
+type Exporter interface {
+	Export(ctx context.Context, service string, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// Tracer batches ended spans and hands them to the exporter from a single
+// goroutine. When the queue is full new spans are dropped rather than
+// slowing requests down.
+type Tracer struct {
+	service  string
+	exporter Exporter
+	queue    chan SpanData
+	stopped  chan struct{}
+	done     chan struct{}
+	once     sync.Once
+	dropped  atomic.Uint64
+}
+
+func NewTracer(service string, exporter Exporter) *Tracer {
+	t := &Tracer{
+		service:  service,
+		exporter: exporter,
+		queue:    make(chan SpanData, queueSize),
+		stopped:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+// SetDefault installs the tracer used by Start. Without one, Start returns
+// nil spans and tracing costs nothing.
+func SetDefault(t *Tracer) {
+	defaultTracer.Store(t)
+}
+
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	return StartKind(ctx, name, KindInternal, attrs...)
+}
+
+func StartKind(ctx context.Context, name string, kind SpanKind, attrs ...Attr) (context.Context, *Span) {
+	t := defaultTracer.Load()
+	if t == nil {
+		return ctx, nil
+	}
+
+	parent := SpanContextFromContext(ctx)
+	if parent.IsValid() && !parent.Sampled {
+		return ctx, nil
+	}
+
+	span := &Span{
+		tracer: t,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+		attrs:  attrs,
+		sc: SpanContext{
+			TraceID: parent.TraceID,
+			SpanID:  newSpanID(),
+			Sampled: true,
+		},
+		parent: parent.SpanID,
+	}
+	if !parent.IsValid() {
+		span.sc.TraceID = newTraceID()
+	}
+
+	return ContextWithSpan(ctx, span), span
+}
+
+// Dropped reports spans lost because the export queue was full.
+func (t *Tracer) Dropped() uint64 {
+	return t.dropped.Load()
+}
+
+// Shutdown exports what is queued and closes the exporter.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.once.Do(func() {
+		if defaultTracer.Load() == t {
+			defaultTracer.Store(nil)
+		}
+		close(t.done)
+	})
+
+	select {
+	case <-t.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+func (t *Tracer) export(s *Span) {
+	s.mu.Lock()
+	data := SpanData{
+		Name:          s.name,
+		Kind:          s.kind,
+		TraceID:       s.sc.TraceID,
+		SpanID:        s.sc.SpanID,
+		ParentSpanID:  s.parent,
+		Start:         s.start,
+		End:           s.end,
+		Attrs:         s.attrs,
+		Status:        s.status,
+		StatusMessage: s.statusMsg,
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-t.done:
+		t.dropped.Add(1)
+	case t.queue <- data:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+func (t *Tracer) run() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, batchSize)
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := t.exporter.Export(ctx, t.service, batch); err != nil {
+			slog.Warn("span export failed", slog.Int("spans", len(batch)), slog.Any("error", err))
+		}
+		cancel()
+		batch = make([]SpanData, 0, batchSize)
+	}
+
+	for {
+		select {
+		case data := <-t.queue:
+			batch = append(batch, data)
+			if len(batch) >= batchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case <-t.done:
+			for len(t.queue) > 0 {
+				batch = append(batch, <-t.queue)
+			}
+			send()
+			close(t.stopped)
+			return
+		}
+	}
+}
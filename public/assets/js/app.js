@@ -6,10 +6,11 @@ import { useFiles } from "./files.js"
 import { useRouter } from "./router.js"
 import { useToast } from "./toast.js"
 import { useFilesDataTable } from "./fileDataTable.js"
-import { formatDate, generateNumericCode, sleep } from "./helpers.js"
+import { formatDate, generateNumericCode, sleep, triggerBrowserDownload } from "./helpers.js"
 import { useSSE } from "./sse.js"
 import { useDirectDialog } from "./directDialog.js"
 import { useDirect } from "./direct.js"
+import { useRTC } from "./rtc.js"
 
 const els = {
     // Others
@@ -81,6 +82,7 @@ const rooms = useRooms()
 const files = useFiles()
 const sse = useSSE()
 const direct = useDirect()
+const rtc = useRTC()
 
 function show(view) {
     document.getElementById('view-list').hidden = view !== 'list'
@@ -137,6 +139,7 @@ function wireEvents() {
         e.preventDefault()
         if (e.submitter.value === 'cancel') {
             direct.abort()
+            rtc.abort()
             directDialog.close()
             return
         }
@@ -149,7 +152,16 @@ function wireEvents() {
             directDialog.disableSendBox(true)
             directDialog.showCodeBox(true)
             try {
-                await direct.download(code)
+                // The piped download stays registered as the fallback while a
+                // peer-to-peer connection is attempted.
+                const viaPeer = rtc.receive(code).then(({ blob, name }) => {
+                    direct.abort()
+                    triggerBrowserDownload(blob, name)
+                })
+                const viaServer = direct.download(code).then(() => rtc.abort())
+                await Promise.any([viaPeer, viaServer]).catch((error) => {
+                    throw error.errors?.at(-1) ?? error
+                })
                 directDialog.clearCopySection()
                 directDialog.disableCreateCodeButton(false)
                 directDialog.disableSendBox(false)
@@ -172,6 +184,7 @@ function wireEvents() {
 
         if (e.submitter.value === 'cancelCode') {
             direct.abort()
+            rtc.abort()
             directDialog.clearCopySection()
             directDialog.disableCreateCodeButton(false)
             directDialog.disableSendBox(false)
@@ -191,8 +204,13 @@ function wireEvents() {
 
             directDialog.disableCreateCodeButton(true)
             directDialog.disableSendButton(true)
+            const code = els.directCodeInput().value?.trim() ?? ''
             try {
-                await direct.upload(els.directCodeInput().value?.trim() ?? '', fileToUpload)
+                try {
+                    await rtc.send(code, fileToUpload)
+                } catch {
+                    await direct.upload(code, fileToUpload)
+                }
                 directDialog.clearFileInput()
             } catch (error) {
                 directDialog.setError(`${error}`.replace("Error:", ""))
@@ -354,6 +372,15 @@ function wireEvents() {
         }
         roomDataTable.loadData(await rooms.get())
     })
+    sse.onEvent("FilesChange", async e => {
+        const roomId = router.getRoomId()
+        if (!roomId || roomId !== e.data) return
+        try {
+            filesDataTable.loadData(await files.get(roomId))
+        } catch (error) {
+            console.error(error)
+        }
+    })
     sse.onEvent("Message", e => toast.show(e.data, 'success'))
     sse.onError(err => console.error("EventSource failed:", err))
 }
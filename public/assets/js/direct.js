@@ -32,14 +32,16 @@ export function useDirect() {
         if (controller) throw Error('Another operation is already in progress')
         controller = new AbortController()
 
-        const form = new FormData()
-        form.append('file', file, file.name)
-
         try {
-            await api(`/direct/${code}/upload`, {
-                method: 'POST',
-                body: form
-            })
+            await api(`/direct/${code}`, {
+                method: 'PUT',
+                headers: {
+                    'Content-Type': 'application/octet-stream',
+                    'X-Filename': encodeURIComponent(file.name)
+                },
+                body: file,
+                signal: controller.signal
+            }, 0)
         } catch (err) {
             if (err.name === 'AbortError') return
             throw err
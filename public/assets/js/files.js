@@ -1,9 +1,8 @@
-import { api, filenameFromDisposition, triggerBrowserDownload } from "./helpers.js"
+import { api, apiPages, filenameFromDisposition, triggerBrowserDownload } from "./helpers.js"
 
 export function useFiles() {
     async function get(roomId) {
-        const files = (await api(`/rooms/${roomId}/files`)).data
-        return files
+        return apiPages(`/rooms/${roomId}/files`, { sort: 'name' })
     }
 
     async function download(roomId, fileId) {
@@ -48,6 +48,20 @@ export async function api(path, options = {}, timeout = 10000) {
     return res.json()
 }
 
+// apiPages follows the `next` cursors of a paginated list and returns every item.
+export async function apiPages(path, params = {}) {
+    const items = []
+    let cursor = ''
+    do {
+        const query = new URLSearchParams(params)
+        if (cursor) query.set('cursor', cursor)
+        const page = await api(`${path}?${query}`)
+        items.push(...page.data)
+        cursor = page.next
+    } while (cursor)
+    return items
+}
+
 export function formatDate(iso) {
     try {
         return new Date(iso).toLocaleString()
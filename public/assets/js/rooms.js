@@ -1,8 +1,8 @@
-import { api } from "./helpers.js"
+import { api, apiPages } from "./helpers.js"
 
 export function useRooms() {
     async function get() {
-        return (await api('/rooms')).data
+        return apiPages('/rooms', { sort: 'createdAt', order: 'desc' })
     }
 
     async function getById(id) {
@@ -0,0 +1,185 @@
+import { api } from "./helpers.js"
+
+const CHUNK_SIZE = 64 * 1024
+const CONNECT_TIMEOUT = 8000
+
+export function useRTC() {
+    let session = null
+
+    function supported() {
+        return typeof RTCPeerConnection !== "undefined" && typeof EventSource !== "undefined"
+    }
+
+    function openSession(code, role) {
+        const es = new EventSource(`/api/v1/direct/${code}/signal?role=${role}`)
+        const pc = supported() ? new RTCPeerConnection({ iceServers: [] }) : null
+
+        const post = (type, payload = '') => api(`/direct/${code}/signal?role=${role}`, {
+            method: 'POST',
+            body: JSON.stringify({ type, payload })
+        }).catch(() => { })
+
+        const on = (name, handler) => es.addEventListener(name, (e) => {
+            const data = JSON.parse(e.data || '{}')
+            handler(data.payload ? JSON.parse(data.payload) : null)
+        })
+
+        if (pc) {
+            pc.onicecandidate = (e) => {
+                if (e.candidate) post('candidate', JSON.stringify(e.candidate))
+            }
+            on('candidate', (candidate) => pc.addIceCandidate(candidate).catch(() => { }))
+        }
+
+        const close = () => {
+            es.close()
+            pc?.close()
+            if (session?.es === es) session = null
+        }
+
+        session = { es, pc, post, close }
+        return session
+    }
+
+    function receive(code) {
+        return new Promise((resolve, reject) => {
+            const { es, pc, post, close } = openSession(code, 'receiver')
+            const fail = (msg) => {
+                close()
+                reject(new Error(msg))
+            }
+
+            es.addEventListener('error', () => {
+                if (es.readyState === EventSource.CLOSED) fail('Signaling closed')
+            })
+            es.addEventListener('fallback', () => fail('Peer requested fallback'))
+
+            if (!pc) {
+                es.addEventListener('open', () => {
+                    post('fallback')
+                    fail('WebRTC not supported')
+                })
+                return
+            }
+
+            es.addEventListener('offer', async (e) => {
+                try {
+                    const { payload } = JSON.parse(e.data)
+                    await pc.setRemoteDescription(JSON.parse(payload))
+                    const answer = await pc.createAnswer()
+                    await pc.setLocalDescription(answer)
+                    await post('answer', JSON.stringify(pc.localDescription))
+                } catch {
+                    post('fallback')
+                    fail('Negotiation failed')
+                }
+            })
+
+            pc.ondatachannel = ({ channel }) => {
+                channel.binaryType = 'arraybuffer'
+                let meta = null
+                let received = 0
+                const parts = []
+
+                channel.onmessage = (e) => {
+                    if (!meta) {
+                        meta = JSON.parse(e.data)
+                    } else {
+                        parts.push(e.data)
+                        received += e.data.byteLength
+                    }
+
+                    if (meta && received >= meta.size) {
+                        channel.send('done')
+                        const blob = new Blob(parts)
+                        setTimeout(close, 500)
+                        resolve({ blob, name: meta.name || 'file' })
+                    }
+                }
+                channel.onclose = () => {
+                    if (!meta || received < meta.size) fail('Peer connection closed')
+                }
+            }
+        })
+    }
+
+    function send(code, file) {
+        return new Promise((resolve, reject) => {
+            const { es, pc, post, close } = openSession(code, 'sender')
+            let settled = false
+            const fail = (msg) => {
+                if (settled) return
+                settled = true
+                clearTimeout(timer)
+                post('fallback').finally(close)
+                reject(new Error(msg))
+            }
+            const timer = setTimeout(() => fail('Peer connection timeout'), CONNECT_TIMEOUT)
+
+            if (!pc) {
+                es.addEventListener('open', () => fail('WebRTC not supported'))
+                return
+            }
+
+            es.addEventListener('fallback', () => fail('Peer requested fallback'))
+
+            const channel = pc.createDataChannel('file', { ordered: true })
+            channel.binaryType = 'arraybuffer'
+            channel.bufferedAmountLowThreshold = CHUNK_SIZE * 4
+
+            es.addEventListener('peer-joined', async () => {
+                try {
+                    const offer = await pc.createOffer()
+                    await pc.setLocalDescription(offer)
+                    await post('offer', JSON.stringify(pc.localDescription))
+                } catch {
+                    fail('Negotiation failed')
+                }
+            })
+            es.addEventListener('answer', async (e) => {
+                try {
+                    const { payload } = JSON.parse(e.data)
+                    await pc.setRemoteDescription(JSON.parse(payload))
+                } catch {
+                    fail('Negotiation failed')
+                }
+            })
+
+            channel.onopen = async () => {
+                clearTimeout(timer)
+                channel.send(JSON.stringify({ name: file.name, size: file.size }))
+
+                for (let offset = 0; offset < file.size; offset += CHUNK_SIZE) {
+                    if (channel.bufferedAmount > channel.bufferedAmountLowThreshold) {
+                        await new Promise(r => channel.addEventListener('bufferedamountlow', r, { once: true }))
+                    }
+                    channel.send(await file.slice(offset, offset + CHUNK_SIZE).arrayBuffer())
+                }
+            }
+            channel.onmessage = (e) => {
+                if (e.data !== 'done' || settled) return
+                settled = true
+                close()
+                resolve()
+            }
+            channel.onclose = () => {
+                if (!settled) {
+                    settled = true
+                    close()
+                    reject(new Error('Peer connection closed'))
+                }
+            }
+        })
+    }
+
+    function abort() {
+        session?.close()
+    }
+
+    return {
+        supported,
+        receive,
+        send,
+        abort
+    }
+}
@@ -0,0 +1,3 @@
+PRAGMA foreign_keys = ON;
+
+ALTER TABLE room_files ADD COLUMN sha256 TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+PRAGMA foreign_keys = ON;
+
+ALTER TABLE room_files ADD COLUMN scan_status TEXT NOT NULL DEFAULT 'unscanned';
+ALTER TABLE room_files ADD COLUMN scan_scanner TEXT NOT NULL DEFAULT '';
+ALTER TABLE room_files ADD COLUMN scanned_at INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+PRAGMA foreign_keys = ON;
+
+ALTER TABLE room_files ADD COLUMN content_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE rooms ADD COLUMN content_rules TEXT NOT NULL DEFAULT '';
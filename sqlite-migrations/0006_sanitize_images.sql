@@ -0,0 +1,6 @@
+PRAGMA foreign_keys = ON;
+
+ALTER TABLE rooms ADD COLUMN sanitize_images INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE room_files ADD COLUMN sanitized INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE room_files ADD COLUMN original_size INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE room_files ADD COLUMN original_sha256 TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,13 @@
+PRAGMA foreign_keys = ON;
+
+ALTER TABLE room_files ADD COLUMN folder TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS room_folders (
+  room_id TEXT NOT NULL,
+  path    TEXT NOT NULL,
+
+  PRIMARY KEY (room_id, path),
+  FOREIGN KEY (room_id) REFERENCES rooms(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_files_room_folder ON room_files(room_id, folder);
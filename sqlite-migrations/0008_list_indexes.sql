@@ -0,0 +1,11 @@
+PRAGMA foreign_keys = ON;
+
+CREATE INDEX IF NOT EXISTS idx_room_files_room_name ON room_files(room_id, name COLLATE NOCASE, id);
+
+CREATE INDEX IF NOT EXISTS idx_room_files_room_size ON room_files(room_id, size, id);
+
+CREATE INDEX IF NOT EXISTS idx_room_files_room_created_at ON room_files(room_id, created_at, id);
+
+CREATE INDEX IF NOT EXISTS idx_rooms_created_at ON rooms(created_at, id);
+
+CREATE INDEX IF NOT EXISTS idx_rooms_expires_at_id ON rooms(expires_at, id);
@@ -0,0 +1,7 @@
+PRAGMA foreign_keys = ON;
+
+ALTER TABLE room_files ADD COLUMN expires_at INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE room_files ADD COLUMN max_downloads INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE room_files ADD COLUMN downloads INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_room_files_expires_at ON room_files(expires_at) WHERE expires_at > 0;
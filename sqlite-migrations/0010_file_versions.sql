@@ -0,0 +1,20 @@
+PRAGMA foreign_keys = ON;
+
+ALTER TABLE rooms ADD COLUMN versioning INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE room_files ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS room_file_versions (
+  file_id      TEXT NOT NULL,
+  room_id      TEXT NOT NULL,
+  version      INTEGER NOT NULL,
+  path         TEXT NOT NULL,
+  size         INTEGER NOT NULL,
+  sha256       TEXT NOT NULL DEFAULT '',
+  content_type TEXT NOT NULL DEFAULT '',
+  created_at   INTEGER NOT NULL,
+
+  PRIMARY KEY (file_id, version),
+  FOREIGN KEY (room_id) REFERENCES rooms(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_file_versions_room_id ON room_file_versions(room_id);
@@ -0,0 +1,15 @@
+PRAGMA foreign_keys = ON;
+
+ALTER TABLE room_files ADD COLUMN description TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS room_messages (
+  id         TEXT PRIMARY KEY,
+  room_id    TEXT NOT NULL,
+  author     TEXT NOT NULL DEFAULT '',
+  text       TEXT NOT NULL,
+  created_at INTEGER NOT NULL,
+
+  FOREIGN KEY (room_id) REFERENCES rooms(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_messages_room_created ON room_messages(room_id, created_at);